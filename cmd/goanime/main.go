@@ -24,6 +24,32 @@ func main() {
 			}
 			return
 		}
+		// Check if error is doctor request
+		if err == util.ErrDoctorRequested {
+			if doctorErr := handlers.HandleDoctorRequest(); doctorErr != nil {
+				log.Fatalln(util.ErrorHandler(doctorErr))
+			}
+			return
+		}
+		// Check if error is cookies request
+		if err == util.ErrCookiesRequested {
+			if cookiesErr := handlers.HandleCookiesRequest(); cookiesErr != nil {
+				log.Fatalln(util.ErrorHandler(cookiesErr))
+			}
+			return
+		}
+		// Check if error is resolve request
+		if err == util.ErrResolveRequested {
+			if resolveErr := handlers.HandleResolveRequest(); resolveErr != nil {
+				log.Fatalln(util.ErrorHandler(resolveErr))
+			}
+			return
+		}
+		// Check if error is browse request
+		if err == util.ErrBrowseRequested {
+			handlers.HandleBrowseRequest()
+			return
+		}
 		// For help and version requests, just exit silently
 		if err == util.ErrHelpRequested {
 			return
@@ -12,6 +12,10 @@ import (
 
 // GetEpisodeStreamURLEnhanced gets streaming URL with AllAnime navigation support
 func GetEpisodeStreamURLEnhanced(episode *models.Episode, anime *models.Anime, quality string) (string, error) {
+	if _, ok := customScraperFor(anime); ok {
+		return GetEpisodeStreamURL(episode, anime, quality)
+	}
+
 	// Determine source type and use appropriate method
 	sourceName := "Unknown"
 	scraperType := scraper.AllAnimeType // Default
@@ -61,6 +65,7 @@ func GetEpisodeStreamURLEnhanced(episode *models.Episode, anime *models.Anime, q
 		util.Debug("AllAnime episode URL retrieved via direct method",
 			"episode", episode.Number,
 			"quality", metadata["quality"],
+			"provider", metadata["provider"],
 			"priority", metadata["priority"])
 
 		return url, nil
@@ -84,7 +89,7 @@ func GetAllAnimeEpisodeURLDirect(anime *models.Anime, episodeNumber string, qual
 		return "", nil, fmt.Errorf("could not extract anime ID from URL: %s", anime.URL)
 	}
 
-	mode := "sub" // Default
+	mode := util.Mode()
 	if quality == "" {
 		quality = "best"
 	}
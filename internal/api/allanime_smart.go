@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/alvarorichard/Goanime/internal/models"
+	netcfg "github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/scraper"
 	"github.com/alvarorichard/Goanime/internal/util"
 	"github.com/lrstanley/go-ytdlp"
 )
@@ -50,7 +52,7 @@ func DownloadAllAnimeSmartRange(anime *models.Anime, startEp, endEp int, quality
 	// Iterate episodes and download
 	for i := startEp; i <= endEp; i++ {
 		ep := episodes[i-1]
-		filePath := filepath.Join(outDir, fmt.Sprintf("%d.mp4", i))
+		filePath := ep.FilePath(outDir)
 
 		if alreadyDownloaded(filePath) {
 			util.Infof("Episode %d already exists, skipping", i)
@@ -94,6 +96,22 @@ func smartDownload(url, dest string) error {
 		ctx := context.Background()
 		ytdlp.MustInstall(ctx, nil)
 		dl := ytdlp.New().Output(safeDest)
+		if proxy := netcfg.ProxyURL(netcfg.SourceAllAnime); proxy != "" {
+			dl = dl.Proxy(proxy)
+		}
+		if cookies := netcfg.CookiesFile(); cookies != "" {
+			dl = dl.Cookies(cookies)
+		}
+		headers := scraper.StreamHeaders(url)
+		if ref := headers["Referer"]; ref != "" {
+			dl = dl.Referer(ref)
+		}
+		if ua := headers["User-Agent"]; ua != "" {
+			dl = dl.UserAgent(ua)
+		}
+		if origin := headers["Origin"]; origin != "" {
+			dl = dl.AddHeaders("Origin:" + origin)
+		}
 		_, err := dl.Run(ctx, url)
 		if err != nil {
 			return fmt.Errorf("yt-dlp failed: %w", err)
@@ -106,8 +124,15 @@ func smartDownload(url, dest string) error {
 	}
 
 	// Otherwise, simple HTTP download
-	client := &http.Client{Timeout: 0}
-	resp, err := client.Get(url)
+	client := netcfg.NewClient("", 0)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range netcfg.StreamHeaders(scraper.StreamHeaders(url)) {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -12,19 +12,22 @@ import (
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/alvarorichard/Goanime/internal/mirrors"
 	"github.com/alvarorichard/Goanime/internal/models"
+	netcfg "github.com/alvarorichard/Goanime/internal/network"
 	"github.com/alvarorichard/Goanime/internal/util"
 	"github.com/ktr0731/go-fuzzyfinder"
 	"github.com/pkg/errors"
 )
 
 // Common HTTP client instance
-var httpClient = &http.Client{}
+var httpClient = netcfg.NewClient("", 0)
 
 func GetEpisodeData(animeID int, episodeNo int, anime *models.Anime) error {
 
-	url := fmt.Sprintf("https://api.jikan.moe/v4/anime/%d/episodes/%d", animeID, episodeNo)
+	url := fmt.Sprintf("%s/anime/%d/episodes/%d", jikanBaseURL, animeID, episodeNo)
 
+	waitJikan()
 	response, err := makeGetRequest(url, nil)
 	if err != nil {
 		return fmt.Errorf("error fetching data from Jikan (MyAnimeList) API: %w", err)
@@ -126,7 +129,7 @@ func GetMovieData(animeID int, anime *models.Anime) error {
 
 // FetchAnimeDetails retrieves additional information for the selected anime
 func FetchAnimeDetails(anime *models.Anime) error {
-	response, err := http.Get(anime.URL)
+	response, err := httpClient.Get(anime.URL)
 	if err != nil {
 		return errors.Wrap(err, "failed to get anime details page")
 	}
@@ -159,7 +162,8 @@ func SearchAnime(animeName string) (*models.Anime, error) {
 	start := time.Now()
 	util.Debugf("[PERF] SearchAnime started for %s", animeName)
 
-	currentPageURL := fmt.Sprintf("%s/pesquisar/%s", models.AnimeFireURL, url.PathEscape(animeName))
+	baseURL := mirrors.Get(netcfg.SourceAnimeFire).Active().Base
+	currentPageURL := fmt.Sprintf("%s/pesquisar/%s", baseURL, url.PathEscape(animeName))
 
 	for {
 		selectedAnime, nextPageURL, err := searchAnimeOnPage(currentPageURL)
@@ -179,7 +183,7 @@ func SearchAnime(animeName string) (*models.Anime, error) {
 			util.Debugf("[PERF] No results found for %s after %v", animeName, time.Since(start))
 			return nil, errors.New("no anime found with the given name")
 		}
-		currentPageURL = models.AnimeFireURL + nextPageURL
+		currentPageURL = baseURL + nextPageURL
 	}
 }
 
@@ -302,7 +306,7 @@ func ParseAnimes(doc *goquery.Document) []models.Anime {
 			name := strings.TrimSpace(s.Text())
 			animes = append(animes, models.Anime{
 				Name: name,
-				URL:  resolveURL(models.AnimeFireURL, urlPath),
+				URL:  resolveURL(mirrors.Get(netcfg.SourceAnimeFire).Active().Base, urlPath),
 			})
 			util.Debugf("Parsed: %s", name)
 		}
@@ -2,26 +2,59 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"time"
 
+	"github.com/alvarorichard/Goanime/internal/config"
 	"github.com/alvarorichard/Goanime/internal/models"
+	netcfg "github.com/alvarorichard/Goanime/internal/network"
 	"github.com/alvarorichard/Goanime/internal/util"
 )
 
-// GetAniSkipData fetches skip times data for a given anime ID and episode
-func GetAniSkipData(animeMalId int, episode int) (string, error) {
-	baseURL := "https://api.aniskip.com/v1/skip-times"
+const (
+	// aniSkipBaseURL is the AniSkip v2 skip-times endpoint
+	aniSkipBaseURL = "https://api.aniskip.com/v2/skip-times"
+	// aniSkipTTL is how long found skip times are reused
+	aniSkipTTL = 7 * 24 * time.Hour
+	// aniSkipMissTTL is how long an episode without skip times is not asked
+	// about again; new episodes get submissions in their first days
+	aniSkipMissTTL = 12 * time.Hour
+)
+
+// aniSkipTypes are the interval types asked for
+var aniSkipTypes = []string{
+	models.SkipTypeOp,
+	models.SkipTypeEd,
+	models.SkipTypeMixedOp,
+	models.SkipTypeMixedEd,
+	models.SkipTypeRecap,
+	models.SkipTypePreview,
+}
 
-	url := fmt.Sprintf("%s/%d/%d?types=op&types=ed", baseURL, animeMalId, episode)
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+// ErrNoSkipTimes is returned when AniSkip has no skip times for an episode
+var ErrNoSkipTimes = errors.New("no skip times found")
+
+// GetAniSkipData fetches skip times data for a given anime ID and episode.
+// episodeLength, in seconds, lets AniSkip match the submissions made for a
+// release of that length; 0 asks for all of them.
+func GetAniSkipData(animeMalId int, episode int, episodeLength float64) (string, error) {
+	query := url.Values{}
+	for _, t := range aniSkipTypes {
+		query.Add("types[]", t)
 	}
+	query.Set("episodeLength", strconv.FormatFloat(episodeLength, 'f', -1, 64))
+	endpoint := fmt.Sprintf("%s/%d/%d?%s", aniSkipBaseURL, animeMalId, episode, query.Encode())
+	client := netcfg.NewClient("", 10*time.Second)
 
-	resp, err := client.Get(url)
+	resp, err := client.Get(endpoint)
 	if err != nil {
 		return "", fmt.Errorf("error fetching data from AniSkip API: %w", err)
 	}
@@ -32,7 +65,8 @@ func GetAniSkipData(animeMalId int, episode int) (string, error) {
 		}
 	}(resp.Body)
 
-	if resp.StatusCode != http.StatusOK {
+	// AniSkip answers 404 with a JSON body when it has no skip times
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
 		return "", fmt.Errorf("AniSkip API request failed with status %d", resp.StatusCode)
 	}
 
@@ -50,51 +84,146 @@ func RoundTime(timeValue float64, precision int) float64 {
 	return math.Floor(timeValue*multiplier+0.5) / multiplier
 }
 
-// ParseAniSkipResponse parses the response text from the AniSkip API and updates the Episode struct
-func ParseAniSkipResponse(responseText string, episode *models.Episode, timePrecision int) error {
+// decodeAniSkipResponse decodes a response of the skip-times endpoint
+func decodeAniSkipResponse(responseText string) (models.SkipTimesResponse, error) {
+	var data models.SkipTimesResponse
 	if responseText == "" {
-		return fmt.Errorf("response text is empty")
+		return data, fmt.Errorf("response text is empty")
 	}
-
-	var data models.SkipTimesResponse
-	err := json.Unmarshal([]byte(responseText), &data)
-	if err != nil {
-		return fmt.Errorf("error unmarshalling response: %w", err)
+	if err := json.Unmarshal([]byte(responseText), &data); err != nil {
+		return data, fmt.Errorf("error unmarshalling response: %w", err)
 	}
-
 	if util.IsDebug {
 		// Log the raw response for debugging
 		fmt.Printf("AniSkip Raw Response: %s\n", responseText)
 	}
+	return data, nil
+}
 
-	if !data.Found {
-		return fmt.Errorf("no skip times found")
+// matchSkipResults keeps, of each type, the result submitted for the release
+// closest to episodeLength seconds; with no length, the first one
+func matchSkipResults(results []models.SkipResult, episodeLength float64) []models.SkipResult {
+	best := map[string]int{}
+	var order []string
+	for i, r := range results {
+		j, seen := best[r.Type]
+		if !seen {
+			order = append(order, r.Type)
+			best[r.Type] = i
+			continue
+		}
+		if episodeLength > 0 && math.Abs(r.EpisodeLength-episodeLength) < math.Abs(results[j].EpisodeLength-episodeLength) {
+			best[r.Type] = i
+		}
 	}
+	matched := make([]models.SkipResult, 0, len(order))
+	for _, t := range order {
+		matched = append(matched, results[best[t]])
+	}
+	return matched
+}
 
-	// Populate skip times for the episode
-	for _, result := range data.Results {
-		start := int(RoundTime(result.Interval.StartTime, timePrecision))
-		end := int(RoundTime(result.Interval.EndTime, timePrecision))
-
-		// Populate based on the type of skip (OP or ED)
-		switch result.Type {
-		case "op":
-			episode.SkipTimes.Op = models.Skip{Start: start, End: end}
-		case "ed":
-			episode.SkipTimes.Ed = models.Skip{Start: start, End: end}
+// applySkipResults sets the skip times of episode to the results matching its length
+func applySkipResults(results []models.SkipResult, episode *models.Episode, timePrecision int) {
+	var times models.SkipTimes
+	for _, r := range matchSkipResults(results, float64(episode.Duration)) {
+		switch r.Type {
+		case models.SkipTypeOp, models.SkipTypeEd, models.SkipTypeMixedOp, models.SkipTypeMixedEd,
+			models.SkipTypeRecap, models.SkipTypePreview:
+			times.Add(models.Interval{
+				Type:  r.Type,
+				Start: RoundTime(r.Interval.StartTime, timePrecision),
+				End:   RoundTime(r.Interval.EndTime, timePrecision),
+			})
 		default:
-			fmt.Printf("Unknown skip type encountered: %s\n", result.Type)
+			util.Debugf("Unknown skip type encountered: %s", r.Type)
 		}
 	}
+	episode.SkipTimes = times
+}
 
+// ParseAniSkipResponse parses the response text from the AniSkip API and updates the Episode struct
+func ParseAniSkipResponse(responseText string, episode *models.Episode, timePrecision int) error {
+	data, err := decodeAniSkipResponse(responseText)
+	if err != nil {
+		return err
+	}
+	if !data.Found {
+		return ErrNoSkipTimes
+	}
+	applySkipResults(data.Results, episode, timePrecision)
 	return nil
 }
 
-// GetAndParseAniSkipData fetches and parses skip times for a given anime ID and episode
+// aniSkipCache is a cached AniSkip answer; Results holds the submissions of
+// each release AniSkip returned, so the match is made again for each length
+type aniSkipCache struct {
+	FetchedAt time.Time           `json:"fetched_at"`
+	Found     bool                `json:"found"`
+	Results   []models.SkipResult `json:"results,omitempty"`
+}
+
+// fresh reports whether the cached answer may still be used
+func (c aniSkipCache) fresh() bool {
+	ttl := aniSkipMissTTL
+	if c.Found {
+		ttl = aniSkipTTL
+	}
+	return time.Since(c.FetchedAt) < ttl
+}
+
+// aniSkipCachePath is where the skip times of an episode are cached
+func aniSkipCachePath(malID, episode int) string {
+	return filepath.Join(config.Dir(), "cache", "aniskip", fmt.Sprintf("%d-%d.json", malID, episode))
+}
+
+// aniSkipResults returns AniSkip's submissions for an episode, from the cache
+// when it is fresh. The length of the episode is only known roughly from
+// metadata, so the submissions of every release are fetched and cached, and
+// matched to the length afterwards.
+func aniSkipResults(malID, episodeNum int) (aniSkipCache, error) {
+	path := aniSkipCachePath(malID, episodeNum)
+	if data, err := os.ReadFile(path); err == nil {
+		var cached aniSkipCache
+		if json.Unmarshal(data, &cached) == nil && cached.fresh() {
+			return cached, nil
+		}
+	}
+
+	responseText, err := GetAniSkipData(malID, episodeNum, 0)
+	if err != nil {
+		return aniSkipCache{}, err
+	}
+	data, err := decodeAniSkipResponse(responseText)
+	if err != nil {
+		return aniSkipCache{}, err
+	}
+
+	result := aniSkipCache{FetchedAt: time.Now(), Found: data.Found, Results: data.Results}
+	if data, err := json.Marshal(result); err == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err == nil {
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				util.Debug("Failed to cache AniSkip data", "error", err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// GetAndParseAniSkipData fetches and parses skip times for a given anime ID
+// and episode, matched to the episode's length when it is known. Answers are
+// cached per MAL ID and episode.
 func GetAndParseAniSkipData(animeMalId int, episodeNum int, episode *models.Episode) error {
-	responseText, err := GetAniSkipData(animeMalId, episodeNum)
+	if animeMalId <= 0 {
+		return fmt.Errorf("no MAL ID to look up skip times for")
+	}
+	cached, err := aniSkipResults(animeMalId, episodeNum)
 	if err != nil {
 		return err
 	}
-	return ParseAniSkipResponse(responseText, episode, 0)
+	if !cached.Found {
+		return ErrNoSkipTimes
+	}
+	applySkipResults(cached.Results, episode, 0)
+	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	netcfg "github.com/alvarorichard/Goanime/internal/network"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
 )
@@ -65,6 +66,7 @@ func checkDisallowedIP(conn net.Conn) error {
 // It also checks if the IP address of the connection is allowed.
 //
 // Parameters:
+// - ctx: the context governing the dial and the TLS handshake.
 // - network: the network type (e.g., "tcp", "udp") to use for the connection.
 // - addr: the address to connect to, in the form "host:port".
 // - timeout: the maximum amount of time allowed for the connection attempt.
@@ -73,33 +75,49 @@ func checkDisallowedIP(conn net.Conn) error {
 // Returns:
 // - net.Conn: the established network connection.
 // - error: an error if the connection fails or if the IP address is disallowed.
-func dialFunc(network, addr string, timeout time.Duration, tlsConfig *tls.Config) (net.Conn, error) {
+func dialFunc(ctx context.Context, network, addr string, timeout time.Duration, tlsConfig *tls.Config) (net.Conn, error) {
 	// Create a net.Dialer with the specified timeout.
 	dialer := &net.Dialer{Timeout: timeout}
 
-	var conn net.Conn
-	var err error
-
-	// If a TLS configuration is provided, use tls.DialWithDialer to establish a TLS connection.
-	// Otherwise, establish a regular network connection using dialer.Dial.
-	if tlsConfig != nil {
-		conn, err = tls.DialWithDialer(dialer, network, addr, tlsConfig)
-	} else {
-		conn, err = dialer.Dial(network, addr)
-	}
+	// Connect through the shared network dialer so DNS-over-HTTPS settings apply.
+	conn, err := netcfg.Dial(ctx, dialer, network, addr)
 
 	// If there was an error during the connection attempt, return the error.
 	if err != nil {
 		return nil, err
 	}
 
+	// Connections to the user's configured proxy are allowed even on loopback or private
+	// addresses (e.g. a local Tor/SOCKS tunnel); the proxy is what reaches the real target.
+	if netcfg.IsProxyAddr(addr) {
+		return conn, nil
+	}
+
 	// Check if the IP address of the connection is allowed. If not, return an error.
 	if err := checkDisallowedIP(conn); err != nil {
 		return nil, err
 	}
 
+	// Without a TLS configuration the plain connection is returned as-is.
+	if tlsConfig == nil {
+		return conn, nil
+	}
+
+	// Otherwise, complete the TLS handshake within the same timeout.
+	cfg := tlsConfig.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	tlsConn := tls.Client(conn, cfg)
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
 	// Return the established connection.
-	return conn, nil
+	return tlsConn, nil
 }
 
 // SafeTransport returns an http.Transport with custom dial functions for both regular and TLS connections.
@@ -117,12 +135,16 @@ func SafeTransport(timeout time.Duration) *http.Transport {
 	return &http.Transport{
 		// Custom dial function for regular (non-TLS) connections.
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialFunc(network, addr, timeout, nil)
+			return dialFunc(ctx, network, addr, timeout, nil)
 		},
 		// Custom dial function for TLS connections, using the specified TLS configuration.
 		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialFunc(network, addr, timeout, tlsConfig)
+			return dialFunc(ctx, network, addr, timeout, tlsConfig)
 		},
+		// Route requests through the configured proxy, if any. Proxied HTTPS requests
+		// tunnel through DialContext and use TLSClientConfig for the handshake.
+		Proxy:           netcfg.ProxyFunc(""),
+		TLSClientConfig: tlsConfig,
 		// Set the timeout for the TLS handshake process.
 		TLSHandshakeTimeout: timeout,
 	}
@@ -140,7 +162,8 @@ func SafeTransport(timeout time.Duration) *http.Transport {
 func SafeGet(url string) (*http.Response, error) {
 	// Create an HTTP client with a custom transport that includes a 10-second timeout.
 	httpClient := &http.Client{
-		Transport: SafeTransport(10 * time.Second),
+		Transport: netcfg.Overridable(SafeTransport(10 * time.Second)),
+		Jar:       netcfg.CookieJar(""),
 	}
 
 	// Perform the GET request using the custom HTTP client and return the response.
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// nextPageLabel is the extra entry that loads the next page of browse results
+const nextPageLabel = "» Next page"
+
+// SearchOptionsFromFlags converts the command line filters into validated scraper options
+func SearchOptionsFromFlags() (scraper.SearchOptions, error) {
+	f := util.GlobalSearchFilters
+	sort, err := scraper.ParseSortOrder(f.Sort)
+	if err != nil {
+		return scraper.SearchOptions{}, err
+	}
+	opts := scraper.SearchOptions{
+		Page:    f.Page,
+		Limit:   f.Limit,
+		Season:  f.Season,
+		Year:    f.Year,
+		Genres:  f.Genres,
+		Format:  f.Format,
+		Country: f.Country,
+		Sort:    sort,
+	}
+	if _, err := opts.Normalize(); err != nil {
+		return scraper.SearchOptions{}, fmt.Errorf("invalid search filters: %w", err)
+	}
+	return opts, nil
+}
+
+// BrowseAnime lists titles for mode page by page until the user picks one.
+// source limits the listing to one source like -source does for searches.
+func BrowseAnime(mode scraper.BrowseMode, opts scraper.SearchOptions, source string) (*models.Anime, error) {
+	scraperManager := scraper.NewScraperManager()
+	scraperType := scraperTypeFor(source)
+
+	if _, err := opts.Normalize(); err != nil {
+		return nil, fmt.Errorf("invalid search filters: %w", err)
+	}
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+
+	var previous []*models.Anime
+	for {
+		util.Debug("Browsing", "mode", mode, "page", opts.Page)
+		animes, more, err := scraperManager.Browse(mode, opts, scraperType)
+		if err != nil {
+			return nil, err
+		}
+		if len(animes) == 0 {
+			if previous == nil {
+				return nil, fmt.Errorf("nothing found for %s", mode)
+			}
+			// Ran past the last page: show the previous one again
+			util.Warnf("No more results after page %d", opts.Page-1)
+			opts.Page--
+			animes = previous
+		}
+		previous = animes
+
+		// Only offer to go on when a source has another page
+		choices := animes[:len(animes):len(animes)]
+		var next *models.Anime
+		if more {
+			next = &models.Anime{Name: nextPageLabel}
+			choices = append(choices, next)
+		}
+		selected, err := selectAnime(choices)
+		if err != nil {
+			return nil, err
+		}
+		if next != nil && selected == next {
+			opts.Page++
+			continue
+		}
+		if selected, err = pickVariant(selected); err != nil {
+			return nil, err
+		}
+
+		util.Debug("Anime selected", "name", selected.Name, "source", selected.Source)
+		if err := enrichAnimeData(selected); err != nil {
+			util.Errorf("Error enriching anime data: %v", err)
+		}
+		return selected, nil
+	}
+}
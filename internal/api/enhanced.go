@@ -8,33 +8,61 @@ import (
 	"github.com/alvarorichard/Goanime/internal/models"
 	"github.com/alvarorichard/Goanime/internal/scraper"
 	"github.com/alvarorichard/Goanime/internal/util"
+	"github.com/charmbracelet/huh"
 	"github.com/ktr0731/go-fuzzyfinder"
 )
 
+// scraperTypeFor maps a -source value to a scraper; nil means every source
+func scraperTypeFor(source string) *scraper.ScraperType {
+	var t scraper.ScraperType
+	switch strings.ToLower(source) {
+	case "allanime":
+		t = scraper.AllAnimeType
+		util.Debug("Searching specific source", "source", "AllAnime")
+	case "animefire":
+		t = scraper.AnimefireType
+		util.Debug("Searching specific source", "source", "AnimeFire")
+	default:
+		custom, ok := scraper.LookupSource(source)
+		if !ok {
+			return nil
+		}
+		t = custom
+		util.Debug("Searching specific source", "source", source)
+	}
+	return &t
+}
+
+// customScraperFor returns the declarative source anime came from, if any
+func customScraperFor(anime *models.Anime) (scraper.UnifiedScraper, bool) {
+	if anime == nil {
+		return nil, false
+	}
+	s, ok := scraper.CustomSource(anime.Source)
+	if !ok {
+		return nil, false
+	}
+	return s, true
+}
+
 // Enhanced search that supports multiple sources - always searches both animefire.plus and allanime simultaneously
 func SearchAnimeEnhanced(name string, source string) (*models.Anime, error) {
 	scraperManager := scraper.NewScraperManager()
 
-	var scraperType *scraper.ScraperType
-
-	// If a specific source is requested, honor it
-	if strings.ToLower(source) == "allanime" {
-		t := scraper.AllAnimeType
-		scraperType = &t
-		util.Debug("Searching specific source", "source", "AllAnime")
-	} else if strings.ToLower(source) == "animefire" {
-		t := scraper.AnimefireType
-		scraperType = &t
-		util.Debug("Searching specific source", "source", "AnimeFire")
-	} else {
-		// Default behavior: search both sources simultaneously
-		scraperType = nil
+	// If a specific source is requested, honor it; otherwise search both sources simultaneously
+	scraperType := scraperTypeFor(source)
+	if scraperType == nil {
 		util.Debug("Searching all sources", "query", name)
 	}
 
+	opts, err := SearchOptionsFromFlags()
+	if err != nil {
+		return nil, err
+	}
+
 	// Perform the search - this will search both sources if scraperType is nil
 	util.Debug("Searching for anime", "query", name)
-	animes, err := scraperManager.SearchAnime(name, scraperType)
+	animes, err := scraperManager.SearchAnime(name, scraperType, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search anime: %w", err)
 	}
@@ -82,6 +110,12 @@ func SearchAnimeEnhanced(name string, source string) (*models.Anime, error) {
 	if len(animes) == 1 {
 		util.Debug("Auto-selecting single result", "anime", animes[0].Name)
 
+		selected, err := pickVariant(animes[0])
+		if err != nil {
+			return nil, err
+		}
+		animes[0] = selected
+
 		// CRITICAL: Enrich with AniList data for images and metadata (like the original system)
 		if err := enrichAnimeData(animes[0]); err != nil {
 			util.Errorf("Error enriching anime data: %v", err)
@@ -90,6 +124,25 @@ func SearchAnimeEnhanced(name string, source string) (*models.Anime, error) {
 		return animes[0], nil
 	}
 
+	selectedAnime, err := selectAnime(animes)
+	if err != nil {
+		return nil, err
+	}
+	if selectedAnime, err = pickVariant(selectedAnime); err != nil {
+		return nil, err
+	}
+	util.Debug("Anime selected", "name", selectedAnime.Name, "source", selectedAnime.Source)
+
+	// CRITICAL: Enrich with AniList data for images and metadata (like the original system)
+	if err := enrichAnimeData(selectedAnime); err != nil {
+		util.Errorf("Error enriching anime data: %v", err)
+	}
+
+	return selectedAnime, nil
+}
+
+// selectAnime lets the user pick one of animes with the fuzzy finder
+func selectAnime(animes []*models.Anime) (*models.Anime, error) {
 	// Helper to map provider tags to user-friendly language labels for display only
 	providerLabel := func(src string) string {
 		if strings.Contains(src, "AnimeFire") {
@@ -103,6 +156,7 @@ func SearchAnimeEnhanced(name string, source string) (*models.Anime, error) {
 
 	// Use fuzzy finder to let user select
 	var idx int
+	var err error
 
 	if util.IsDebug {
 		// In debug mode, show preview window with technical details
@@ -148,19 +202,48 @@ func SearchAnimeEnhanced(name string, source string) (*models.Anime, error) {
 		return nil, fmt.Errorf("seleção de anime cancelada: %w", err)
 	}
 
-	selectedAnime := animes[idx]
-	util.Debug("Anime selected", "name", selectedAnime.Name, "source", selectedAnime.Source)
+	return animes[idx], nil
+}
 
-	// CRITICAL: Enrich with AniList data for images and metadata (like the original system)
-	if err := enrichAnimeData(selectedAnime); err != nil {
-		util.Errorf("Error enriching anime data: %v", err)
+// pickVariant asks which language to watch when anime groups dubbed and subtitled versions
+func pickVariant(anime *models.Anime) (*models.Anime, error) {
+	if len(anime.Variants) < 2 {
+		return anime, nil
 	}
 
-	return selectedAnime, nil
+	options := make([]huh.Option[int], len(anime.Variants))
+	for i, v := range anime.Variants {
+		label := "Legendado (subtitled)"
+		if v.Dubbed {
+			label = "Dublado (dubbed)"
+		}
+		options[i] = huh.NewOption(label, i)
+	}
+
+	var idx int
+	menu := huh.NewSelect[int]().
+		Title(strings.TrimSpace(strings.TrimSuffix(anime.Name, "(Dublado/Legendado)"))).
+		Description("Which version do you want to watch?").
+		Options(options...).
+		Value(&idx)
+	if err := menu.Run(); err != nil {
+		return nil, fmt.Errorf("language selection cancelled: %w", err)
+	}
+	return anime.Variants[idx], nil
 }
 
 // Enhanced episode fetching that works with different sources
 func GetAnimeEpisodesEnhanced(anime *models.Anime) ([]models.Episode, error) {
+	// Declarative sources from the config dir handle their own URLs
+	if custom, ok := customScraperFor(anime); ok {
+		episodes, err := custom.GetAnimeEpisodes(anime.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get episodes from %s: %w", anime.Source, err)
+		}
+		enrichEpisodeMetadata(anime, episodes)
+		return episodes, nil
+	}
+
 	// Determine source type from multiple indicators with enhanced logic
 	var sourceName string
 
@@ -237,11 +320,20 @@ func GetAnimeEpisodesEnhanced(anime *models.Anime) ([]models.Episode, error) {
 		util.Warn("No episodes found", "source", sourceName)
 	}
 
+	enrichEpisodeMetadata(anime, episodes)
 	return episodes, nil
 }
 
 // Enhanced episode URL fetching with improved source detection
 func GetEpisodeStreamURL(episode *models.Episode, anime *models.Anime, quality string) (string, error) {
+	if custom, ok := customScraperFor(anime); ok {
+		streamURL, _, err := custom.GetStreamURL(episode.URL, quality)
+		if err != nil {
+			return "", fmt.Errorf("failed to get stream URL from %s: %w", anime.Source, err)
+		}
+		return streamURL, nil
+	}
+
 	scraperManager := scraper.NewScraperManager()
 
 	// Determine source type with enhanced logic
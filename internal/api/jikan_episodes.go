@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+const (
+	// jikanInterval spaces requests to stay under Jikan's limit of 3 per second
+	jikanInterval = 400 * time.Millisecond
+	// jikanRetries is how often a request is retried after a 429
+	jikanRetries = 3
+	// jikanMaxPages bounds the episode list (100 episodes per page)
+	jikanMaxPages = 30
+	// jikanEpisodesTTL is how long a fetched episode list is reused; airing shows gain episodes weekly
+	jikanEpisodesTTL = 12 * time.Hour
+	// jikanBaseURL is the Jikan v4 API root
+	jikanBaseURL = "https://api.jikan.moe/v4"
+)
+
+var (
+	jikanMu   sync.Mutex
+	jikanNext time.Time
+)
+
+// waitJikan blocks until the next Jikan request may be sent
+func waitJikan() {
+	jikanMu.Lock()
+	now := time.Now()
+	wait := max(jikanNext.Sub(now), 0)
+	jikanNext = now.Add(wait + jikanInterval)
+	jikanMu.Unlock()
+	time.Sleep(wait)
+}
+
+// jikanEpisode is one entry of /anime/{id}/episodes
+type jikanEpisode struct {
+	MalID         int    `json:"mal_id"`
+	Title         string `json:"title"`
+	TitleJapanese string `json:"title_japanese"`
+	TitleRomanji  string `json:"title_romanji"`
+	Aired         string `json:"aired"`
+	Filler        bool   `json:"filler"`
+	Recap         bool   `json:"recap"`
+}
+
+// jikanEpisodesPage is one page of /anime/{id}/episodes
+type jikanEpisodesPage struct {
+	Data       []jikanEpisode `json:"data"`
+	Pagination struct {
+		HasNextPage bool `json:"has_next_page"`
+	} `json:"pagination"`
+}
+
+// jikanEpisodesCache is the on-disk copy of an episode list
+type jikanEpisodesCache struct {
+	FetchedAt time.Time      `json:"fetched_at"`
+	Episodes  []jikanEpisode `json:"episodes"`
+}
+
+// EnrichEpisodes fills the titles, air dates and filler/recap flags of episodes
+// from Jikan's episode list for malID. Episodes are matched by number; those
+// Jikan does not list keep what the source gave them. Lists are cached on disk.
+func EnrichEpisodes(malID int, episodes []models.Episode) error {
+	if malID <= 0 || len(episodes) == 0 {
+		return nil
+	}
+	list, err := jikanEpisodes(malID)
+	if err != nil {
+		return err
+	}
+
+	enriched := applyJikanEpisodes(list, episodes)
+	util.Debug("Episodes enriched from Jikan", "malID", malID, "listed", len(list), "matched", enriched)
+	return nil
+}
+
+// applyJikanEpisodes copies the entries of list onto the episodes with the same
+// number and returns how many matched
+func applyJikanEpisodes(list []jikanEpisode, episodes []models.Episode) int {
+	byNumber := make(map[int]jikanEpisode, len(list))
+	for _, e := range list {
+		byNumber[e.MalID] = e
+	}
+	matched := 0
+	for i := range episodes {
+		e, ok := byNumber[episodes[i].Num]
+		if !ok {
+			continue
+		}
+		ep := &episodes[i]
+		if e.Title != "" {
+			ep.Title.English = e.Title
+		}
+		if e.TitleRomanji != "" {
+			ep.Title.Romaji = e.TitleRomanji
+		}
+		if e.TitleJapanese != "" {
+			ep.Title.Japanese = e.TitleJapanese
+		}
+		if e.Aired != "" {
+			ep.Aired = e.Aired
+		}
+		ep.IsFiller = e.Filler
+		ep.IsRecap = e.Recap
+		matched++
+	}
+	return matched
+}
+
+// enrichEpisodeMetadata applies EnrichEpisodes when the MAL id of anime is known;
+// missing metadata never stops the episode list from loading
+func enrichEpisodeMetadata(anime *models.Anime, episodes []models.Episode) {
+	if anime.MalID <= 0 {
+		return
+	}
+	if err := EnrichEpisodes(anime.MalID, episodes); err != nil {
+		util.Debug("Episode metadata unavailable", "malID", anime.MalID, "error", err)
+	}
+}
+
+// jikanEpisodes returns the episode list of malID, from the cache when it is fresh
+func jikanEpisodes(malID int) ([]jikanEpisode, error) {
+	path := jikanCachePath(malID)
+	if data, err := os.ReadFile(path); err == nil {
+		var cached jikanEpisodesCache
+		if json.Unmarshal(data, &cached) == nil && time.Since(cached.FetchedAt) < jikanEpisodesTTL {
+			return cached.Episodes, nil
+		}
+	}
+
+	var list []jikanEpisode
+	for page := 1; page <= jikanMaxPages; page++ {
+		var resp jikanEpisodesPage
+		url := fmt.Sprintf("%s/anime/%d/episodes?page=%d", jikanBaseURL, malID, page)
+		if err := getJikan(url, &resp); err != nil {
+			// Keep what earlier pages gave rather than nothing
+			if len(list) > 0 {
+				util.Debug("Jikan episode list incomplete", "malID", malID, "page", page, "error", err)
+				return list, nil
+			}
+			return nil, fmt.Errorf("error fetching episode list from Jikan (MyAnimeList) API: %w", err)
+		}
+		list = append(list, resp.Data...)
+		if !resp.Pagination.HasNextPage {
+			break
+		}
+	}
+
+	if data, err := json.Marshal(jikanEpisodesCache{FetchedAt: time.Now(), Episodes: list}); err == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err == nil {
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				util.Debug("Failed to cache Jikan episode list", "error", err)
+			}
+		}
+	}
+	return list, nil
+}
+
+// jikanCachePath is where the episode list of malID is cached
+func jikanCachePath(malID int) string {
+	return filepath.Join(config.Dir(), "cache", "jikan", fmt.Sprintf("episodes-%d.json", malID))
+}
+
+// getJikan decodes a Jikan response into out, waiting for the rate limit and
+// retrying when Jikan answers 429
+func getJikan(url string, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		waitJikan()
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("GET request failed: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < jikanRetries {
+			safeClose(resp.Body, "Jikan response body")
+			wait := time.Second
+			if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+				wait = time.Duration(secs) * time.Second
+			}
+			util.Debug("Jikan rate limited, retrying", "wait", wait)
+			time.Sleep(wait)
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			safeClose(resp.Body, "Jikan response body")
+			return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(out)
+		safeClose(resp.Body, "Jikan response body")
+		if err != nil {
+			return fmt.Errorf("JSON decode failed: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/httpreplay"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrichEpisodesReplay(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	httpreplay.Use(t, metadataFixtures)
+
+	episodes := []models.Episode{
+		{Number: "1", Num: 1, Title: models.TitleDetails{Romaji: "Episode 1"}},
+		{Number: "3", Num: 3},
+		{Number: "29", Num: 29, Title: models.TitleDetails{Romaji: "Episode 29"}},
+	}
+	require.NoError(t, EnrichEpisodes(52991, episodes))
+
+	assert.Equal(t, "The Journey's End", episodes[0].Title.English)
+	assert.Equal(t, "Bouken no Owari", episodes[0].Title.Romaji, "the placeholder is replaced")
+	assert.Equal(t, "2023-09-29T00:00:00+00:00", episodes[0].Aired)
+	assert.Equal(t, "Killing Magic", episodes[1].Title.English, "later pages are fetched")
+	assert.Equal(t, "Episode 29", episodes[2].Title.Romaji, "unlisted episodes keep the source data")
+
+	// The second lookup is served from the cache
+	_, err := os.Stat(jikanCachePath(52991))
+	require.NoError(t, err)
+	httpreplay.Use(t, t.TempDir())
+	again := []models.Episode{{Number: "2", Num: 2}}
+	require.NoError(t, EnrichEpisodes(52991, again))
+	assert.Equal(t, "It Didn't Have to Be Magic...", again[0].Title.English)
+}
+
+func TestApplyJikanEpisodes(t *testing.T) {
+	t.Parallel()
+
+	episodes := []models.Episode{{Num: 1}, {Num: 2, Title: models.TitleDetails{Japanese: "元の題"}}}
+	matched := applyJikanEpisodes([]jikanEpisode{
+		{MalID: 2, Title: "Recap", Filler: false, Recap: true},
+		{MalID: 5, Title: "Filler", Filler: true},
+	}, episodes)
+
+	assert.Equal(t, 1, matched)
+	assert.Empty(t, episodes[0].Title.English)
+	assert.Equal(t, "Recap", episodes[1].Title.English)
+	assert.Equal(t, "元の題", episodes[1].Title.Japanese, "empty Jikan fields keep the source data")
+	assert.True(t, episodes[1].IsRecap)
+	assert.False(t, episodes[1].IsFiller)
+}
+
+func TestGetJikanRetriesWhenRateLimited(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"mal_id":1,"title":"One"}],"pagination":{"has_next_page":false}}`))
+	}))
+	defer srv.Close()
+
+	var page jikanEpisodesPage
+	require.NoError(t, getJikan(srv.URL, &page))
+	assert.Equal(t, int32(2), calls.Load())
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, "One", page.Data[0].Title)
+}
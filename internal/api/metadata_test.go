@@ -0,0 +1,98 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/httpreplay"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var metadataFixtures = filepath.Join("testdata", "fixtures", "metadata")
+
+func TestFetchAnimeFromAniListReplay(t *testing.T) {
+	httpreplay.Use(t, metadataFixtures)
+
+	result, err := FetchAnimeFromAniList("Sousou no Frieren")
+	require.NoError(t, err)
+	assert.Equal(t, 154587, result.Data.Media.ID)
+	assert.Equal(t, 52991, result.Data.Media.IDMal)
+	assert.Equal(t, "Frieren: Beyond Journey's End", result.Data.Media.Title.English)
+}
+
+func TestGetEpisodeDataReplay(t *testing.T) {
+	httpreplay.Use(t, metadataFixtures)
+
+	anime := &models.Anime{}
+	require.NoError(t, GetEpisodeData(52991, 1, anime))
+	require.Len(t, anime.Episodes, 1)
+
+	ep := anime.Episodes[0]
+	assert.Equal(t, "The Journey's End", ep.Title.English)
+	assert.Equal(t, "Bouken no Owari", ep.Title.Romaji)
+	assert.Equal(t, 1500, ep.Duration)
+	assert.False(t, ep.IsFiller)
+}
+
+func TestAniSkipReplay(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	httpreplay.Use(t, metadataFixtures)
+
+	var ep models.Episode
+	require.NoError(t, GetAndParseAniSkipData(52991, 1, &ep))
+	assert.Equal(t, models.Skip{Start: 90, End: 181}, ep.SkipTimes.Op)
+	assert.Equal(t, models.Skip{Start: 1331, End: 1421}, ep.SkipTimes.Ed)
+	assert.Equal(t, []models.Interval{
+		{Type: models.SkipTypeOp, Start: 90, End: 181},
+		{Type: models.SkipTypeEd, Start: 1331, End: 1421},
+		{Type: models.SkipTypePreview, Start: 1440, End: 1468},
+	}, ep.SkipTimes.Intervals)
+
+	// The second lookup is served from the cache, matched to the release's length
+	httpreplay.Use(t, t.TempDir())
+	shorter := models.Episode{Duration: 1440}
+	require.NoError(t, GetAndParseAniSkipData(52991, 1, &shorter))
+	assert.Equal(t, models.Skip{Start: 62, End: 152}, shorter.SkipTimes.Op)
+	assert.Equal(t, models.Skip{Start: 1331, End: 1421}, shorter.SkipTimes.Ed)
+}
+
+func TestAniSkipCachesMisses(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	httpreplay.Use(t, t.TempDir())
+
+	// No fixture: the request fails and nothing is cached
+	var ep models.Episode
+	require.Error(t, GetAndParseAniSkipData(52991, 2, &ep))
+	_, err := os.Stat(aniSkipCachePath(52991, 2))
+	assert.True(t, os.IsNotExist(err))
+
+	cached := aniSkipCache{FetchedAt: time.Now().Add(-time.Hour), Found: false}
+	assert.True(t, cached.fresh())
+	cached.FetchedAt = time.Now().Add(-aniSkipMissTTL)
+	assert.False(t, cached.fresh(), "misses are asked about again sooner")
+	cached.Found = true
+	assert.True(t, cached.fresh())
+}
+
+func TestParseAniSkipResponseV2(t *testing.T) {
+	ep := models.Episode{Duration: 1420}
+	err := ParseAniSkipResponse(`{"found":true,"results":[
+		{"interval":{"startTime":0,"endTime":75.5},"skipType":"recap","episodeLength":1420},
+		{"interval":{"startTime":75.5,"endTime":165},"skipType":"mixed-op","episodeLength":1420},
+		{"interval":{"startTime":1300,"endTime":1390},"skipType":"mixed-ed","episodeLength":1420}
+	]}`, &ep, 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.Skip{Start: 0, End: 76}, ep.SkipTimes.Recap)
+	assert.Equal(t, models.Skip{Start: 76, End: 165}, ep.SkipTimes.Op, "a mixed opening stands in for the opening")
+	assert.Equal(t, models.Skip{Start: 1300, End: 1390}, ep.SkipTimes.Ed)
+	assert.Len(t, ep.SkipTimes.Of(models.SkipTypeMixedOp), 1)
+
+	err = ParseAniSkipResponse(`{"found":false,"results":[],"message":"No skip times found","statusCode":404}`, &ep, 0)
+	assert.ErrorIs(t, err, ErrNoSkipTimes)
+}
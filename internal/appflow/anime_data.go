@@ -8,7 +8,9 @@ import (
 
 	"github.com/alvarorichard/Goanime/internal/api"
 
+	"github.com/alvarorichard/Goanime/internal/mirrors"
 	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/network"
 	"github.com/alvarorichard/Goanime/internal/util"
 	"github.com/charmbracelet/huh"
 )
@@ -45,6 +47,11 @@ func SearchAnimeWithRetry(name string) (*models.Anime, error) {
 	const maxRetries = 3
 	currentName := name
 
+	// Bad filter flags would fail every attempt; report them instead of asking for a new name
+	if _, err := api.SearchOptionsFromFlags(); err != nil {
+		return nil, err
+	}
+
 	for i := 0; i < maxRetries; i++ {
 		searchStart := time.Now()
 
@@ -121,7 +128,7 @@ func FetchAnimeDetails(anime *models.Anime) {
 	}
 
 	// Fallback: tentar buscar detalhes específicos da fonte se necessário
-	if anime.Source == "AllAnime" && len(anime.URL) > 20 && strings.Contains(anime.URL, "allanime.to") {
+	if anime.Source == "AllAnime" && len(anime.URL) > 20 && mirrors.MatchesHost(network.SourceAllAnime, anime.URL) {
 		if err := api.FetchAnimeDetails(anime); err != nil {
 			util.Debugf("Failed to fetch anime details from source: %v", err)
 		}
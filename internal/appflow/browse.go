@@ -0,0 +1,147 @@
+package appflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/api"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/util"
+	"github.com/charmbracelet/huh"
+)
+
+// BrowseAnime lists titles for the mode given on the command line, or asks for
+// a mode and filters on the browse screen when none was given
+func BrowseAnime() (*models.Anime, error) {
+	browseStart := time.Now()
+
+	opts, err := api.SearchOptionsFromFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	var mode scraper.BrowseMode
+	if util.GlobalBrowseMode != "" {
+		if mode, err = scraper.ParseBrowseMode(util.GlobalBrowseMode); err != nil {
+			return nil, err
+		}
+	} else if mode, opts, err = browseScreen(opts); err != nil {
+		return nil, err
+	}
+
+	anime, err := api.BrowseAnime(mode, opts, util.GlobalSource)
+	if err != nil {
+		return nil, err
+	}
+	util.Debugf("[PERF] BrowseAnime completed in %v", time.Since(browseStart))
+	return anime, nil
+}
+
+// browseScreen asks for a browse mode and optional filters, starting from opts
+func browseScreen(opts scraper.SearchOptions) (scraper.BrowseMode, scraper.SearchOptions, error) {
+	mode := string(scraper.BrowseTrending)
+	season := opts.Season
+	year := ""
+	if opts.Year != 0 {
+		year = strconv.Itoa(opts.Year)
+	}
+	genres := strings.Join(opts.Genres, ", ")
+	format := opts.Format
+	sort := string(opts.Sort)
+
+	currentSeason, currentYear := scraper.CurrentSeason(time.Now())
+
+	seasonOptions := []huh.Option[string]{huh.NewOption("Any", "")}
+	for _, s := range scraper.Seasons {
+		seasonOptions = append(seasonOptions, huh.NewOption(s, s))
+	}
+	formatOptions := []huh.Option[string]{huh.NewOption("Any", "")}
+	for _, f := range scraper.Formats {
+		formatOptions = append(formatOptions, huh.NewOption(f, f))
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Browse").
+				Description("What would you like to see?").
+				Options(
+					huh.NewOption("Trending today", string(scraper.BrowseTrending)),
+					huh.NewOption("Recently updated", string(scraper.BrowseRecent)),
+					huh.NewOption(fmt.Sprintf("This season (%s %d)", currentSeason, currentYear), string(scraper.BrowseSeason)),
+					huh.NewOption("By genre", string(scraper.BrowseGenre)),
+				).
+				Value(&mode),
+		),
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Season").
+				Description("Leave as Any to use the current season when browsing this season").
+				Options(seasonOptions...).
+				Value(&season),
+			huh.NewInput().
+				Title("Year").
+				Placeholder("any").
+				Value(&year).
+				Validate(func(v string) error {
+					if v = strings.TrimSpace(v); v == "" {
+						return nil
+					}
+					if _, err := strconv.Atoi(v); err != nil {
+						return fmt.Errorf("year must be a number")
+					}
+					return nil
+				}),
+			huh.NewInput().
+				Title("Genres").
+				Description("Comma-separated, e.g. action, fantasy").
+				Placeholder("any").
+				Value(&genres).
+				Validate(func(v string) error {
+					if mode == string(scraper.BrowseGenre) && strings.TrimSpace(v) == "" {
+						return fmt.Errorf("enter at least one genre")
+					}
+					return nil
+				}),
+			huh.NewSelect[string]().
+				Title("Format").
+				Options(formatOptions...).
+				Value(&format),
+			huh.NewSelect[string]().
+				Title("Sort by").
+				Options(
+					huh.NewOption("Default", string(scraper.SortRelevance)),
+					huh.NewOption("Popular", string(scraper.SortPopular)),
+					huh.NewOption("Recently updated", string(scraper.SortRecent)),
+					huh.NewOption("Score", string(scraper.SortScore)),
+				).
+				Value(&sort),
+		).WithHideFunc(func() bool {
+			// Trending is AllAnime's own ranking and takes no filters
+			return mode == string(scraper.BrowseTrending)
+		}),
+	)
+
+	if err := form.Run(); err != nil {
+		return "", opts, fmt.Errorf("browse cancelled: %w", err)
+	}
+
+	opts.Season = season
+	opts.Year = 0
+	if y := strings.TrimSpace(year); y != "" {
+		opts.Year, _ = strconv.Atoi(y)
+	}
+	opts.Genres = nil
+	for _, g := range strings.Split(genres, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			opts.Genres = append(opts.Genres, g)
+		}
+	}
+	opts.Format = format
+	opts.Sort = scraper.SortOrder(sort)
+
+	return scraper.BrowseMode(mode), opts, nil
+}
@@ -0,0 +1,430 @@
+// Package config loads and persists the user's GoAnime settings
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config holds every user-tunable setting stored in config.json
+type Config struct {
+	Network NetworkConfig `json:"network"`
+	// Mirrors replaces the built-in mirror list per source ("allanime", "animefire"), in priority order
+	Mirrors map[string][]Mirror `json:"mirrors,omitempty"`
+	// Subtitles controls which subtitle tracks are shown and saved
+	Subtitles SubtitleConfig `json:"subtitles"`
+	// Playback controls what happens around episodes played in mpv
+	Playback PlaybackConfig `json:"playback"`
+	// Skip chooses what happens when an opening, ending, recap or preview starts
+	Skip SkipConfig `json:"skip"`
+	// Keys binds GoAnime actions to keys in the mpv window
+	Keys KeyConfig `json:"keys"`
+	// MPV customizes the mpv episodes are played in
+	MPV MPVConfig `json:"mpv"`
+}
+
+// MPVConfig adds the user's own settings to the mpv GoAnime starts
+type MPVConfig struct {
+	// Args are extra mpv options ("--alang=jpn", "--deband").
+	// Options outside GoAnime's allowlist are ignored with a warning; see docs/MPV_OPTIONS.md.
+	Args []string `json:"args,omitempty"`
+	// UserConfig loads the user's mpv.conf, input.conf and scripts instead of
+	// GoAnime's tuned defaults
+	UserConfig bool `json:"user_config,omitempty"`
+	// Profile applies a profile from the user's mpv.conf; it implies UserConfig
+	Profile string `json:"profile,omitempty"`
+}
+
+// LoadsUserConfig reports whether mpv reads the user's own configuration
+func (m MPVConfig) LoadsUserConfig() bool {
+	return m.UserConfig || m.Profile != ""
+}
+
+// KeyConfig holds the mpv keys of GoAnime's actions, in mpv's key syntax
+// ("Ctrl+x", ">", "F5"). Empty uses the default; "none" leaves the action unbound.
+type KeyConfig struct {
+	Next        string `json:"next,omitempty"`
+	Previous    string `json:"previous,omitempty"`
+	SkipIntro   string `json:"skip_intro,omitempty"`
+	SubDub      string `json:"sub_dub,omitempty"`
+	MarkWatched string `json:"mark_watched,omitempty"`
+	Search      string `json:"search,omitempty"`
+	// Skip accepts a skip offered in "ask" mode, Undo reverts an automatic one
+	Skip string `json:"skip,omitempty"`
+	Undo string `json:"undo,omitempty"`
+	// MarkOpening and MarkEnding mark where the opening or ending starts,
+	// then, pressed again, where it ends
+	MarkOpening string `json:"mark_opening,omitempty"`
+	MarkEnding  string `json:"mark_ending,omitempty"`
+}
+
+// KeyBinding is a key and the action it triggers
+type KeyBinding struct {
+	Action string
+	Key    string
+}
+
+// Bindings returns the bound keys with defaults applied, in a fixed order
+func (k KeyConfig) Bindings() []KeyBinding {
+	var bindings []KeyBinding
+	for _, b := range []struct {
+		action, key, fallback string
+	}{
+		{"next", k.Next, ">"},
+		{"previous", k.Previous, "<"},
+		{"skip-intro", k.SkipIntro, "Ctrl+o"},
+		{"sub-dub", k.SubDub, "Ctrl+d"},
+		{"mark-watched", k.MarkWatched, "Ctrl+e"},
+		{"search", k.Search, "Ctrl+f"},
+		{"skip", k.Skip, "Ctrl+x"},
+		{"undo", k.Undo, "Ctrl+z"},
+		{"mark-opening", k.MarkOpening, "Ctrl+k"},
+		{"mark-ending", k.MarkEnding, "Ctrl+j"},
+	} {
+		key := strings.TrimSpace(b.key)
+		if key == "" {
+			key = b.fallback
+		}
+		if strings.EqualFold(key, "none") {
+			continue
+		}
+		bindings = append(bindings, KeyBinding{Action: b.action, Key: key})
+	}
+	return bindings
+}
+
+// Key returns the key bound to action, or "" when it is unbound
+func (k KeyConfig) Key(action string) string {
+	for _, b := range k.Bindings() {
+		if b.Action == action {
+			return b.Key
+		}
+	}
+	return ""
+}
+
+// Skip modes for a kind of segment
+const (
+	// SkipAuto seeks past the segment and offers to undo it
+	SkipAuto = "auto"
+	// SkipAsk offers a key that seeks past the segment
+	SkipAsk = "ask"
+	// SkipNever plays the segment
+	SkipNever = "never"
+)
+
+// SkipConfig holds the skip mode of each kind of segment; empty means SkipAuto
+type SkipConfig struct {
+	Opening string `json:"opening,omitempty"`
+	Ending  string `json:"ending,omitempty"`
+	Recap   string `json:"recap,omitempty"`
+	Preview string `json:"preview,omitempty"`
+}
+
+// Mode returns the skip mode for "opening", "ending", "recap" or "preview"
+func (s SkipConfig) Mode(kind string) string {
+	var mode string
+	switch kind {
+	case "opening":
+		mode = s.Opening
+	case "ending":
+		mode = s.Ending
+	case "recap":
+		mode = s.Recap
+	case "preview":
+		mode = s.Preview
+	}
+	if mode == "" {
+		return SkipAuto
+	}
+	return strings.ToLower(mode)
+}
+
+const (
+	// DefaultAutoplayLimit is how many episodes autoplay continues with before
+	// waiting for the user again
+	DefaultAutoplayLimit = 12
+	// DefaultAutoplayCountdown is how long, in seconds, the next episode is
+	// announced before it starts
+	DefaultAutoplayCountdown = 5
+)
+
+// Players GoAnime can drive
+const (
+	PlayerMPV = "mpv"
+	PlayerVLC = "vlc"
+)
+
+// PlaybackConfig controls the player and binge mode
+type PlaybackConfig struct {
+	// Player is the media player episodes are played in: "mpv" (default) or "vlc"
+	Player string `json:"player,omitempty"`
+	// Autoplay continues with the next episode in the same mpv window when one ends
+	Autoplay bool `json:"autoplay,omitempty"`
+	// AutoplayLimit caps how many episodes play in a row without input; 0 uses DefaultAutoplayLimit
+	AutoplayLimit int `json:"autoplay_limit,omitempty"`
+	// AutoplayCountdown is the delay in seconds before the next episode; 0 uses DefaultAutoplayCountdown
+	AutoplayCountdown int `json:"autoplay_countdown,omitempty"`
+}
+
+// PlayerName returns the configured player with its default applied
+func (p PlaybackConfig) PlayerName() string {
+	if p.Player == "" {
+		return PlayerMPV
+	}
+	return strings.ToLower(p.Player)
+}
+
+// Limit returns the autoplay cap with its default applied
+func (p PlaybackConfig) Limit() int {
+	if p.AutoplayLimit > 0 {
+		return p.AutoplayLimit
+	}
+	return DefaultAutoplayLimit
+}
+
+// Countdown returns the autoplay countdown with its default applied
+func (p PlaybackConfig) Countdown() time.Duration {
+	if p.AutoplayCountdown > 0 {
+		return time.Duration(p.AutoplayCountdown) * time.Second
+	}
+	return DefaultAutoplayCountdown * time.Second
+}
+
+// SubtitleConfig selects subtitle tracks offered by the sources
+type SubtitleConfig struct {
+	// Language is the preferred track language ("en", "pt-BR", ...); it is
+	// selected first and other tracks stay available. "off" disables subtitles.
+	Language string `json:"language,omitempty"`
+}
+
+// Off reports whether subtitles are disabled
+func (s SubtitleConfig) Off() bool {
+	return strings.EqualFold(s.Language, "off")
+}
+
+// Mirror is one set of domains a source can be reached through
+type Mirror struct {
+	// Base is the site root, e.g. https://animefire.plus
+	Base string `json:"base"`
+	// API is the API endpoint when it differs from Base
+	API string `json:"api,omitempty"`
+	// Referer is sent with requests; defaults to Base
+	Referer string `json:"referer,omitempty"`
+}
+
+// NetworkConfig controls how outgoing HTTP traffic is routed and resolved
+type NetworkConfig struct {
+	// Proxy is an http://, https:// or socks5:// URL (credentials allowed) used for all traffic
+	Proxy string `json:"proxy,omitempty"`
+	// NoProxy is a comma-separated list of hosts/domains/CIDRs that bypass the proxy
+	NoProxy string `json:"no_proxy,omitempty"`
+	// SourceProxies overrides Proxy per source (e.g. "allanime", "animefire"); "direct" disables it
+	SourceProxies map[string]string `json:"source_proxies,omitempty"`
+	// DoH is a DNS-over-HTTPS endpoint speaking the JSON API (e.g. https://cloudflare-dns.com/dns-query)
+	DoH string `json:"doh,omitempty"`
+}
+
+var (
+	mu      sync.Mutex
+	current *Config
+	loadErr error
+)
+
+// Dir returns the directory where GoAnime keeps its configuration
+func Dir() string {
+	if runtime.GOOS == "windows" {
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			return filepath.Join(local, "GoAnime")
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		if u, uerr := user.Current(); uerr == nil {
+			home = u.HomeDir
+		}
+	}
+	return filepath.Join(home, ".local", "goanime")
+}
+
+// Path returns the location of config.json
+func Path() string {
+	return filepath.Join(Dir(), "config.json")
+}
+
+// Default returns a configuration with every setting at its default value
+func Default() *Config {
+	return &Config{}
+}
+
+// Load reads the configuration at path. A missing file yields the defaults.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return Default(), fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Save writes the configuration to path, creating parent directories as needed
+func (c *Config) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// Validate reports settings that are syntactically valid JSON but cannot be used
+func (c *Config) Validate() error {
+	var errs []error
+
+	checkURL := func(field, raw string, schemes ...string) {
+		if raw == "" || raw == "direct" || raw == "none" {
+			return
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s: %q is not a valid URL", field, raw))
+			return
+		}
+		for _, s := range schemes {
+			if u.Scheme == s {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: unsupported scheme %q (want one of %v)", field, u.Scheme, schemes))
+	}
+
+	proxySchemes := []string{"http", "https", "socks5", "socks5h"}
+	checkURL("network.proxy", c.Network.Proxy, proxySchemes...)
+	for _, source := range sortedKeys(c.Network.SourceProxies) {
+		checkURL("network.source_proxies."+source, c.Network.SourceProxies[source], proxySchemes...)
+	}
+	checkURL("network.doh", c.Network.DoH, "https")
+
+	for _, source := range sortedKeys(c.Mirrors) {
+		for i, m := range c.Mirrors[source] {
+			field := fmt.Sprintf("mirrors.%s[%d]", source, i)
+			if m.Base == "" {
+				errs = append(errs, fmt.Errorf("%s.base is required", field))
+				continue
+			}
+			checkURL(field+".base", m.Base, "http", "https")
+			checkURL(field+".api", m.API, "http", "https")
+			checkURL(field+".referer", m.Referer, "http", "https")
+		}
+	}
+
+	for _, kind := range []string{"opening", "ending", "recap", "preview"} {
+		switch c.Skip.Mode(kind) {
+		case SkipAuto, SkipAsk, SkipNever:
+		default:
+			errs = append(errs, fmt.Errorf("skip.%s: unknown mode (want auto, ask or never)", kind))
+		}
+	}
+	seenKeys := map[string]string{}
+	for _, b := range c.Keys.Bindings() {
+		if strings.ContainsAny(b.Key, " \t\n#") {
+			errs = append(errs, fmt.Errorf("keys.%s: %q is not a single mpv key", strings.ReplaceAll(b.Action, "-", "_"), b.Key))
+			continue
+		}
+		if other, ok := seenKeys[strings.ToLower(b.Key)]; ok {
+			errs = append(errs, fmt.Errorf("keys.%s: %s is already bound to %s", strings.ReplaceAll(b.Action, "-", "_"), b.Key, other))
+			continue
+		}
+		seenKeys[strings.ToLower(b.Key)] = b.Action
+	}
+	for i, arg := range c.MPV.Args {
+		if !strings.HasPrefix(arg, "--") || len(arg) < 3 || strings.ContainsAny(arg, "\x00\n\r") {
+			errs = append(errs, fmt.Errorf("mpv.args[%d]: %q is not an mpv option (want --name or --name=value)", i, arg))
+		}
+	}
+	if strings.ContainsAny(c.MPV.Profile, " \t\n\r=") {
+		errs = append(errs, fmt.Errorf("mpv.profile: %q is not a profile name", c.MPV.Profile))
+	}
+	if name := c.Playback.PlayerName(); name != PlayerMPV && name != PlayerVLC {
+		errs = append(errs, fmt.Errorf("playback.player: %q is not mpv or vlc", c.Playback.Player))
+	}
+	if c.Playback.AutoplayLimit < 0 {
+		errs = append(errs, fmt.Errorf("playback.autoplay_limit: must not be negative"))
+	}
+	if c.Playback.AutoplayCountdown < 0 {
+		errs = append(errs, fmt.Errorf("playback.autoplay_countdown: must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns the active configuration, loading it from Path() on first use.
+// A broken file falls back to defaults; the error is available via LoadError.
+func Get() *Config {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		current, loadErr = Load(Path())
+	}
+	return current
+}
+
+// LoadError reports the error encountered while loading the active configuration, if any
+func LoadError() error {
+	Get()
+	mu.Lock()
+	defer mu.Unlock()
+	return loadErr
+}
+
+// Set replaces the active configuration (used by tests and CLI overrides)
+func Set(c *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = c
+	loadErr = nil
+}
+
+// Update applies fn to the active configuration and persists the result
+func Update(fn func(c *Config)) error {
+	cfg := Get()
+	mu.Lock()
+	fn(cfg)
+	mu.Unlock()
+	return cfg.Save(Path())
+}
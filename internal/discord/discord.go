@@ -2,7 +2,9 @@ package discord
 
 import (
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alvarorichard/Goanime/internal/models"
@@ -23,6 +25,20 @@ type RichPresenceUpdater struct {
 	episodeStarted  bool                                             // Whether the episode has started
 	socketPath      string                                           // Path to mpv IPC socket
 	mpvSendCommand  func(string, []interface{}) (interface{}, error) // Função para enviar comandos ao MPV
+
+	// Playback state pushed by mpv after Watch; read instead of polling the socket
+	observedMu       sync.Mutex
+	watching         bool
+	observedPosition interface{}
+	observedDuration float64
+	unwatch          []func()
+
+	connected atomic.Bool // whether the last presence update reached Discord
+}
+
+// PropertyObserver subscribes to mpv properties, like *mpvipc.Client
+type PropertyObserver interface {
+	Observe(property string) (<-chan interface{}, func(), error)
 }
 
 // NewRichPresenceUpdater cria uma nova instância do atualizador de Rich Presence
@@ -49,11 +65,73 @@ func NewRichPresenceUpdater(
 	}
 }
 
+// Watch subscribes to mpv's time-pos, duration and pause so the presence is
+// built from the values mpv pushes instead of querying the socket each update
+func (rpu *RichPresenceUpdater) Watch(o PropertyObserver) error {
+	for _, property := range []string{"time-pos", "duration", "pause"} {
+		values, cancel, err := o.Observe(property)
+		if err != nil {
+			rpu.stopWatching()
+			return fmt.Errorf("failed to observe %s: %w", property, err)
+		}
+		rpu.observedMu.Lock()
+		rpu.unwatch = append(rpu.unwatch, cancel)
+		rpu.observedMu.Unlock()
+		go rpu.follow(property, values)
+	}
+	rpu.observedMu.Lock()
+	rpu.watching = true
+	rpu.observedMu.Unlock()
+	return nil
+}
+
+// follow stores the values of one observed property until the subscription ends
+func (rpu *RichPresenceUpdater) follow(property string, values <-chan interface{}) {
+	for v := range values {
+		switch property {
+		case "time-pos":
+			rpu.observedMu.Lock()
+			rpu.observedPosition = v
+			rpu.observedMu.Unlock()
+		case "duration":
+			if seconds, ok := v.(float64); ok && seconds > 0 {
+				rpu.observedMu.Lock()
+				rpu.observedDuration = seconds
+				rpu.observedMu.Unlock()
+			}
+		case "pause":
+			if paused, ok := v.(bool); ok && rpu.isPaused != nil {
+				rpu.animeMutex.Lock()
+				*rpu.isPaused = paused
+				rpu.animeMutex.Unlock()
+			}
+		}
+	}
+}
+
+// stopWatching cancels the subscriptions made by Watch
+func (rpu *RichPresenceUpdater) stopWatching() {
+	rpu.observedMu.Lock()
+	cancels := rpu.unwatch
+	rpu.unwatch = nil
+	rpu.watching = false
+	rpu.observedMu.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
+
 // GetCurrentPlaybackPosition obtém a posição atual de reprodução do MPV
 func (rpu *RichPresenceUpdater) GetCurrentPlaybackPosition() (time.Duration, error) {
-	position, err := rpu.mpvSendCommand(rpu.socketPath, []interface{}{"get_property", "time-pos"})
-	if err != nil {
-		return 0, err
+	rpu.observedMu.Lock()
+	watching, position := rpu.watching, rpu.observedPosition
+	rpu.observedMu.Unlock()
+	if !watching {
+		var err error
+		position, err = rpu.mpvSendCommand(rpu.socketPath, []interface{}{"get_property", "time-pos"})
+		if err != nil {
+			return 0, err
+		}
 	}
 
 	// Convert position to float64 and then to time.Duration
@@ -115,6 +193,11 @@ func (rpu *RichPresenceUpdater) GetUpdateFreq() time.Duration {
 	return rpu.updateFreq
 }
 
+// Connected reports whether the last presence update reached Discord
+func (rpu *RichPresenceUpdater) Connected() bool {
+	return rpu.connected.Load()
+}
+
 // Start inicia as atualizações periódicas do Rich Presence
 func (rpu *RichPresenceUpdater) Start() {
 	rpu.wg.Add(1)
@@ -146,6 +229,7 @@ func (rpu *RichPresenceUpdater) Stop() {
 		default:
 			close(rpu.done)
 		}
+		rpu.stopWatching()
 		rpu.wg.Wait()
 		util.Debug("Rich Presence updater stopped.")
 	}
@@ -163,7 +247,13 @@ func (rpu *RichPresenceUpdater) updateDiscordPresence() {
 	}
 
 	// Se a duração do episódio não estiver definida ou for 0, buscar do MPV
-	if rpu.episodeDuration == 0 {
+	rpu.observedMu.Lock()
+	watching, observed := rpu.watching, rpu.observedDuration
+	rpu.observedMu.Unlock()
+	if rpu.episodeDuration == 0 && observed > 0 {
+		rpu.episodeDuration = time.Duration(observed) * time.Second
+	}
+	if rpu.episodeDuration == 0 && !watching {
 		durationResponse, err := rpu.mpvSendCommand(rpu.socketPath, []interface{}{"get_property", "duration"})
 		if err == nil && durationResponse != nil {
 			if durationSeconds, ok := durationResponse.(float64); ok && durationSeconds > 0 {
@@ -188,7 +278,7 @@ func (rpu *RichPresenceUpdater) updateDiscordPresence() {
 	// Create the activity with updated Details
 	activity := client.Activity{
 		Details:    fmt.Sprintf("%s | Episode %s | %s / %d min", rpu.anime.Details.Title.Romaji, rpu.anime.Episodes[0].Number, timeInfo, totalMinutes),
-		State:      "Watching",
+		State:      episodeState(rpu.anime.Episodes[0]),
 		LargeImage: rpu.anime.ImageURL,
 		LargeText:  rpu.anime.Details.Title.Romaji,
 		Buttons: []*client.Button{
@@ -198,13 +288,30 @@ func (rpu *RichPresenceUpdater) updateDiscordPresence() {
 	}
 
 	// Set the activity in Discord Rich Presence
-	if err := client.SetActivity(activity); err != nil {
+	err = client.SetActivity(activity)
+	rpu.connected.Store(err == nil)
+	if err != nil {
 		util.Debugf("Error updating Discord Rich Presence: %v", err)
 	} else {
 		util.Debugf("Discord Rich Presence updated with elapsed time: %s", timeInfo)
 	}
 }
 
+// maxStateLength is Discord's limit for the activity state
+const maxStateLength = 128
+
+// episodeState shows the episode title when it is known
+func episodeState(ep models.Episode) string {
+	title := ep.DisplayTitle()
+	if len(title) < 2 {
+		return "Watching"
+	}
+	if len(title) > maxStateLength {
+		title = strings.ToValidUTF8(title[:maxStateLength-3], "") + "..."
+	}
+	return title
+}
+
 // FetchDuration fetches the episode duration from MPV and calls the callback with duration in seconds
 func (rpu *RichPresenceUpdater) FetchDuration(socketPath string, f func(durSec int)) {
 	// Use the provided socketPath or fall back to the instance's socketPath
@@ -0,0 +1,51 @@
+package discord
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ipcSlots is how many discord-ipc-N endpoints the Discord client may expose
+const ipcSlots = 10
+
+// FindIPCEndpoint returns the first Discord IPC socket (or named pipe on Windows)
+// present on this machine, without logging in. It is used to diagnose why Rich
+// Presence is unavailable.
+func FindIPCEndpoint() (string, error) {
+	for _, dir := range ipcDirs() {
+		for i := 0; i < ipcSlots; i++ {
+			path := filepath.Join(dir, fmt.Sprintf("discord-ipc-%d", i))
+			if runtime.GOOS == "windows" {
+				path = fmt.Sprintf(`\\.\pipe\discord-ipc-%d`, i)
+			}
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+		if runtime.GOOS == "windows" {
+			break
+		}
+	}
+	return "", fmt.Errorf("no Discord IPC endpoint found (is the Discord desktop app running?)")
+}
+
+// ipcDirs mirrors the lookup order used by the rich-go client
+func ipcDirs() []string {
+	if runtime.GOOS == "windows" {
+		return []string{""}
+	}
+	var dirs []string
+	for _, env := range []string{"XDG_RUNTIME_DIR", "TMPDIR", "TMP", "TEMP"} {
+		if v := os.Getenv(env); v != "" {
+			dirs = append(dirs, v)
+		}
+	}
+	dirs = append(dirs, "/tmp")
+	// Flatpak and Snap builds of Discord place the socket in a sub-directory
+	if xdg := os.Getenv("XDG_RUNTIME_DIR"); xdg != "" {
+		dirs = append(dirs, filepath.Join(xdg, "app", "com.discordapp.Discord"), filepath.Join(xdg, "snap.discord"))
+	}
+	return dirs
+}
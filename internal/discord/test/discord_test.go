@@ -384,3 +384,36 @@ func TestFetchDuration(t *testing.T) {
 		assert.Equal(t, 600, receivedDuration) // 10 minutes = 600 seconds
 	})
 }
+
+// fakeObserver entrega valores de propriedades como o mpv faria
+type fakeObserver struct {
+	channels map[string]chan interface{}
+}
+
+func (f *fakeObserver) Observe(property string) (<-chan interface{}, func(), error) {
+	ch := make(chan interface{}, 4)
+	f.channels[property] = ch
+	return ch, func() {}, nil
+}
+
+// TestWatchUsesObservedState tests that a watching updater reads mpv's pushed values
+func TestWatchUsesObservedState(t *testing.T) {
+	isPaused := false
+	mutex := &sync.Mutex{}
+	updater := discord.NewRichPresenceUpdater(&models.Anime{}, &isPaused, mutex, time.Second, 0, "/tmp/mpv",
+		func(string, []interface{}) (interface{}, error) {
+			return nil, fmt.Errorf("the socket must not be polled while watching")
+		})
+
+	observer := &fakeObserver{channels: map[string]chan interface{}{}}
+	assert.NoError(t, updater.Watch(observer))
+	observer.channels["time-pos"] <- 95.5
+	observer.channels["pause"] <- true
+
+	assert.Eventually(t, func() bool {
+		pos, err := updater.GetCurrentPlaybackPosition()
+		mutex.Lock()
+		defer mutex.Unlock()
+		return err == nil && pos == 95*time.Second && isPaused
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,319 @@
+// Package doctor diagnoses the local environment and the reachability of every source
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/discord"
+	"github.com/alvarorichard/Goanime/internal/mirrors"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/player"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/tracking"
+	"github.com/alvarorichard/Goanime/internal/version"
+	"github.com/lrstanley/go-ytdlp"
+)
+
+// Status is the outcome of a single check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Check is one line of the doctor report
+type Check struct {
+	Category  string `json:"category"`
+	Name      string `json:"name"`
+	Status    Status `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+}
+
+// Report is the full doctor output, suitable for attaching to bug reports
+type Report struct {
+	Version   string    `json:"version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	GoVersion string    `json:"go_version"`
+	Generated time.Time `json:"generated"`
+	Checks    []Check   `json:"checks"`
+}
+
+// Failed reports whether any check failed outright
+func (r *Report) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// checkTimeout bounds every network request made by the doctor
+const checkTimeout = 10 * time.Second
+
+// metadataAPIs are the third-party APIs GoAnime depends on besides the sources
+var metadataAPIs = []struct {
+	name string
+	url  string
+}{
+	{"AniList", "https://graphql.anilist.co"},
+	{"Jikan", "https://api.jikan.moe/v4"},
+	{"AniSkip", "https://api.aniskip.com/v1/skip-times/1/1?types=op"},
+}
+
+// Run executes every check. Independent checks run concurrently; the report keeps a stable order.
+func Run(ctx context.Context) *Report {
+	report := &Report{
+		Version:   version.Version,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		GoVersion: runtime.Version(),
+		Generated: time.Now().UTC(),
+	}
+
+	checks := []func(context.Context) []Check{
+		checkMPV,
+		checkYtDlp,
+		checkTracking,
+		checkDiscord,
+		checkConfig,
+		checkSources,
+		checkCustomSources,
+		checkMetadataAPIs,
+	}
+
+	results := make([][]Check, len(checks))
+	var wg sync.WaitGroup
+	for i, fn := range checks {
+		wg.Add(1)
+		go func(i int, fn func(context.Context) []Check) {
+			defer wg.Done()
+			results[i] = fn(ctx)
+		}(i, fn)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		report.Checks = append(report.Checks, r...)
+	}
+	return report
+}
+
+func checkMPV(context.Context) []Check {
+	path, err := exec.LookPath("mpv")
+	if err != nil {
+		return []Check{{Category: "player", Name: "mpv", Status: StatusFail,
+			Detail: "mpv not found in PATH. Install it from https://mpv.io/installation/"}}
+	}
+
+	checks := []Check{{Category: "player", Name: "mpv", Status: StatusOK, Detail: path}}
+
+	// #nosec G204: fixed argument, path resolved via LookPath
+	if out, err := exec.Command(path, "--version").Output(); err == nil {
+		checks[0].Detail = fmt.Sprintf("%s (%s)", firstLine(string(out)), path)
+	} else {
+		checks[0].Status = StatusWarn
+		checks[0].Detail = fmt.Sprintf("%s (failed to run --version: %v)", path, err)
+	}
+
+	start := time.Now()
+	if _, err := player.ProbeIPC(5 * time.Second); err != nil {
+		checks = append(checks, Check{Category: "player", Name: "mpv IPC socket", Status: StatusFail, Detail: err.Error()})
+	} else {
+		checks = append(checks, Check{Category: "player", Name: "mpv IPC socket", Status: StatusOK,
+			Detail: "socket created and answered", LatencyMS: time.Since(start).Milliseconds()})
+	}
+	return checks
+}
+
+func checkYtDlp(ctx context.Context) []Check {
+	resolved, err := ytdlp.Install(ctx, &ytdlp.InstallOptions{DisableDownload: true})
+	if err != nil || resolved == nil {
+		return []Check{{Category: "download", Name: "yt-dlp", Status: StatusWarn,
+			Detail: "not installed; it will be downloaded automatically on the first HLS download"}}
+	}
+	detail := resolved.Executable
+	if resolved.Version != "" {
+		detail = fmt.Sprintf("%s (%s)", resolved.Version, resolved.Executable)
+	}
+	return []Check{{Category: "download", Name: "yt-dlp", Status: StatusOK, Detail: detail}}
+}
+
+func checkTracking(context.Context) []Check {
+	if !tracking.IsCgoEnabled {
+		return []Check{{Category: "tracking", Name: "SQLite", Status: StatusWarn,
+			Detail: "built without CGO; progress tracking and resume are disabled"}}
+	}
+
+	dir, err := os.MkdirTemp("", "goanime-doctor-")
+	if err != nil {
+		return []Check{{Category: "tracking", Name: "SQLite", Status: StatusFail, Detail: err.Error()}}
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tracker := tracking.NewLocalTracker(filepath.Join(dir, "progress.db"))
+	if tracker == nil {
+		return []Check{{Category: "tracking", Name: "SQLite", Status: StatusFail,
+			Detail: "failed to open a test database"}}
+	}
+	_ = tracker.Close()
+	return []Check{{Category: "tracking", Name: "SQLite", Status: StatusOK, Detail: "CGO enabled, database opens"}}
+}
+
+func checkDiscord(context.Context) []Check {
+	endpoint, err := discord.FindIPCEndpoint()
+	if err != nil {
+		return []Check{{Category: "integrations", Name: "Discord IPC", Status: StatusWarn, Detail: err.Error()}}
+	}
+	return []Check{{Category: "integrations", Name: "Discord IPC", Status: StatusOK, Detail: endpoint}}
+}
+
+func checkConfig(context.Context) []Check {
+	path := config.Path()
+	if err := config.LoadError(); err != nil {
+		return []Check{{Category: "config", Name: "config.json", Status: StatusFail, Detail: err.Error()}}
+	}
+	if err := config.Get().Validate(); err != nil {
+		return []Check{{Category: "config", Name: "config.json", Status: StatusFail,
+			Detail: strings.ReplaceAll(err.Error(), "\n", "; ")}}
+	}
+	detail := path
+	if _, err := os.Stat(path); err != nil {
+		detail = path + " (not present, using defaults)"
+	}
+	checks := []Check{{Category: "config", Name: "config.json", Status: StatusOK, Detail: detail}}
+	if rejected := player.RejectedMPVArgs(config.Get().MPV.Args); len(rejected) > 0 {
+		checks = append(checks, Check{Category: "config", Name: "mpv.args", Status: StatusWarn,
+			Detail: "not on the allowlist, ignored: " + strings.Join(rejected, " ")})
+	}
+	if p := network.ProxyURL(""); p != "" {
+		checks = append(checks, Check{Category: "config", Name: "proxy", Status: StatusOK, Detail: redactProxy(p)})
+	}
+	return checks
+}
+
+func checkSources(ctx context.Context) []Check {
+	var checks []Check
+	for _, source := range []string{network.SourceAllAnime, network.SourceAnimeFire} {
+		set := mirrors.Get(source)
+		active := set.Active().Base
+		for _, res := range set.Probe(ctx) {
+			c := Check{Category: "sources", Name: fmt.Sprintf("%s %s", source, res.Mirror.Base)}
+			if res.Mirror.Base == active {
+				c.Name += " (active)"
+			}
+			if res.Err != nil {
+				c.Status = StatusFail
+				c.Detail = res.Err.Error()
+			} else {
+				c.Status = StatusOK
+				c.LatencyMS = res.Latency.Milliseconds()
+			}
+			checks = append(checks, c)
+		}
+	}
+	return checks
+}
+
+// checkCustomSources reports definition files that failed to load and whether
+// the site of each loaded one answers
+func checkCustomSources(ctx context.Context) []Check {
+	customs, errs := scraper.CustomSources()
+	checks := make([]Check, 0, len(customs)+len(errs))
+	for _, err := range errs {
+		checks = append(checks, Check{Category: "custom sources", Name: scraper.SourcesDir(), Status: StatusFail,
+			Detail: strings.ReplaceAll(err.Error(), "\n", "; ")})
+	}
+	for _, s := range customs {
+		def := s.Definition()
+		c := checkEndpoint(ctx, network.NewClient(def.ID, checkTimeout), def.ID, def.BaseURL)
+		c.Category = "custom sources"
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+func checkMetadataAPIs(ctx context.Context) []Check {
+	client := network.NewClient("", checkTimeout)
+	checks := make([]Check, len(metadataAPIs))
+	var wg sync.WaitGroup
+	for i, api := range metadataAPIs {
+		wg.Add(1)
+		go func(i int, name, target string) {
+			defer wg.Done()
+			checks[i] = checkEndpoint(ctx, client, name, target)
+		}(i, api.name, api.url)
+	}
+	wg.Wait()
+	return checks
+}
+
+// checkEndpoint treats any non-5xx answer as reachable: these APIs reject bare requests with 4xx
+func checkEndpoint(ctx context.Context, client *http.Client, name, target string) Check {
+	c := Check{Category: "metadata", Name: name}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		c.Status, c.Detail = StatusFail, err.Error()
+		return c
+	}
+	req.Header.Set("User-Agent", network.UserAgent)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		c.Status, c.Detail = StatusFail, err.Error()
+		return c
+	}
+	_ = resp.Body.Close()
+	c.LatencyMS = time.Since(start).Milliseconds()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		c.Status, c.Detail = StatusFail, resp.Status
+		return c
+	}
+	c.Status = StatusOK
+	return c
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return s
+}
+
+// redactProxy hides proxy credentials so reports can be shared safely
+func redactProxy(p string) string {
+	at := strings.LastIndex(p, "@")
+	scheme := strings.Index(p, "://")
+	if at < 0 || scheme < 0 || at < scheme {
+		return p
+	}
+	return p[:scheme+3] + "***" + p[at:]
+}
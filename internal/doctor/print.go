@@ -0,0 +1,68 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	headerStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#6366F1")).Bold(true).MarginLeft(2)
+	categoryStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#90EE90")).Bold(true).PaddingLeft(2)
+	nameStyle     = lipgloss.NewStyle().Bold(true)
+	detailStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#A9A9A9"))
+	okStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF7F")).Bold(true)
+	warnStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD700")).Bold(true)
+	failStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F5F")).Bold(true)
+)
+
+// Print writes a human-readable version of the report
+func (r *Report) Print(w io.Writer) {
+	var b strings.Builder
+
+	b.WriteString(headerStyle.Render(fmt.Sprintf("GoAnime v%s doctor (%s/%s)", r.Version, r.OS, r.Arch)))
+	b.WriteString("\n")
+
+	category := ""
+	for _, c := range r.Checks {
+		if c.Category != category {
+			category = c.Category
+			b.WriteString("\n")
+			b.WriteString(categoryStyle.Render(strings.ToUpper(category[:1]) + category[1:]))
+			b.WriteString("\n")
+		}
+
+		line := fmt.Sprintf("    %s %s", statusBadge(c.Status), nameStyle.Render(c.Name))
+		if c.LatencyMS > 0 {
+			line += detailStyle.Render(fmt.Sprintf(" %dms", c.LatencyMS))
+		}
+		if c.Detail != "" {
+			line += detailStyle.Render(" - " + c.Detail)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	if r.Failed() {
+		b.WriteString(failStyle.Render("  Some checks failed. Run `goanime doctor --json` and attach the output to a bug report."))
+	} else {
+		b.WriteString(okStyle.Render("  Everything looks good."))
+	}
+	b.WriteString("\n")
+
+	_, _ = fmt.Fprint(w, b.String())
+}
+
+func statusBadge(s Status) string {
+	switch s {
+	case StatusOK:
+		return okStyle.Render("[ OK ]")
+	case StatusWarn:
+		return warnStyle.Render("[WARN]")
+	default:
+		return failStyle.Render("[FAIL]")
+	}
+}
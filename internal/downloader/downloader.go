@@ -13,8 +13,11 @@ import (
 	"time"
 
 	"github.com/alvarorichard/Goanime/internal/api"
+	"github.com/alvarorichard/Goanime/internal/hls"
 	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/network"
 	"github.com/alvarorichard/Goanime/internal/player"
+	"github.com/alvarorichard/Goanime/internal/scraper"
 	"github.com/alvarorichard/Goanime/internal/util"
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
@@ -71,7 +74,7 @@ func (d *EpisodeDownloader) DownloadSingleEpisode(episodeNum int) error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	episodePath := filepath.Join(d.config.OutputDir, fmt.Sprintf("%d.mp4", episodeNum))
+	episodePath := d.episodePath(episode)
 
 	// Check if episode already exists
 	if d.fileExists(episodePath) {
@@ -104,12 +107,12 @@ func (d *EpisodeDownloader) DownloadEpisodeRange(startEp, endEp int) error {
 	var episodesToDownload []int
 	var existingEpisodes []int
 	for epNum := startEp; epNum <= endEp; epNum++ {
-		_, found := d.findEpisodeByNumber(epNum)
+		episode, found := d.findEpisodeByNumber(epNum)
 		if !found {
 			util.Warnf("Episode %d not found, skipping", epNum)
 			continue
 		}
-		episodePath := filepath.Join(d.config.OutputDir, fmt.Sprintf("%d.mp4", epNum))
+		episodePath := d.episodePath(episode)
 		if d.fileExists(episodePath) {
 			existingEpisodes = append(existingEpisodes, epNum)
 		} else {
@@ -159,7 +162,7 @@ func (d *EpisodeDownloader) downloadConcurrentWithProgress(episodeNums []int) er
 			continue
 		}
 
-		episodePath := filepath.Join(d.config.OutputDir, fmt.Sprintf("%d.mp4", epNum))
+		episodePath := d.episodePath(episode)
 
 		// Get content length
 		size, err := d.getContentLength(videoURL)
@@ -251,6 +254,7 @@ func (d *EpisodeDownloader) downloadMultipleWithProgress(episodeNums []int, epis
 				errChan <- fmt.Errorf("episode %d: download failed: %w", episodeNum, err)
 				return
 			}
+			player.SaveSubtitles(info.videoURL, info.path)
 
 			program.Send(statusMsg(fmt.Sprintf("Episode %d completed!", episodeNum)))
 		}(epNum, info)
@@ -371,6 +375,20 @@ func (d *EpisodeDownloader) findEpisodeByNumber(num int) (models.Episode, bool)
 	return models.Episode{}, false
 }
 
+// episodePath is where episode is saved in the output directory
+func (d *EpisodeDownloader) episodePath(episode models.Episode) string {
+	return episode.FilePath(d.config.OutputDir)
+}
+
+// episodePathByNumber is episodePath for an episode number, which may not be in the list
+func (d *EpisodeDownloader) episodePathByNumber(num int) string {
+	episode, found := d.findEpisodeByNumber(num)
+	if !found {
+		episode = models.Episode{Num: num}
+	}
+	return d.episodePath(episode)
+}
+
 func (d *EpisodeDownloader) fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return !os.IsNotExist(err)
@@ -421,18 +439,24 @@ func (d *EpisodeDownloader) getContentLength(url string) (int64, error) {
 		strings.Contains(url, "allanime.pro") ||
 		strings.Contains(url, "blogger.com")
 
-	// For streaming URLs that we know won't have Content-Length, return estimate immediately
-	if strings.Contains(url, ".m3u8") || strings.Contains(url, "master.m3u8") {
-		fmt.Println("HLS stream detected, using estimated size")
-		return 400 * 1024 * 1024, nil // 400MB estimate for HLS streams
-	}
-
 	// Simple HTTP HEAD request to get content length
 	httpClient := &http.Client{
 		Transport: api.SafeTransport(10 * time.Second),
 		Timeout:   10 * time.Second,
 	}
 
+	// HLS streams are sized from the playlist of the variant that will be downloaded
+	if strings.Contains(url, ".m3u8") {
+		est, err := hls.EstimateSize(context.Background(), httpClient, url, d.streamHeaders(url), hlsQuality())
+		if err == nil && est.Size > 0 {
+			fmt.Printf("HLS stream detected: %s, %s, ~%.0f MB\n", qualityLabel(est.Variant), est.Duration.Round(time.Second), float64(est.Size)/(1024*1024))
+			return est.Size, nil
+		}
+		fmt.Println("HLS stream detected, using estimated size")
+		util.Debugf("HLS size estimate failed: %v", err)
+		return 400 * 1024 * 1024, nil // 400MB estimate for HLS streams
+	}
+
 	req, err := http.NewRequest("HEAD", url, nil)
 	if err != nil {
 		if isAllAnimeURL {
@@ -443,8 +467,8 @@ func (d *EpisodeDownloader) getContentLength(url string) (int64, error) {
 	}
 
 	// Add referer for AllAnime URLs (like ani-cli does)
-	if isAllAnimeURL {
-		req.Header.Set("Referer", "https://allmanga.to")
+	for k, v := range d.streamHeaders(url) {
+		req.Header.Set(k, v)
 	}
 
 	resp, err := httpClient.Do(req)
@@ -473,6 +497,67 @@ func (d *EpisodeDownloader) getContentLength(url string) (int64, error) {
 	return strconv.ParseInt(contentLength, 10, 64)
 }
 
+// streamHeaders returns the headers the source reported for url, or the AllAnime
+// referer for hosts known to need it (like ani-cli does)
+func (d *EpisodeDownloader) streamHeaders(url string) map[string]string {
+	if headers := scraper.StreamHeaders(url); len(headers) > 0 {
+		return headers
+	}
+	isAllAnimeURL := strings.Contains(url, "sharepoint.com") ||
+		strings.Contains(url, "wixmp.com") ||
+		strings.Contains(url, ".m3u8") ||
+		strings.Contains(url, "allanime.pro") ||
+		strings.Contains(url, "blogger.com")
+	if !isAllAnimeURL {
+		return nil
+	}
+	return map[string]string{"Referer": "https://allmanga.to"}
+}
+
+// qualityLabel names an HLS variant for messages
+func qualityLabel(v hls.Variant) string {
+	if r := v.Resolution(); r != "" {
+		return r
+	}
+	return "single variant"
+}
+
+// hlsQuality is the variant of an HLS master that is measured and downloaded:
+// the -quality asked for, the best one otherwise
+func hlsQuality() string {
+	switch q := strings.ToLower(util.GlobalQuality); q {
+	case "", "hls":
+		return "best"
+	default:
+		return q
+	}
+}
+
+// pinHLSVariant resolves a master playlist to the variant getContentLength
+// measured, so the size estimate matches what is downloaded. A master whose
+// variant has separate audio is kept, with the yt-dlp format that selects the
+// variant and its audio.
+func (d *EpisodeDownloader) pinHLSVariant(videoURL string) (string, string) {
+	if !hls.IsPlaylistURL(videoURL) {
+		return videoURL, ""
+	}
+	client := &http.Client{Transport: api.SafeTransport(10 * time.Second), Timeout: 10 * time.Second}
+	pinned, v, err := hls.ResolveVariant(context.Background(), client, videoURL, d.streamHeaders(videoURL), hlsQuality())
+	if err != nil {
+		util.Debugf("Keeping HLS master playlist: %v", err)
+		return videoURL, ""
+	}
+	if pinned == videoURL {
+		if v.Height == 0 {
+			return videoURL, ""
+		}
+		util.Debugf("Keeping HLS master playlist for the separate audio of %s", qualityLabel(v))
+		return videoURL, fmt.Sprintf("bv*[height=%d]+ba/b[height=%d]", v.Height, v.Height)
+	}
+	util.Debugf("Pinned HLS variant %s: %s", qualityLabel(v), pinned)
+	return pinned, ""
+}
+
 // estimateContentLengthForAllAnime provides a fallback method to estimate content length for AllAnime URLs
 func (d *EpisodeDownloader) estimateContentLengthForAllAnime(url string, client *http.Client) (int64, error) {
 	// For streaming URLs (.m3u8), we can't get exact size, so return a reasonable estimate
@@ -588,6 +673,8 @@ func (d *EpisodeDownloader) downloadWithProgress(videoURL, episodePath string, e
 		return fmt.Errorf("download verification failed: file is too small (%d bytes)", stat.Size())
 	}
 
+	player.SaveSubtitles(videoURL, episodePath)
+
 	fmt.Printf("\nEpisode %d downloaded successfully!\n", episodeNum)
 	return d.promptPlayDownloaded(episodeNum, episodePath)
 }
@@ -605,19 +692,21 @@ func (d *EpisodeDownloader) downloadEpisodeWithProgress(videoURL, destPath strin
 	// For m3u8 streams (HLS) - use yt-dlp like ani-cli
 	if strings.Contains(videoURL, ".m3u8") || strings.Contains(videoURL, "master.m3u8") {
 		fmt.Println("Detected HLS stream, using yt-dlp download (ani-cli style)")
-		return d.downloadM3U8WithYtDlp(videoURL, destPath, progressModel, program)
+		headers := d.streamHeaders(videoURL)
+		pinned, format := d.pinHLSVariant(videoURL)
+		return d.downloadM3U8WithYtDlp(pinned, format, headers, destPath, progressModel, program)
 	}
 
 	// For wixmp.com URLs (common in AllAnime) - use yt-dlp
 	if strings.Contains(videoURL, "wixmp.com") || strings.Contains(videoURL, "repackager.wixmp.com") {
 		fmt.Println("Detected wixmp URL, using yt-dlp download")
-		return d.downloadM3U8WithYtDlp(videoURL, destPath, progressModel, program)
+		return d.downloadM3U8WithYtDlp(videoURL, "", d.streamHeaders(videoURL), destPath, progressModel, program)
 	}
 
 	// For blogger.com URLs - use yt-dlp
 	if strings.Contains(videoURL, "blogger.com") {
 		fmt.Println("Detected blogger URL, using yt-dlp download")
-		return d.downloadM3U8WithYtDlp(videoURL, destPath, progressModel, program)
+		return d.downloadM3U8WithYtDlp(videoURL, "", d.streamHeaders(videoURL), destPath, progressModel, program)
 	}
 
 	// For sharepoint URLs (AllAnime) - try HTTP first, fallback to yt-dlp
@@ -626,7 +715,7 @@ func (d *EpisodeDownloader) downloadEpisodeWithProgress(videoURL, destPath strin
 		err := d.downloadHTTPWithProgress(videoURL, destPath, progressModel, program)
 		if err != nil {
 			fmt.Printf("HTTP download failed: %v, trying yt-dlp fallback\n", err)
-			return d.downloadM3U8WithYtDlp(videoURL, destPath, progressModel, program)
+			return d.downloadM3U8WithYtDlp(videoURL, "", d.streamHeaders(videoURL), destPath, progressModel, program)
 		}
 		return nil
 	}
@@ -634,7 +723,7 @@ func (d *EpisodeDownloader) downloadEpisodeWithProgress(videoURL, destPath strin
 	// For any AllAnime URL, try yt-dlp as default
 	if strings.Contains(videoURL, "allanime") || strings.Contains(videoURL, "allmanga") {
 		fmt.Println("Detected AllAnime URL, using yt-dlp download")
-		return d.downloadM3U8WithYtDlp(videoURL, destPath, progressModel, program)
+		return d.downloadM3U8WithYtDlp(videoURL, "", d.streamHeaders(videoURL), destPath, progressModel, program)
 	}
 
 	// For regular MP4 URLs - use HTTP download
@@ -745,8 +834,10 @@ func (d *EpisodeDownloader) downloadHTTPWithProgress(videoURL, destPath string,
 	return nil
 }
 
-// downloadM3U8WithYtDlp downloads m3u8/HLS streams using go-ytdlp library
-func (d *EpisodeDownloader) downloadM3U8WithYtDlp(videoURL, destPath string, progressModel *progressModel, program *tea.Program) error {
+// downloadM3U8WithYtDlp downloads m3u8/HLS streams using go-ytdlp library, sending
+// headers to the host (the URL may be a pinned variant the source never reported)
+// and selecting format when it is not empty
+func (d *EpisodeDownloader) downloadM3U8WithYtDlp(videoURL, format string, headers map[string]string, destPath string, progressModel *progressModel, program *tea.Program) error {
 	program.Send(statusMsg("Starting yt-dlp download (using go-ytdlp library)..."))
 
 	// Create directory if it doesn't exist
@@ -791,6 +882,16 @@ func (d *EpisodeDownloader) downloadM3U8WithYtDlp(videoURL, destPath string, pro
 	// Configure downloader using the basic API that we know works
 	dl := ytdlp.New().
 		Output(destPath) // -o destPath
+	if format != "" {
+		dl = dl.Format(format)
+	}
+	if proxy := scraper.StreamProxy(videoURL); proxy != "" {
+		dl = dl.Proxy(proxy)
+	}
+	if cookies := network.CookiesFile(); cookies != "" {
+		dl = dl.Cookies(cookies)
+	}
+	dl = player.YtDlpHeaders(dl, headers)
 
 	// Execute download
 	_, err := dl.Run(ctx, videoURL)
@@ -852,6 +953,13 @@ func (d *EpisodeDownloader) downloadWithYtDlp(url, path string) error {
 	// Configure downloader
 	dl := ytdlp.New().
 		Output(path) // -o path
+	if proxy := scraper.StreamProxy(url); proxy != "" {
+		dl = dl.Proxy(proxy)
+	}
+	if cookies := network.CookiesFile(); cookies != "" {
+		dl = dl.Cookies(cookies)
+	}
+	dl = player.YtDlpHeaders(dl, d.streamHeaders(url))
 
 	fmt.Printf("Running go-ytdlp for: %s\n", url)
 
@@ -925,8 +1033,7 @@ func (d *EpisodeDownloader) promptPlayDownloadedRangeHuh(episodeNums []int) erro
 	// Check if choice is in the downloaded episodes
 	for _, epNum := range episodeNums {
 		if epNum == choice {
-			episodePath := filepath.Join(d.config.OutputDir, fmt.Sprintf("%d.mp4", epNum))
-			return d.playEpisode(episodePath, epNum)
+			return d.playEpisode(d.episodePathByNumber(epNum), epNum)
 		}
 	}
 
@@ -956,8 +1063,7 @@ func (d *EpisodeDownloader) promptPlayExistingRangeHuh(episodeNums []int) error
 	// Check if choice is in the existing episodes
 	for _, epNum := range episodeNums {
 		if epNum == choice {
-			episodePath := filepath.Join(d.config.OutputDir, fmt.Sprintf("%d.mp4", epNum))
-			return d.playEpisode(episodePath, epNum)
+			return d.playEpisode(d.episodePathByNumber(epNum), epNum)
 		}
 	}
 
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/mirrors"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// cookieSources are the sources with their own cookie jar: the built-in ones
+// and every custom source in the config dir
+func cookieSources() []string {
+	sources := []string{network.SourceAllAnime, network.SourceAnimeFire}
+	customs, _ := scraper.CustomSources()
+	for _, c := range customs {
+		sources = append(sources, c.Definition().ID)
+	}
+	return sources
+}
+
+// HandleCookiesRequest runs `goanime cookies import|list|clear`
+func HandleCookiesRequest() error {
+	util.InitLogger()
+
+	args := util.GlobalCookiesArgs
+	switch args[0] {
+	case "import":
+		return importCookies(args[1])
+	case "list":
+		listCookies()
+		return nil
+	default:
+		return clearCookies(args[1:])
+	}
+}
+
+// importCookies stores the cookies of a Netscape cookies.txt file. With -source every
+// cookie goes to that source; otherwise cookies are sorted by mirror domain and the
+// rest (e.g. video CDNs) are kept in the shared jar that mpv and yt-dlp also receive.
+func importCookies(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open cookies file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	entries, err := network.ParseCookiesTxt(f)
+	if err != nil {
+		return fmt.Errorf("failed to read cookies file: %w", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no cookies found in %s (expected the Netscape cookies.txt format)", path)
+	}
+
+	forced := strings.ToLower(util.GlobalSource)
+	if forced != "" && forced != network.SourceAllAnime && forced != network.SourceAnimeFire {
+		custom, ok := scraper.CustomSource(forced)
+		if !ok {
+			return fmt.Errorf("unknown source %q", util.GlobalSource)
+		}
+		forced = custom.Definition().ID
+	}
+
+	bySource := map[string][]network.CookieEntry{}
+	now := time.Now()
+	skipped := 0
+	for _, e := range entries {
+		if !e.Expires.IsZero() && e.Expires.Before(now) {
+			skipped++
+			continue
+		}
+		source := forced
+		if source == "" {
+			source = cookieSourceFor(e.Domain)
+		}
+		bySource[source] = append(bySource[source], e)
+	}
+
+	for _, source := range sortedSourceKeys(bySource) {
+		network.SourceJar(source).Add(bySource[source]...)
+		util.Infof("Imported %d cookies for %s", len(bySource[source]), jarLabel(source))
+	}
+	if skipped > 0 {
+		util.Warnf("Skipped %d expired cookies", skipped)
+	}
+	return nil
+}
+
+// cookieSourceFor returns the source whose mirrors serve domain, or "" for the shared jar
+func cookieSourceFor(domain string) string {
+	host := "https://" + strings.TrimPrefix(strings.ToLower(domain), ".")
+	for _, source := range []string{network.SourceAllAnime, network.SourceAnimeFire} {
+		if mirrors.MatchesHost(source, host) {
+			return source
+		}
+	}
+	customs, _ := scraper.CustomSources()
+	for _, c := range customs {
+		if u, err := url.Parse(c.Definition().BaseURL); err == nil && sameSite(u.Hostname(), host) {
+			return c.Definition().ID
+		}
+	}
+	return ""
+}
+
+func listCookies() {
+	for _, source := range append(cookieSources(), "") {
+		entries := network.SourceJar(source).Entries()
+		if len(entries) == 0 {
+			fmt.Printf("%s: no cookies\n", jarLabel(source))
+			continue
+		}
+		domains := map[string]int{}
+		var firstExpiry time.Time
+		for _, e := range entries {
+			domains[strings.TrimPrefix(e.Domain, ".")]++
+			if !e.Expires.IsZero() && (firstExpiry.IsZero() || e.Expires.Before(firstExpiry)) {
+				firstExpiry = e.Expires
+			}
+		}
+		fmt.Printf("%s: %d cookies\n", jarLabel(source), len(entries))
+		for _, d := range sortedSourceKeys(domains) {
+			fmt.Printf("  %s (%d)\n", d, domains[d])
+		}
+		if !firstExpiry.IsZero() {
+			fmt.Printf("  first expiry: %s\n", firstExpiry.Local().Format(time.RFC1123))
+		}
+	}
+	fmt.Printf("Stored in %s\n", network.CookieDir())
+}
+
+func clearCookies(args []string) error {
+	sources := append(cookieSources(), "")
+	if len(args) == 1 {
+		sources = []string{strings.ToLower(args[0])}
+		if sources[0] == "shared" {
+			sources[0] = ""
+		}
+	}
+	for _, source := range sources {
+		if err := network.SourceJar(source).Clear(); err != nil {
+			return fmt.Errorf("failed to clear cookies for %s: %w", jarLabel(source), err)
+		}
+		util.Infof("Cleared cookies for %s", jarLabel(source))
+	}
+	return nil
+}
+
+// sameSite reports whether the cookie host is siteHost or one of its subdomains, ignoring "www."
+func sameSite(siteHost, cookieURL string) bool {
+	u, err := url.Parse(cookieURL)
+	if err != nil {
+		return false
+	}
+	site := strings.TrimPrefix(strings.ToLower(siteHost), "www.")
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	return host == site || strings.HasSuffix(host, "."+site)
+}
+
+func jarLabel(source string) string {
+	if source == "" {
+		return "shared (video hosts and other sites)"
+	}
+	return source
+}
+
+func sortedSourceKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/alvarorichard/Goanime/internal/doctor"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// HandleDoctorRequest runs the environment diagnostics and prints the report
+func HandleDoctorRequest() error {
+	util.InitLogger()
+
+	if !util.GlobalDoctorJSON {
+		fmt.Println("Running diagnostics, this may take a few seconds...")
+	}
+
+	report := doctor.Run(context.Background())
+	if util.GlobalDoctorJSON {
+		if err := report.WriteJSON(os.Stdout); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	} else {
+		report.Print(os.Stdout)
+	}
+
+	if report.Failed() {
+		return errors.New("one or more doctor checks failed")
+	}
+	return nil
+}
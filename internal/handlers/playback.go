@@ -5,6 +5,7 @@ import (
 
 	"github.com/alvarorichard/Goanime/internal/appflow"
 	"github.com/alvarorichard/Goanime/internal/discord"
+	"github.com/alvarorichard/Goanime/internal/models"
 	"github.com/alvarorichard/Goanime/internal/playback"
 	"github.com/alvarorichard/Goanime/internal/tracking"
 	"github.com/alvarorichard/Goanime/internal/util"
@@ -13,6 +14,19 @@ import (
 
 // HandlePlaybackMode processes normal anime playback
 func HandlePlaybackMode(animeName string) {
+	runPlayback("search", func() (*models.Anime, error) {
+		// Use enhanced search with retry logic
+		return appflow.SearchAnimeWithRetry(animeName)
+	})
+}
+
+// HandleBrowseRequest lets the user pick an anime from a browse list and plays it
+func HandleBrowseRequest() {
+	runPlayback("browse", appflow.BrowseAnime)
+}
+
+// runPlayback sets up logging and Discord, lets pick choose the anime and plays it
+func runPlayback(action string, pick func() (*models.Anime, error)) {
 	startAll := time.Now()
 
 	// Initialize the beautiful logger
@@ -28,10 +42,9 @@ func HandlePlaybackMode(animeName string) {
 		defer discordManager.Shutdown()
 	}
 
-	// Use enhanced search with retry logic
-	anime, err := appflow.SearchAnimeWithRetry(animeName)
+	anime, err := pick()
 	if err != nil {
-		util.Errorf("Failed to search for anime: %v", err)
+		util.Errorf("Failed to %s for anime: %v", action, err)
 		return
 	}
 
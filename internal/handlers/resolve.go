@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/alvarorichard/Goanime/internal/appflow"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/playback"
+	"github.com/alvarorichard/Goanime/internal/player"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/streamproxy"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// HandleResolveRequest runs `goanime resolve <anime>`: the anime and episode
+// are picked as for playback and the stream URL is printed instead of played.
+// With -stream-proxy the URL is a local one that sends the headers the host
+// expects, for players that cannot, and it is served until Ctrl+C.
+func HandleResolveRequest() error {
+	util.InitLogger()
+
+	anime, err := appflow.SearchAnimeWithRetry(util.GlobalResolveQuery)
+	if err != nil {
+		return fmt.Errorf("failed to search for anime: %w", err)
+	}
+	appflow.FetchAnimeDetails(anime)
+	episodes := appflow.GetAnimeEpisodes(anime)
+
+	episode := episodes[0]
+	if len(episodes) > 1 {
+		_, number, _, err := playback.SelectInitialEpisode(episodes)
+		if err != nil {
+			return fmt.Errorf("failed to select episode: %w", err)
+		}
+		for _, ep := range episodes {
+			if ep.Number == number {
+				episode = ep
+				break
+			}
+		}
+	}
+
+	streamURL, err := player.GetVideoURLForEpisodeEnhanced(&episode, anime)
+	if err != nil {
+		return fmt.Errorf("failed to get video URL: %w", err)
+	}
+	headers := scraper.StreamHeaders(streamURL)
+
+	if util.GlobalStreamProxy == "" {
+		fmt.Println(streamURL)
+		// The headers go to stderr so the URL can be piped to a player
+		h := network.StreamHeaders(headers)
+		names := make([]string, 0, len(h))
+		for name := range h {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, h[name])
+		}
+		return nil
+	}
+
+	proxy, err := streamproxy.Shared(util.GlobalStreamProxy)
+	if err != nil {
+		return err
+	}
+	fmt.Println(proxy.URL(streamURL, scraper.StreamSource(streamURL), headers))
+	fmt.Fprintln(os.Stderr, "Serving the stream, press Ctrl+C to stop")
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	return proxy.Close()
+}
@@ -0,0 +1,158 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/network"
+)
+
+// maxPlaylistSize bounds how much of a playlist is read
+const maxPlaylistSize = 8 << 20
+
+// IsPlaylistURL reports whether rawURL looks like an HLS playlist
+func IsPlaylistURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return strings.Contains(rawURL, ".m3u8")
+	}
+	return strings.HasSuffix(strings.ToLower(u.Path), ".m3u8")
+}
+
+// Fetch downloads and parses the playlist at rawURL
+func Fetch(ctx context.Context, client *http.Client, rawURL string, headers map[string]string) (*Playlist, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", network.UserAgent)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("playlist request returned %s", resp.Status)
+	}
+	// Redirects change the base of relative URIs
+	return Parse(io.LimitReader(resp.Body, maxPlaylistSize), resp.Request.URL.String())
+}
+
+// Estimate describes the size of an HLS stream
+type Estimate struct {
+	// Variant is the rendition the estimate is for; zero for a media playlist URL
+	Variant  Variant
+	Duration time.Duration
+	Size     int64
+	// Exact is true when the size comes from byte ranges rather than the bitrate
+	Exact bool
+}
+
+// EstimateSize resolves rawURL to the media playlist for quality (see Playlist.Variant)
+// and estimates its size: the byte ranges when the playlist has them, otherwise
+// the variant bitrate over the duration, otherwise the size of the first segment
+// scaled to the whole stream.
+func EstimateSize(ctx context.Context, client *http.Client, rawURL string, headers map[string]string, quality string) (Estimate, error) {
+	playlist, err := Fetch(ctx, client, rawURL, headers)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	var est Estimate
+	if playlist.IsMaster() {
+		v, ok := playlist.Variant(quality)
+		if !ok {
+			return Estimate{}, fmt.Errorf("no %s variant in playlist", quality)
+		}
+		est.Variant = v
+		if playlist, err = Fetch(ctx, client, v.URI, headers); err != nil {
+			return Estimate{}, fmt.Errorf("variant playlist: %w", err)
+		}
+		if playlist.IsMaster() {
+			return Estimate{}, fmt.Errorf("variant playlist is a master playlist")
+		}
+	}
+	if len(playlist.Segments) == 0 {
+		return Estimate{}, fmt.Errorf("playlist has no segments")
+	}
+	est.Duration = playlist.Duration()
+
+	var ranged int64
+	for _, s := range playlist.Segments {
+		ranged += s.Length
+	}
+	switch {
+	case playlist.Segments[0].Length > 0:
+		est.Size, est.Exact = ranged, true
+	case est.Variant.bitrate() > 0:
+		est.Size = int64(float64(est.Variant.bitrate()) / 8 * est.Duration.Seconds())
+	default:
+		first := playlist.Segments[0]
+		length, err := contentLength(ctx, client, first.URI, headers)
+		if err != nil {
+			return Estimate{}, fmt.Errorf("first segment: %w", err)
+		}
+		if first.Duration <= 0 {
+			return Estimate{}, fmt.Errorf("first segment has no duration")
+		}
+		est.Size = int64(float64(length) * est.Duration.Seconds() / first.Duration.Seconds())
+	}
+	return est, nil
+}
+
+// contentLength asks for the size of rawURL with a HEAD request
+func contentLength(ctx context.Context, client *http.Client, rawURL string, headers map[string]string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", network.UserAgent)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("segment request returned %s", resp.Status)
+	}
+	n, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("segment has no Content-Length")
+	}
+	return n, nil
+}
+
+// ResolveVariant pins rawURL to the media playlist for quality when it is a
+// master playlist; other URLs are returned unchanged. A master whose variant
+// plays its audio from separate renditions is kept too, as the variant's
+// playlist alone would download without sound; the variant is still returned
+// for the caller to select it.
+func ResolveVariant(ctx context.Context, client *http.Client, rawURL string, headers map[string]string, quality string) (string, Variant, error) {
+	playlist, err := Fetch(ctx, client, rawURL, headers)
+	if err != nil {
+		return "", Variant{}, err
+	}
+	if !playlist.IsMaster() {
+		return rawURL, Variant{}, nil
+	}
+	v, ok := playlist.Variant(quality)
+	if !ok {
+		return "", Variant{}, fmt.Errorf("no %s variant in playlist", quality)
+	}
+	if playlist.SeparateAudio(v) {
+		return rawURL, v, nil
+	}
+	return v.URI, v, nil
+}
@@ -0,0 +1,102 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func playlistServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	media := "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10,\ns1.ts\n#EXTINF:10,\ns2.ts\n#EXTINF:5,\ns3.ts\n#EXT-X-ENDLIST\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != "https://allanime.to" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/master.m3u8":
+			_, _ = fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\n360.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=4000000,AVERAGE-BANDWIDTH=3200000,RESOLUTION=1920x1080\n1080.m3u8\n")
+		case "/dubbed.m3u8":
+			_, _ = fmt.Fprint(w, "#EXTM3U\n#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"a\",NAME=\"English\",URI=\"en.m3u8\"\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,AUDIO=\"a\"\n360.m3u8\n")
+		case "/360.m3u8", "/1080.m3u8", "/media.m3u8":
+			_, _ = fmt.Fprint(w, media)
+		case "/ranged.m3u8":
+			_, _ = fmt.Fprint(w, "#EXTM3U\n#EXTINF:10,\n#EXT-X-BYTERANGE:4000@0\nall.ts\n#EXTINF:10,\n#EXT-X-BYTERANGE:6000\nall.ts\n")
+		case "/s1.ts":
+			w.Header().Set("Content-Length", "2000000")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEstimateSize(t *testing.T) {
+	t.Parallel()
+
+	srv := playlistServer(t)
+	headers := map[string]string{"Referer": "https://allanime.to"}
+	ctx := context.Background()
+
+	est, err := EstimateSize(ctx, srv.Client(), srv.URL+"/master.m3u8", headers, "best")
+	require.NoError(t, err)
+	assert.Equal(t, "1080p", est.Variant.Resolution())
+	assert.Equal(t, 25*time.Second, est.Duration)
+	assert.Equal(t, int64(3200000/8*25), est.Size, "the average bandwidth is preferred")
+	assert.False(t, est.Exact)
+
+	est, err = EstimateSize(ctx, srv.Client(), srv.URL+"/master.m3u8", headers, "360p")
+	require.NoError(t, err)
+	assert.Equal(t, int64(800000/8*25), est.Size)
+
+	est, err = EstimateSize(ctx, srv.Client(), srv.URL+"/ranged.m3u8", headers, "best")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000), est.Size)
+	assert.True(t, est.Exact)
+
+	est, err = EstimateSize(ctx, srv.Client(), srv.URL+"/media.m3u8", headers, "best")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2000000*25/10), est.Size, "the first segment is scaled to the duration")
+
+	_, err = EstimateSize(ctx, srv.Client(), srv.URL+"/master.m3u8", nil, "best")
+	assert.ErrorContains(t, err, "403")
+	_, err = EstimateSize(ctx, srv.Client(), srv.URL+"/master.m3u8", headers, "720p")
+	assert.ErrorContains(t, err, "no 720p variant")
+}
+
+func TestResolveVariant(t *testing.T) {
+	t.Parallel()
+
+	srv := playlistServer(t)
+	headers := map[string]string{"Referer": "https://allanime.to"}
+
+	pinned, v, err := ResolveVariant(context.Background(), srv.Client(), srv.URL+"/master.m3u8", headers, "worst")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/360.m3u8", pinned)
+	assert.Equal(t, 360, v.Height)
+
+	pinned, _, err = ResolveVariant(context.Background(), srv.Client(), srv.URL+"/media.m3u8", headers, "best")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/media.m3u8", pinned, "media playlists are already pinned")
+
+	pinned, v, err = ResolveVariant(context.Background(), srv.Client(), srv.URL+"/dubbed.m3u8", headers, "best")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/dubbed.m3u8", pinned, "the video playlist alone would have no sound")
+	assert.Equal(t, 360, v.Height)
+}
+
+func TestIsPlaylistURL(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, IsPlaylistURL("https://a.example/x/master.m3u8?token=1"))
+	assert.True(t, IsPlaylistURL("https://a.example/x/INDEX.M3U8"))
+	assert.False(t, IsPlaylistURL("https://a.example/x/ep1.mp4?list=.m3u8"))
+}
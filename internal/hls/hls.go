@@ -0,0 +1,361 @@
+// Package hls parses HLS master and media playlists
+package hls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Variant is one #EXT-X-STREAM-INF entry of a master playlist
+type Variant struct {
+	// URI is absolute when the playlist was parsed with a base URL
+	URI              string
+	Bandwidth        int64
+	AverageBandwidth int64
+	Width            int
+	Height           int
+	Codecs           string
+	FrameRate        float64
+	// Audio is the GROUP-ID of the audio renditions played with the variant
+	Audio string
+}
+
+// Resolution returns "1080p" style labels, "" when the variant has no RESOLUTION
+func (v Variant) Resolution() string {
+	if v.Height == 0 {
+		return ""
+	}
+	return strconv.Itoa(v.Height) + "p"
+}
+
+// bitrate is the bandwidth used for size estimates, in bits per second
+func (v Variant) bitrate() int64 {
+	if v.AverageBandwidth > 0 {
+		return v.AverageBandwidth
+	}
+	return v.Bandwidth
+}
+
+// Media is an #EXT-X-MEDIA rendition of a master playlist
+type Media struct {
+	Type     string // AUDIO, SUBTITLES, VIDEO or CLOSED-CAPTIONS
+	GroupID  string
+	Name     string
+	Language string
+	Default  bool
+	// URI is empty when the rendition is muxed into the variant streams
+	URI string
+}
+
+// Key is an #EXT-X-KEY tag; Method "NONE" or "" means segments are not encrypted
+type Key struct {
+	Method string
+	URI    string
+	IV     string
+}
+
+// Segment is one media segment of a media playlist
+type Segment struct {
+	URI      string
+	Duration time.Duration
+	Title    string
+	// Key is the encryption in effect for the segment, nil when there is none
+	Key *Key
+	// Length and Offset come from #EXT-X-BYTERANGE; Length is 0 without one
+	Length int64
+	Offset int64
+}
+
+// Playlist is a parsed master playlist (Variants set) or media playlist (Segments set)
+type Playlist struct {
+	Variants       []Variant
+	Media          []Media
+	TargetDuration time.Duration
+	MediaSequence  int
+	Segments       []Segment
+	// Ended is true when the media playlist has #EXT-X-ENDLIST (VOD)
+	Ended bool
+}
+
+// IsMaster reports whether the playlist lists variants rather than segments
+func (p *Playlist) IsMaster() bool {
+	return len(p.Variants) > 0
+}
+
+// Duration is the sum of the segment durations
+func (p *Playlist) Duration() time.Duration {
+	var d time.Duration
+	for _, s := range p.Segments {
+		d += s.Duration
+	}
+	return d
+}
+
+// Encrypted reports whether any segment uses a key
+func (p *Playlist) Encrypted() bool {
+	for _, s := range p.Segments {
+		if s.Key != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SeparateAudio reports whether v plays its audio from renditions of their
+// own, so that its media playlist alone has no sound
+func (p *Playlist) SeparateAudio(v Variant) bool {
+	if v.Audio == "" {
+		return false
+	}
+	for _, m := range p.Media {
+		if m.Type == "AUDIO" && m.GroupID == v.Audio && m.URI != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Variant picks a variant for quality: "best" (or ""), "worst", or a resolution
+// such as "720p". Variants are compared by height, then bandwidth.
+func (p *Playlist) Variant(quality string) (Variant, bool) {
+	if len(p.Variants) == 0 {
+		return Variant{}, false
+	}
+	ordered := append([]Variant(nil), p.Variants...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Height != ordered[j].Height {
+			return ordered[i].Height > ordered[j].Height
+		}
+		return ordered[i].Bandwidth > ordered[j].Bandwidth
+	})
+
+	switch strings.ToLower(quality) {
+	case "", "best":
+		return ordered[0], true
+	case "worst":
+		return ordered[len(ordered)-1], true
+	}
+	want := strings.TrimSuffix(strings.ToLower(quality), "p") + "p"
+	for _, v := range ordered {
+		if v.Resolution() == want {
+			return v, true
+		}
+	}
+	return Variant{}, false
+}
+
+// Parse reads a playlist. Relative URIs are resolved against baseURL when it is not empty.
+func Parse(r io.Reader, baseURL string) (*Playlist, error) {
+	var base *url.URL
+	if baseURL != "" {
+		var err error
+		if base, err = url.Parse(baseURL); err != nil {
+			return nil, fmt.Errorf("invalid playlist URL: %w", err)
+		}
+	}
+	resolve := func(ref string) string {
+		if base == nil {
+			return ref
+		}
+		u, err := base.Parse(ref)
+		if err != nil {
+			return ref
+		}
+		return u.String()
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	p := &Playlist{}
+	var (
+		header     bool
+		pendingVar *Variant
+		pendingSeg *Segment
+		key        *Key
+		nextOffset int64
+	)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" {
+			continue
+		}
+		if !header {
+			if line != "#EXTM3U" {
+				return nil, fmt.Errorf("not an HLS playlist")
+			}
+			header = true
+			continue
+		}
+
+		if !strings.HasPrefix(line, "#") {
+			switch {
+			case pendingVar != nil:
+				pendingVar.URI = resolve(line)
+				p.Variants = append(p.Variants, *pendingVar)
+				pendingVar = nil
+			case pendingSeg != nil:
+				pendingSeg.URI = resolve(line)
+				pendingSeg.Key = key
+				p.Segments = append(p.Segments, *pendingSeg)
+				pendingSeg = nil
+			}
+			continue
+		}
+
+		tag, value, _ := strings.Cut(line, ":")
+		switch tag {
+		case "#EXT-X-STREAM-INF":
+			v, err := parseVariant(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			pendingVar = &v
+		case "#EXT-X-MEDIA":
+			attrs := parseAttributes(value)
+			m := Media{
+				Type:     attrs["TYPE"],
+				GroupID:  attrs["GROUP-ID"],
+				Name:     attrs["NAME"],
+				Language: attrs["LANGUAGE"],
+				Default:  attrs["DEFAULT"] == "YES",
+			}
+			if attrs["URI"] != "" {
+				m.URI = resolve(attrs["URI"])
+			}
+			p.Media = append(p.Media, m)
+		case "#EXTINF":
+			durStr, title, _ := strings.Cut(value, ",")
+			secs, err := strconv.ParseFloat(strings.TrimSpace(durStr), 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid segment duration %q", lineNo, durStr)
+			}
+			if pendingSeg == nil {
+				pendingSeg = &Segment{}
+			}
+			pendingSeg.Duration = time.Duration(secs * float64(time.Second))
+			pendingSeg.Title = title
+		case "#EXT-X-BYTERANGE":
+			length, offset, err := parseByteRange(value, nextOffset)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if pendingSeg == nil {
+				pendingSeg = &Segment{}
+			}
+			pendingSeg.Length, pendingSeg.Offset = length, offset
+			nextOffset = offset + length
+		case "#EXT-X-KEY":
+			attrs := parseAttributes(value)
+			if method := attrs["METHOD"]; method == "" || method == "NONE" {
+				key = nil
+			} else {
+				k := Key{Method: method, IV: attrs["IV"]}
+				if attrs["URI"] != "" {
+					k.URI = resolve(attrs["URI"])
+				}
+				key = &k
+			}
+		case "#EXT-X-TARGETDURATION":
+			secs, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid target duration %q", lineNo, value)
+			}
+			p.TargetDuration = time.Duration(secs) * time.Second
+		case "#EXT-X-MEDIA-SEQUENCE":
+			seq, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid media sequence %q", lineNo, value)
+			}
+			p.MediaSequence = seq
+		case "#EXT-X-ENDLIST":
+			p.Ended = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, fmt.Errorf("not an HLS playlist")
+	}
+	return p, nil
+}
+
+// parseVariant reads the attributes of #EXT-X-STREAM-INF
+func parseVariant(value string) (Variant, error) {
+	attrs := parseAttributes(value)
+	var v Variant
+	var err error
+	if v.Bandwidth, err = strconv.ParseInt(attrs["BANDWIDTH"], 10, 64); err != nil {
+		return Variant{}, fmt.Errorf("invalid BANDWIDTH %q", attrs["BANDWIDTH"])
+	}
+	if s := attrs["AVERAGE-BANDWIDTH"]; s != "" {
+		v.AverageBandwidth, _ = strconv.ParseInt(s, 10, 64)
+	}
+	if s := attrs["RESOLUTION"]; s != "" {
+		w, h, ok := strings.Cut(strings.ToLower(s), "x")
+		if !ok {
+			return Variant{}, fmt.Errorf("invalid RESOLUTION %q", s)
+		}
+		v.Width, _ = strconv.Atoi(w)
+		v.Height, _ = strconv.Atoi(h)
+	}
+	if s := attrs["FRAME-RATE"]; s != "" {
+		v.FrameRate, _ = strconv.ParseFloat(s, 64)
+	}
+	v.Codecs = attrs["CODECS"]
+	v.Audio = attrs["AUDIO"]
+	return v, nil
+}
+
+// parseByteRange reads "<n>[@<o>]"; without an offset the range follows the previous one
+func parseByteRange(value string, next int64) (int64, int64, error) {
+	lengthStr, offsetStr, hasOffset := strings.Cut(value, "@")
+	length, err := strconv.ParseInt(lengthStr, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid byte range %q", value)
+	}
+	offset := next
+	if hasOffset {
+		if offset, err = strconv.ParseInt(offsetStr, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid byte range %q", value)
+		}
+	}
+	return length, offset, nil
+}
+
+// parseAttributes reads an attribute list such as `BANDWIDTH=1,CODECS="a,b"`
+func parseAttributes(s string) map[string]string {
+	attrs := map[string]string{}
+	for s != "" {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		name = strings.TrimSpace(name)
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[name] = value
+		s = rest
+	}
+	return attrs
+}
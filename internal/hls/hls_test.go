@@ -0,0 +1,122 @@
+package hls
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFile(t *testing.T, name, base string) *Playlist {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	p, err := Parse(f, base)
+	require.NoError(t, err)
+	return p
+}
+
+func TestParseMasterPlaylist(t *testing.T) {
+	t.Parallel()
+
+	p := parseFile(t, "master.m3u8", "https://cdn.example.net/show/ep1/master.m3u8")
+	require.True(t, p.IsMaster())
+	require.Len(t, p.Variants, 3)
+
+	assert.Equal(t, Variant{
+		URI:              "https://cdn.example.net/show/ep1/480/index.m3u8",
+		Bandwidth:        1400000,
+		AverageBandwidth: 1200000,
+		Width:            854,
+		Height:           480,
+		Codecs:           "avc1.4d401e,mp4a.40.2",
+		FrameRate:        23.976,
+		Audio:            "aud",
+	}, p.Variants[0], "quoted attributes keep their commas")
+	assert.Equal(t, "https://cdn.example.net/1080/index.m3u8", p.Variants[1].URI)
+
+	assert.Equal(t, []Media{{
+		Type:     "AUDIO",
+		GroupID:  "aud",
+		Name:     "Japanese",
+		Language: "ja",
+		Default:  true,
+		URI:      "https://cdn.example.net/show/ep1/audio/ja.m3u8",
+	}}, p.Media)
+	assert.True(t, p.SeparateAudio(p.Variants[0]), "480p plays the Japanese rendition")
+	assert.False(t, p.SeparateAudio(p.Variants[1]), "1080p has its audio muxed in")
+
+	tests := []struct {
+		quality string
+		want    string
+	}{
+		{"best", "1080p"},
+		{"", "1080p"},
+		{"worst", "480p"},
+		{"720p", "720p"},
+		{"720", "720p"},
+	}
+	for _, tt := range tests {
+		v, ok := p.Variant(tt.quality)
+		require.True(t, ok, tt.quality)
+		assert.Equal(t, tt.want, v.Resolution(), tt.quality)
+	}
+	_, ok := p.Variant("360p")
+	assert.False(t, ok)
+}
+
+func TestParseMediaPlaylist(t *testing.T) {
+	t.Parallel()
+
+	p := parseFile(t, "media.m3u8", "https://cdn.example.net/show/ep1/720/index.m3u8")
+	require.False(t, p.IsMaster())
+	require.Len(t, p.Segments, 3)
+
+	assert.Equal(t, 10*time.Second, p.TargetDuration)
+	assert.Equal(t, 7, p.MediaSequence)
+	assert.True(t, p.Ended)
+	assert.Equal(t, 24500*time.Millisecond, p.Duration())
+	assert.True(t, p.Encrypted())
+
+	assert.Nil(t, p.Segments[0].Key)
+	assert.Equal(t, &Key{
+		Method: "AES-128",
+		URI:    "https://cdn.example.net/show/ep1/720/key.bin",
+		IV:     "0x0000000000000000000000000000000a",
+	}, p.Segments[1].Key)
+	assert.Nil(t, p.Segments[2].Key, "METHOD=NONE ends encryption")
+	assert.Equal(t, "credits", p.Segments[2].Title)
+	assert.Equal(t, "https://cdn.example.net/show/ep1/720/seg-9.ts", p.Segments[2].URI)
+}
+
+func TestParseByteRanges(t *testing.T) {
+	t.Parallel()
+
+	p, err := Parse(strings.NewReader("#EXTM3U\n#EXTINF:5,\n#EXT-X-BYTERANGE:1000@0\nall.ts\n#EXTINF:5,\n#EXT-X-BYTERANGE:1500\nall.ts\n"), "")
+	require.NoError(t, err)
+	require.Len(t, p.Segments, 2)
+	assert.Equal(t, int64(1500), p.Segments[1].Length)
+	assert.Equal(t, int64(1000), p.Segments[1].Offset, "a range without offset follows the previous one")
+	assert.Equal(t, "all.ts", p.Segments[1].URI)
+}
+
+func TestParseRejectsInvalidPlaylists(t *testing.T) {
+	t.Parallel()
+
+	for name, body := range map[string]string{
+		"html":      "<html>blocked</html>",
+		"empty":     "",
+		"bandwidth": "#EXTM3U\n#EXT-X-STREAM-INF:RESOLUTION=1x1\nv.m3u8\n",
+		"duration":  "#EXTM3U\n#EXTINF:abc,\ns.ts\n",
+	} {
+		_, err := Parse(strings.NewReader(body), "")
+		assert.Error(t, err, name)
+	}
+
+	_, err := Parse(strings.NewReader("\ufeff#EXTM3U\n"), "")
+	assert.NoError(t, err, "a byte order mark is allowed")
+}
@@ -0,0 +1,264 @@
+// Package httpreplay records HTTP exchanges to golden fixture files and replays them,
+// so scraper and metadata tests run offline and deterministically.
+//
+// Tests replay by default. Run them with -record (or GOANIME_RECORD=1) to hit the
+// real services through the configured network stack and rewrite the fixtures:
+//
+//	go test ./internal/scraper/ -record
+package httpreplay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/network"
+)
+
+var recordFlag = flag.Bool("record", false, "record HTTP fixtures against the live services instead of replaying them")
+
+// Recording reports whether fixtures should be refreshed from the live services
+func Recording() bool {
+	return *recordFlag || os.Getenv("GOANIME_RECORD") == "1"
+}
+
+// Fixture is one recorded request/response pair as stored on disk
+type Fixture struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+// FixtureRequest identifies the request a fixture answers. Query parameters in URL
+// are matched as a subset, so hand-written fixtures may list only the ones that matter.
+type FixtureRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Body, when set, must equal the request body exactly
+	Body string `json:"body,omitempty"`
+}
+
+// FixtureResponse is the canned answer. JSON bodies are stored inline for readability.
+type FixtureResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	JSON    json.RawMessage   `json:"json,omitempty"`
+}
+
+// recordedHeaders are the response headers worth keeping in fixtures
+var recordedHeaders = []string{"Content-Type", "Location"}
+
+// Transport replays fixtures from a directory, or records into it when recording
+type Transport struct {
+	dir    string
+	record bool
+	next   http.RoundTripper
+
+	mu       sync.Mutex
+	fixtures []Fixture
+}
+
+// New returns a transport backed by the fixtures in dir. When recording, requests are
+// forwarded to next and every exchange is written to dir.
+func New(dir string, record bool, next http.RoundTripper) (*Transport, error) {
+	t := &Transport{dir: dir, record: record, next: next}
+	if record {
+		return t, os.MkdirAll(dir, 0755)
+	}
+	return t, t.load()
+}
+
+func (t *Transport) load() error {
+	files, err := filepath.Glob(filepath.Join(t.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		var fx Fixture
+		if err := json.Unmarshal(data, &fx); err != nil {
+			return fmt.Errorf("fixture %s: %w", filepath.Base(f), err)
+		}
+		if fx.Request.Method == "" {
+			fx.Request.Method = http.MethodGet
+		}
+		t.fixtures = append(t.fixtures, fx)
+	}
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if t.record {
+		return t.recordExchange(req, body)
+	}
+
+	fx, ok := t.match(req, string(body))
+	if !ok {
+		return nil, fmt.Errorf("httpreplay: no fixture for %s %s (run the test with -record)", req.Method, req.URL)
+	}
+	return fx.Response.toHTTP(req), nil
+}
+
+// match returns the fixture whose request matches req, preferring the one that pins the most query parameters
+func (t *Transport) match(req *http.Request, body string) (Fixture, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	best, bestScore := Fixture{}, -1
+	for _, fx := range t.fixtures {
+		if !strings.EqualFold(fx.Request.Method, req.Method) {
+			continue
+		}
+		if fx.Request.Body != "" && fx.Request.Body != body {
+			continue
+		}
+		score, ok := matchURL(fx.Request.URL, req.URL)
+		if ok && score > bestScore {
+			best, bestScore = fx, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+func matchURL(pattern string, u *url.URL) (int, bool) {
+	p, err := url.Parse(pattern)
+	if err != nil {
+		return 0, false
+	}
+	if !strings.EqualFold(p.Host, u.Host) || p.Path != u.Path {
+		return 0, false
+	}
+	want := p.Query()
+	got := u.Query()
+	for key, values := range want {
+		if strings.Join(got[key], "\x00") != strings.Join(values, "\x00") {
+			return 0, false
+		}
+	}
+	return len(want), true
+}
+
+func (r FixtureResponse) toHTTP(req *http.Request) *http.Response {
+	body := []byte(r.Body)
+	if len(r.JSON) > 0 {
+		body = r.JSON
+	}
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := make(http.Header)
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func (t *Transport) recordExchange(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	fx := Fixture{
+		Request: FixtureRequest{Method: req.Method, URL: req.URL.String(), Body: string(body)},
+		Response: FixtureResponse{
+			Status:  resp.StatusCode,
+			Headers: map[string]string{},
+		},
+	}
+	for _, h := range recordedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			fx.Response.Headers[h] = v
+		}
+	}
+	if json.Valid(respBody) && len(bytes.TrimSpace(respBody)) > 0 {
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, respBody, "", "  ") == nil {
+			fx.Response.JSON = pretty.Bytes()
+		}
+	}
+	if fx.Response.JSON == nil {
+		fx.Response.Body = string(respBody)
+	}
+
+	data, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.WriteFile(filepath.Join(t.dir, fixtureName(req, body)), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// fixtureName derives a readable, stable file name from the request
+func fixtureName(req *http.Request, body []byte) string {
+	slug := strings.Trim(unsafeChars.ReplaceAllString(req.URL.Host+req.URL.Path, "-"), "-")
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+	sum := sha256.Sum256(append([]byte(req.Method+" "+req.URL.String()+"\n"), body...))
+	return fmt.Sprintf("%s-%s.json", strings.ToLower(slug), hex.EncodeToString(sum[:4]))
+}
+
+// Use routes all traffic from network.NewClient clients through fixtures in dir
+// for the duration of the test. Tests using it must not run in parallel.
+func Use(t testing.TB, dir string) {
+	t.Helper()
+	tr, err := New(dir, Recording(), network.NewTransport(""))
+	if err != nil {
+		t.Fatalf("httpreplay: %v", err)
+	}
+	t.Cleanup(network.SetRoundTripper(tr))
+}
@@ -0,0 +1,73 @@
+package httpreplay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFixture(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func TestReplayPrefersMostSpecificFixture(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, dir, "any.json", `{"request":{"url":"https://example.com/search"},"response":{"status":200,"body":"any"}}`)
+	writeFixture(t, dir, "naruto.json", `{"request":{"url":"https://example.com/search?q=naruto"},"response":{"status":200,"json":{"name":"naruto"}}}`)
+
+	tr, err := New(dir, false, nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: tr}
+
+	resp, err := client.Get("https://example.com/search?q=naruto&page=1")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"name":"naruto"}`, string(body))
+
+	resp, err = client.Get("https://example.com/search?q=bleach")
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, "any", string(body))
+
+	_, err = client.Get("https://example.com/other")
+	assert.ErrorContains(t, err, "no fixture")
+}
+
+func TestRecordWritesReplayableFixture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	rec, err := New(dir, true, http.DefaultTransport)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: rec}).Post(server.URL+"/graphql", "application/json", strings.NewReader(`{"q":1}`))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.Len(t, files, 1)
+
+	replay, err := New(dir, false, nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: replay}
+
+	resp, err = client.Post(server.URL+"/graphql", "application/json", strings.NewReader(`{"q":1}`))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"ok":true}`, string(body))
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	_, err = client.Post(server.URL+"/graphql", "application/json", strings.NewReader(`{"q":2}`))
+	assert.Error(t, err, "recorded request bodies must match")
+}
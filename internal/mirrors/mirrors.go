@@ -0,0 +1,338 @@
+// Package mirrors keeps the ordered domain lists for each source and fails over between them
+package mirrors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// probeTimeout bounds each mirror reachability check
+const probeTimeout = 5 * time.Second
+
+// defaults are the built-in mirrors, in priority order. config.json "mirrors" replaces them per source.
+var defaults = map[string][]config.Mirror{
+	network.SourceAllAnime: {
+		{Base: "https://allanime.day", API: "https://api.allanime.day/api", Referer: "https://allanime.to"},
+		{Base: "https://allanime.to", API: "https://api.allanime.to/api", Referer: "https://allanime.to"},
+		{Base: "https://allmanga.to", API: "https://api.allmanga.to/api", Referer: "https://allmanga.to"},
+	},
+	network.SourceAnimeFire: {
+		{Base: "https://animefire.plus"},
+		{Base: "https://animefire.io"},
+	},
+}
+
+// Set is the mirror list of one source together with the currently active entry
+type Set struct {
+	source string
+
+	mu      sync.RWMutex
+	mirrors []config.Mirror
+	active  int
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Set{}
+
+	// probe is swapped out in tests
+	probe = probeMirror
+)
+
+// Get returns the mirror set for source, restoring the last working mirror from disk
+func Get(source string) *Set {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if s, ok := registry[source]; ok {
+		return s
+	}
+
+	list := normalize(config.Get().Mirrors[source])
+	if len(list) == 0 {
+		list = normalize(defaults[source])
+	}
+	s := &Set{source: source, mirrors: list}
+	if last := loadState()[source]; last != "" {
+		for i, m := range list {
+			if m.Base == last {
+				s.active = i
+				break
+			}
+		}
+	}
+	registry[source] = s
+	return s
+}
+
+// Reset drops the cached sets so the next Get re-reads config and state
+func Reset() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = map[string]*Set{}
+}
+
+func normalize(list []config.Mirror) []config.Mirror {
+	out := make([]config.Mirror, 0, len(list))
+	for _, m := range list {
+		m.Base = strings.TrimRight(strings.TrimSpace(m.Base), "/")
+		if m.Base == "" {
+			continue
+		}
+		if m.API == "" {
+			m.API = m.Base
+		}
+		if m.Referer == "" {
+			m.Referer = m.Base
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// Active returns the mirror currently in use
+func (s *Set) Active() config.Mirror {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.mirrors) == 0 {
+		return config.Mirror{}
+	}
+	return s.mirrors[s.active]
+}
+
+// All returns every mirror of the set in priority order
+func (s *Set) All() []config.Mirror {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]config.Mirror(nil), s.mirrors...)
+}
+
+// Host returns the hostname of the active mirror's Base
+func (s *Set) Host() string {
+	u, err := url.Parse(s.Active().Base)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// Failover is called after a request against failed could not reach the site.
+// It probes the other mirrors in order, activates the first reachable one and
+// persists the choice. It returns false when no other mirror responds. Probing
+// happens without holding the set, so other requests keep the active mirror
+// meanwhile.
+func (s *Set) Failover(ctx context.Context, failed config.Mirror) (config.Mirror, bool) {
+	s.mu.RLock()
+	list := append([]config.Mirror(nil), s.mirrors...)
+	active := s.active
+	s.mu.RUnlock()
+
+	if len(list) == 0 {
+		return config.Mirror{}, false
+	}
+	// Another request already moved away from the failed mirror
+	if cur := list[active]; cur.Base != failed.Base {
+		return cur, true
+	}
+
+	for i := 1; i < len(list); i++ {
+		idx := (active + i) % len(list)
+		candidate := list[idx]
+		if _, err := probe(ctx, s.source, candidate); err != nil {
+			util.Debug("Mirror probe failed", "source", s.source, "mirror", candidate.Base, "error", err)
+			continue
+		}
+
+		s.mu.Lock()
+		// A concurrent failover may have switched while this one was probing
+		if cur := s.mirrors[s.active]; cur.Base != failed.Base {
+			s.mu.Unlock()
+			return cur, true
+		}
+		s.active = idx
+		s.mu.Unlock()
+
+		util.Info("Switched mirror", "source", s.source, "from", failed.Base, "to", candidate.Base)
+		saveActive(s.source, candidate.Base)
+		return candidate, true
+	}
+	return config.Mirror{}, false
+}
+
+// ProbeResult reports the reachability of a single mirror
+type ProbeResult struct {
+	Mirror  config.Mirror
+	Latency time.Duration
+	Err     error
+}
+
+// Probe checks every mirror of the set and reports latency or the failure reason
+func (s *Set) Probe(ctx context.Context) []ProbeResult {
+	list := s.All()
+	results := make([]ProbeResult, len(list))
+	var wg sync.WaitGroup
+	for i, m := range list {
+		wg.Add(1)
+		go func(i int, m config.Mirror) {
+			defer wg.Done()
+			latency, err := probe(ctx, s.source, m)
+			results[i] = ProbeResult{Mirror: m, Latency: latency, Err: err}
+		}(i, m)
+	}
+	wg.Wait()
+	return results
+}
+
+// probeMirror requests the mirror's API endpoint; any non-5xx answer counts as reachable
+func probeMirror(ctx context.Context, source string, m config.Mirror) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.API, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", network.UserAgent)
+	req.Header.Set("Referer", m.Referer)
+
+	start := time.Now()
+	resp, err := network.NewClient(source, probeTimeout).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return 0, errors.New("mirror returned " + resp.Status)
+	}
+	return time.Since(start), nil
+}
+
+// ShouldFailover reports whether a request outcome means the mirror itself is unreachable:
+// DNS/connection/TLS failures, timeouts, or gateway errors from a CDN in front of a dead origin.
+func ShouldFailover(resp *http.Response, err error) bool {
+	if err != nil {
+		// The caller giving up is not the mirror's fault
+		return !errors.Is(err, context.Canceled)
+	}
+	if resp == nil {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	// Cloudflare origin errors (520-530)
+	return resp.StatusCode >= 520 && resp.StatusCode <= 530
+}
+
+// Do sends req, which was built against used. If used turns out to be unreachable,
+// the set fails over and the request is replayed once against the new mirror.
+// Only requests without a body can be replayed.
+func (s *Set) Do(client *http.Client, used config.Mirror, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if req.Body != nil || !ShouldFailover(resp, err) {
+		return resp, err
+	}
+
+	next, ok := s.Failover(req.Context(), used)
+	if !ok || next.Base == used.Base {
+		return resp, err
+	}
+
+	target := req.URL.String()
+	if rebased := Rebase(target, used.API, next.API); rebased != target {
+		target = rebased
+	} else {
+		target = Rebase(target, used.Base, next.Base)
+	}
+	retryURL, perr := url.Parse(target)
+	if perr != nil {
+		return resp, err
+	}
+	if resp != nil {
+		_ = resp.Body.Close()
+	}
+
+	retry := req.Clone(req.Context())
+	retry.URL = retryURL
+	retry.Host = ""
+	if retry.Header.Get("Referer") != "" {
+		retry.Header.Set("Referer", Rebase(retry.Header.Get("Referer"), used.Referer, next.Referer))
+	}
+	return client.Do(retry)
+}
+
+// Rebase moves rawURL from one mirror prefix to another. URLs outside from are returned unchanged.
+func Rebase(rawURL, from, to string) string {
+	if from == "" || !strings.HasPrefix(rawURL, from) {
+		return rawURL
+	}
+	return to + strings.TrimPrefix(rawURL, from)
+}
+
+// MatchesHost reports whether rawURL points at any known mirror of source
+func MatchesHost(source, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, m := range Get(source).All() {
+		for _, candidate := range []string{m.Base, m.API, m.Referer} {
+			cu, err := url.Parse(candidate)
+			if err == nil && cu.Hostname() != "" && strings.EqualFold(cu.Hostname(), host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// statePath is where the last working mirror per source is remembered
+func statePath() string {
+	return filepath.Join(config.Dir(), "mirrors.json")
+}
+
+var stateMu sync.Mutex
+
+func loadState() map[string]string {
+	state := map[string]string{}
+	data, err := os.ReadFile(statePath())
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		util.Debug("Ignoring unreadable mirror state", "error", err)
+	}
+	return state
+}
+
+func saveActive(source, base string) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	state := loadState()
+	state[source] = base
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath()), 0700); err != nil {
+		util.Debug("Failed to persist mirror state", "error", err)
+		return
+	}
+	if err := os.WriteFile(statePath(), data, 0600); err != nil {
+		util.Debug("Failed to persist mirror state", "error", err)
+	}
+}
@@ -0,0 +1,129 @@
+package mirrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useMirrors isolates the test from the user's config and state, and installs list for source
+func useMirrors(t *testing.T, source string, list []config.Mirror) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	cfg := config.Default()
+	cfg.Mirrors = map[string][]config.Mirror{source: list}
+	config.Set(cfg)
+	Reset()
+	t.Cleanup(func() {
+		config.Set(config.Default())
+		Reset()
+		probe = probeMirror
+	})
+}
+
+func TestGetNormalizesConfiguredMirrors(t *testing.T) {
+	useMirrors(t, "animefire", []config.Mirror{{Base: " https://a.example/ "}, {Base: ""}, {Base: "https://b.example"}})
+
+	all := Get("animefire").All()
+	require.Len(t, all, 2)
+	assert.Equal(t, config.Mirror{Base: "https://a.example", API: "https://a.example", Referer: "https://a.example"}, all[0])
+	assert.Equal(t, "a.example", Get("animefire").Host())
+}
+
+func TestFailoverSkipsUnreachableMirrorsAndPersists(t *testing.T) {
+	useMirrors(t, "animefire", []config.Mirror{{Base: "https://a.example"}, {Base: "https://b.example"}, {Base: "https://c.example"}})
+	probe = func(_ context.Context, _ string, m config.Mirror) (time.Duration, error) {
+		if m.Base == "https://b.example" {
+			return 0, errors.New("down")
+		}
+		return time.Millisecond, nil
+	}
+
+	set := Get("animefire")
+	next, ok := set.Failover(context.Background(), set.Active())
+	require.True(t, ok)
+	assert.Equal(t, "https://c.example", next.Base)
+
+	// A fresh registry restores the persisted mirror
+	Reset()
+	assert.Equal(t, "https://c.example", Get("animefire").Active().Base)
+}
+
+func TestFailoverProbesWithoutBlockingTheSet(t *testing.T) {
+	useMirrors(t, "animefire", []config.Mirror{{Base: "https://a.example"}, {Base: "https://b.example"}})
+	set := Get("animefire")
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	probe = func(context.Context, string, config.Mirror) (time.Duration, error) {
+		close(probing)
+		<-release
+		return time.Millisecond, nil
+	}
+
+	done := make(chan config.Mirror)
+	go func() {
+		next, _ := set.Failover(context.Background(), set.Active())
+		done <- next
+	}()
+	<-probing
+	assert.Equal(t, "https://a.example", set.Active().Base, "requests keep the active mirror while probing")
+	close(release)
+	assert.Equal(t, "https://b.example", (<-done).Base)
+}
+
+func TestAllAnimeShipsAlternateMirrors(t *testing.T) {
+	assert.Greater(t, len(defaults["allanime"]), 1, "failover needs somewhere to go")
+}
+
+func TestDoReplaysRequestOnNextMirror(t *testing.T) {
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer dead.Close()
+	alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s|%s", r.URL.Path, r.Header.Get("Referer"))
+	}))
+	defer alive.Close()
+
+	useMirrors(t, "animefire", []config.Mirror{{Base: dead.URL}, {Base: alive.URL}})
+	probe = func(context.Context, string, config.Mirror) (time.Duration, error) { return 0, nil }
+
+	set := Get("animefire")
+	used := set.Active()
+	req, err := http.NewRequest(http.MethodGet, used.Base+"/pesquisar/naruto", nil)
+	require.NoError(t, err)
+	req.Header.Set("Referer", used.Referer+"/")
+
+	resp, err := set.Do(http.DefaultClient, used, req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	body := make([]byte, 256)
+	n, _ := resp.Body.Read(body)
+	assert.Equal(t, "/pesquisar/naruto|"+alive.URL+"/", string(body[:n]))
+	assert.Equal(t, alive.URL, set.Active().Base)
+}
+
+func TestMatchesHost(t *testing.T) {
+	useMirrors(t, "allanime", []config.Mirror{{Base: "https://allanime.day", API: "https://api.allanime.day/api", Referer: "https://allanime.to"}})
+
+	assert.True(t, MatchesHost("allanime", "https://api.allanime.day/api?x=1"))
+	assert.True(t, MatchesHost("allanime", "https://allanime.to/anime/abc"))
+	assert.False(t, MatchesHost("allanime", "https://example.com/allanime.day"))
+}
+
+func TestShouldFailover(t *testing.T) {
+	assert.True(t, ShouldFailover(nil, errors.New("dial tcp: no such host")))
+	assert.False(t, ShouldFailover(nil, context.Canceled))
+	assert.True(t, ShouldFailover(&http.Response{StatusCode: 522}, nil))
+	assert.False(t, ShouldFailover(&http.Response{StatusCode: http.StatusForbidden}, nil))
+}
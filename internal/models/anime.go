@@ -8,7 +8,9 @@ type Anime struct {
 	AnilistID int
 	MalID     int
 	Details   AniListDetails
-	Source    string // Identifies the source (AllAnime, AnimeFire, etc.)
+	Source    string   // Identifies the source (AllAnime, AnimeFire, etc.)
+	Dubbed    bool     // Set for AnimeFire's "Dublado" pages
+	Variants  []*Anime // Language versions of the same show; one must be picked before playing
 }
 
 // Episode represents a single episode of an anime series, containing details such as episode number,
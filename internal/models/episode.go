@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxFileTitle bounds the title part of a download file name, in runes
+const maxFileTitle = 80
+
+// DisplayTitle returns the episode's own title (English, then romaji, then
+// Japanese), or "" when the source only gave a placeholder like "Episode 3"
+func (e Episode) DisplayTitle() string {
+	for _, t := range []string{e.Title.English, e.Title.Romaji, e.Title.Japanese} {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(t, "Episode "); ok && (rest == e.Number || rest == strconv.Itoa(e.Num)) {
+			continue
+		}
+		return t
+	}
+	return ""
+}
+
+// FileName is the download file name of the episode: "3 - The Journey's End.mp4"
+// when its title is known, "3.mp4" otherwise
+func (e Episode) FileName() string {
+	title := sanitizeFileTitle(e.DisplayTitle())
+	if title == "" {
+		return fmt.Sprintf("%d.mp4", e.Num)
+	}
+	return fmt.Sprintf("%d - %s.mp4", e.Num, title)
+}
+
+// FilePath returns where the episode is saved in dir. A download saved before
+// episodes were named after their titles ("3.mp4") is still found.
+func (e Episode) FilePath(dir string) string {
+	named := filepath.Join(dir, e.FileName())
+	plain := filepath.Join(dir, fmt.Sprintf("%d.mp4", e.Num))
+	if named != plain {
+		if _, err := os.Stat(named); os.IsNotExist(err) {
+			if _, err := os.Stat(plain); err == nil {
+				return plain
+			}
+		}
+	}
+	return named
+}
+
+// sanitizeFileTitle removes characters that are not allowed in file names on
+// Windows, macOS or Linux (separators become spaces) and shortens long titles
+func sanitizeFileTitle(title string) string {
+	title = strings.Map(func(r rune) rune {
+		switch {
+		case strings.ContainsRune(`:/\|`, r), unicode.IsControl(r):
+			return ' '
+		case strings.ContainsRune(`<>"?*`, r):
+			return -1
+		default:
+			return r
+		}
+	}, title)
+	title = strings.Join(strings.Fields(title), " ")
+	if runes := []rune(title); len(runes) > maxFileTitle {
+		title = string(runes[:maxFileTitle])
+	}
+	// Windows refuses names ending in a dot or space
+	return strings.TrimRight(title, ". ")
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEpisodeDisplayTitle(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, Episode{Number: "3", Num: 3, Title: TitleDetails{Romaji: "Episode 3"}}.DisplayTitle())
+	assert.Equal(t, "Killing Magic", Episode{Num: 3, Title: TitleDetails{Romaji: "Hito o Korosu Mahou", English: "Killing Magic"}}.DisplayTitle())
+	assert.Equal(t, "人を殺す魔法", Episode{Num: 3, Title: TitleDetails{Romaji: "Episode 3", Japanese: "人を殺す魔法"}}.DisplayTitle())
+	assert.Equal(t, "Episode of Frieren", Episode{Num: 3, Title: TitleDetails{English: "Episode of Frieren"}}.DisplayTitle())
+}
+
+func TestEpisodeFileName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "3.mp4", Episode{Num: 3, Title: TitleDetails{Romaji: "Episode 3"}}.FileName())
+	assert.Equal(t, "2 - It Didn't Have to Be Magic.mp4",
+		Episode{Num: 2, Title: TitleDetails{English: "It Didn't Have to Be Magic..."}}.FileName())
+	assert.Equal(t, "7 - Who What Part 2.mp4",
+		Episode{Num: 7, Title: TitleDetails{English: "Who/What? \"Part\t2\""}}.FileName())
+}
+
+func TestEpisodeFilePathKeepsPlainDownloads(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ep := Episode{Num: 1, Title: TitleDetails{English: "The Journey's End"}}
+	assert.Equal(t, filepath.Join(dir, "1 - The Journey's End.mp4"), ep.FilePath(dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1.mp4"), nil, 0600))
+	assert.Equal(t, filepath.Join(dir, "1.mp4"), ep.FilePath(dir), "an earlier download is still found")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1 - The Journey's End.mp4"), nil, 0600))
+	assert.Equal(t, filepath.Join(dir, "1 - The Journey's End.mp4"), ep.FilePath(dir))
+}
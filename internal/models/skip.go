@@ -1,31 +1,96 @@
 package models
 
+import "math"
+
 // Skip represents a skip interval with a start and end time
 type Skip struct {
 	Start int
 	End   int
 }
 
-// SkipTimes holds the skip intervals for OP and ED
+// Interval types, as AniSkip names them
+const (
+	SkipTypeOp      = "op"
+	SkipTypeEd      = "ed"
+	SkipTypeMixedOp = "mixed-op" // an opening over scenes of the episode
+	SkipTypeMixedEd = "mixed-ed" // an ending over scenes of the episode
+	SkipTypeRecap   = "recap"
+	SkipTypePreview = "preview" // the next episode's preview, after the ending
+)
+
+// Interval is a part of an episode of one type, in seconds
+type Interval struct {
+	Type  string  `json:"type"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// SkipTimes holds the skip intervals of an episode. Op, Ed and Recap are the
+// opening, ending and recap in whole seconds; a mixed opening or ending fills
+// them when the episode has no plain one. Intervals lists every typed interval.
 type SkipTimes struct {
-	Op Skip
-	Ed Skip
+	Op        Skip
+	Ed        Skip
+	Recap     Skip
+	Intervals []Interval
+}
+
+// Add records iv, and fills the Op, Ed or Recap it stands for when unset
+func (s *SkipTimes) Add(iv Interval) {
+	s.Intervals = append(s.Intervals, iv)
+	skip := Skip{Start: roundSecond(iv.Start), End: roundSecond(iv.End)}
+	switch iv.Type {
+	case SkipTypeOp:
+		s.Op = skip
+	case SkipTypeMixedOp:
+		if s.Op.End == 0 {
+			s.Op = skip
+		}
+	case SkipTypeEd:
+		s.Ed = skip
+	case SkipTypeMixedEd:
+		if s.Ed.End == 0 {
+			s.Ed = skip
+		}
+	case SkipTypeRecap:
+		s.Recap = skip
+	}
+}
+
+// Of returns the intervals of type typ
+func (s SkipTimes) Of(typ string) []Interval {
+	var out []Interval
+	for _, iv := range s.Intervals {
+		if iv.Type == typ {
+			out = append(out, iv)
+		}
+	}
+	return out
+}
+
+func roundSecond(t float64) int {
+	return int(math.Floor(t + 0.5))
 }
 
-// skipTimesResponse struct to hold the response from the AniSkip API
+// SkipTimesResponse holds a response of AniSkip's v2 skip-times endpoint
 type SkipTimesResponse struct {
-	Found   bool         `json:"found"`
-	Results []SkipResult `json:"results"`
+	Found      bool         `json:"found"`
+	Results    []SkipResult `json:"results"`
+	Message    string       `json:"message"`
+	StatusCode int          `json:"statusCode"`
 }
 
-// SkipResult struct to hold individual skip result data
+// SkipResult is one interval of a SkipTimesResponse, submitted for a release
+// of episodeLength seconds
 type SkipResult struct {
-	Interval SkipInterval `json:"interval"`
-	Type     string       `json:"skip_type"` // Corrected the tag to match the JSON response
+	Interval      SkipInterval `json:"interval"`
+	Type          string       `json:"skipType"`
+	SkipID        string       `json:"skipId"`
+	EpisodeLength float64      `json:"episodeLength"`
 }
 
-// skipInterval struct to hold the start and end times for skip intervals
+// SkipInterval holds the start and end times of a SkipResult
 type SkipInterval struct {
-	StartTime float64 `json:"start_time"`
-	EndTime   float64 `json:"end_time"`
+	StartTime float64 `json:"startTime"`
+	EndTime   float64 `json:"endTime"`
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipTimesAdd(t *testing.T) {
+	t.Parallel()
+
+	var times SkipTimes
+	times.Add(Interval{Type: SkipTypeMixedOp, Start: 10.4, End: 99.5})
+	assert.Equal(t, Skip{Start: 10, End: 100}, times.Op, "a mixed opening fills an unset opening")
+
+	times.Add(Interval{Type: SkipTypeOp, Start: 20, End: 110})
+	times.Add(Interval{Type: SkipTypeEd, Start: 1300, End: 1390})
+	times.Add(Interval{Type: SkipTypeMixedEd, Start: 1200, End: 1290})
+	times.Add(Interval{Type: SkipTypePreview, Start: 1400, End: 1420})
+	assert.Equal(t, Skip{Start: 20, End: 110}, times.Op, "a plain opening wins")
+	assert.Equal(t, Skip{Start: 1300, End: 1390}, times.Ed, "a mixed ending does not replace the ending")
+	assert.Len(t, times.Intervals, 5)
+	assert.Equal(t, []Interval{{Type: SkipTypePreview, Start: 1400, End: 1420}}, times.Of(SkipTypePreview))
+}
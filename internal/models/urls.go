@@ -1,3 +0,0 @@
-package models
-
-const AnimeFireURL = "https://animefire.plus"
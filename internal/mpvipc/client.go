@@ -0,0 +1,333 @@
+// Package mpvipc talks to mpv over its JSON IPC socket with one long-lived
+// connection. Commands carry request ids and are matched with their replies,
+// and events and observed properties are delivered on channels, so callers
+// subscribe to playback state instead of polling it.
+package mpvipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds how long Command waits for a reply
+	DefaultTimeout = 5 * time.Second
+	// maxMessageSize bounds one IPC line; track and chapter lists can be long
+	maxMessageSize = 4 << 20
+	// subscriptionBuffer is how many values a slow observer may lag behind
+	// before the oldest are dropped
+	subscriptionBuffer = 16
+)
+
+// ErrClosed is returned once the connection to mpv is gone, usually because mpv exited
+var ErrClosed = errors.New("mpv IPC connection closed")
+
+// Error is an error reply from mpv, such as "property unavailable"
+type Error struct {
+	Command string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("mpv %s: %s", e.Command, e.Message)
+}
+
+// IsUnavailable reports whether err is mpv's "property unavailable", which
+// properties like time-pos return until a file is playing
+func IsUnavailable(err error) bool {
+	var mpvErr *Error
+	return errors.As(err, &mpvErr) && mpvErr.Message == "property unavailable"
+}
+
+// Event is an mpv event such as "end-file", "file-loaded" or "property-change"
+type Event struct {
+	Name string
+	// Property and Data are set for property-change events
+	Property string
+	Data     interface{}
+	// Reason ("eof", "error", "quit", ...) and FileError are set for end-file events
+	Reason    string
+	FileError string
+	// Args are set for client-message events, e.g. from a "script-message" key binding
+	Args []string
+}
+
+// message is any line mpv sends: a reply (request_id set) or an event
+type message struct {
+	Event     string      `json:"event"`
+	ID        int64       `json:"id"`
+	Name      string      `json:"name"`
+	Data      interface{} `json:"data"`
+	Reason    string      `json:"reason"`
+	FileError string      `json:"file_error"`
+	Args      []string    `json:"args"`
+	RequestID *int64      `json:"request_id"`
+	Error     string      `json:"error"`
+}
+
+// Client is a connection to one mpv instance. It is safe for concurrent use.
+type Client struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	nextID    int64
+	pending   map[int64]chan message
+	observers map[int64]chan interface{}
+	listeners map[int64]*EventQueue
+	done      chan struct{}
+	err       error
+}
+
+// New starts reading from conn, an open mpv IPC connection
+func New(conn net.Conn) *Client {
+	c := &Client{
+		conn:      conn,
+		pending:   map[int64]chan message{},
+		observers: map[int64]chan interface{}{},
+		listeners: map[int64]*EventQueue{},
+		done:      make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Done is closed when the connection ends
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended, nil while it is open
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close ends the connection; mpv keeps running
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Command sends an IPC command such as ("seek", 85, "absolute") and returns the
+// data of its reply, waiting at most DefaultTimeout
+func (c *Client) Command(args ...interface{}) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	return c.CommandContext(ctx, args...)
+}
+
+// CommandContext is Command with a caller-controlled deadline
+func (c *Client) CommandContext(ctx context.Context, args ...interface{}) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, errors.New("empty mpv command")
+	}
+	reply := make(chan message, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = reply
+	c.mu.Unlock()
+
+	if err := c.write(map[string]interface{}{"command": args, "request_id": id}); err != nil {
+		c.forget(id)
+		return nil, err
+	}
+
+	select {
+	case msg := <-reply:
+		if msg.Error != "success" {
+			return nil, &Error{Command: fmt.Sprint(args[0]), Message: msg.Error}
+		}
+		return msg.Data, nil
+	case <-c.done:
+		return nil, c.Err()
+	case <-ctx.Done():
+		c.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+// Get returns the value of a property
+func (c *Client) Get(property string) (interface{}, error) {
+	return c.Command("get_property", property)
+}
+
+// Set changes a property
+func (c *Client) Set(property string, value interface{}) error {
+	_, err := c.Command("set_property", property, value)
+	return err
+}
+
+// Observe subscribes to a property. mpv sends its current value first, then
+// every change; nil means the property is unavailable (e.g. time-pos before a
+// file plays). The channel is closed by cancel or when the connection ends.
+// Values are dropped oldest-first when the receiver falls behind.
+func (c *Client) Observe(property string) (<-chan interface{}, func(), error) {
+	ch := make(chan interface{}, subscriptionBuffer)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, nil, c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.observers[id] = ch
+	c.mu.Unlock()
+
+	if _, err := c.Command("observe_property", id, property); err != nil {
+		c.removeObserver(id)
+		return nil, nil, err
+	}
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			if c.removeObserver(id) {
+				go func() { _, _ = c.Command("unobserve_property", id) }()
+			}
+		})
+	}
+	return ch, cancel, nil
+}
+
+// Events subscribes to every event mpv sends, property changes included. A
+// subscriber that falls behind only misses intermediate values of a property,
+// see EventQueue. The channel is closed by cancel or when the connection ends.
+func (c *Client) Events() (<-chan Event, func()) {
+	q := NewEventQueue()
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		q.Close()
+		return q.C(), func() {}
+	}
+	c.nextID++
+	id := c.nextID
+	c.listeners[id] = q
+	c.mu.Unlock()
+
+	return q.C(), func() {
+		c.mu.Lock()
+		delete(c.listeners, id)
+		c.mu.Unlock()
+		q.Cancel()
+	}
+}
+
+// write sends one JSON line
+func (c *Client) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.conn.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to mpv: %w", err)
+	}
+	return nil
+}
+
+// forget drops a pending request whose caller stopped waiting
+func (c *Client) forget(id int64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// removeObserver closes an observer's channel; it reports whether it was still registered
+func (c *Client) removeObserver(id int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.observers[id]
+	if ok {
+		delete(c.observers, id)
+		close(ch)
+	}
+	return ok
+}
+
+// readLoop splits the stream into lines and routes replies and events
+func (c *Client) readLoop() {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.Event != "" {
+			c.dispatch(msg)
+			continue
+		}
+		if msg.RequestID == nil {
+			continue
+		}
+		c.mu.Lock()
+		reply, ok := c.pending[*msg.RequestID]
+		delete(c.pending, *msg.RequestID)
+		c.mu.Unlock()
+		if ok {
+			reply <- msg
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = ErrClosed
+	} else {
+		err = fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	c.mu.Lock()
+	c.err = err
+	for id, ch := range c.observers {
+		delete(c.observers, id)
+		close(ch)
+	}
+	for id, q := range c.listeners {
+		delete(c.listeners, id)
+		q.Close()
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// dispatch hands an event to the listeners and, for property changes, to the observer
+func (c *Client) dispatch(msg message) {
+	ev := Event{Name: msg.Event, Property: msg.Name, Data: msg.Data, Reason: msg.Reason, FileError: msg.FileError, Args: msg.Args}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if msg.Event == "property-change" {
+		if ch, ok := c.observers[msg.ID]; ok {
+			deliver(ch, msg.Data)
+		}
+	}
+	for _, q := range c.listeners {
+		q.Push(ev)
+	}
+}
+
+// deliver sends v without blocking, dropping the oldest queued value when ch is full
+func deliver[T any](ch chan T, v T) {
+	for {
+		select {
+		case ch <- v:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package mpvipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// request is a command as the fake mpv receives it
+type request struct {
+	Command   []interface{} `json:"command"`
+	RequestID int64         `json:"request_id"`
+}
+
+// fakeMPV is the mpv end of a connection
+type fakeMPV struct {
+	t    *testing.T
+	conn net.Conn
+	in   *bufio.Scanner
+}
+
+func newPair(t *testing.T) (*Client, *fakeMPV) {
+	t.Helper()
+	a, b := net.Pipe()
+	c := New(a)
+	t.Cleanup(func() { _ = c.Close(); _ = b.Close() })
+	return c, &fakeMPV{t: t, conn: b, in: bufio.NewScanner(b)}
+}
+
+func (m *fakeMPV) next() request {
+	m.t.Helper()
+	require.NoError(m.t, m.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	require.True(m.t, m.in.Scan(), "expected a command")
+	var r request
+	require.NoError(m.t, json.Unmarshal(m.in.Bytes(), &r))
+	return r
+}
+
+func (m *fakeMPV) send(format string, args ...interface{}) {
+	m.t.Helper()
+	_, err := fmt.Fprintf(m.conn, format+"\n", args...)
+	require.NoError(m.t, err)
+}
+
+func TestCommandMatchesRepliesByRequestID(t *testing.T) {
+	c, m := newPair(t)
+
+	type result struct {
+		data interface{}
+		err  error
+	}
+	first, second := make(chan result, 1), make(chan result, 1)
+	go func() { d, err := c.Get("time-pos"); first <- result{d, err} }()
+	r1 := m.next()
+	go func() { d, err := c.Get("duration"); second <- result{d, err} }()
+	r2 := m.next()
+	assert.Equal(t, []interface{}{"get_property", "time-pos"}, r1.Command)
+	assert.NotEqual(t, r1.RequestID, r2.RequestID)
+
+	// Replies arrive out of order, split by an event and in one write
+	m.send(`{"data":1420.5,"request_id":%d,"error":"success"}`+"\n"+`{"event":"pause"}`+"\n"+`{"data":12.25,"request_id":%d,"error":"success"}`, r2.RequestID, r1.RequestID)
+
+	res := <-first
+	require.NoError(t, res.err)
+	assert.Equal(t, 12.25, res.data)
+	res = <-second
+	require.NoError(t, res.err)
+	assert.Equal(t, 1420.5, res.data)
+}
+
+func TestCommandReturnsMPVErrors(t *testing.T) {
+	c, m := newPair(t)
+
+	errc := make(chan error, 1)
+	go func() { _, err := c.Get("time-pos"); errc <- err }()
+	m.send(`{"request_id":%d,"error":"property unavailable"}`, m.next().RequestID)
+
+	err := <-errc
+	require.Error(t, err)
+	assert.True(t, IsUnavailable(err))
+	assert.EqualError(t, err, "mpv get_property: property unavailable")
+}
+
+func TestCommandContextTimesOut(t *testing.T) {
+	c, m := newPair(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { _, err := c.CommandContext(ctx, "quit"); errc <- err }()
+	r := m.next()
+
+	assert.ErrorIs(t, <-errc, context.DeadlineExceeded)
+	// A late reply is ignored
+	m.send(`{"request_id":%d,"error":"success"}`, r.RequestID)
+}
+
+func TestObserveDeliversPropertyChanges(t *testing.T) {
+	c, m := newPair(t)
+
+	type observed struct {
+		ch     <-chan interface{}
+		cancel func()
+		err    error
+	}
+	res := make(chan observed, 1)
+	go func() { ch, cancel, err := c.Observe("time-pos"); res <- observed{ch, cancel, err} }()
+	r := m.next()
+	require.Len(t, r.Command, 3)
+	assert.Equal(t, "observe_property", r.Command[0])
+	assert.Equal(t, "time-pos", r.Command[2])
+	id := int64(r.Command[1].(float64))
+
+	// mpv announces the current value before it acknowledges the command
+	m.send(`{"event":"property-change","id":%d,"name":"time-pos"}`, id)
+	m.send(`{"request_id":%d,"error":"success"}`, r.RequestID)
+	o := <-res
+	require.NoError(t, o.err)
+
+	events, stopEvents := c.Events()
+	defer stopEvents()
+	m.send(`{"event":"property-change","id":%d,"name":"time-pos","data":3.5}`, id)
+	m.send(`{"event":"property-change","id":%d,"name":"pause","data":true}`, id+100)
+	m.send(`{"event":"end-file","reason":"error","file_error":"loading failed"}`)
+	m.send(`{"event":"client-message","args":["goanime-skip"]}`)
+
+	assert.Nil(t, <-o.ch, "unavailable until playback starts")
+	assert.Equal(t, 3.5, <-o.ch)
+	assert.Equal(t, Event{Name: "property-change", Property: "time-pos", Data: 3.5}, <-events)
+	assert.Equal(t, "pause", (<-events).Property)
+	assert.Equal(t, Event{Name: "end-file", Reason: "error", FileError: "loading failed"}, <-events)
+	assert.Equal(t, Event{Name: "client-message", Args: []string{"goanime-skip"}}, <-events)
+
+	o.cancel()
+	_, open := <-o.ch
+	assert.False(t, open)
+	unobserve := m.next()
+	assert.Equal(t, []interface{}{"unobserve_property", float64(id)}, unobserve.Command)
+}
+
+func TestSlowObserversKeepTheLatestValues(t *testing.T) {
+	ch := make(chan interface{}, 2)
+	for i := 1; i <= 5; i++ {
+		deliver(ch, interface{}(i))
+	}
+	assert.Equal(t, 4, <-ch)
+	assert.Equal(t, 5, <-ch)
+}
+
+func TestEventsKeepEndFileUnderPropertyFloods(t *testing.T) {
+	c, m := newPair(t)
+
+	events, stop := c.Events()
+	defer stop()
+	for i := 1; i <= 500; i++ {
+		m.send(`{"event":"property-change","id":1,"name":"time-pos","data":%d}`, i)
+	}
+	m.send(`{"event":"end-file","reason":"eof"}`)
+	m.send(`{"event":"property-change","id":1,"name":"time-pos","data":501}`)
+	m.send(`{"event":"property-change","id":2,"name":"pause","data":true}`)
+
+	var got []Event
+	for len(got) == 0 || got[len(got)-1].Property != "pause" {
+		select {
+		case ev := <-events:
+			got = append(got, ev)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("events stopped after %v", got)
+		}
+	}
+	var names []string
+	for _, ev := range got {
+		names = append(names, ev.Name)
+	}
+	assert.Contains(t, names, "end-file", "end-file is never dropped")
+	assert.Equal(t, Event{Name: "property-change", Property: "time-pos", Data: float64(501)}, got[len(got)-2],
+		"the latest position arrives after end-file")
+}
+
+func TestEventQueueCoalescesPropertyChanges(t *testing.T) {
+	q := NewEventQueue()
+	// The first event may already be on its way to the subscriber
+	q.Push(Event{Name: "start-file"})
+	q.Push(Event{Name: "property-change", Property: "time-pos", Data: 1.0})
+	q.Push(Event{Name: "property-change", Property: "pause", Data: false})
+	q.Push(Event{Name: "end-file", Reason: "error"})
+	q.Push(Event{Name: "property-change", Property: "time-pos", Data: 2.0})
+	q.Close()
+
+	var got []Event
+	for ev := range q.C() {
+		got = append(got, ev)
+	}
+	assert.Equal(t, []Event{
+		{Name: "start-file"},
+		{Name: "property-change", Property: "pause", Data: false},
+		{Name: "end-file", Reason: "error"},
+		{Name: "property-change", Property: "time-pos", Data: 2.0},
+	}, got)
+}
+
+func TestConnectionEndClosesSubscriptions(t *testing.T) {
+	c, m := newPair(t)
+
+	events, _ := c.Events()
+	errc := make(chan error, 1)
+	go func() { _, err := c.Command("quit"); errc <- err }()
+	m.next()
+	require.NoError(t, m.conn.Close())
+
+	assert.True(t, errors.Is(<-errc, ErrClosed))
+	_, open := <-events
+	assert.False(t, open)
+	<-c.Done()
+	_, err := c.Command("quit")
+	assert.ErrorIs(t, err, ErrClosed)
+	_, _, err = c.Observe("pause")
+	assert.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,105 @@
+package mpvipc
+
+import "sync"
+
+// EventQueue hands events to one subscriber without ever blocking the sender.
+// Events wait until the subscriber takes them; a property change still waiting
+// is dropped when a newer value of the same property arrives, so a busy
+// time-pos can never push out an event such as end-file.
+type EventQueue struct {
+	out  chan Event
+	wake chan struct{}
+	stop chan struct{}
+
+	mu       sync.Mutex
+	queue    []Event
+	ended    bool
+	stopOnce sync.Once
+}
+
+// NewEventQueue starts a queue; its events are received from C
+func NewEventQueue() *EventQueue {
+	q := &EventQueue{
+		out:  make(chan Event),
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// C is the channel the events are received from
+func (q *EventQueue) C() <-chan Event {
+	return q.out
+}
+
+// Push queues ev, replacing the queued change of the same property
+func (q *EventQueue) Push(ev Event) {
+	q.mu.Lock()
+	if q.ended {
+		q.mu.Unlock()
+		return
+	}
+	if ev.Name == "property-change" {
+		for i, queued := range q.queue {
+			if queued.Name == "property-change" && queued.Property == ev.Property {
+				// The newer value goes last so it stays after the events it followed
+				q.queue = append(q.queue[:i], q.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	q.queue = append(q.queue, ev)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// Close closes C once the queued events were received
+func (q *EventQueue) Close() {
+	q.mu.Lock()
+	q.ended = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+// Cancel closes C now, dropping the queued events
+func (q *EventQueue) Cancel() {
+	q.stopOnce.Do(func() { close(q.stop) })
+}
+
+func (q *EventQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the queued events in order until the queue is closed or cancelled
+func (q *EventQueue) run() {
+	defer close(q.out)
+	for {
+		q.mu.Lock()
+		if len(q.queue) == 0 {
+			ended := q.ended
+			q.mu.Unlock()
+			if ended {
+				return
+			}
+			select {
+			case <-q.wake:
+			case <-q.stop:
+				return
+			}
+			continue
+		}
+		ev := q.queue[0]
+		q.queue = q.queue[1:]
+		q.mu.Unlock()
+
+		select {
+		case q.out <- ev:
+		case <-q.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/util"
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieEntry is one line of a Netscape cookies.txt file
+type CookieEntry struct {
+	Domain            string
+	IncludeSubdomains bool
+	Path              string
+	Secure            bool
+	HttpOnly          bool
+	Expires           time.Time // zero for session cookies
+	Name              string
+	Value             string
+}
+
+func (e CookieEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !e.Expires.After(now)
+}
+
+// matches reports whether the entry should be sent to u
+func (e CookieEntry) matches(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	domain := strings.ToLower(strings.TrimPrefix(e.Domain, "."))
+	if host != domain && !(e.IncludeSubdomains && strings.HasSuffix(host, "."+domain)) {
+		return false
+	}
+	if e.Secure && u.Scheme != "https" {
+		return false
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	return e.Path == "" || path == e.Path || strings.HasPrefix(path, strings.TrimSuffix(e.Path, "/")+"/")
+}
+
+// ParseCookiesTxt reads a Netscape cookies.txt file as exported by browser extensions,
+// curl and yt-dlp. Malformed lines are skipped.
+func ParseCookiesTxt(r io.Reader) ([]CookieEntry, error) {
+	var entries []CookieEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			httpOnly = true
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			continue
+		}
+		e := CookieEntry{
+			Domain:            fields[0],
+			IncludeSubdomains: strings.EqualFold(fields[1], "TRUE"),
+			Path:              fields[2],
+			Secure:            strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly:          httpOnly,
+			Name:              fields[5],
+			Value:             strings.Join(fields[6:], "\t"),
+		}
+		if exp, err := strconv.ParseInt(fields[4], 10, 64); err == nil && exp > 0 {
+			e.Expires = time.Unix(exp, 0)
+		}
+		if e.Domain == "" || e.Name == "" {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// WriteCookiesTxt writes entries in the Netscape format understood by mpv and yt-dlp
+func WriteCookiesTxt(w io.Writer, entries []CookieEntry) error {
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintln(bw, "# Netscape HTTP Cookie File")
+	_, _ = fmt.Fprintln(bw, "# Written by GoAnime; edits are kept but may be overwritten by new cookies.")
+	for _, e := range entries {
+		prefix := ""
+		if e.HttpOnly {
+			prefix = "#HttpOnly_"
+		}
+		var expires int64
+		if !e.Expires.IsZero() {
+			expires = e.Expires.Unix()
+		}
+		_, _ = fmt.Fprintf(bw, "%s%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			prefix, e.Domain, boolTxt(e.IncludeSubdomains), e.Path, boolTxt(e.Secure), expires, e.Name, e.Value)
+	}
+	return bw.Flush()
+}
+
+func boolTxt(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// Jar is a persistent http.CookieJar backed by a cookies.txt file
+type Jar struct {
+	mu      sync.Mutex
+	path    string
+	entries []CookieEntry
+}
+
+// loadJar reads the jar at path; a missing file yields an empty jar
+func loadJar(path string) *Jar {
+	j := &Jar{path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		return j
+	}
+	defer func() { _ = f.Close() }()
+	entries, err := ParseCookiesTxt(f)
+	if err != nil {
+		util.Debug("Ignoring unreadable cookie jar", "path", path, "error", err)
+	}
+	now := time.Now()
+	for _, e := range entries {
+		if !e.expired(now) {
+			j.entries = append(j.entries, e)
+		}
+	}
+	return j
+}
+
+// Cookies implements http.CookieJar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	var cookies []*http.Cookie
+	for _, e := range j.entries {
+		if !e.expired(now) && e.matches(u) {
+			cookies = append(cookies, &http.Cookie{Name: e.Name, Value: e.Value})
+		}
+	}
+	return cookies
+}
+
+// SetCookies implements http.CookieJar and persists the jar
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	now := time.Now()
+	var entries []CookieEntry
+	for _, c := range cookies {
+		e := CookieEntry{
+			Domain:   strings.ToLower(u.Hostname()),
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			Expires:  c.Expires,
+			Name:     c.Name,
+			Value:    c.Value,
+		}
+		if c.Domain != "" {
+			domain, hostOnly, ok := cookieDomain(e.Domain, c.Domain)
+			if !ok {
+				util.Debug("Rejected cookie for a foreign domain", "host", e.Domain, "domain", c.Domain, "name", c.Name)
+				continue
+			}
+			if !hostOnly {
+				e.Domain = "." + domain
+				e.IncludeSubdomains = true
+			}
+		}
+		if e.Path == "" || !strings.HasPrefix(e.Path, "/") {
+			e.Path = "/"
+		}
+		switch {
+		case c.MaxAge < 0:
+			e.Expires = now.Add(-time.Second)
+		case c.MaxAge > 0:
+			e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		entries = append(entries, e)
+	}
+	j.Add(entries...)
+}
+
+// cookieDomain checks the Domain attribute of a cookie set by host the way
+// net/http/cookiejar does: host must domain-match it, IP addresses only get
+// host cookies, and a cookie for a public suffix (such as "com" or
+// "github.io") stays with the host that set it, if that host is the suffix.
+func cookieDomain(host, attr string) (domain string, hostOnly bool, ok bool) {
+	domain = strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(attr), "."), ".")
+	if net.ParseIP(host) != nil {
+		return host, true, domain == host
+	}
+	if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+		return host, true, domain == host
+	}
+	if host != domain && !strings.HasSuffix(host, "."+domain) {
+		return "", false, false
+	}
+	return domain, false, true
+}
+
+// Add inserts or replaces entries (same domain, path and name) and persists the jar.
+// Expired entries delete their counterpart.
+func (j *Jar) Add(entries ...CookieEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range entries {
+		kept := j.entries[:0]
+		for _, old := range j.entries {
+			if !strings.EqualFold(old.Domain, e.Domain) || old.Path != e.Path || old.Name != e.Name {
+				kept = append(kept, old)
+			}
+		}
+		j.entries = kept
+		if !e.expired(now) {
+			j.entries = append(j.entries, e)
+		}
+	}
+	j.saveLocked()
+}
+
+// Entries returns a copy of the cookies currently in the jar
+func (j *Jar) Entries() []CookieEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]CookieEntry(nil), j.entries...)
+}
+
+// Clear removes every cookie and deletes the file
+func (j *Jar) Clear() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = nil
+	cookiesChanged.Add(1)
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (j *Jar) saveLocked() {
+	cookiesChanged.Add(1)
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		util.Debug("Failed to persist cookies", "error", err)
+		return
+	}
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		util.Debug("Failed to persist cookies", "error", err)
+		return
+	}
+	err = WriteCookiesTxt(f, j.entries)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, j.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		util.Debug("Failed to persist cookies", "error", err)
+	}
+}
+
+// sharedJarName holds cookies set through clients not tied to a source
+const sharedJarName = "shared"
+
+var (
+	jarsMu sync.Mutex
+	jars   = map[string]*Jar{}
+	// jarLists caches allJars per cookie directory until a new jar is opened
+	jarLists = map[string][]*Jar{}
+
+	// cookiesChanged counts changes to any jar, so the export for mpv and
+	// yt-dlp is only rewritten after one
+	cookiesChanged atomic.Uint64
+)
+
+// CookieDir is where the per-source cookie jars live
+func CookieDir() string {
+	return filepath.Join(config.Dir(), "cookies")
+}
+
+// SourceJar returns the persistent cookie jar of source
+func SourceJar(source string) *Jar {
+	jarsMu.Lock()
+	defer jarsMu.Unlock()
+	return sourceJarLocked(CookieDir(), source)
+}
+
+func sourceJarLocked(dir, source string) *Jar {
+	if source == "" {
+		source = sharedJarName
+	}
+	path := filepath.Join(dir, source+".txt")
+	if j, ok := jars[path]; ok {
+		return j
+	}
+	j := loadJar(path)
+	jars[path] = j
+	delete(jarLists, dir)
+	cookiesChanged.Add(1)
+	return j
+}
+
+// allJars returns every jar on disk so clients without a source can see all
+// cookies. The directory is listed once; jars opened later are added.
+func allJars() []*Jar {
+	dir := CookieDir()
+	jarsMu.Lock()
+	defer jarsMu.Unlock()
+	if list, ok := jarLists[dir]; ok {
+		return list
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.txt"))
+	names := []string{sharedJarName}
+	for _, f := range files {
+		if name := strings.TrimSuffix(filepath.Base(f), ".txt"); name != sharedJarName {
+			names = append(names, name)
+		}
+	}
+	for path := range jars {
+		if filepath.Dir(path) == dir {
+			if name := strings.TrimSuffix(filepath.Base(path), ".txt"); name != sharedJarName {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names[1:])
+
+	list := make([]*Jar, 0, len(names))
+	seen := map[*Jar]bool{}
+	for _, name := range names {
+		if j := sourceJarLocked(dir, name); !seen[j] {
+			seen[j] = true
+			list = append(list, j)
+		}
+	}
+	jarLists[dir] = list
+	return list
+}
+
+// CookieJar returns the jar for clients of source. Clients without a source read
+// cookies from every jar and store new ones in the jar that already knows the host.
+func CookieJar(source string) http.CookieJar {
+	if source != "" {
+		return SourceJar(source)
+	}
+	return sharedView{}
+}
+
+// sharedView spans every per-source jar; jars are looked up per call so a change
+// of config directory (tests) is picked up
+type sharedView struct{}
+
+func (sharedView) Cookies(u *url.URL) []*http.Cookie {
+	var cookies []*http.Cookie
+	for _, j := range allJars() {
+		cookies = append(cookies, j.Cookies(u)...)
+	}
+	return cookies
+}
+
+func (sharedView) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	for _, j := range allJars() {
+		if len(j.Cookies(u)) > 0 {
+			j.SetCookies(u, cookies)
+			return
+		}
+	}
+	SourceJar("").SetCookies(u, cookies)
+}
+
+var (
+	exportMu sync.Mutex
+	// exported is the last export, reused while no jar changed
+	exported struct {
+		dir     string
+		changes uint64
+		path    string
+	}
+)
+
+// ExportCookies merges every jar into a single cookies.txt for mpv and yt-dlp and
+// returns its path, or "" when there are no cookies to forward. The file is only
+// rewritten when a jar changed since the last export.
+func ExportCookies() (string, error) {
+	exportMu.Lock()
+	defer exportMu.Unlock()
+
+	dir := CookieDir()
+	jarList := allJars()
+	changes := cookiesChanged.Load()
+	if exported.dir == dir && exported.changes == changes {
+		if exported.path == "" {
+			return "", nil
+		}
+		if _, err := os.Stat(exported.path); err == nil {
+			return exported.path, nil
+		}
+	}
+
+	var entries []CookieEntry
+	for _, j := range jarList {
+		entries = append(entries, j.Entries()...)
+	}
+	path := ""
+	if len(entries) > 0 {
+		path = filepath.Join(dir, "export", "cookies.txt")
+		if err := writeExport(path, entries); err != nil {
+			return "", err
+		}
+	}
+	exported.dir, exported.changes, exported.path = dir, changes, path
+	return path, nil
+}
+
+// writeExport writes entries to path, creating its directory
+func writeExport(path string, entries []CookieEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = WriteCookiesTxt(f, entries)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// CookiesFile is ExportCookies for callers that only forward cookies when available
+func CookiesFile() string {
+	path, err := ExportCookies()
+	if err != nil {
+		util.Debug("Failed to export cookies", "error", err)
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,158 @@
+package network
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleCookiesTxt = "# Netscape HTTP Cookie File\n" +
+	".animefire.plus\tTRUE\t/\tTRUE\t4102444800\tcf_clearance\tabc123\n" +
+	"#HttpOnly_animefire.plus\tFALSE\t/\tFALSE\t0\tsession\txyz\n" +
+	"broken line\n" +
+	"allanime.day\tFALSE\t/api\tFALSE\t4102444800\ttoken\tt\tab\n"
+
+func TestParseCookiesTxtRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	entries, err := ParseCookiesTxt(strings.NewReader(sampleCookiesTxt))
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "cf_clearance", entries[0].Name)
+	assert.True(t, entries[0].IncludeSubdomains)
+	assert.True(t, entries[0].Secure)
+	assert.Equal(t, time.Unix(4102444800, 0), entries[0].Expires)
+	assert.True(t, entries[1].HttpOnly)
+	assert.True(t, entries[1].Expires.IsZero())
+	assert.Equal(t, "t\tab", entries[2].Value)
+
+	var buf strings.Builder
+	require.NoError(t, WriteCookiesTxt(&buf, entries))
+	again, err := ParseCookiesTxt(strings.NewReader(buf.String()))
+	require.NoError(t, err)
+	assert.Equal(t, entries, again)
+}
+
+func TestJarMatchingAndPersistence(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	entries, err := ParseCookiesTxt(strings.NewReader(sampleCookiesTxt))
+	require.NoError(t, err)
+	SourceJar(SourceAnimeFire).Add(entries...)
+
+	names := func(jar http.CookieJar, raw string) []string {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		var out []string
+		for _, c := range jar.Cookies(u) {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+	jar := CookieJar(SourceAnimeFire)
+	assert.Equal(t, []string{"cf_clearance", "session"}, names(jar, "https://animefire.plus/animes"))
+	assert.Equal(t, []string{"cf_clearance"}, names(jar, "https://www.animefire.plus/"))
+	assert.Equal(t, []string{"session"}, names(jar, "http://animefire.plus/"))
+	assert.Equal(t, []string{"token"}, names(jar, "http://allanime.day/api/graphql"))
+	assert.Empty(t, names(jar, "http://allanime.day/other"))
+
+	// Server cookies replace imported ones and survive a reload from disk
+	u, _ := url.Parse("https://animefire.plus/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "new"}, {Name: "cf_clearance", Domain: "animefire.plus", MaxAge: -1}})
+
+	path := filepath.Join(CookieDir(), SourceAnimeFire+".txt")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	if os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+	reloaded := loadJar(path)
+	got := map[string]string{}
+	for _, e := range reloaded.Entries() {
+		got[e.Name] = e.Value
+	}
+	assert.Equal(t, map[string]string{"session": "new", "token": "t\tab"}, got)
+
+	require.NoError(t, SourceJar(SourceAnimeFire).Clear())
+	assert.NoFileExists(t, path)
+}
+
+func TestSharedViewAndExport(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	path, err := ExportCookies()
+	require.NoError(t, err)
+	assert.Empty(t, path, "nothing to forward without cookies")
+
+	SourceJar(SourceAllAnime).Add(CookieEntry{Domain: "allanime.day", Path: "/", Name: "a", Value: "1"})
+	shared := CookieJar("")
+	u, _ := url.Parse("https://allanime.day/")
+	require.Len(t, shared.Cookies(u), 1)
+
+	// New cookies for a host a jar already knows go to that jar, others to the shared jar
+	shared.SetCookies(u, []*http.Cookie{{Name: "b", Value: "2"}})
+	cdn, _ := url.Parse("https://cdn.example.com/video.m3u8")
+	shared.SetCookies(cdn, []*http.Cookie{{Name: "c", Value: "3"}})
+	assert.Len(t, SourceJar(SourceAllAnime).Entries(), 2)
+	assert.Len(t, SourceJar("").Entries(), 1)
+
+	path, err = ExportCookies()
+	require.NoError(t, err)
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	exported, err := ParseCookiesTxt(f)
+	require.NoError(t, err)
+	assert.Len(t, exported, 3)
+}
+
+func TestSetCookiesChecksDomain(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	jar := SourceJar(SourceAnimeFire)
+	u, _ := url.Parse("https://www.animefire.plus/")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "parent", Value: "1", Domain: ".animefire.plus"},
+		{Name: "foreign", Value: "2", Domain: "allanime.day"},
+		{Name: "suffix", Value: "3", Domain: "plus"},
+		{Name: "child", Value: "4", Domain: "cdn.www.animefire.plus"},
+	})
+
+	domains := map[string]string{}
+	for _, e := range jar.Entries() {
+		domains[e.Name] = e.Domain
+	}
+	assert.Equal(t, map[string]string{"parent": ".animefire.plus"}, domains)
+
+	ip, _ := url.Parse("http://127.0.0.1:8080/")
+	jar.SetCookies(ip, []*http.Cookie{{Name: "ip", Value: "5", Domain: "127.0.0.1"}, {Name: "other", Value: "6", Domain: "0.0.1"}})
+	ipNames := []string{}
+	for _, c := range jar.Cookies(ip) {
+		ipNames = append(ipNames, c.Name)
+	}
+	assert.Equal(t, []string{"ip"}, ipNames)
+}
+
+func TestExportIsRewrittenOnlyAfterChanges(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	SourceJar(SourceAllAnime).Add(CookieEntry{Domain: "allanime.day", Path: "/", Name: "a", Value: "1"})
+
+	path := CookiesFile()
+	require.NotEmpty(t, path)
+	require.NoError(t, os.WriteFile(path, []byte("unchanged"), 0600))
+	assert.Equal(t, path, CookiesFile())
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "unchanged", string(data), "the export is reused while no jar changed")
+
+	SourceJar(SourceAllAnime).Add(CookieEntry{Domain: "allanime.day", Path: "/", Name: "b", Value: "2"})
+	assert.Equal(t, path, CookiesFile())
+	data, _ = os.ReadFile(path)
+	assert.Contains(t, string(data), "\tb\t2", "a new cookie rewrites the export")
+}
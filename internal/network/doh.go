@@ -0,0 +1,169 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// DNS record types queried through DoH
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+)
+
+// minimum time a DoH answer is cached, regardless of the record TTL
+const dohMinTTL = 30 * time.Second
+
+// dohResponse is the subset of the application/dns-json answer format we use
+type dohResponse struct {
+	Status int `json:"Status"`
+	Answer []struct {
+		Type int    `json:"type"`
+		TTL  int    `json:"TTL"`
+		Data string `json:"data"`
+	} `json:"Answer"`
+}
+
+type dohEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// dohResolver resolves hostnames via a DNS-over-HTTPS JSON endpoint and caches answers
+type dohResolver struct {
+	endpoint string
+	client   *http.Client
+
+	mu    sync.Mutex
+	cache map[string]dohEntry
+}
+
+var (
+	resolversMu sync.Mutex
+	resolvers   = map[string]*dohResolver{}
+)
+
+// dohEndpoint returns the active DoH endpoint: -doh flag first, then config
+func dohEndpoint() string {
+	if util.GlobalDoH != "" {
+		return util.GlobalDoH
+	}
+	return config.Get().Network.DoH
+}
+
+func resolverFor(endpoint string) *dohResolver {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	r, ok := resolvers[endpoint]
+	if !ok {
+		r = newDoHResolver(endpoint, nil)
+		resolvers[endpoint] = r
+	}
+	return r
+}
+
+// newDoHResolver creates a resolver; the endpoint host itself is resolved by the system resolver
+func newDoHResolver(endpoint string, client *http.Client) *dohResolver {
+	if client == nil {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.Proxy = ProxyFunc("")
+		client = &http.Client{Timeout: 10 * time.Second, Transport: tr}
+	}
+	return &dohResolver{
+		endpoint: endpoint,
+		client:   client,
+		cache:    make(map[string]dohEntry),
+	}
+}
+
+// LookupIP returns the IPv4 addresses of host, falling back to IPv6 when there are none
+func (r *dohResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	r.mu.Lock()
+	if e, ok := r.cache[host]; ok && time.Now().Before(e.expires) {
+		r.mu.Unlock()
+		return e.ips, nil
+	}
+	r.mu.Unlock()
+
+	ips, ttl, err := r.query(ctx, host, dnsTypeA)
+	if err == nil && len(ips) == 0 {
+		ips, ttl, err = r.query(ctx, host, dnsTypeAAAA)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("doh: no addresses for %s", host)
+	}
+
+	if ttl < dohMinTTL {
+		ttl = dohMinTTL
+	}
+	r.mu.Lock()
+	r.cache[host] = dohEntry{ips: ips, expires: time.Now().Add(ttl)}
+	r.mu.Unlock()
+	return ips, nil
+}
+
+func (r *dohResolver) query(ctx context.Context, host string, qtype int) ([]net.IP, time.Duration, error) {
+	u, err := url.Parse(r.endpoint)
+	if err != nil {
+		return nil, 0, fmt.Errorf("doh: invalid endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("name", host)
+	q.Set("type", fmt.Sprint(qtype))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/dns-json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("doh: request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("doh: endpoint returned status %d", resp.StatusCode)
+	}
+
+	var body dohResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, 0, fmt.Errorf("doh: invalid response: %w", err)
+	}
+	if body.Status != 0 {
+		return nil, 0, fmt.Errorf("doh: lookup of %s failed with rcode %d", host, body.Status)
+	}
+
+	var ips []net.IP
+	ttl := time.Duration(0)
+	for _, ans := range body.Answer {
+		if ans.Type != qtype {
+			continue // skip CNAME chain entries
+		}
+		ip := net.ParseIP(ans.Data)
+		if ip == nil {
+			continue
+		}
+		ips = append(ips, ip)
+		if d := time.Duration(ans.TTL) * time.Second; ttl == 0 || d < ttl {
+			ttl = d
+		}
+	}
+	return ips, ttl, nil
+}
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxHeaderValue bounds forwarded header values
+const maxHeaderValue = 2048
+
+// streamHeaderNames are the headers a source may ask mpv and yt-dlp to send.
+// Anything else (Cookie, Authorization, Host, ...) is never forwarded from
+// scraped data; cookies go through the cookie jar instead.
+var streamHeaderNames = map[string]bool{
+	"Referer":    true,
+	"Origin":     true,
+	"User-Agent": true,
+}
+
+// ValidStreamHeader checks that name: value may be forwarded to a player or
+// downloader: an allowed name, no control characters, and an http(s) URL for
+// Referer and Origin.
+func ValidStreamHeader(name, value string) error {
+	name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+	if !streamHeaderNames[name] {
+		return fmt.Errorf("header %q is not forwarded", name)
+	}
+	if value == "" || len(value) > maxHeaderValue {
+		return fmt.Errorf("header %s has an invalid length", name)
+	}
+	for _, r := range value {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("header %s contains control characters", name)
+		}
+	}
+	switch name {
+	case "Referer", "Origin":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("header %s must be an http(s) URL", name)
+		}
+		// Origin is sent through mpv's comma-separated header list
+		if name == "Origin" && strings.Contains(value, ",") {
+			return fmt.Errorf("header Origin must not contain commas")
+		}
+	}
+	return nil
+}
+
+// StreamHeaders returns the headers of h that pass ValidStreamHeader, with
+// canonical names. Rejected headers are dropped.
+func StreamHeaders(h map[string]string) map[string]string {
+	out := map[string]string{}
+	for name, value := range h {
+		if err := ValidStreamHeader(name, value); err != nil {
+			continue
+		}
+		out[http.CanonicalHeaderKey(strings.TrimSpace(name))] = value
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,36 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidStreamHeader(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, ValidStreamHeader("Referer", "https://allmanga.to/"))
+	assert.NoError(t, ValidStreamHeader("referer", "https://a.example/,1080p,/master.m3u8"), "names are canonicalised and Referer may contain commas")
+	assert.NoError(t, ValidStreamHeader("User-Agent", UserAgent))
+	assert.NoError(t, ValidStreamHeader("Origin", "https://allmanga.to"))
+
+	assert.ErrorContains(t, ValidStreamHeader("Cookie", "session=1"), "not forwarded")
+	assert.ErrorContains(t, ValidStreamHeader("Authorization", "Bearer x"), "not forwarded")
+	assert.ErrorContains(t, ValidStreamHeader("Referer", "https://a.example/\r\nX-Evil: 1"), "control characters")
+	assert.ErrorContains(t, ValidStreamHeader("Referer", "javascript:alert(1)"), "http(s) URL")
+	assert.ErrorContains(t, ValidStreamHeader("Origin", "https://a.example,https://b.example"), "commas")
+	assert.Error(t, ValidStreamHeader("User-Agent", ""))
+}
+
+func TestStreamHeadersDropsRejectedHeaders(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, map[string]string{"Referer": "https://a.example/", "User-Agent": "mpv"}, StreamHeaders(map[string]string{
+		"referer":    "https://a.example/",
+		"user-agent": "mpv",
+		"Cookie":     "session=1",
+		"Origin":     "file:///etc/passwd",
+	}))
+	assert.Nil(t, StreamHeaders(map[string]string{"Host": "a.example"}))
+	assert.Nil(t, StreamHeaders(nil))
+}
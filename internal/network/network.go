@@ -0,0 +1,199 @@
+// Package network builds HTTP transports that honor the user's proxy and DNS settings
+package network
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/util"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Source keys accepted in network.source_proxies
+const (
+	SourceAllAnime  = "allanime"
+	SourceAnimeFire = "animefire"
+)
+
+// UserAgent is the browser identity sent to sources that reject non-browser clients
+const UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/121.0"
+
+// directProxy disables proxying when used as a per-source override
+const directProxy = "direct"
+
+// ProxyURL returns the proxy configured for source, or "" when traffic goes direct.
+// Precedence: -proxy flag, per-source override, global config proxy, environment.
+func ProxyURL(source string) string {
+	if util.GlobalProxy != "" {
+		return normalizeProxy(util.GlobalProxy)
+	}
+	netCfg := config.Get().Network
+	if p, ok := netCfg.SourceProxies[source]; ok && source != "" {
+		return normalizeProxy(p)
+	}
+	if netCfg.Proxy != "" {
+		return normalizeProxy(netCfg.Proxy)
+	}
+	env := httpproxy.FromEnvironment()
+	if env.HTTPSProxy != "" {
+		return env.HTTPSProxy
+	}
+	return env.HTTPProxy
+}
+
+func normalizeProxy(p string) string {
+	p = strings.TrimSpace(p)
+	if strings.EqualFold(p, directProxy) || strings.EqualFold(p, "none") {
+		return ""
+	}
+	return p
+}
+
+// noProxy returns the bypass list from config, falling back to NO_PROXY
+func noProxy() string {
+	if np := config.Get().Network.NoProxy; np != "" {
+		return np
+	}
+	return httpproxy.FromEnvironment().NoProxy
+}
+
+// ProxyFunc returns an http.Transport Proxy function for source.
+// Settings are evaluated per request so flags parsed after client creation still apply.
+func ProxyFunc(source string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		p := ProxyURL(source)
+		if p == "" {
+			return nil, nil
+		}
+		cfg := httpproxy.Config{HTTPProxy: p, HTTPSProxy: p, NoProxy: noProxy()}
+		return cfg.ProxyFunc()(req.URL)
+	}
+}
+
+// IsProxyAddr reports whether addr (host:port) is the address of a configured proxy.
+// Used to let a local proxy (e.g. Tor on 127.0.0.1) pass private-address checks.
+func IsProxyAddr(addr string) bool {
+	candidates := []string{ProxyURL("")}
+	if util.GlobalProxy == "" {
+		for _, p := range config.Get().Network.SourceProxies {
+			candidates = append(candidates, normalizeProxy(p))
+		}
+	}
+	for _, p := range candidates {
+		if p == "" {
+			continue
+		}
+		u, err := url.Parse(p)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if sameHostPort(u, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameHostPort(u *url.URL, addr string) bool {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	host, aport, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(host, u.Hostname()) && aport == port
+}
+
+// Dial connects to addr using dialer, resolving the host through DoH when configured
+func Dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	endpoint := dohEndpoint()
+	host, port, err := net.SplitHostPort(addr)
+	if endpoint == "" || err != nil || net.ParseIP(host) != nil || strings.EqualFold(host, "localhost") {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	ips, err := resolverFor(endpoint).LookupIP(ctx, host)
+	if err != nil {
+		util.Debug("DoH lookup failed, falling back to system resolver", "host", host, "error", err)
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		conn, derr := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if derr == nil {
+			return conn, nil
+		}
+		lastErr = derr
+	}
+	return nil, lastErr
+}
+
+// DialContext returns a DialContext function for http.Transport backed by Dial
+func DialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return Dial(ctx, dialer, network, addr)
+	}
+}
+
+// NewTransport returns a transport for source that applies the proxy and DoH settings
+func NewTransport(source string) *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = ProxyFunc(source)
+	tr.DialContext = DialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	return tr
+}
+
+// NewClient returns an http.Client for source with the given timeout (0 means none).
+// Cookies persist in the source's jar, see CookieJar.
+func NewClient(source string, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Jar:       CookieJar(source),
+		Transport: Overridable(NewTransport(source)),
+	}
+}
+
+// Overridable wraps base so SetRoundTripper applies to it, for clients that
+// need their own transport but must still replay fixtures in tests
+func Overridable(base http.RoundTripper) http.RoundTripper {
+	return &overridableTransport{base: base}
+}
+
+// override, when set, serves every request made by clients from NewClient
+var override atomic.Pointer[http.RoundTripper]
+
+// SetRoundTripper routes all NewClient traffic through rt (fixture replay in tests,
+// recording). It returns a function that restores the previous behaviour.
+func SetRoundTripper(rt http.RoundTripper) (restore func()) {
+	prev := override.Swap(&rt)
+	return func() { override.Store(prev) }
+}
+
+// overridableTransport consults the override per request so package-level
+// clients created at init time are covered too
+type overridableTransport struct {
+	base http.RoundTripper
+}
+
+func (t *overridableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt := override.Load(); rt != nil && *rt != nil {
+		return (*rt).RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
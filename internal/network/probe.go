@@ -0,0 +1,72 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// probeBytes is how much of an MP4 stream is requested when probing it
+const probeBytes = 1024
+
+// ProbeStream checks that rawURL serves video before it is handed to the player:
+// HLS playlists must be fetchable and start with #EXTM3U, other streams must
+// answer a range request with data. It returns the time to first byte.
+func ProbeStream(ctx context.Context, client *http.Client, rawURL string, headers map[string]string) (time.Duration, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	hls := strings.Contains(strings.ToLower(u.Path), ".m3u8")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if !hls {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", probeBytes-1))
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden && headers["Referer"] == "":
+		return 0, fmt.Errorf("%s (the host may require a Referer)", resp.Status)
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent:
+		return 0, fmt.Errorf("%s", resp.Status)
+	}
+
+	body := bufio.NewReader(io.LimitReader(resp.Body, probeBytes))
+	first, err := body.Peek(1)
+	ttfb := time.Since(start)
+	if len(first) == 0 {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, fmt.Errorf("empty response: %w", err)
+	}
+
+	if hls {
+		head, _ := body.Peek(probeBytes)
+		head = bytes.TrimPrefix(bytes.TrimSpace(head), []byte("\xef\xbb\xbf"))
+		if !bytes.HasPrefix(head, []byte("#EXTM3U")) {
+			return 0, fmt.Errorf("not an HLS playlist")
+		}
+	}
+	return ttfb, nil
+}
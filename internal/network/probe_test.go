@@ -0,0 +1,57 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeStream(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			_, _ = w.Write([]byte("\xef\xbb\xbf#EXTM3U\n#EXT-X-VERSION:3\n"))
+		case "/error.m3u8":
+			_, _ = w.Write([]byte("<html>blocked</html>"))
+		case "/video.mp4":
+			if r.Header.Get("Range") != "bytes=0-1023" {
+				http.Error(w, "range expected", http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte("ftypisom"))
+		case "/referer.mp4":
+			if r.Header.Get("Referer") == "" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte("ftypisom"))
+		case "/empty.mp4":
+			w.WriteHeader(http.StatusPartialContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	probe := func(path string, headers map[string]string) error {
+		_, err := ProbeStream(context.Background(), srv.Client(), srv.URL+path, headers)
+		return err
+	}
+
+	require.NoError(t, probe("/master.m3u8", nil))
+	require.NoError(t, probe("/video.mp4", nil))
+	require.NoError(t, probe("/referer.mp4", map[string]string{"Referer": "https://example.org/"}))
+
+	assert.ErrorContains(t, probe("/error.m3u8", nil), "not an HLS playlist")
+	assert.ErrorContains(t, probe("/referer.mp4", nil), "Referer")
+	assert.ErrorContains(t, probe("/empty.mp4", nil), "empty response")
+	assert.ErrorContains(t, probe("/missing.mp4", nil), "404")
+}
@@ -36,7 +36,7 @@ func NewAllAnimeNavigator(anime *models.Anime) (*AllAnimeNavigator, error) {
 	}
 
 	// Fetch episodes list
-	episodes, err := client.GetEpisodesList(animeID, "sub")
+	episodes, err := client.GetEpisodesList(animeID, util.Mode())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get episodes list: %w", err)
 	}
@@ -25,11 +25,20 @@ func PlayEpisode(
 	animeMutex *sync.Mutex,
 ) error {
 	animeMutex.Lock()
-	anime.Episodes = []models.Episode{{
+	current := models.Episode{
 		Number: episodeNumberStr,
 		Num:    episodeNum,
 		URL:    episodeURL,
-	}}
+	}
+	// Keep the titles and flags the episode list was enriched with
+	for _, ep := range episodes {
+		if ep.Number == episodeNumberStr {
+			current.Title, current.Aired = ep.Title, ep.Aired
+			current.IsFiller, current.IsRecap = ep.IsFiller, ep.IsRecap
+			break
+		}
+	}
+	anime.Episodes = []models.Episode{current}
 	animeMutex.Unlock()
 
 	if err := api.GetEpisodeData(anime.MalID, episodeNum, anime); err != nil {
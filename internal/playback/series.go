@@ -277,6 +277,9 @@ func ChangeAnimeLocal() (*models.Anime, []models.Episode, error) {
 			return nil, nil, fmt.Errorf("failed to find anime after %d attempts", maxRetries)
 		}
 
+		// A new show starts in sub whatever the last one was switched to
+		util.ResetMode()
+
 		// Get episodes for the new anime using enhanced API
 		episodes, err := api.GetAnimeEpisodesEnhanced(anime)
 		if err != nil {
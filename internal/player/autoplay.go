@@ -0,0 +1,181 @@
+package player
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/discord"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/streamproxy"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// bingeStreak counts the episodes autoplay started since the user last chose
+// something in the player menu. Only the playback goroutine touches it.
+var bingeStreak int
+
+// autoplayEnabled reports whether binge mode is on, from -autoplay or the config file
+func autoplayEnabled() bool {
+	return util.GlobalAutoplay || config.Get().Playback.Autoplay
+}
+
+// autoplayNext is the episode autoplay continues with
+type autoplayNext struct {
+	index int
+	url   string
+}
+
+// startAutoplay watches the episode playing in the player at socketPath. While
+// it plays, the next episode's stream is resolved; when it ends, a countdown is
+// shown in the player and the next episode is sent on the returned channel for
+// the caller to load into the same window. The channel is closed without a
+// value when autoplay is off, the cap is reached or no next episode can be
+// played; in the last two cases, and when playback is stopped without another
+// episode taking over, the player, kept idle for autoplay, is quit.
+func startAutoplay(socketPath string, episodes []models.Episode, currentIndex int, anime *models.Anime) <-chan autoplayNext {
+	out := make(chan autoplayNext, 1)
+	if !autoplayEnabled() {
+		close(out)
+		return out
+	}
+	p, err := playerFor(socketPath)
+	if err != nil {
+		util.Debugf("Autoplay disabled, cannot connect to the player: %v", err)
+		close(out)
+		return out
+	}
+
+	playback := config.Get().Playback
+	resolved := make(chan autoplayNext, 1)
+	next := currentIndex + 1
+	switch {
+	case next >= len(episodes):
+		util.Debug("Autoplay stops after the last episode")
+		close(resolved)
+	case bingeStreak >= playback.Limit():
+		util.Debugf("Autoplay limit of %d episodes reached", playback.Limit())
+		close(resolved)
+	default:
+		go func() {
+			defer close(resolved)
+			target := episodes[next]
+			url, err := resolveEpisodeURL(&target, anime)
+			if err != nil {
+				util.Debugf("Autoplay cannot resolve episode %s: %v", target.Number, err)
+				return
+			}
+			resolved <- autoplayNext{index: next, url: url}
+		}()
+	}
+
+	label := func(n autoplayNext) string {
+		return "Up next: Episode " + episodeLabel(episodes[n.index])
+	}
+	// Subscribe before returning so the end of a short file is not missed
+	events, stop := p.Events()
+	go func() {
+		defer stop()
+		runAutoplay(p, events, resolved, label, playback.Countdown(), out)
+	}()
+	return out
+}
+
+// runAutoplay implements startAutoplay over a player and its events. resolved
+// yields the next episode, or is closed without one when there is none.
+func runAutoplay(p Player, events <-chan Event, resolved <-chan autoplayNext, label func(autoplayNext) string, countdown time.Duration, out chan<- autoplayNext) {
+	defer close(out)
+	if _, unobserve, err := p.Observe("idle-active"); err != nil {
+		util.Debugf("Autoplay cannot follow whether the player is idle: %v", err)
+	} else {
+		defer unobserve()
+	}
+	stopped := false
+	for ev := range events {
+		switch {
+		case ev.Name == "end-file" && ev.Reason == "eof":
+			next, ok := <-resolved
+			if !ok {
+				_ = p.Quit()
+				return
+			}
+			if announceNext(p, label(next), countdown) {
+				out <- next
+			}
+			return
+		case ev.Name == "end-file" && ev.Reason == "stop":
+			// Either the episode is being replaced or playback was stopped
+			stopped = true
+		case stopped && ev.Name == "start-file":
+			return
+		case stopped && leftIdle(ev):
+			_ = p.Quit()
+			return
+		case ev.Name == "end-file" && ev.Reason == "quit":
+			return
+		}
+	}
+}
+
+// announceNext shows label with a countdown in the player; it reports false
+// when the player was closed before the countdown ended
+func announceNext(p Player, label string, countdown time.Duration) bool {
+	for remaining := countdown; remaining > 0; remaining -= time.Second {
+		seconds := int(math.Ceil(remaining.Seconds()))
+		msg := fmt.Sprintf("%s\nStarting in %ds, press q to stop", label, seconds)
+		showText(p, msg, 1100*time.Millisecond)
+		select {
+		case <-p.Done():
+			return false
+		case <-time.After(min(remaining, time.Second)):
+		}
+	}
+	select {
+	case <-p.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// loadVideo plays link in the running player at socketPath, the way
+// launchPlayer would have started it, resuming at resumeTime seconds when it
+// is positive
+func loadVideo(socketPath, link string, resumeTime int) error {
+	safeLink, err := sanitizeMediaTarget(link)
+	if err != nil {
+		return fmt.Errorf("invalid media target: %w", err)
+	}
+	p, err := playerFor(socketPath)
+	if err != nil {
+		return err
+	}
+
+	// Streams served by the local proxy get their headers from it
+	origin, proxied := streamproxy.Upstream(link)
+	opts := LoadOptions{
+		Subtitles: subtitleFiles(scraper.StreamSubtitles(origin)),
+		Start:     resumeTime,
+	}
+	if !proxied {
+		opts.Headers = scraper.StreamHeaders(link)
+	}
+	return p.Load(safeLink, opts)
+}
+
+// continueEpisode moves playback to the episode autoplay resolved, in the same mpv window
+func continueEpisode(next autoplayNext, episodes []models.Episode, anilistID int, updater *discord.RichPresenceUpdater, stopTracking chan struct{}, socketPath string) error {
+	targetNum, err := episodeNumber(episodes[next.index])
+	if err != nil {
+		return err
+	}
+
+	if updater != nil {
+		updater.Stop()
+	}
+	close(stopTracking)
+
+	return playVideoIn(socketPath, next.url, episodes, targetNum, anilistID, nextUpdater(updater, episodes[next.index]), -1)
+}
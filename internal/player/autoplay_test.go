@@ -0,0 +1,90 @@
+package player
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/mpvipc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startAutoplayWatcher(t *testing.T, resolved chan autoplayNext, countdown time.Duration) (*fakeMPV, chan autoplayNext) {
+	conn, server := net.Pipe()
+	client := mpvipc.New(conn)
+	t.Cleanup(func() { _ = client.Close() })
+	out := make(chan autoplayNext, 1)
+	label := func(n autoplayNext) string { return "Up next: Episode 2" }
+	events, stop := client.Events()
+	go func() {
+		defer stop()
+		runAutoplay(newMPVPlayer(client), events, resolved, label, countdown, out)
+	}()
+	m := &fakeMPV{t: t, conn: server, in: bufio.NewScanner(server)}
+	assert.Equal(t, "idle-active", m.expect()[2])
+	return m, out
+}
+
+func TestAutoplayContinuesAfterCountdown(t *testing.T) {
+	resolved := make(chan autoplayNext, 1)
+	resolved <- autoplayNext{index: 1, url: "https://b.example/ep2.mp4"}
+	m, out := startAutoplayWatcher(t, resolved, 1500*time.Millisecond)
+
+	m.emit(`{"event":"end-file","reason":"error"}`)
+	m.emit(`{"event":"end-file","reason":"eof"}`)
+	assert.Equal(t, []interface{}{"show-text", "Up next: Episode 2\nStarting in 2s, press q to stop", float64(1100)}, m.expect())
+	assert.Equal(t, []interface{}{"show-text", "Up next: Episode 2\nStarting in 1s, press q to stop", float64(1100)}, m.expect())
+
+	next, ok := <-out
+	require.True(t, ok)
+	assert.Equal(t, autoplayNext{index: 1, url: "https://b.example/ep2.mp4"}, next)
+}
+
+func TestAutoplayQuitsWithoutNextEpisode(t *testing.T) {
+	resolved := make(chan autoplayNext)
+	close(resolved)
+	m, out := startAutoplayWatcher(t, resolved, time.Second)
+
+	m.emit(`{"event":"end-file","reason":"eof"}`)
+	assert.Equal(t, []interface{}{"quit"}, m.expect())
+	_, ok := <-out
+	assert.False(t, ok)
+}
+
+func TestAutoplayStopsWhenMPVCloses(t *testing.T) {
+	resolved := make(chan autoplayNext, 1)
+	resolved <- autoplayNext{index: 1}
+	m, out := startAutoplayWatcher(t, resolved, time.Minute)
+
+	m.emit(`{"event":"end-file","reason":"eof"}`)
+	m.expect()
+	require.NoError(t, m.conn.Close())
+	_, ok := <-out
+	assert.False(t, ok, "closing mpv during the countdown cancels autoplay")
+}
+
+func TestAutoplayQuitsWhenStoppedPlaybackLeavesMPVIdle(t *testing.T) {
+	resolved := make(chan autoplayNext, 1)
+	resolved <- autoplayNext{index: 1}
+	m, out := startAutoplayWatcher(t, resolved, time.Second)
+
+	m.emit(`{"event":"end-file","reason":"stop"}`)
+	m.emit(`{"event":"property-change","name":"idle-active","data":true}`)
+	assert.Equal(t, []interface{}{"quit"}, m.expect(), "mpv is not left open with nothing to play")
+	_, ok := <-out
+	assert.False(t, ok)
+}
+
+func TestAutoplayLeavesAReplacedEpisodeAlone(t *testing.T) {
+	resolved := make(chan autoplayNext, 1)
+	resolved <- autoplayNext{index: 1}
+	m, out := startAutoplayWatcher(t, resolved, time.Second)
+
+	m.emit(`{"event":"end-file","reason":"stop"}`)
+	m.emit(`{"event":"start-file"}`)
+	_, ok := <-out
+	assert.False(t, ok)
+	assert.Equal(t, "unobserve_property", m.expect()[0], "mpv is not quit")
+}
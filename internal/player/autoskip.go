@@ -0,0 +1,235 @@
+package player
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// undoWindow is how long after a skip it can still be undone
+const undoWindow = 10 * time.Second
+
+// skipSegment is a part of the episode GoAnime can skip
+type skipSegment struct {
+	Kind  string // "opening", "ending", "recap" or "preview"
+	Start float64
+	End   float64
+	Mode  string // config.SkipAuto or config.SkipAsk
+}
+
+// skipSegments lists the segments of times that are not set to be played
+func skipSegments(times models.SkipTimes, cfg config.SkipConfig) []skipSegment {
+	type part struct {
+		kind string
+		skip models.Skip
+	}
+	all := []part{{"recap", times.Recap}, {"opening", times.Op}, {"ending", times.Ed}}
+	for _, iv := range times.Of(models.SkipTypePreview) {
+		all = append(all, part{"preview", models.Skip{Start: int(iv.Start), End: int(iv.End)}})
+	}
+
+	var segments []skipSegment
+	for _, s := range all {
+		mode := cfg.Mode(s.kind)
+		if s.skip.End <= s.skip.Start || mode == config.SkipNever {
+			continue
+		}
+		segments = append(segments, skipSegment{Kind: s.kind, Start: float64(s.skip.Start), End: float64(s.skip.End), Mode: mode})
+	}
+	return segments
+}
+
+// autoSkip holds the skip times of the episode being played and hands them to
+// its skipper as they change: AniSkip may answer late, and markers are
+// recorded while the episode plays
+type autoSkip struct {
+	mu       sync.Mutex // guards episode.SkipTimes and onChange, and orders the updates
+	episode  *models.Episode
+	onChange func(models.SkipTimes)
+	updates  chan []skipSegment
+	done     chan struct{} // closed once the skipper returned
+}
+
+// times returns the episode's skip times
+func (a *autoSkip) times() models.SkipTimes {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.episode.SkipTimes
+}
+
+// set changes the episode's skip times with change; the skipper follows the
+// new times while the episode plays
+func (a *autoSkip) set(change func(*models.SkipTimes)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	change(&a.episode.SkipTimes)
+	times := a.episode.SkipTimes
+	if a.onChange != nil {
+		a.onChange(times)
+	}
+	select {
+	case a.updates <- skipSegments(times, config.Get().Skip):
+	case <-a.done:
+	}
+}
+
+// follow calls f with the skip times now and each time they change
+func (a *autoSkip) follow(f func(models.SkipTimes)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onChange = f
+	f(a.episode.SkipTimes)
+}
+
+// playing reports whether the skipper still follows the episode
+func (a *autoSkip) playing() bool {
+	select {
+	case <-a.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// startAutoSkip follows the playback position of the player at socketPath and
+// skips the episode's opening, ending and recap as configured, with an OSD
+// message and a key to undo or accept the skip (bound by bindPlayerKeys). It
+// works for every source and needs no mpv script. When the player cannot be
+// followed, the skip times are only kept.
+func startAutoSkip(socketPath string, episode *models.Episode) *autoSkip {
+	a := &autoSkip{episode: episode, updates: make(chan []skipSegment), done: make(chan struct{})}
+	p, err := playerFor(socketPath)
+	if err != nil {
+		util.Debugf("Automatic skipping disabled, cannot connect to the player: %v", err)
+		close(a.done)
+		return a
+	}
+
+	events, stop := p.Events()
+	_, unobserve, err := p.Observe("time-pos")
+	if err != nil {
+		stop()
+		util.Debugf("Automatic skipping disabled, cannot follow the playback position: %v", err)
+		close(a.done)
+		return a
+	}
+	segments := skipSegments(a.times(), config.Get().Skip)
+	keys := config.Get().Keys
+	go func() {
+		defer close(a.done)
+		defer stop()
+		defer unobserve()
+		runAutoSkip(p, events, segments, a.updates, keys)
+	}()
+	return a
+}
+
+// runAutoSkip implements startAutoSkip over a player and its events, taking
+// new segments from updates. It returns when the episode ends or is replaced.
+func runAutoSkip(p Player, events <-chan Event, segments []skipSegment, updates <-chan []skipSegment, keys config.KeyConfig) {
+	var (
+		handled   = make([]bool, len(segments))
+		offered   = -1 // segment the user may skip with the skip key
+		position  float64
+		undoTo    float64
+		undoUntil time.Time
+	)
+	skip := func(s skipSegment) {
+		if err := p.Seek(s.End); err != nil {
+			util.Debugf("Failed to skip %s: %v", s.Kind, err)
+			return
+		}
+		undoTo, undoUntil = position, time.Now().Add(undoWindow)
+		msg := "Skipped " + s.Kind
+		if key := keys.Key("undo"); key != "" {
+			msg += " — press " + key + " to undo"
+		}
+		showText(p, msg, 3*time.Second)
+	}
+
+	for {
+		var ev Event
+		select {
+		case next := <-updates:
+			// Segments of a kind already handled stay handled
+			var offeredKind string
+			if offered >= 0 {
+				offeredKind = segments[offered].Kind
+			}
+			done := map[string]bool{}
+			for i, s := range segments {
+				done[s.Kind] = handled[i]
+			}
+			segments, handled, offered = next, make([]bool, len(next)), -1
+			for i, s := range segments {
+				handled[i] = done[s.Kind]
+				if s.Kind == offeredKind {
+					offered = i
+				}
+			}
+			continue
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			ev = e
+		}
+
+		switch {
+		case ev.Name == "property-change" && ev.Property == "time-pos":
+			pos, ok := ev.Data.(float64)
+			if !ok {
+				continue
+			}
+			position = pos
+			for i, s := range segments {
+				// Near its end a segment is not worth skipping
+				if handled[i] || pos < s.Start || pos >= s.End-1 {
+					continue
+				}
+				handled[i] = true
+				if key := keys.Key("skip"); s.Mode == config.SkipAsk && key != "" {
+					offered = i
+					showText(p, fmt.Sprintf("%s — press %s to skip", capitalize(s.Kind), key), 5*time.Second)
+				} else if s.Mode != config.SkipAsk {
+					skip(s)
+				}
+			}
+		case ev.Name == "client-message":
+			action, _ := keyAction(ev)
+			switch action {
+			case "skip":
+				if offered >= 0 && position < segments[offered].End {
+					skip(segments[offered])
+				}
+				offered = -1
+			case "undo":
+				if time.Now().Before(undoUntil) {
+					_ = p.Seek(undoTo)
+					undoUntil = time.Time{}
+					showText(p, "Skip undone", 2*time.Second)
+				}
+			}
+		case ev.Name == "end-file" && ev.Reason != "error":
+			// A failed stream is replaced by a mirror of the same episode
+			return
+		}
+	}
+}
+
+// showText shows msg on the player's OSD, where it has one
+func showText(p Player, msg string, d time.Duration) {
+	_ = p.ShowText(msg, d)
+}
+
+// capitalize upper-cases the first letter of an ASCII word
+func capitalize(s string) string {
+	if s == "" || s[0] < 'a' || s[0] > 'z' {
+		return s
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
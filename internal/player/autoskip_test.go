@@ -0,0 +1,101 @@
+package player
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/mpvipc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipSegmentsFollowConfig(t *testing.T) {
+	times := models.SkipTimes{
+		Op:    models.Skip{Start: 90, End: 180},
+		Ed:    models.Skip{Start: 1300, End: 1390},
+		Recap: models.Skip{Start: 0, End: 0},
+	}
+	segments := skipSegments(times, config.SkipConfig{Ending: config.SkipAsk})
+	assert.Equal(t, []skipSegment{
+		{Kind: "opening", Start: 90, End: 180, Mode: config.SkipAuto},
+		{Kind: "ending", Start: 1300, End: 1390, Mode: config.SkipAsk},
+	}, segments)
+
+	assert.Empty(t, skipSegments(times, config.SkipConfig{Opening: config.SkipNever, Ending: config.SkipNever}))
+
+	times.Add(models.Interval{Type: models.SkipTypePreview, Start: 1400, End: 1420})
+	segments = skipSegments(times, config.SkipConfig{Opening: config.SkipNever, Ending: config.SkipNever, Preview: config.SkipAsk})
+	assert.Equal(t, []skipSegment{{Kind: "preview", Start: 1400, End: 1420, Mode: config.SkipAsk}}, segments)
+}
+
+func startSkipper(t *testing.T, segments []skipSegment, updates <-chan []skipSegment) (*fakeMPV, chan struct{}) {
+	conn, server := net.Pipe()
+	client := mpvipc.New(conn)
+	t.Cleanup(func() { _ = client.Close() })
+	events, stop := client.Events()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer stop()
+		runAutoSkip(newMPVPlayer(client), events, segments, updates, config.KeyConfig{})
+	}()
+	return &fakeMPV{t: t, conn: server, in: bufio.NewScanner(server)}, done
+}
+
+func TestAutoSkipSeeksPastOpeningAndUndoes(t *testing.T) {
+	m, done := startSkipper(t, []skipSegment{{Kind: "opening", Start: 90, End: 180, Mode: config.SkipAuto}}, nil)
+
+	m.emit(`{"event":"property-change","name":"time-pos","data":89.5}`)
+	m.emit(`{"event":"property-change","name":"time-pos","data":90.2}`)
+	assert.Equal(t, []interface{}{"seek", float64(180), "absolute"}, m.expect())
+	assert.Equal(t, []interface{}{"show-text", "Skipped opening — press Ctrl+z to undo", float64(3000)}, m.expect())
+
+	m.emit(`{"event":"client-message","args":["goanime","undo"]}`)
+	assert.Equal(t, []interface{}{"seek", 90.2, "absolute"}, m.expect())
+	assert.Equal(t, "show-text", m.expect()[0])
+
+	// Back inside the opening, it is not skipped again
+	m.emit(`{"event":"property-change","name":"time-pos","data":91}`)
+	m.emit(`{"event":"end-file","reason":"eof"}`)
+	<-done
+}
+
+func TestAutoSkipAsksBeforeSkipping(t *testing.T) {
+	m, done := startSkipper(t, []skipSegment{{Kind: "ending", Start: 1300, End: 1390, Mode: config.SkipAsk}}, nil)
+
+	m.emit(`{"event":"property-change","name":"time-pos","data":1301}`)
+	assert.Equal(t, []interface{}{"show-text", "Ending — press Ctrl+x to skip", float64(5000)}, m.expect())
+
+	m.emit(`{"event":"client-message","args":["goanime","skip"]}`)
+	assert.Equal(t, []interface{}{"seek", float64(1390), "absolute"}, m.expect())
+	assert.Equal(t, "show-text", m.expect()[0])
+
+	// A failed stream is replaced by a mirror, the skipper keeps going
+	m.emit(`{"event":"end-file","reason":"error"}`)
+	m.emit(`{"event":"end-file","reason":"stop"}`)
+	<-done
+}
+
+func TestAutoSkipFollowsNewMarkers(t *testing.T) {
+	updates := make(chan []skipSegment)
+	m, done := startSkipper(t, []skipSegment{{Kind: "opening", Start: 90, End: 180, Mode: config.SkipAuto}}, updates)
+
+	m.emit(`{"event":"property-change","name":"time-pos","data":90.5}`)
+	assert.Equal(t, "seek", m.expect()[0])
+	assert.Equal(t, "show-text", m.expect()[0])
+
+	// The ending was just marked: it is skipped, the opening is not skipped again
+	updates <- []skipSegment{
+		{Kind: "opening", Start: 90, End: 180, Mode: config.SkipAuto},
+		{Kind: "ending", Start: 1300, End: 1390, Mode: config.SkipAuto},
+	}
+	m.emit(`{"event":"property-change","name":"time-pos","data":91}`)
+	m.emit(`{"event":"property-change","name":"time-pos","data":1301}`)
+	assert.Equal(t, []interface{}{"seek", float64(1390), "absolute"}, m.expect())
+	assert.Equal(t, "show-text", m.expect()[0])
+
+	m.emit(`{"event":"end-file","reason":"eof"}`)
+	<-done
+}
@@ -0,0 +1,215 @@
+package player
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/mpvipc"
+)
+
+// Player is a running media player GoAnime drives. Properties and events use
+// mpv's names ("time-pos", "duration", "pause"; end-file with reason "eof",
+// "stop", "quit" or "error"), which other players translate theirs to.
+type Player interface {
+	// Load replaces the playing file with target
+	Load(target string, opts LoadOptions) error
+	// Seek moves to an absolute position in seconds
+	Seek(seconds float64) error
+	SetPause(paused bool) error
+	Get(property string) (interface{}, error)
+	Set(property string, value interface{}) error
+	// Observe sends the current value of property, then every change, until
+	// cancel is called or the player exits
+	Observe(property string) (<-chan interface{}, func(), error)
+	// Events sends every event until cancel is called or the player exits
+	Events() (<-chan Event, func())
+	// ShowText shows msg over the video for d
+	ShowText(msg string, d time.Duration) error
+	Quit() error
+	// Done is closed when the player exits
+	Done() <-chan struct{}
+}
+
+// Event is something that happened in a player, named the way mpv names it
+type Event = mpvipc.Event
+
+// LoadOptions are the per-file settings of Player.Load
+type LoadOptions struct {
+	// Headers are sent with the stream requests
+	Headers map[string]string
+	// Subtitles are external subtitle files or URLs, replacing the previous file's
+	Subtitles []string
+	// Start is the position to start at, in seconds
+	Start int
+}
+
+// keyBinder is a Player that can bind GoAnime's keys in its window; pressed
+// keys arrive as client-message events (see keyAction)
+type keyBinder interface {
+	BindKeys(bindings []config.KeyBinding) error
+}
+
+var (
+	// ErrUnsupported is returned for what the running player cannot do
+	ErrUnsupported  = errors.New("not supported by this player")
+	errPlayerClosed = errors.New("player closed")
+)
+
+var (
+	playersMu sync.Mutex
+	// players are the running players by handle; a handle without a player is
+	// taken for the socket path of an mpv started elsewhere
+	players = map[string]Player{}
+
+	// launchPlayer is swapped out in tests
+	launchPlayer = startPlayer
+)
+
+// startPlayer opens link in the configured player and returns its handle.
+// mpvArgs only apply to mpv; other players get start (in seconds) instead.
+func startPlayer(link string, mpvArgs []string, start int) (string, error) {
+	if config.Get().Playback.PlayerName() == config.PlayerVLC {
+		return StartVLC(link, start)
+	}
+	return StartVideo(link, mpvArgs)
+}
+
+// registerPlayer makes p reachable through handle until it exits
+func registerPlayer(handle string, p Player) {
+	playersMu.Lock()
+	players[handle] = p
+	playersMu.Unlock()
+	go func() {
+		<-p.Done()
+		playersMu.Lock()
+		if players[handle] == p {
+			delete(players, handle)
+		}
+		playersMu.Unlock()
+	}()
+}
+
+// playerFor returns the player behind handle, connecting to the mpv listening
+// on it on first use. A new mpv on the same path gets a fresh connection.
+func playerFor(handle string) (Player, error) {
+	playersMu.Lock()
+	p, ok := players[handle]
+	playersMu.Unlock()
+	if ok {
+		select {
+		case <-p.Done():
+		default:
+			return p, nil
+		}
+	}
+
+	conn, err := dialMPVSocket(handle)
+	if err != nil {
+		return nil, err
+	}
+	p = newMPVPlayer(mpvipc.New(conn))
+	registerPlayer(handle, p)
+	return p, nil
+}
+
+// observePlayer subscribes to a property of the player behind handle
+func observePlayer(handle, property string) (<-chan interface{}, func(), error) {
+	p, err := playerFor(handle)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.Observe(property)
+}
+
+// quitPlayer closes the player behind handle, if it still runs
+func quitPlayer(handle string) {
+	if p, err := playerFor(handle); err == nil {
+		_ = p.Quit()
+	}
+}
+
+// eventHub fans the events of a player without an event connection of its own
+// out to subscribers, the way mpvipc.Client does for mpv: a subscriber that
+// falls behind only misses intermediate property values, see mpvipc.EventQueue.
+type eventHub struct {
+	mu        sync.Mutex
+	closed    bool
+	nextID    int
+	listeners map[int]*mpvipc.EventQueue
+}
+
+// subscribe returns the events published from now on
+func (h *eventHub) subscribe() (<-chan Event, func()) {
+	q := mpvipc.NewEventQueue()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		q.Close()
+		return q.C(), func() {}
+	}
+	if h.listeners == nil {
+		h.listeners = map[int]*mpvipc.EventQueue{}
+	}
+	h.nextID++
+	id := h.nextID
+	h.listeners[id] = q
+
+	return q.C(), func() {
+		h.mu.Lock()
+		delete(h.listeners, id)
+		h.mu.Unlock()
+		q.Cancel()
+	}
+}
+
+// observe follows the property-change events of property, starting with current
+func (h *eventHub) observe(property string, current interface{}) (<-chan interface{}, func()) {
+	events, cancel := h.subscribe()
+	values := make(chan interface{}, 16)
+	values <- current
+	go func() {
+		defer close(values)
+		for ev := range events {
+			if ev.Name != "property-change" || ev.Property != property {
+				continue
+			}
+			for sent := false; !sent; {
+				select {
+				case values <- ev.Data:
+					sent = true
+				default:
+					select {
+					case <-values:
+					default:
+					}
+				}
+			}
+		}
+	}()
+	return values, cancel
+}
+
+// publish hands ev to every subscriber
+func (h *eventHub) publish(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, q := range h.listeners {
+		q.Push(ev)
+	}
+}
+
+// close ends every subscription
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for id, q := range h.listeners {
+		delete(h.listeners, id)
+		q.Close()
+	}
+}
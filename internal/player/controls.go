@@ -0,0 +1,341 @@
+package player
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/util"
+	"github.com/charmbracelet/huh"
+)
+
+// seekStep is how far the seek controls jump, an opening's length
+const seekStep = 85
+
+// speedPresets are the speeds offered in the player menu
+var speedPresets = []float64{0.75, 1, 1.25, 1.5, 2}
+
+// statProperties are the properties the stats view shows, in order
+var statProperties = []string{
+	"time-pos",
+	"duration",
+	"speed",
+	"volume",
+	"pause",
+	"filename",
+	"video-bitrate",
+	"audio-bitrate",
+	"frame-drop-count",
+	"decoder-frame-drop-count",
+	"demuxer-cache-duration",
+	"hwdec-current",
+}
+
+// mediaTrack is an audio or subtitle track of the playing file
+type mediaTrack struct {
+	ID       int
+	Type     string // "audio" or "sub"
+	Lang     string
+	Title    string
+	Selected bool
+}
+
+// label names the track in menus and messages
+func (t mediaTrack) label() string {
+	parts := []string{fmt.Sprintf("#%d", t.ID)}
+	if t.Lang != "" {
+		parts = append(parts, t.Lang)
+	}
+	if t.Title != "" {
+		parts = append(parts, t.Title)
+	}
+	return strings.Join(parts, " ")
+}
+
+// playerTracks returns the tracks of kind ("audio" or "sub") from mpv's track-list
+func playerTracks(p Player, kind string) ([]mediaTrack, error) {
+	value, err := p.Get("track-list")
+	if err != nil {
+		return nil, err
+	}
+	list, _ := value.([]interface{})
+	var tracks []mediaTrack
+	for _, item := range list {
+		fields, ok := item.(map[string]interface{})
+		if !ok || fields["type"] != kind {
+			continue
+		}
+		id, _ := fields["id"].(float64)
+		track := mediaTrack{ID: int(id), Type: kind}
+		track.Lang, _ = fields["lang"].(string)
+		track.Title, _ = fields["title"].(string)
+		track.Selected, _ = fields["selected"].(bool)
+		tracks = append(tracks, track)
+	}
+	return tracks, nil
+}
+
+// trackProperty is the property that selects a track of kind
+func trackProperty(kind string) string {
+	if kind == "audio" {
+		return "aid"
+	}
+	return "sid"
+}
+
+// selectTrack switches to track and remembers its language for the show
+func selectTrack(p Player, track mediaTrack, show string) error {
+	if err := p.Set(trackProperty(track.Type), track.ID); err != nil {
+		return err
+	}
+	if track.Lang != "" {
+		rememberForShow(show, func(prefs *showPrefs) {
+			if track.Type == "audio" {
+				prefs.AudioLang = track.Lang
+			} else {
+				prefs.SubLang = track.Lang
+			}
+		})
+	}
+	return nil
+}
+
+// cycleSubtitles switches to the subtitle track after the selected one
+func cycleSubtitles(p Player, show string) (mediaTrack, error) {
+	tracks, err := playerTracks(p, "sub")
+	if err != nil {
+		return mediaTrack{}, err
+	}
+	if len(tracks) == 0 {
+		return mediaTrack{}, fmt.Errorf("no subtitle tracks")
+	}
+	next := tracks[0]
+	for i, t := range tracks {
+		if t.Selected {
+			next = tracks[(i+1)%len(tracks)]
+			break
+		}
+	}
+	return next, selectTrack(p, next, show)
+}
+
+// toggleSubtitles shows or hides the subtitles and reports whether they are visible
+func toggleSubtitles(p Player, show string) (bool, error) {
+	value, err := p.Get("sub-visibility")
+	if err != nil {
+		return false, err
+	}
+	visible, _ := value.(bool)
+	if err := p.Set("sub-visibility", !visible); err != nil {
+		return visible, err
+	}
+	rememberForShow(show, func(prefs *showPrefs) { prefs.SubsOff = visible })
+	return !visible, nil
+}
+
+// setSpeed changes the playback speed and remembers it for the show
+func setSpeed(p Player, speed float64, show string) error {
+	if err := p.Set("speed", speed); err != nil {
+		return err
+	}
+	rememberForShow(show, func(prefs *showPrefs) { prefs.Speed = speed })
+	return nil
+}
+
+// seekBy moves delta seconds from the current position, within the episode
+func seekBy(p Player, delta float64) (float64, error) {
+	value, err := p.Get("time-pos")
+	if err != nil {
+		return 0, err
+	}
+	position, _ := value.(float64)
+	target := max(position+delta, 0)
+	// Seeking past the end would end the episode
+	if duration, err := p.Get("duration"); err == nil {
+		if d, ok := duration.(float64); ok && d > 0 && target > d-1 {
+			target = max(d-1, 0)
+		}
+	}
+	return target, p.Seek(target)
+}
+
+// playbackStats reads the stats view's properties; those the player does not
+// have yet, like the bitrate before the first frames, are left out
+func playbackStats(p Player) map[string]interface{} {
+	stats := make(map[string]interface{})
+	for _, prop := range statProperties {
+		if value, err := p.Get(prop); err == nil && value != nil {
+			stats[prop] = value
+		}
+	}
+	return stats
+}
+
+// formatStats renders stats for the terminal
+func formatStats(stats map[string]interface{}) string {
+	number := func(prop string) (float64, bool) {
+		v, ok := stats[prop].(float64)
+		return v, ok
+	}
+	var lines []string
+	add := func(label, value string) {
+		lines = append(lines, fmt.Sprintf("  %-16s %s", label, value))
+	}
+
+	if pos, ok := number("time-pos"); ok {
+		value := clock(pos)
+		if duration, ok := number("duration"); ok {
+			value += " / " + clock(duration)
+		}
+		add("Position", value)
+	}
+	if speed, ok := number("speed"); ok {
+		add("Speed", strconv.FormatFloat(speed, 'f', -1, 64)+"x")
+	}
+	for _, b := range []struct{ prop, label string }{{"video-bitrate", "Video bitrate"}, {"audio-bitrate", "Audio bitrate"}} {
+		if bitrate, ok := number(b.prop); ok {
+			add(b.label, formatBitrate(bitrate))
+		}
+	}
+	if dropped, ok := number("frame-drop-count"); ok {
+		decoder, _ := number("decoder-frame-drop-count")
+		add("Dropped frames", fmt.Sprintf("%d (decoder %d)", int(dropped), int(decoder)))
+	}
+	if cached, ok := number("demuxer-cache-duration"); ok {
+		add("Cache", fmt.Sprintf("%.0fs ahead", cached))
+	}
+	if hwdec, ok := stats["hwdec-current"].(string); ok && hwdec != "" && hwdec != "no" {
+		add("Hardware decode", hwdec)
+	}
+	if len(lines) == 0 {
+		return "No playback stats available yet"
+	}
+	return "Playback stats\n" + strings.Join(lines, "\n")
+}
+
+// clock formats seconds as m:ss or h:mm:ss
+func clock(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// formatBitrate formats bits per second
+func formatBitrate(bps float64) string {
+	if bps >= 1e6 {
+		return fmt.Sprintf("%.1f Mbps", bps/1e6)
+	}
+	return fmt.Sprintf("%.0f kbps", bps/1e3)
+}
+
+// chooseFrom shows a one-question menu
+func chooseFrom[T comparable](title string, options []huh.Option[T]) (T, error) {
+	var choice T
+	menu := huh.NewSelect[T]().Title(title).Options(options...).Value(&choice)
+	if err := huh.NewForm(huh.NewGroup(menu)).WithShowHelp(false).Run(); err != nil {
+		return choice, fmt.Errorf("error showing menu: %w", err)
+	}
+	return choice, nil
+}
+
+// playbackControls shows the speed, track, seek and stats controls of the
+// player at socketPath until the user goes back to the player menu. Choices
+// that suit the whole show are remembered for its next episodes.
+func playbackControls(socketPath, show string) {
+	p, err := playerFor(socketPath)
+	if err != nil {
+		util.Debugf("Playback controls unavailable: %v", err)
+		return
+	}
+	for {
+		choice, err := chooseFrom("Playback controls", []huh.Option[string]{
+			huh.NewOption(fmt.Sprintf("Back %ds", seekStep), "rewind"),
+			huh.NewOption(fmt.Sprintf("Forward %ds", seekStep), "forward"),
+			huh.NewOption("Speed", "speed"),
+			huh.NewOption("Subtitles on/off", "subs"),
+			huh.NewOption("Next subtitle track", "sub-track"),
+			huh.NewOption("Audio track", "audio"),
+			huh.NewOption("Stats", "stats"),
+			huh.NewOption("Back to player menu", "back"),
+		})
+		if err != nil || choice == "back" {
+			return
+		}
+		runControl(p, socketPath, choice, show)
+	}
+}
+
+// runControl carries out a playback control and reports the result
+func runControl(p Player, socketPath, choice, show string) {
+	fail := func(what string, err error) {
+		notify(socketPath, fmt.Sprintf("Cannot %s: %v", what, err))
+	}
+	switch choice {
+	case "rewind", "forward":
+		delta := float64(seekStep)
+		if choice == "rewind" {
+			delta = -delta
+		}
+		if target, err := seekBy(p, delta); err != nil {
+			fail("seek", err)
+		} else {
+			notify(socketPath, "Position "+clock(target))
+		}
+	case "speed":
+		options := make([]huh.Option[float64], 0, len(speedPresets))
+		for _, s := range speedPresets {
+			options = append(options, huh.NewOption(strconv.FormatFloat(s, 'f', -1, 64)+"x", s))
+		}
+		speed, err := chooseFrom("Playback speed", options)
+		if err != nil {
+			return
+		}
+		if err := setSpeed(p, speed, show); err != nil {
+			fail("change the speed", err)
+		} else {
+			notify(socketPath, fmt.Sprintf("Speed %sx", strconv.FormatFloat(speed, 'f', -1, 64)))
+		}
+	case "subs":
+		if visible, err := toggleSubtitles(p, show); err != nil {
+			fail("toggle subtitles", err)
+		} else if visible {
+			notify(socketPath, "Subtitles on")
+		} else {
+			notify(socketPath, "Subtitles off")
+		}
+	case "sub-track":
+		if track, err := cycleSubtitles(p, show); err != nil {
+			fail("switch subtitles", err)
+		} else {
+			notify(socketPath, "Subtitles "+track.label())
+		}
+	case "audio":
+		tracks, err := playerTracks(p, "audio")
+		if err == nil && len(tracks) == 0 {
+			err = fmt.Errorf("no audio tracks")
+		}
+		if err != nil {
+			fail("list audio tracks", err)
+			return
+		}
+		options := make([]huh.Option[int], 0, len(tracks))
+		for i, t := range tracks {
+			options = append(options, huh.NewOption(t.label(), i).Selected(t.Selected))
+		}
+		i, err := chooseFrom("Audio track", options)
+		if err != nil {
+			return
+		}
+		if err := selectTrack(p, tracks[i], show); err != nil {
+			fail("switch audio", err)
+		} else {
+			notify(socketPath, "Audio "+tracks[i].label())
+		}
+	case "stats":
+		fmt.Println(formatStats(playbackStats(p)))
+	}
+}
@@ -0,0 +1,121 @@
+package player
+
+import (
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useTempConfigDir keeps remembered choices out of the user's config
+func useTempConfigDir(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+}
+
+func trackListPlayer() *fakePlayer {
+	p := newFakePlayer()
+	p.change("track-list", []interface{}{
+		map[string]interface{}{"id": float64(1), "type": "video", "selected": true},
+		map[string]interface{}{"id": float64(1), "type": "audio", "lang": "jpn", "selected": true},
+		map[string]interface{}{"id": float64(2), "type": "audio", "lang": "eng", "title": "Dub"},
+		map[string]interface{}{"id": float64(1), "type": "sub", "lang": "eng"},
+		map[string]interface{}{"id": float64(2), "type": "sub", "lang": "por", "selected": true},
+	})
+	return p
+}
+
+func TestPlayerTracks(t *testing.T) {
+	tracks, err := playerTracks(trackListPlayer(), "audio")
+	require.NoError(t, err)
+	assert.Equal(t, []mediaTrack{
+		{ID: 1, Type: "audio", Lang: "jpn", Selected: true},
+		{ID: 2, Type: "audio", Lang: "eng", Title: "Dub"},
+	}, tracks)
+	assert.Equal(t, "#2 eng Dub", tracks[1].label())
+}
+
+func TestCycleSubtitlesWrapsAndRemembersLanguage(t *testing.T) {
+	useTempConfigDir(t)
+	p := trackListPlayer()
+
+	track, err := cycleSubtitles(p, "42")
+	require.NoError(t, err)
+	assert.Equal(t, 1, track.ID)
+	assert.Contains(t, p.Calls(), "set sid 1")
+	assert.Equal(t, "eng", prefsFor("42").SubLang)
+}
+
+func TestSelectAudioTrackIsRememberedForTheShow(t *testing.T) {
+	useTempConfigDir(t)
+	p := trackListPlayer()
+
+	tracks, err := playerTracks(p, "audio")
+	require.NoError(t, err)
+	require.NoError(t, selectTrack(p, tracks[1], "42"))
+
+	assert.Contains(t, p.Calls(), "set aid 2")
+	assert.Equal(t, showPrefs{AudioLang: "eng"}, prefsFor("42"))
+	assert.Equal(t, showPrefs{}, prefsFor("7"), "other shows keep their own choices")
+}
+
+func TestToggleSubtitlesAndSpeed(t *testing.T) {
+	useTempConfigDir(t)
+	p := newFakePlayer()
+	p.change("sub-visibility", true)
+
+	visible, err := toggleSubtitles(p, "42")
+	require.NoError(t, err)
+	assert.False(t, visible)
+	require.NoError(t, setSpeed(p, 1.5, "42"))
+
+	assert.Equal(t, showPrefs{SubsOff: true, Speed: 1.5}, prefsFor("42"))
+	assert.Equal(t, []string{"--no-sub-visibility", "--speed=1.5"}, prefsFor("42").mpvArgs())
+}
+
+func TestSeekByStaysWithinTheEpisode(t *testing.T) {
+	p := newFakePlayer()
+	p.change("duration", float64(1440))
+
+	p.play(30)
+	target, err := seekBy(p, -seekStep)
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), target)
+
+	p.play(1400)
+	target, err = seekBy(p, seekStep)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1439), target)
+
+	p.play(600)
+	target, err = seekBy(p, seekStep)
+	require.NoError(t, err)
+	assert.Equal(t, float64(685), target)
+}
+
+func TestPlaybackStatsSkipUnavailableProperties(t *testing.T) {
+	p := newFakePlayer()
+	p.change("time-pos", float64(65))
+	p.change("duration", float64(1440))
+	p.change("video-bitrate", float64(2.5e6))
+	p.change("frame-drop-count", float64(3))
+	p.change("demuxer-cache-duration", float64(19.6))
+
+	stats := playbackStats(p)
+	assert.NotContains(t, stats, "audio-bitrate")
+
+	out := formatStats(stats)
+	assert.Contains(t, out, "1:05 / 24:00")
+	assert.Contains(t, out, "2.5 Mbps")
+	assert.Contains(t, out, "3 (decoder 0)")
+	assert.Contains(t, out, "20s ahead")
+	assert.Equal(t, "No playback stats available yet", formatStats(map[string]interface{}{}))
+}
+
+func TestShowKey(t *testing.T) {
+	anime := &models.Anime{URL: "https://example.com/frieren"}
+	assert.Equal(t, "154587", showKey(154587, anime))
+	assert.Equal(t, anime.URL, showKey(0, anime))
+	assert.Equal(t, "", showKey(0, nil))
+}
@@ -0,0 +1,476 @@
+package player
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/util"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// dashboardWidth is the width of the progress bar and the marker row under it
+const dashboardWidth = 48
+
+// nowPlaying is what the dashboard shows about the episode being played
+type nowPlaying struct {
+	SocketPath    string
+	Anime         string
+	Episode       int
+	EpisodeTitle  string
+	Next          string // the next episode, "" on the last one
+	Autoplay      bool
+	Skips         []skipSegment
+	CanSwitchMode bool
+	Tracking      bool
+	Presence      func() bool // whether Discord shows the episode, nil when presence is off
+}
+
+// newNowPlaying describes the episode at index for the dashboard
+func newNowPlaying(socketPath, animeName string, episodes []models.Episode, index, episodeNum int, episode *models.Episode) nowPlaying {
+	np := nowPlaying{SocketPath: socketPath, Anime: animeName, Episode: episodeNum}
+	if episode != nil {
+		np.EpisodeTitle = episode.DisplayTitle()
+	}
+	if index+1 < len(episodes) {
+		next := episodes[index+1]
+		np.Next = "Episode " + next.Number
+		if title := next.DisplayTitle(); title != "" {
+			np.Next += " - " + title
+		}
+	}
+	return np
+}
+
+// dashboardKeys are the single-key shortcuts of the dashboard, each choosing
+// the player menu action it is named after
+type dashboardKeys struct {
+	next, previous, skip, selectEp, quit    key.Binding
+	change, watched, subdub, controls, mark key.Binding
+}
+
+func newDashboardKeys() dashboardKeys {
+	return dashboardKeys{
+		next:     key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "next")),
+		previous: key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "previous")),
+		skip:     key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "skip intro")),
+		selectEp: key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "episodes")),
+		quit:     key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
+		change:   key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "change anime")),
+		watched:  key.NewBinding(key.WithKeys("w"), key.WithHelp("w", "watched")),
+		subdub:   key.NewBinding(key.WithKeys("d"), key.WithHelp("d", strings.ToLower(switchModeLabel()))),
+		controls: key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "controls")),
+		mark:     key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "mark op/ed")),
+	}
+}
+
+// dashboardAction is a shortcut and the player menu action it chooses
+type dashboardAction struct {
+	binding key.Binding
+	choice  string
+}
+
+// actions lists the shortcuts, in the order of their help line
+func (k dashboardKeys) actions(canSwitchMode bool) []dashboardAction {
+	actions := []dashboardAction{
+		{k.next, "next"}, {k.previous, "previous"}, {k.skip, "skip"}, {k.selectEp, "select"},
+		{k.controls, "controls"}, {k.mark, "mark"}, {k.watched, "watched"}, {k.change, "change"},
+	}
+	if canSwitchMode {
+		actions = append(actions, dashboardAction{k.subdub, "subdub"})
+	}
+	return append(actions, dashboardAction{k.quit, "quit"})
+}
+
+// playerPropMsg reports a change of an observed player property
+type playerPropMsg struct {
+	property string
+	value    interface{}
+}
+
+// playerClosedMsg reports that the player window was closed
+type playerClosedMsg struct{}
+
+// presenceMsg reports whether Discord shows the episode
+type presenceMsg bool
+
+// skipsMsg reports the segments the episode's skipper follows
+type skipsMsg []skipSegment
+
+// dashboardHideMsg clears the dashboard while another dialog uses the
+// terminal. ack is closed once the empty view was rendered.
+type dashboardHideMsg struct {
+	ack chan<- struct{}
+}
+
+// dashboardHiddenMsg follows dashboardHideMsg: the program takes it once the
+// view of the hidden dashboard was rendered
+type dashboardHiddenMsg struct {
+	ack chan<- struct{}
+}
+
+// dashboardShowMsg brings the dashboard back after dashboardHideMsg
+type dashboardShowMsg struct{}
+
+// dashboardCloseMsg clears the dashboard and ends it
+type dashboardCloseMsg struct{}
+
+// dashboardModel is the Bubble Tea model of the "now playing" dashboard. It
+// stays on screen while the episode plays; its keys send player menu actions
+// to actions, until closing is closed.
+type dashboardModel struct {
+	np       nowPlaying
+	keys     dashboardKeys
+	bar      progress.Model
+	actions  chan<- string
+	closing  <-chan struct{}
+	position float64
+	duration float64
+	paused   bool
+	buffer   bool // waiting for the cache to fill
+	closed   bool
+	presence bool
+	hidden   bool
+}
+
+func newDashboardModel(np nowPlaying) *dashboardModel {
+	return &dashboardModel{
+		np:   np,
+		keys: newDashboardKeys(),
+		bar:  progress.New(progress.WithDefaultGradient(), progress.WithWidth(dashboardWidth), progress.WithoutPercentage()),
+	}
+}
+
+// Init starts without a command: the player's changes arrive as messages
+func (m *dashboardModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update follows the player's properties and hands action keys on
+func (m *dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		for _, c := range m.keys.actions(m.np.CanSwitchMode) {
+			if key.Matches(msg, c.binding) {
+				return m, m.choose(c.choice)
+			}
+		}
+	case playerPropMsg:
+		value, _ := msg.value.(float64)
+		flag, _ := msg.value.(bool)
+		switch msg.property {
+		case "time-pos":
+			m.position = value
+		case "duration":
+			m.duration = value
+		case "pause":
+			m.paused = flag
+		case "paused-for-cache":
+			m.buffer = flag
+		}
+	case playerClosedMsg:
+		m.closed = true
+	case presenceMsg:
+		m.presence = bool(msg)
+	case skipsMsg:
+		m.np.Skips = msg
+	case dashboardHideMsg:
+		m.hidden = true
+		return m, func() tea.Msg { return dashboardHiddenMsg(msg) }
+	case dashboardHiddenMsg:
+		if msg.ack != nil {
+			close(msg.ack)
+		}
+	case dashboardShowMsg:
+		m.hidden = false
+	case dashboardCloseMsg:
+		m.hidden = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// choose sends choice to the actions without holding up the dashboard
+func (m *dashboardModel) choose(choice string) tea.Cmd {
+	actions, closing := m.actions, m.closing
+	return func() tea.Msg {
+		select {
+		case actions <- choice:
+		case <-closing:
+		}
+		return nil
+	}
+}
+
+var (
+	dashboardTitle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FFA500"))
+	dashboardDim   = lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+)
+
+// View renders the dashboard; it is empty while hidden
+func (m *dashboardModel) View() string {
+	if m.hidden {
+		return ""
+	}
+	pad := strings.Repeat(" ", padding)
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(pad + s + "\n")
+	}
+
+	title := fmt.Sprintf("Episode %d", m.np.Episode)
+	if m.np.Anime != "" {
+		title = m.np.Anime + " - " + title
+	}
+	b.WriteString("\n")
+	line(dashboardTitle.Render(title))
+	if m.np.EpisodeTitle != "" {
+		line(m.np.EpisodeTitle)
+	}
+	b.WriteString("\n")
+
+	percent := 0.0
+	if m.duration > 0 {
+		percent = min(m.position/m.duration, 1)
+	}
+	line(m.bar.ViewAs(percent) + "  " + clock(m.position) + " / " + clock(m.duration) + "  " + m.state())
+	if markers := m.markers(); markers != "" {
+		line(dashboardDim.Render(markers))
+	}
+	for _, s := range m.np.Skips {
+		line(dashboardDim.Render(fmt.Sprintf("%s %s-%s (%s)", s.Kind, clock(s.Start), clock(s.End), s.Mode)))
+	}
+	b.WriteString("\n")
+
+	if m.np.Next != "" {
+		next := "Next: " + m.np.Next
+		if m.np.Autoplay {
+			next += " (autoplay)"
+		}
+		line(next)
+	}
+	line(dashboardDim.Render("Tracking " + onOff(m.np.Tracking) + " · Discord " + m.discord()))
+	b.WriteString("\n")
+
+	var help []string
+	for _, c := range m.keys.actions(m.np.CanSwitchMode) {
+		h := c.binding.Help()
+		help = append(help, h.Key+" "+h.Desc)
+	}
+	line(dashboardDim.Render(strings.Join(help, " · ")))
+	return b.String()
+}
+
+// state describes what the player is doing
+func (m *dashboardModel) state() string {
+	switch {
+	case m.closed:
+		return "Player closed"
+	case m.buffer:
+		return "Buffering..."
+	case m.paused:
+		return "Paused"
+	case m.duration == 0:
+		return "Loading..."
+	default:
+		return "Playing"
+	}
+}
+
+// markers draws the skip segments under the progress bar, with the first
+// letter of their kind
+func (m *dashboardModel) markers() string {
+	if m.duration <= 0 || len(m.np.Skips) == 0 {
+		return ""
+	}
+	row := []rune(strings.Repeat(" ", dashboardWidth))
+	for _, s := range m.np.Skips {
+		first := int(s.Start / m.duration * dashboardWidth)
+		last := int(s.End / m.duration * dashboardWidth)
+		if first < 0 {
+			first = 0
+		}
+		for i := first; i <= last && i < dashboardWidth; i++ {
+			row[i] = []rune(strings.ToUpper(s.Kind[:1]))[0]
+		}
+	}
+	return strings.TrimRight(string(row), " ")
+}
+
+// discord describes the Discord presence
+func (m *dashboardModel) discord() string {
+	switch {
+	case m.np.Presence == nil:
+		return "off"
+	case m.presence:
+		return "connected"
+	default:
+		return "not connected"
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// dashboardProperties are the player properties the dashboard follows
+var dashboardProperties = []string{"time-pos", "duration", "pause", "paused-for-cache"}
+
+// presenceInterval is how often the dashboard checks the Discord presence
+const presenceInterval = 2 * time.Second
+
+// playerMenu is the menu shown while an episode plays
+type playerMenu interface {
+	// Actions delivers the player menu actions the user chooses
+	Actions() <-chan string
+	// Done is closed when the menu ended without Close, Err tells why
+	Done() <-chan struct{}
+	Err() error
+	// Suspend runs f, which may use the terminal, with the menu put away
+	Suspend(f func())
+	// ShowSkips shows the segments the episode's skipper follows
+	ShowSkips(segments []skipSegment)
+	// Close ends the menu
+	Close()
+}
+
+// dashboard is the "now playing" dashboard: a single Bubble Tea program that
+// stays up until the episode is left
+type dashboard struct {
+	program   *tea.Program
+	actions   chan string
+	closing   chan struct{} // closed by Close; ends the feeds and pending actions
+	done      chan struct{} // closed when the program ended
+	err       error
+	closeOnce sync.Once
+}
+
+// startDashboard shows the "now playing" dashboard of np
+func startDashboard(np nowPlaying) playerMenu {
+	d := &dashboard{
+		actions: make(chan string),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	m := newDashboardModel(np)
+	m.actions, m.closing = d.actions, d.closing
+	d.program = tea.NewProgram(m)
+	d.follow(np)
+	go func() {
+		defer close(d.done)
+		if _, err := d.program.Run(); err != nil {
+			d.err = fmt.Errorf("error showing menu: %w", err)
+		}
+	}()
+	return d
+}
+
+// follow feeds the player's changes and the Discord presence to the dashboard
+// until it is closed
+func (d *dashboard) follow(np nowPlaying) {
+	if np.Presence != nil {
+		go func() {
+			ticker := time.NewTicker(presenceInterval)
+			defer ticker.Stop()
+			for {
+				d.program.Send(presenceMsg(np.Presence()))
+				select {
+				case <-ticker.C:
+				case <-d.closing:
+					return
+				}
+			}
+		}()
+	}
+
+	p, err := playerFor(np.SocketPath)
+	if err != nil {
+		util.Debugf("Dashboard cannot follow the player: %v", err)
+		return
+	}
+	for _, prop := range dashboardProperties {
+		values, cancel, err := p.Observe(prop)
+		if err != nil {
+			util.Debugf("Dashboard cannot follow %s: %v", prop, err)
+			continue
+		}
+		go func(prop string) {
+			defer cancel()
+			for {
+				select {
+				case value, ok := <-values:
+					if !ok {
+						return
+					}
+					d.program.Send(playerPropMsg{property: prop, value: value})
+				case <-d.closing:
+					return
+				}
+			}
+		}(prop)
+	}
+	go func() {
+		select {
+		case <-p.Done():
+			d.program.Send(playerClosedMsg{})
+		case <-d.closing:
+		}
+	}()
+}
+
+func (d *dashboard) Actions() <-chan string { return d.actions }
+
+func (d *dashboard) Done() <-chan struct{} { return d.done }
+
+func (d *dashboard) ShowSkips(segments []skipSegment) {
+	d.program.Send(skipsMsg(segments))
+}
+
+func (d *dashboard) Err() error {
+	<-d.done
+	return d.err
+}
+
+// Suspend clears the dashboard and hands the terminal to f, then shows the
+// dashboard again below what f printed
+func (d *dashboard) Suspend(f func()) {
+	// The terminal is released once the dashboard was cleared
+	hidden := make(chan struct{})
+	d.program.Send(dashboardHideMsg{ack: hidden})
+	select {
+	case <-hidden:
+	case <-d.done:
+		f()
+		return
+	}
+	if err := d.program.ReleaseTerminal(); err != nil {
+		util.Debugf("Dashboard cannot release the terminal: %v", err)
+	}
+	// Releasing wakes the renderer's goroutine to stop its ticker; it must run
+	// before RestoreTerminal restarts the ticker, or the dashboard is never
+	// repainted when f returns at once
+	runtime.Gosched()
+	f()
+	if err := d.program.RestoreTerminal(); err != nil {
+		util.Debugf("Dashboard cannot restore the terminal: %v", err)
+	}
+	d.program.Send(dashboardShowMsg{})
+}
+
+// Close clears the dashboard and waits for it to end
+func (d *dashboard) Close() {
+	d.closeOnce.Do(func() {
+		close(d.closing)
+		d.program.Send(dashboardCloseMsg{})
+		<-d.done
+	})
+}
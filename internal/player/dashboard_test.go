@@ -0,0 +1,137 @@
+package player
+
+import (
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/models"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keyPress(r rune) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}
+}
+
+// press sends the key r to m and returns the action it chose, "" for none
+func press(m *dashboardModel, r rune) string {
+	actions := make(chan string, 1)
+	m.actions = actions
+	_, cmd := m.Update(keyPress(r))
+	if cmd == nil {
+		return ""
+	}
+	cmd()
+	select {
+	case choice := <-actions:
+		return choice
+	default:
+		return ""
+	}
+}
+
+func TestDashboardKeysChooseActions(t *testing.T) {
+	for r, choice := range map[rune]string{'n': "next", 'p': "previous", 's': "skip", 'e': "select", 'q': "quit", 'o': "controls", 'm': "mark"} {
+		m := newDashboardModel(nowPlaying{Episode: 1})
+		assert.Equal(t, choice, press(m, r), "key %c", r)
+		assert.NotEmpty(t, m.View(), "the dashboard stays up after key %c", r)
+	}
+
+	m := newDashboardModel(nowPlaying{Episode: 1})
+	assert.Empty(t, press(m, 'd'), "sub/dub needs a source that can switch")
+
+	m.np.CanSwitchMode = true
+	assert.Equal(t, "subdub", press(m, 'd'))
+}
+
+func TestDashboardHidesAndCloses(t *testing.T) {
+	m := newDashboardModel(nowPlaying{Episode: 1})
+	hidden := make(chan struct{})
+	_, cmd := m.Update(dashboardHideMsg{ack: hidden})
+	assert.Empty(t, m.View(), "another dialog has the terminal")
+	require.NotNil(t, cmd)
+	// The terminal is handed over once the empty view was rendered, when the
+	// program takes the next message
+	m.Update(cmd())
+	select {
+	case <-hidden:
+	default:
+		t.Fatal("hiding was not acknowledged")
+	}
+	m.Update(dashboardShowMsg{})
+	assert.Contains(t, m.View(), "Episode 1")
+
+	_, cmd = m.Update(dashboardCloseMsg{})
+	assert.NotNil(t, cmd, "closing ends the program")
+	assert.Empty(t, m.View(), "the dashboard clears when it ends")
+
+	closing := make(chan struct{})
+	close(closing)
+	m.closing = closing
+	m.actions = make(chan string)
+	_, cmd = m.Update(keyPress('n'))
+	assert.Nil(t, cmd(), "a key pressed while closing does not wait for a reader")
+}
+
+func TestDashboardFollowsThePlayer(t *testing.T) {
+	m := newDashboardModel(nowPlaying{
+		Anime:        "Frieren",
+		Episode:      1,
+		EpisodeTitle: "The Journey's End",
+		Next:         "Episode 2",
+		Autoplay:     true,
+		Tracking:     true,
+		Skips:        []skipSegment{{Kind: "opening", Start: 90, End: 180, Mode: "auto"}},
+	})
+	assert.Contains(t, m.View(), "Loading...")
+
+	m.Update(playerPropMsg{property: "duration", value: float64(1440)})
+	m.Update(playerPropMsg{property: "time-pos", value: float64(65)})
+	view := m.View()
+	assert.Contains(t, view, "Frieren - Episode 1")
+	assert.Contains(t, view, "The Journey's End")
+	assert.Contains(t, view, "1:05 / 24:00  Playing")
+	assert.Contains(t, view, "opening 1:30-3:00 (auto)")
+	assert.Contains(t, view, "Next: Episode 2 (autoplay)")
+	assert.Contains(t, view, "Tracking on · Discord off")
+
+	m.np.Presence = func() bool { return false }
+	assert.Contains(t, m.View(), "Discord not connected")
+	m.Update(presenceMsg(true))
+	assert.Contains(t, m.View(), "Discord connected")
+
+	m.Update(playerPropMsg{property: "paused-for-cache", value: true})
+	assert.Contains(t, m.View(), "Buffering...")
+	m.Update(playerPropMsg{property: "paused-for-cache", value: false})
+	m.Update(playerPropMsg{property: "pause", value: true})
+	assert.Contains(t, m.View(), "Paused")
+	m.Update(playerClosedMsg{})
+	assert.Contains(t, m.View(), "Player closed")
+}
+
+func TestDashboardMarkers(t *testing.T) {
+	m := newDashboardModel(nowPlaying{Skips: []skipSegment{
+		{Kind: "opening", Start: 0, End: 120},
+		{Kind: "ending", Start: 1320, End: 1440},
+	}})
+	assert.Empty(t, m.markers(), "no markers before the duration is known")
+
+	m.duration = 1440
+	markers := m.markers()
+	assert.Equal(t, "OOOOO", markers[:5])
+	assert.Equal(t, dashboardWidth, len(markers))
+	assert.Equal(t, byte('E'), markers[dashboardWidth-1])
+}
+
+func TestNewNowPlaying(t *testing.T) {
+	episodes := []models.Episode{
+		{Number: "1", Title: models.TitleDetails{Romaji: "Tabi no Owari"}},
+		{Number: "2", Title: models.TitleDetails{English: "It Didn't Have to Be Magic...", Romaji: "Betsu ni Mahou"}},
+	}
+	np := newNowPlaying("sock", "Frieren", episodes, 0, 1, &episodes[0])
+	assert.Equal(t, "Tabi no Owari", np.EpisodeTitle)
+	assert.Equal(t, "Episode 2 - It Didn't Have to Be Magic...", np.Next)
+
+	np = newNowPlaying("sock", "Frieren", episodes, 1, 2, &episodes[1])
+	assert.Empty(t, np.Next, "nothing follows the last episode")
+}
@@ -16,6 +16,8 @@ import (
 
 	"github.com/alvarorichard/Goanime/internal/api"
 	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/scraper"
 	"github.com/alvarorichard/Goanime/internal/util"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/progress"
@@ -184,6 +186,22 @@ func DownloadVideo(url, destPath string, numThreads int, m *model) error {
 	return nil
 }
 
+// YtDlpHeaders applies the headers a stream host expects to a yt-dlp command.
+// Only the headers network.StreamHeaders allows are forwarded.
+func YtDlpHeaders(dl *ytdlp.Command, headers map[string]string) *ytdlp.Command {
+	h := network.StreamHeaders(headers)
+	if v := h["Referer"]; v != "" {
+		dl = dl.Referer(v)
+	}
+	if v := h["User-Agent"]; v != "" {
+		dl = dl.UserAgent(v)
+	}
+	if v := h["Origin"]; v != "" {
+		dl = dl.AddHeaders("Origin:" + v)
+	}
+	return dl
+}
+
 // downloadWithYtDlp downloads a video using yt-dlp and updates the progress model if provided.
 func downloadWithYtDlp(url, path string, m *model) error {
 	// Sanitize inputs
@@ -308,6 +326,13 @@ func downloadWithYtDlp(url, path string, m *model) error {
 
 	dl := ytdlp.New().
 		Output(safePath)
+	if proxy := scraper.StreamProxy(url); proxy != "" {
+		dl = dl.Proxy(proxy)
+	}
+	if cookies := network.CookiesFile(); cookies != "" {
+		dl = dl.Cookies(cookies)
+	}
+	dl = YtDlpHeaders(dl, scraper.StreamHeaders(url))
 
 	// Run the download with HLS-friendly options and retry logic
 	var runErr error
@@ -387,7 +412,7 @@ func ExtractVideoSources(episodeURL string) ([]struct {
 	if err != nil {
 		return nil, err
 	}
-	if strings.Contains(videoSrc, "animefire.plus/video/") {
+	if isAnimefireVideoPage(videoSrc) {
 		resp, err := api.SafeGet(videoSrc)
 		if err != nil {
 			return nil, err
@@ -567,7 +592,7 @@ func HandleBatchDownload(episodes []models.Episode, animeURL string) error {
 		}
 
 		// Check if episode already exists
-		episodePath, err := createEpisodePath(animeURL, episodeNum)
+		episodePath, err := createEpisodePath(animeURL, episode)
 		if err != nil {
 			util.Logger.Error("Episode path error", "episode", episodeNum, "error", err)
 			continue
@@ -639,7 +664,7 @@ func HandleBatchDownload(episodes []models.Episode, animeURL string) error {
 					util.Logger.Warn("Skipping episode in batch", "episode", epNum, "error", err)
 					return
 				}
-				episodePath, err := createEpisodePath(animeURL, epNum)
+				episodePath, err := createEpisodePath(animeURL, episode)
 				if err != nil {
 					util.Logger.Error("Episode path error", "episode", epNum, "error", err)
 					return
@@ -667,6 +692,8 @@ func HandleBatchDownload(episodes []models.Episode, animeURL string) error {
 				}
 				if err != nil {
 					util.Logger.Error("Failed episode download", "episode", epNum, "error", err)
+				} else {
+					SaveSubtitles(videoURL, episodePath)
 				}
 			}(epNum)
 		}
@@ -733,7 +760,7 @@ func HandleBatchDownloadRange(episodes []models.Episode, animeURL string, startN
 			continue
 		}
 
-		episodePath, err := createEpisodePath(animeURL, episodeNum)
+		episodePath, err := createEpisodePath(animeURL, episode)
 		if err != nil {
 			util.Logger.Error("Episode path error", "episode", episodeNum, "error", err)
 			continue
@@ -795,7 +822,7 @@ func HandleBatchDownloadRange(episodes []models.Episode, animeURL string, startN
 					util.Logger.Warn("Skipping episode in batch", "episode", epNum, "error", err)
 					return
 				}
-				episodePath, err := createEpisodePath(animeURL, epNum)
+				episodePath, err := createEpisodePath(animeURL, episode)
 				if err != nil {
 					util.Logger.Error("Episode path error", "episode", epNum, "error", err)
 					return
@@ -822,6 +849,7 @@ func HandleBatchDownloadRange(episodes []models.Episode, animeURL string, startN
 					util.Logger.Error("Failed episode download", "episode", epNum, "error", dlErr)
 					return
 				}
+				SaveSubtitles(videoURL, episodePath)
 
 				// Optional: write AniSkip sidecar when AllAnime Smart is enabled
 				if util.GlobalDownloadRequest != nil && util.GlobalDownloadRequest.AllAnimeSmart {
@@ -911,7 +939,7 @@ func findEpisode(episodes []models.Episode, episodeNum int) (models.Episode, boo
 }
 
 // createEpisodePath creates the file path for the downloaded episode.
-func createEpisodePath(animeURL string, epNum int) (string, error) {
+func createEpisodePath(animeURL string, episode models.Episode) (string, error) {
 	userHome, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -921,7 +949,7 @@ func createEpisodePath(animeURL string, epNum int) (string, error) {
 	if err := os.MkdirAll(downloadDir, 0700); err != nil {
 		return "", err
 	}
-	return filepath.Join(downloadDir, fmt.Sprintf("%d.mp4", epNum)), nil
+	return episode.FilePath(downloadDir), nil
 }
 
 // fileExists verifica se o arquivo existe.
@@ -942,7 +970,7 @@ func handleExistingEpisodes(episodes []models.Episode, animeURL string, startNum
 			continue
 		}
 
-		episodePath, err := createEpisodePath(animeURL, episodeNum)
+		episodePath, err := createEpisodePath(animeURL, episode)
 		if err != nil {
 			continue
 		}
@@ -995,7 +1023,7 @@ func handleExistingEpisodes(episodes []models.Episode, animeURL string, startNum
 	}
 
 	// Verify the episode exists in our list
-	_, found := findEpisode(existingEpisodes, episodeNum)
+	episode, found := findEpisode(existingEpisodes, episodeNum)
 	if !found {
 		return fmt.Errorf("selected episode not found")
 	}
@@ -1003,7 +1031,7 @@ func handleExistingEpisodes(episodes []models.Episode, animeURL string, startNum
 	fmt.Printf("Playing Episode %d...\n", episodeNum)
 
 	// Get the episode path and play it
-	episodePath, err := createEpisodePath(animeURL, episodeNum)
+	episodePath, err := createEpisodePath(animeURL, episode)
 	if err != nil {
 		return fmt.Errorf("failed to get episode path: %w", err)
 	}
@@ -1024,7 +1052,7 @@ func askAndPlayDownloadedEpisode(episodes []models.Episode, animeURL string, sta
 			continue
 		}
 
-		episodePath, err := createEpisodePath(animeURL, episodeNum)
+		episodePath, err := createEpisodePath(animeURL, episode)
 		if err != nil {
 			continue
 		}
@@ -1077,7 +1105,7 @@ func askAndPlayDownloadedEpisode(episodes []models.Episode, animeURL string, sta
 	}
 
 	// Verify the episode exists in our list
-	_, found := findEpisode(downloadedEpisodes, episodeNum)
+	episode, found := findEpisode(downloadedEpisodes, episodeNum)
 	if !found {
 		return fmt.Errorf("selected episode not found")
 	}
@@ -1085,7 +1113,7 @@ func askAndPlayDownloadedEpisode(episodes []models.Episode, animeURL string, sta
 	fmt.Printf("Playing Episode %d...\n", episodeNum)
 
 	// Get the episode path and play it
-	episodePath, err := createEpisodePath(animeURL, episodeNum)
+	episodePath, err := createEpisodePath(animeURL, episode)
 	if err != nil {
 		return fmt.Errorf("failed to get episode path: %w", err)
 	}
@@ -0,0 +1,72 @@
+package player
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/models"
+)
+
+// episodeLabel is the line shown for ep in the episode picker, e.g.
+// "3 - The Journey's End [filler]"
+func episodeLabel(ep models.Episode) string {
+	label := ep.Number
+	if title := ep.DisplayTitle(); title != "" {
+		label += " - " + title
+	}
+	switch {
+	case ep.IsFiller:
+		label += " [filler]"
+	case ep.IsRecap:
+		label += " [recap]"
+	}
+	return label
+}
+
+// episodePreview describes ep in the picker's preview window
+func episodePreview(ep models.Episode) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Episode %s\n", ep.Number)
+	if title := ep.DisplayTitle(); title != "" {
+		b.WriteString(title + "\n")
+	}
+	if ep.Title.Japanese != "" && ep.Title.Japanese != ep.DisplayTitle() {
+		b.WriteString(ep.Title.Japanese + "\n")
+	}
+	b.WriteString("\n")
+	if aired := airDate(ep.Aired); aired != "" {
+		fmt.Fprintf(&b, "Aired: %s\n", aired)
+	}
+	if ep.Duration > 0 {
+		fmt.Fprintf(&b, "Duration: %d min\n", ep.Duration/60)
+	}
+	switch {
+	case ep.IsFiller:
+		b.WriteString("Filler\n")
+	case ep.IsRecap:
+		b.WriteString("Recap\n")
+	}
+	if ep.Synopsis != "" {
+		b.WriteString("\n" + ep.Synopsis + "\n")
+	}
+	return b.String()
+}
+
+// hasEpisodeDetails reports whether any episode has metadata worth previewing
+func hasEpisodeDetails(episodes []models.Episode) bool {
+	for _, ep := range episodes {
+		if ep.DisplayTitle() != "" || ep.Aired != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// airDate turns Jikan's "2023-09-29T00:00:00+00:00" into "2023-09-29"; other values are kept
+func airDate(aired string) string {
+	if t, err := time.Parse(time.RFC3339, aired); err == nil {
+		return t.Format("2006-01-02")
+	}
+	return aired
+}
@@ -0,0 +1,27 @@
+package player
+
+import (
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEpisodeLabelAndPreview(t *testing.T) {
+	t.Parallel()
+
+	ep := models.Episode{
+		Number:   "3",
+		Num:      3,
+		Title:    models.TitleDetails{English: "Killing Magic", Japanese: "人を殺す魔法"},
+		Aired:    "2023-09-29T00:00:00+00:00",
+		IsFiller: true,
+	}
+	assert.Equal(t, "3 - Killing Magic [filler]", episodeLabel(ep))
+	assert.Equal(t, "Episode 3\nKilling Magic\n人を殺す魔法\n\nAired: 2023-09-29\nFiller\n", episodePreview(ep))
+
+	plain := models.Episode{Number: "4", Num: 4, Title: models.TitleDetails{Romaji: "Episode 4"}}
+	assert.Equal(t, "4", episodeLabel(plain))
+	assert.False(t, hasEpisodeDetails([]models.Episode{plain}))
+	assert.True(t, hasEpisodeDetails([]models.Episode{plain, ep}))
+}
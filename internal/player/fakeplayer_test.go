@@ -0,0 +1,234 @@
+package player
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePlayer is an in-process Player that records what it is asked to do and
+// plays whatever the test makes it report
+type fakePlayer struct {
+	hub       eventHub
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu    sync.Mutex
+	calls []string
+	props map[string]interface{}
+}
+
+func newFakePlayer() *fakePlayer {
+	return &fakePlayer{done: make(chan struct{}), props: map[string]interface{}{}}
+}
+
+func (f *fakePlayer) record(format string, args ...interface{}) {
+	f.mu.Lock()
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+	f.mu.Unlock()
+}
+
+// Calls returns what the player was asked to do so far
+func (f *fakePlayer) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// waitCall waits until the player was asked to do call
+func (f *fakePlayer) waitCall(t *testing.T, call string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		for _, c := range f.Calls() {
+			if c == call {
+				return true
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond, "expected %q in %q", call, f.Calls())
+}
+
+// change sets a property and reports it like mpv does for observed ones
+func (f *fakePlayer) change(property string, value interface{}) {
+	f.mu.Lock()
+	f.props[property] = value
+	f.mu.Unlock()
+	f.hub.publish(Event{Name: "property-change", Property: property, Data: value})
+}
+
+// play reports the playback position
+func (f *fakePlayer) play(position float64) {
+	f.change("time-pos", position)
+}
+
+// end reports the end of the file
+func (f *fakePlayer) end(reason string) {
+	f.hub.publish(Event{Name: "end-file", Reason: reason})
+}
+
+func (f *fakePlayer) Load(target string, opts LoadOptions) error {
+	f.record("load %s start=%d", target, opts.Start)
+	return nil
+}
+
+func (f *fakePlayer) Seek(seconds float64) error {
+	f.record("seek %g", seconds)
+	f.change("time-pos", seconds)
+	return nil
+}
+
+func (f *fakePlayer) SetPause(paused bool) error {
+	f.record("pause %t", paused)
+	f.change("pause", paused)
+	return nil
+}
+
+func (f *fakePlayer) Get(property string) (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.props[property]
+	if !ok {
+		return nil, fmt.Errorf("%s: property unavailable", property)
+	}
+	return value, nil
+}
+
+func (f *fakePlayer) Set(property string, value interface{}) error {
+	f.record("set %s %v", property, value)
+	f.change(property, value)
+	return nil
+}
+
+func (f *fakePlayer) Observe(property string) (<-chan interface{}, func(), error) {
+	f.mu.Lock()
+	current := f.props[property]
+	f.mu.Unlock()
+	values, cancel := f.hub.observe(property, current)
+	return values, cancel, nil
+}
+
+func (f *fakePlayer) Events() (<-chan Event, func()) {
+	return f.hub.subscribe()
+}
+
+func (f *fakePlayer) ShowText(msg string, _ time.Duration) error {
+	f.record("text %s", msg)
+	return nil
+}
+
+func (f *fakePlayer) Quit() error {
+	f.record("quit")
+	f.closeOnce.Do(func() {
+		f.end("quit")
+		f.hub.close()
+		close(f.done)
+	})
+	return nil
+}
+
+func (f *fakePlayer) Done() <-chan struct{} {
+	return f.done
+}
+
+// fakeLaunch is a player launchPlayer started
+type fakeLaunch struct {
+	link   string
+	start  int
+	player *fakePlayer
+}
+
+// fakeLauncher starts fake players in place of mpv
+type fakeLauncher struct {
+	mu       sync.Mutex
+	launches []fakeLaunch
+}
+
+// useFakePlayers makes playVideo start fake players for the rest of the test
+func useFakePlayers(t *testing.T) *fakeLauncher {
+	l := &fakeLauncher{}
+	previous := launchPlayer
+	launchPlayer = func(link string, _ []string, start int) (string, error) {
+		p := newFakePlayer()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.launches = append(l.launches, fakeLaunch{link: link, start: start, player: p})
+		handle := fmt.Sprintf("fake:%s:%d", t.Name(), len(l.launches))
+		registerPlayer(handle, p)
+		return handle, nil
+	}
+	t.Cleanup(func() {
+		launchPlayer = previous
+		for _, launch := range l.Launches() {
+			_ = launch.player.Quit()
+		}
+	})
+	return l
+}
+
+// Launches returns the players started so far
+func (l *fakeLauncher) Launches() []fakeLaunch {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]fakeLaunch(nil), l.launches...)
+}
+
+// fakeMenu stands in for the player menu: each time it opens it reports on
+// shown, and the choices sent to it are the actions chosen there
+type fakeMenu struct {
+	shown   chan string
+	choices chan string
+}
+
+func useFakeMenu(t *testing.T) *fakeMenu {
+	m := &fakeMenu{shown: make(chan string, 8), choices: make(chan string, 8)}
+	previous := openPlayerMenu
+	openPlayerMenu = func(np nowPlaying) playerMenu {
+		m.shown <- fmt.Sprintf("Episode %d", np.Episode)
+		return m
+	}
+	t.Cleanup(func() { openPlayerMenu = previous })
+	return m
+}
+
+func (m *fakeMenu) Actions() <-chan string { return m.choices }
+
+// Done is never closed: the fake menu only ends when it is left
+func (m *fakeMenu) Done() <-chan struct{} { return nil }
+
+func (m *fakeMenu) Err() error { return nil }
+
+func (m *fakeMenu) Suspend(f func()) { f() }
+
+func (m *fakeMenu) ShowSkips([]skipSegment) {}
+
+func (m *fakeMenu) Close() {}
+
+// choose waits for the menu of episode and picks choice
+func (m *fakeMenu) choose(t *testing.T, episode, choice string) {
+	t.Helper()
+	select {
+	case shown := <-m.shown:
+		assert.Equal(t, episode, shown)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the menu of %s was not shown", episode)
+	}
+	m.choices <- choice
+}
+
+func TestFakePlayerObservesItsProperties(t *testing.T) {
+	p := newFakePlayer()
+	p.change("duration", 1440.0)
+	durations, cancel, err := p.Observe("duration")
+	require.NoError(t, err)
+	defer cancel()
+	assert.Equal(t, 1440.0, <-durations, "the current value comes first")
+
+	require.NoError(t, p.Quit())
+	_, open := <-durations
+	assert.False(t, open)
+	assert.Equal(t, []string{"quit"}, p.Calls())
+}
@@ -0,0 +1,121 @@
+package player
+
+import (
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// watchStreamErrors keeps playback going when the stream the player was
+// started with fails: on an end-file error the next candidate is loaded at the
+// last known position. mpv must run with --idle=yes so it stays up for the
+// next candidate; the watcher quits the player when no candidate is left, when
+// playback is stopped without another file taking over and, with quitAtEOF,
+// when the file ends normally. It returns when the file ends or is replaced.
+func watchStreamErrors(socketPath string, candidates []scraper.StreamLink, quitAtEOF bool) {
+	p, err := playerFor(socketPath)
+	if err != nil {
+		util.Debugf("Stream fallback disabled, cannot connect to the player: %v", err)
+		return
+	}
+	runFallbackWatcher(p, candidates, quitAtEOF)
+}
+
+// runFallbackWatcher implements watchStreamErrors over a player
+func runFallbackWatcher(p Player, candidates []scraper.StreamLink, quitAtEOF bool) {
+	quit := func() {
+		if err := p.Quit(); err != nil {
+			util.Debugf("Failed to quit the player: %v", err)
+		}
+	}
+
+	events, stop := p.Events()
+	defer stop()
+	// Positions are read from the event stream so they stay ordered with end-file
+	_, unobserve, err := p.Observe("time-pos")
+	if err != nil {
+		util.Debugf("Stream fallback cannot follow the playback position: %v", err)
+	} else {
+		defer unobserve()
+	}
+	if _, unobserveIdle, err := p.Observe("idle-active"); err != nil {
+		util.Debugf("Stream fallback cannot follow whether the player is idle: %v", err)
+	} else {
+		defer unobserveIdle()
+	}
+
+	var position float64
+	stopped := false
+	for ev := range events {
+		switch {
+		case ev.Name == "property-change" && ev.Property == "time-pos":
+			if pos, ok := ev.Data.(float64); ok && pos > 0 {
+				position = pos
+			}
+		case ev.Name == "end-file" && ev.Reason == "eof":
+			if quitAtEOF {
+				quit()
+			}
+			return
+		case ev.Name == "end-file" && ev.Reason == "stop":
+			// Either another episode is being loaded or playback was stopped
+			stopped = true
+		case stopped && ev.Name == "start-file":
+			return
+		case stopped && leftIdle(ev):
+			// Nothing else will play in the window --idle=yes kept open
+			quit()
+			return
+		case ev.Name == "end-file" && ev.Reason == "quit":
+			return
+		case ev.Name == "end-file" && ev.Reason == "error":
+			next, rest, ok := nextCandidate(candidates)
+			candidates = rest
+			if !ok {
+				util.Warn("Stream failed and no other mirror is left", "error", ev.FileError)
+				quit()
+				continue
+			}
+			util.Warn("Stream failed, switching mirror", "error", ev.FileError, "provider", next.Provider, "position", int(position))
+			opts := LoadOptions{Headers: next.Headers, Subtitles: subtitleFiles(next.Subtitles), Start: int(position)}
+			if err := p.Load(next.URL, opts); err != nil {
+				util.Debugf("Failed to load %s: %v", next.URL, err)
+			}
+		}
+	}
+}
+
+// leftIdle reports whether ev is mpv turning idle, with no file to play
+func leftIdle(ev Event) bool {
+	idle, _ := ev.Data.(bool)
+	return ev.Name == "property-change" && ev.Property == "idle-active" && idle
+}
+
+// nextCandidate returns the first candidate mpv can be given, skipping unsafe URLs
+func nextCandidate(candidates []scraper.StreamLink) (scraper.StreamLink, []scraper.StreamLink, bool) {
+	for i, c := range candidates {
+		safe, err := sanitizeMediaTarget(c.URL)
+		if err != nil {
+			util.Debugf("Skipping fallback %s: %v", c.URL, err)
+			continue
+		}
+		c.URL = safe
+		return c, candidates[i+1:], true
+	}
+	return scraper.StreamLink{}, nil, false
+}
+
+// mpvHeaderProperties maps stream headers to the mpv properties that send them,
+// clearing the Referer and Origin of the previous stream
+func mpvHeaderProperties(headers map[string]string) [][2]string {
+	h := network.StreamHeaders(headers)
+	props := [][2]string{{"referrer", h["Referer"]}}
+	if v := h["User-Agent"]; v != "" {
+		props = append(props, [2]string{"user-agent", v})
+	}
+	origin := ""
+	if v := h["Origin"]; v != "" {
+		origin = "Origin: " + v
+	}
+	return append(props, [2]string{"http-header-fields", origin})
+}
@@ -0,0 +1,131 @@
+package player
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/mpvipc"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMPV is the mpv end of an IPC connection
+type fakeMPV struct {
+	t    *testing.T
+	conn net.Conn
+	in   *bufio.Scanner
+}
+
+func (m *fakeMPV) emit(event string) {
+	m.t.Helper()
+	_, err := m.conn.Write([]byte(event + "\n"))
+	require.NoError(m.t, err)
+}
+
+// expect reads the next command from the watcher and acknowledges it
+func (m *fakeMPV) expect() []interface{} {
+	m.t.Helper()
+	require.NoError(m.t, m.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	require.True(m.t, m.in.Scan(), "expected a command from the watcher")
+	var msg struct {
+		Command   []interface{} `json:"command"`
+		RequestID int64         `json:"request_id"`
+	}
+	require.NoError(m.t, json.Unmarshal(m.in.Bytes(), &msg))
+	m.emit(fmt.Sprintf(`{"request_id":%d,"error":"success"}`, msg.RequestID))
+	return msg.Command
+}
+
+// startWatcher runs the watcher and returns the id it observes time-pos with
+func startWatcher(t *testing.T, candidates []scraper.StreamLink, quitAtEOF bool) (*fakeMPV, int, chan struct{}) {
+	conn, server := net.Pipe()
+	client := mpvipc.New(conn)
+	done := make(chan struct{})
+	go func() {
+		runFallbackWatcher(newMPVPlayer(client), candidates, quitAtEOF)
+		close(done)
+	}()
+	m := &fakeMPV{t: t, conn: server, in: bufio.NewScanner(server)}
+	observe := m.expect()
+	require.Len(t, observe, 3)
+	assert.Equal(t, "observe_property", observe[0])
+	assert.Equal(t, "time-pos", observe[2])
+	assert.Equal(t, "idle-active", m.expect()[2])
+	return m, int(observe[1].(float64)), done
+}
+
+func TestFallbackWatcherLoadsNextMirrorAtPosition(t *testing.T) {
+	m, id, done := startWatcher(t, []scraper.StreamLink{
+		{URL: "file:///etc/passwd"},
+		{Provider: "Sak", URL: "https://b.example/ep1.mp4", Headers: map[string]string{"Referer": "https://b.example/"}},
+		{Provider: "Kir", URL: "https://c.example/ep1.m3u8"},
+	}, true)
+
+	m.emit(fmt.Sprintf(`{"event":"property-change","id":%d,"name":"time-pos","data":312.7}`, id))
+	m.emit(`{"event":"end-file","reason":"error","file_error":"loading failed"}`)
+	assert.Equal(t, []interface{}{"set_property", "referrer", "https://b.example/"}, m.expect())
+	assert.Equal(t, []interface{}{"set_property", "http-header-fields", ""}, m.expect())
+	assert.Equal(t, []interface{}{"change-list", "sub-files", "clr", ""}, m.expect())
+	assert.Equal(t, []interface{}{"set_property", "start", "+312"}, m.expect())
+	assert.Equal(t, []interface{}{"loadfile", "https://b.example/ep1.mp4", "replace"}, m.expect())
+
+	m.emit(`{"event":"end-file","reason":"error"}`)
+	assert.Equal(t, []interface{}{"set_property", "referrer", ""}, m.expect(), "the previous Referer is cleared")
+	assert.Equal(t, []interface{}{"set_property", "http-header-fields", ""}, m.expect())
+	assert.Equal(t, []interface{}{"change-list", "sub-files", "clr", ""}, m.expect())
+	assert.Equal(t, []interface{}{"set_property", "start", "+312"}, m.expect())
+	assert.Equal(t, []interface{}{"loadfile", "https://c.example/ep1.m3u8", "replace"}, m.expect())
+
+	m.emit(`{"event":"end-file","reason":"error"}`)
+	assert.Equal(t, []interface{}{"quit"}, m.expect(), "mpv is idle once every mirror failed")
+
+	require.NoError(t, m.conn.Close())
+	<-done
+}
+
+func TestFallbackWatcherQuitsAtEndOfFile(t *testing.T) {
+	m, _, done := startWatcher(t, []scraper.StreamLink{{URL: "https://b.example/ep1.mp4"}}, true)
+
+	m.emit(`{"event":"end-file","reason":"eof"}`)
+	assert.Equal(t, []interface{}{"quit"}, m.expect())
+	<-done
+}
+
+func TestFallbackWatcherLeavesEndOfFileToAutoplay(t *testing.T) {
+	m, _, done := startWatcher(t, []scraper.StreamLink{{URL: "https://b.example/ep1.mp4"}}, false)
+
+	m.emit(`{"event":"end-file","reason":"eof"}`)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the watcher should stop with its episode")
+	}
+	assert.Equal(t, "unobserve_property", m.expect()[0], "mpv is not quit")
+}
+
+func TestFallbackWatcherQuitsWhenStoppedPlaybackLeavesMPVIdle(t *testing.T) {
+	m, _, done := startWatcher(t, []scraper.StreamLink{{URL: "https://b.example/ep1.mp4"}}, false)
+
+	m.emit(`{"event":"end-file","reason":"stop"}`)
+	m.emit(`{"event":"property-change","name":"idle-active","data":true}`)
+	assert.Equal(t, []interface{}{"quit"}, m.expect(), "mpv is not left open with nothing to play")
+	<-done
+}
+
+func TestFallbackWatcherKeepsMPVForTheNextEpisode(t *testing.T) {
+	m, _, done := startWatcher(t, []scraper.StreamLink{{URL: "https://b.example/ep1.mp4"}}, true)
+
+	m.emit(`{"event":"end-file","reason":"stop"}`)
+	m.emit(`{"event":"start-file"}`)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the watcher should stop once another episode is loaded")
+	}
+	assert.Equal(t, "unobserve_property", m.expect()[0], "mpv is not quit")
+}
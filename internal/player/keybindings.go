@@ -0,0 +1,119 @@
+package player
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/mpvipc"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+const (
+	// keyMessage is the first argument of the script-message GoAnime's keys send;
+	// the second is the action
+	keyMessage = "goanime"
+	// keySection is the input section used when mpv has no keybind command
+	keySection = "goanime"
+)
+
+// keyMenuChoices maps the key actions that have a player menu entry to it
+var keyMenuChoices = map[string]string{
+	"next":         "next",
+	"previous":     "previous",
+	"skip-intro":   "skip",
+	"sub-dub":      "subdub",
+	"mark-watched": "watched",
+	"search":       "change",
+	"mark-opening": "mark-opening",
+	"mark-ending":  "mark-ending",
+}
+
+// bindPlayerKeys binds the configured keys in the player at socketPath, so it
+// can be driven from a fullscreen window as well as from the menu. Only mpv
+// takes key bindings.
+func bindPlayerKeys(socketPath string) {
+	p, err := playerFor(socketPath)
+	if err != nil {
+		util.Debugf("Player keys disabled, cannot connect to the player: %v", err)
+		return
+	}
+	binder, ok := p.(keyBinder)
+	if !ok {
+		util.Debug("Player keys disabled, the player cannot bind keys")
+		return
+	}
+	if err := binder.BindKeys(config.Get().Keys.Bindings()); err != nil {
+		util.Debugf("Failed to bind player keys: %v", err)
+	}
+}
+
+// bindKeys binds each key to a script-message for GoAnime with mpv's keybind
+// command, or with an input section on versions without it
+func bindKeys(client *mpvipc.Client, bindings []config.KeyBinding) error {
+	for i, b := range bindings {
+		if _, err := client.Command("keybind", b.Key, keyCommand(b.Action)); err != nil {
+			if i > 0 {
+				return fmt.Errorf("keybind %s: %w", b.Key, err)
+			}
+			return bindKeysSection(client, bindings)
+		}
+	}
+	return nil
+}
+
+// bindKeysSection binds the keys through define-section, which overrides
+// mpv's own bindings for them
+func bindKeysSection(client *mpvipc.Client, bindings []config.KeyBinding) error {
+	lines := make([]string, 0, len(bindings))
+	for _, b := range bindings {
+		lines = append(lines, b.Key+" "+keyCommand(b.Action))
+	}
+	if _, err := client.Command("define-section", keySection, strings.Join(lines, "\n"), "force"); err != nil {
+		return err
+	}
+	_, err := client.Command("enable-section", keySection)
+	return err
+}
+
+// keyCommand is the mpv command a key bound to action runs
+func keyCommand(action string) string {
+	return fmt.Sprintf("script-message %s %s", keyMessage, action)
+}
+
+// keyAction returns the GoAnime action of a client-message event
+func keyAction(ev Event) (string, bool) {
+	if ev.Name != "client-message" || len(ev.Args) != 2 || ev.Args[0] != keyMessage {
+		return "", false
+	}
+	return ev.Args[1], true
+}
+
+// menuKeyActions forwards the player menu choices of keys pressed in the
+// player until stop is called or the player exits
+func menuKeyActions(p Player) (<-chan string, func()) {
+	events, stop := p.Events()
+	choices := make(chan string, 4)
+	go func() {
+		defer close(choices)
+		for ev := range events {
+			action, ok := keyAction(ev)
+			if !ok {
+				continue
+			}
+			if choice, ok := keyMenuChoices[action]; ok {
+				select {
+				case choices <- choice:
+				default:
+					// Presses beyond what the menu has handled yet are dropped
+				}
+			}
+		}
+	}()
+	return choices, stop
+}
+
+// watchedEpisodes holds the URLs of episodes marked as watched while they play;
+// their position is no longer saved, which would unmark them
+var watchedEpisodes sync.Map
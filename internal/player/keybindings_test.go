@@ -0,0 +1,77 @@
+package player
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/mpvipc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeClient(t *testing.T) (*mpvipc.Client, *fakeMPV) {
+	conn, server := net.Pipe()
+	client := mpvipc.New(conn)
+	t.Cleanup(func() { _ = client.Close() })
+	return client, &fakeMPV{t: t, conn: server, in: bufio.NewScanner(server)}
+}
+
+// reply reads the next command and answers it with an mpv error status
+func (m *fakeMPV) reply(status string) []interface{} {
+	m.t.Helper()
+	require.NoError(m.t, m.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	require.True(m.t, m.in.Scan())
+	var msg struct {
+		Command   []interface{} `json:"command"`
+		RequestID int64         `json:"request_id"`
+	}
+	require.NoError(m.t, json.Unmarshal(m.in.Bytes(), &msg))
+	m.emit(fmt.Sprintf(`{"request_id":%d,"error":%q}`, msg.RequestID, status))
+	return msg.Command
+}
+
+func TestBindKeysUsesKeybind(t *testing.T) {
+	client, m := newFakeClient(t)
+	bindings := []config.KeyBinding{{Action: "next", Key: ">"}, {Action: "undo", Key: "Ctrl+z"}}
+
+	errc := make(chan error, 1)
+	go func() { errc <- bindKeys(client, bindings) }()
+	assert.Equal(t, []interface{}{"keybind", ">", "script-message goanime next"}, m.expect())
+	assert.Equal(t, []interface{}{"keybind", "Ctrl+z", "script-message goanime undo"}, m.expect())
+	assert.NoError(t, <-errc)
+}
+
+func TestBindKeysFallsBackToInputSection(t *testing.T) {
+	client, m := newFakeClient(t)
+	bindings := []config.KeyBinding{{Action: "next", Key: ">"}, {Action: "search", Key: "Ctrl+f"}}
+
+	errc := make(chan error, 1)
+	go func() { errc <- bindKeys(client, bindings) }()
+	assert.Equal(t, "keybind", m.reply("invalid parameter")[0], "mpv before keybind existed")
+	assert.Equal(t, []interface{}{"define-section", "goanime", "> script-message goanime next\nCtrl+f script-message goanime search", "force"}, m.expect())
+	assert.Equal(t, []interface{}{"enable-section", "goanime"}, m.expect())
+	assert.NoError(t, <-errc)
+}
+
+func TestMenuKeyActionsForwardsMenuChoices(t *testing.T) {
+	client, m := newFakeClient(t)
+	choices, stop := menuKeyActions(newMPVPlayer(client))
+	defer stop()
+
+	m.emit(`{"event":"client-message","args":["goanime","undo"]}`)
+	m.emit(`{"event":"client-message","args":["other-script","next"]}`)
+	m.emit(`{"event":"client-message","args":["goanime","skip-intro"]}`)
+	m.emit(`{"event":"client-message","args":["goanime","search"]}`)
+
+	assert.Equal(t, "skip", <-choices, "undo is handled by the skipper, not the menu")
+	assert.Equal(t, "change", <-choices)
+
+	require.NoError(t, m.conn.Close())
+	_, open := <-choices
+	assert.False(t, open)
+}
@@ -0,0 +1,89 @@
+package player
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/mpvipc"
+)
+
+// mpvPlayer drives mpv over its JSON IPC connection
+type mpvPlayer struct {
+	client *mpvipc.Client
+}
+
+func newMPVPlayer(client *mpvipc.Client) *mpvPlayer {
+	return &mpvPlayer{client: client}
+}
+
+// Load sets the headers, subtitles and start position of the next file, none
+// of which may carry over from the previous one, and loads it
+func (m *mpvPlayer) Load(target string, opts LoadOptions) error {
+	for _, prop := range mpvHeaderProperties(opts.Headers) {
+		if err := m.client.Set(prop[0], prop[1]); err != nil {
+			return err
+		}
+	}
+	if _, err := m.client.Command("change-list", "sub-files", "clr", ""); err != nil {
+		return err
+	}
+	for _, file := range opts.Subtitles {
+		if _, err := m.client.Command("change-list", "sub-files", "append", file); err != nil {
+			return err
+		}
+	}
+	start := "none"
+	if opts.Start > 0 {
+		start = fmt.Sprintf("+%d", opts.Start)
+	}
+	if err := m.client.Set("start", start); err != nil {
+		return err
+	}
+	_, err := m.client.Command("loadfile", target, "replace")
+	return err
+}
+
+func (m *mpvPlayer) Seek(seconds float64) error {
+	_, err := m.client.Command("seek", seconds, "absolute")
+	return err
+}
+
+func (m *mpvPlayer) SetPause(paused bool) error {
+	return m.client.Set("pause", paused)
+}
+
+func (m *mpvPlayer) Get(property string) (interface{}, error) {
+	return m.client.Get(property)
+}
+
+func (m *mpvPlayer) Set(property string, value interface{}) error {
+	return m.client.Set(property, value)
+}
+
+func (m *mpvPlayer) Observe(property string) (<-chan interface{}, func(), error) {
+	return m.client.Observe(property)
+}
+
+func (m *mpvPlayer) Events() (<-chan Event, func()) {
+	return m.client.Events()
+}
+
+func (m *mpvPlayer) ShowText(msg string, d time.Duration) error {
+	_, err := m.client.Command("show-text", msg, d.Milliseconds())
+	return err
+}
+
+func (m *mpvPlayer) Quit() error {
+	_, err := m.client.Command("quit")
+	return err
+}
+
+func (m *mpvPlayer) Done() <-chan struct{} {
+	return m.client.Done()
+}
+
+// BindKeys binds GoAnime's keys in the mpv window
+func (m *mpvPlayer) BindKeys(bindings []config.KeyBinding) error {
+	return bindKeys(m.client, bindings)
+}
@@ -0,0 +1,179 @@
+package player
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// mpvAllowedOptions are the mpv options that may be passed on, whether they
+// come from GoAnime or from the user's config (mpv.args). They tune video,
+// audio, subtitles, caching and the window. Options that run scripts, load
+// other config or input files, write files, open other connections or replace
+// GoAnime's IPC socket (--script, --include, --input-ipc-server, --log-file,
+// --stream-record, --vf/--af, whose lavfi filters can read files,
+// --glsl-shaders, ...) are left out on purpose. Flags are also accepted as
+// --no-<name>. The list is documented in docs/MPV_OPTIONS.md.
+var mpvAllowedOptions = map[string]bool{
+	// GoAnime's own defaults
+	"config": true, "start": true, "idle": true, "keep-open": true,
+	"video-latency-hacks": true, "audio-display": true,
+
+	// Video
+	"hwdec": true, "vo": true, "gpu-api": true, "gpu-context": true, "profile": true,
+	"scale": true, "cscale": true, "dscale": true, "tscale": true,
+	"correct-downscaling": true, "linear-downscaling": true, "sigmoid-upscaling": true,
+	"deband": true, "deband-iterations": true, "deband-threshold": true, "deband-range": true, "deband-grain": true,
+	"dither-depth": true, "interpolation": true, "video-sync": true, "deinterlace": true,
+	"tone-mapping": true, "target-colorspace-hint": true, "icc-profile-auto": true,
+	"brightness": true, "contrast": true, "saturation": true, "gamma": true,
+	"keepaspect": true, "video-aspect-override": true, "panscan": true, "video-unscaled": true,
+
+	// Audio
+	"alang": true, "aid": true, "volume": true, "volume-max": true, "mute": true,
+	"audio-device": true, "audio-channels": true, "audio-exclusive": true,
+	"audio-pitch-correction": true, "audio-delay": true, "audio-normalize-downmix": true,
+
+	// Subtitles
+	"slang": true, "sid": true, "secondary-sid": true, "sub-auto": true, "sub-visibility": true,
+	"sub-font": true, "sub-font-size": true, "sub-bold": true, "sub-scale": true,
+	"sub-color": true, "sub-border-color": true, "sub-border-size": true, "sub-shadow-offset": true,
+	"sub-pos": true, "sub-margin-y": true, "sub-use-margins": true, "sub-ass-override": true,
+	"sub-codepage": true, "sub-delay": true, "sub-fix-timing": true,
+	"blend-subtitles": true, "embeddedfonts": true,
+
+	// Caching and seeking
+	"cache": true, "cache-secs": true, "cache-pause": true, "cache-pause-wait": true,
+	"demuxer-max-bytes": true, "demuxer-max-back-bytes": true, "demuxer-readahead-secs": true,
+	"demuxer-seekable-cache": true, "hr-seek": true, "force-seekable": true,
+
+	// Window, OSD and playback
+	"fullscreen": true, "fs": true, "fs-screen": true, "screen": true, "geometry": true,
+	"autofit": true, "autofit-larger": true, "autofit-smaller": true, "snap-window": true,
+	"ontop": true, "border": true, "force-window": true, "cursor-autohide": true,
+	"osc": true, "osd-level": true, "osd-font-size": true, "osd-duration": true, "osd-bar": true,
+	"speed": true, "pause": true,
+}
+
+// mpvAllowedValues restricts options whose other values would write files:
+// --vo=image saves every frame, so only the video outputs that draw on the
+// screen or in the terminal are accepted
+var mpvAllowedValues = map[string]map[string]bool{
+	"vo": {
+		"gpu": true, "gpu-next": true, "x11": true, "xv": true, "wlshm": true,
+		"dmabuf-wayland": true, "vaapi": true, "vdpau": true, "direct3d": true,
+		"sdl": true, "drm": true, "mediacodec_embed": true,
+		"tct": true, "sixel": true, "kitty": true, "caca": true, "null": true,
+	},
+}
+
+// mpvHeaderFlags maps the header flags callers may pass to the header they set
+var mpvHeaderFlags = map[string]string{
+	"--referrer=":   "Referer",
+	"--user-agent=": "User-Agent",
+}
+
+// mpvPlaybackArgs are the options episodes are played with: GoAnime's tuned
+// defaults, or the user's mpv configuration and profile, then the user's
+// extra options, which mpv lets override the earlier ones. --keep-open=no
+// comes last so that neither can turn it off: autoplay and the stream
+// fallback need the end of each file.
+func mpvPlaybackArgs(cfg config.MPVConfig) []string {
+	var args []string
+	if cfg.LoadsUserConfig() {
+		if cfg.Profile != "" {
+			args = append(args, "--profile="+cfg.Profile)
+		}
+	} else {
+		args = append(args,
+			"--hwdec=auto-safe",
+			"--vo=gpu",
+			"--profile=fast",
+			"--cache=yes",
+			"--demuxer-max-bytes=300M",
+			"--demuxer-readahead-secs=20",
+			"--no-config",
+			"--video-latency-hacks=yes",
+			"--audio-display=no",
+		)
+	}
+	args = append(args, cfg.Args...)
+	return append(args, "--keep-open=no")
+}
+
+// validHeaderArg reports whether a header flag carries a header that may be forwarded
+func validHeaderArg(a string) bool {
+	for prefix, name := range mpvHeaderFlags {
+		if strings.HasPrefix(a, prefix) {
+			return network.ValidStreamHeader(name, strings.TrimPrefix(a, prefix)) == nil
+		}
+	}
+	if field, ok := strings.CutPrefix(a, "--http-header-fields-append="); ok {
+		name, value, found := strings.Cut(field, ":")
+		return found && network.ValidStreamHeader(name, strings.TrimSpace(value)) == nil
+	}
+	return false
+}
+
+// allowedMPVArg reports whether a is an option on the allowlist
+func allowedMPVArg(a string) bool {
+	option, ok := strings.CutPrefix(a, "--")
+	if !ok || strings.ContainsAny(a, "\x00\n\r") {
+		return false
+	}
+	name, value, hasValue := strings.Cut(option, "=")
+	if values, restricted := mpvAllowedValues[name]; restricted && hasValue {
+		// mpv takes a list of fallbacks, each must be allowed
+		for _, v := range strings.Split(value, ",") {
+			if !values[v] {
+				return false
+			}
+		}
+		return true
+	}
+	if mpvAllowedOptions[name] {
+		return true
+	}
+	// --no-<flag> takes no value
+	flag, negated := strings.CutPrefix(name, "no-")
+	return negated && !hasValue && mpvAllowedOptions[flag]
+}
+
+// filterMPVArgs keeps the options that may be passed to mpv, to avoid passing
+// unexpected parameters, and returns the others. Positional arguments are
+// dropped silently: the media target is handled separately.
+func filterMPVArgs(args []string) (kept, rejected []string) {
+	for _, a := range args {
+		if !strings.HasPrefix(a, "--") {
+			continue
+		}
+		// Header flags are checked by value so they cannot smuggle other headers
+		if validHeaderArg(a) || allowedMPVArg(a) {
+			kept = append(kept, a)
+		} else {
+			rejected = append(rejected, a)
+		}
+	}
+	return kept, rejected
+}
+
+// RejectedMPVArgs returns the options of args mpv will not be given
+func RejectedMPVArgs(args []string) []string {
+	_, rejected := filterMPVArgs(args)
+	return rejected
+}
+
+// warnedMPVArgs holds the rejected options already reported
+var warnedMPVArgs sync.Map
+
+// warnRejectedMPVArgs reports each rejected option once per run
+func warnRejectedMPVArgs(rejected []string) {
+	for _, a := range rejected {
+		if _, seen := warnedMPVArgs.LoadOrStore(a, struct{}{}); !seen {
+			util.Warn("mpv option ignored, it is not on the allowlist (see docs/MPV_OPTIONS.md)", "option", a)
+		}
+	}
+}
@@ -2,9 +2,7 @@ package player
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -19,12 +17,14 @@ import (
 	"github.com/alvarorichard/Goanime/internal/api"
 	"github.com/alvarorichard/Goanime/internal/discord"
 	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/streamproxy"
 	"github.com/alvarorichard/Goanime/internal/util"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/huh"
-	"github.com/pkg/errors"
 )
 
 // lastAnimeURL stores the most recent anime URL/ID to support navigation when no updater is present
@@ -60,6 +60,23 @@ func (m *model) Init() tea.Cmd {
 	return tea.Batch(tickCmd(), m.progress.Init())
 }
 
+// newSocketPath returns a unique mpv IPC endpoint: a named pipe on Windows,
+// a Unix socket in the temp directory elsewhere
+func newSocketPath() (string, error) {
+	randomNumber := fmt.Sprintf("%x", time.Now().UnixNano())
+
+	if runtime.GOOS == "windows" {
+		return fmt.Sprintf(`\\.\pipe\goanime_mpvsocket_%s`, randomNumber), nil
+	}
+	// Use os.TempDir() instead of hardcoded /tmp for macOS compatibility
+	// macOS uses /var/folders/... accessed via $TMPDIR
+	tmpDir := os.TempDir()
+	if err := os.MkdirAll(tmpDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create tmp directory: %w", err)
+	}
+	return fmt.Sprintf("%s/goanime_mpvsocket_%s", tmpDir, randomNumber), nil
+}
+
 // StartVideo opens mpv with a socket for IPC
 // Modify the StartVideo function in player.go
 func StartVideo(link string, args []string) (string, error) {
@@ -69,19 +86,9 @@ func StartVideo(link string, args []string) (string, error) {
 		return "", fmt.Errorf("mpv not found in PATH. Please install mpv: https://mpv.io/installation/")
 	}
 
-	randomNumber := fmt.Sprintf("%x", time.Now().UnixNano())
-	var socketPath string
-
-	if runtime.GOOS == "windows" {
-		socketPath = fmt.Sprintf(`\\.\pipe\goanime_mpvsocket_%s`, randomNumber)
-	} else {
-		// Use os.TempDir() instead of hardcoded /tmp for macOS compatibility
-		// macOS uses /var/folders/... accessed via $TMPDIR
-		tmpDir := os.TempDir()
-		if err := os.MkdirAll(tmpDir, 0700); err != nil {
-			return "", fmt.Errorf("failed to create tmp directory: %w", err)
-		}
-		socketPath = fmt.Sprintf("%s/goanime_mpvsocket_%s", tmpDir, randomNumber)
+	socketPath, err := newSocketPath()
+	if err != nil {
+		return "", err
 	}
 
 	mpvArgs := []string{
@@ -89,8 +96,23 @@ func StartVideo(link string, args []string) (string, error) {
 		"--quiet",
 		fmt.Sprintf("--input-ipc-server=%s", socketPath),
 	}
+	mpvArgs = append(mpvArgs, mpvCookieArgs()...)
+	// Streams served by the local proxy get their headers and upstream proxy
+	// from it; subtitles are still looked up by the remote URL
+	origin, proxied := streamproxy.Upstream(link)
+	mpvArgs = append(mpvArgs, mpvSubtitleArgs(origin)...)
+	if !proxied {
+		proxyArgs, err := mpvProxyArgs(scraper.StreamProxy(link))
+		if err != nil {
+			return "", err
+		}
+		mpvArgs = append(mpvArgs, proxyArgs...)
+		mpvArgs = append(mpvArgs, mpvHeaderArgs(scraper.StreamHeaders(link))...)
+	}
 	// Validate and filter any additional args before passing to mpv
-	mpvArgs = append(mpvArgs, filterMPVArgs(args)...)
+	allowed, rejected := filterMPVArgs(args)
+	warnRejectedMPVArgs(rejected)
+	mpvArgs = append(mpvArgs, allowed...)
 
 	// Sanitize media target (URL or local file path)
 	safeLink, err := sanitizeMediaTarget(link)
@@ -169,42 +191,49 @@ func MpvSendCommand(socketPath string, command []interface{}) (interface{}, erro
 	return mpvSendCommand(socketPath, command)
 }
 
-// filterMPVArgs whitelists allowed mpv flags to avoid passing unexpected parameters.
-func filterMPVArgs(args []string) []string {
-	allowedNoValue := map[string]struct{}{
-		"--no-config": {},
-	}
-	allowedWithValuePrefixes := []string{
-		"--hwdec=",
-		"--vo=",
-		"--profile=",
-		"--cache=",
-		"--demuxer-max-bytes=",
-		"--demuxer-readahead-secs=",
-		"--video-latency-hacks=",
-		"--audio-display=",
-		"--start=",
-		// Add more allowed prefixes here if needed in the future
-	}
-
-	var filtered []string
-	for _, a := range args {
-		if !strings.HasPrefix(a, "--") {
-			// ignore positional args; media target is handled separately
-			continue
-		}
-		if _, ok := allowedNoValue[a]; ok {
-			filtered = append(filtered, a)
-			continue
-		}
-		for _, p := range allowedWithValuePrefixes {
-			if strings.HasPrefix(a, p) {
-				filtered = append(filtered, a)
-				break
-			}
-		}
+// mpvProxyArgs forwards proxy, the one configured for the stream's source, to
+// mpv. mpv only understands plain HTTP proxies; playing directly past a SOCKS
+// proxy would leak the connection, so that is an error pointing at the stream
+// proxy, which fetches through any proxy on mpv's behalf.
+func mpvProxyArgs(proxy string) ([]string, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("mpv cannot use %s:// proxies; use an http:// proxy or play through --stream-proxy 127.0.0.1:0", u.Scheme)
+	}
+	return []string{"--http-proxy=" + proxy}, nil
+}
+
+// mpvCookieArgs hands the stored source cookies to mpv, so streams behind bot
+// protection get the same clearance as the scrapers
+func mpvCookieArgs() []string {
+	path := network.CookiesFile()
+	if path == "" {
+		return nil
 	}
-	return filtered
+	return []string{"--cookies=yes", "--cookies-file=" + path}
+}
+
+// mpvHeaderArgs sends the headers a stream host expects. Only the headers
+// network.StreamHeaders allows are forwarded.
+func mpvHeaderArgs(headers map[string]string) []string {
+	h := network.StreamHeaders(headers)
+	var args []string
+	if v := h["Referer"]; v != "" {
+		args = append(args, "--referrer="+v)
+	}
+	if v := h["User-Agent"]; v != "" {
+		args = append(args, "--user-agent="+v)
+	}
+	if v := h["Origin"]; v != "" {
+		args = append(args, "--http-header-fields-append=Origin: "+v)
+	}
+	return args
 }
 
 // sanitizeMediaTarget ensures the media target is a safe http(s) URL or a cleaned file path
@@ -254,67 +283,23 @@ func sanitizeOutputPath(p string) (string, error) {
 	return cleaned, nil
 }
 
-// mpvSendCommand sends a JSON command to MPV via the IPC socket and receives the response.
+// mpvSendCommand sends a command over the shared IPC connection to the mpv at
+// socketPath and returns the data of its reply. Other players only answer
+// get_property, for the properties they know.
 func mpvSendCommand(socketPath string, command []interface{}) (interface{}, error) {
-	conn, err := dialMPVSocket(socketPath)
-	if err != nil {
-		return nil, err
-	}
-	defer func(conn net.Conn) {
-		err := conn.Close()
-		if err != nil {
-			fmt.Println("error closing mpv socket")
-		}
-	}(conn)
-
-	commandJSON, err := json.Marshal(map[string]interface{}{
-		"command": command,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = conn.Write(append(commandJSON, '\n'))
-	if err != nil {
-		return nil, err
-	}
-
-	buffer := make([]byte, 4096)
-	n, err := conn.Read(buffer)
+	p, err := playerFor(socketPath)
 	if err != nil {
 		return nil, err
 	}
-
-	if util.IsDebug {
-		fmt.Printf("[DEBUG]Raw response from mpv: %s\n", string(buffer[:n]))
+	if m, ok := p.(*mpvPlayer); ok {
+		return m.client.Command(command...)
 	}
-
-	// Tratar múltiplos JSONs na mesma resposta
-	responses := bytes.Split(buffer[:n], []byte("\n"))
-	for _, resp := range responses {
-		if len(bytes.TrimSpace(resp)) == 0 {
-			continue
-		}
-		var response map[string]interface{}
-		err = json.Unmarshal(resp, &response)
-		if err != nil {
-			if util.IsDebug {
-				fmt.Printf("[DEBUG]Error when unmarshaling: %v\n", err)
-			}
-			continue
-		}
-		if errStr, ok := response["error"].(string); ok && errStr == "property unavailable" {
-			// Propriedade ainda não disponível, ignore sem erro
-			if util.IsDebug {
-				fmt.Println("[DEBUG] Property not yet available, ignoring...")
-			}
-			continue
-		}
-		if data, exists := response["data"]; exists {
-			return data, nil
+	if len(command) == 2 && command[0] == "get_property" {
+		if property, ok := command[1].(string); ok {
+			return p.Get(property)
 		}
 	}
-	return nil, errors.New("no data field in mpv response")
+	return nil, fmt.Errorf("%v: %w", command, ErrUnsupported)
 }
 
 // windows
@@ -448,6 +433,9 @@ func downloadAndPlayEpisode(
 
 	downloadPath := filepath.Join(currentUser.HomeDir, ".local", "goanime", "downloads", "anime", DownloadFolderFormatter(animeURL))
 	episodePath := filepath.Join(downloadPath, episodeNumberStr+".mp4")
+	if episode, ok := findEpisode(episodes, selectedEpisodeNum); ok {
+		episodePath = episode.FilePath(downloadPath)
+	}
 
 	if _, err := os.Stat(downloadPath); os.IsNotExist(err) {
 		if err := os.MkdirAll(downloadPath, 0700); err != nil {
@@ -489,6 +477,7 @@ func downloadAndPlayEpisode(
 				if err := downloadWithYtDlp(videoURL, episodePath, m); err != nil {
 					util.Fatal("Failed to download video:", err)
 				}
+				SaveSubtitles(videoURL, episodePath)
 				m.mu.Lock()
 				m.done = true
 				m.mu.Unlock()
@@ -542,6 +531,7 @@ func downloadAndPlayEpisode(
 				if err := DownloadVideo(videoURL, episodePath, numThreads, m); err != nil {
 					util.Fatal("Failed to download video:", err)
 				}
+				SaveSubtitles(videoURL, episodePath)
 
 				m.mu.Lock()
 				m.done = true
@@ -634,44 +624,30 @@ func askForPlayOffline() bool {
 
 // ToggleSubtitle toggles subtitle visibility
 func ToggleSubtitle(socketPath string) error {
-	_, err := mpvSendCommand(socketPath, []interface{}{
-		"cycle",
-		"sub-visibility",
-	})
+	p, err := playerFor(socketPath)
+	if err != nil {
+		return err
+	}
+	_, err = toggleSubtitles(p, "")
 	return err
 }
 
-// GetPlaybackStats returns current playback statistics
+// GetPlaybackStats returns current playback statistics: position, speed,
+// bitrates, dropped frames and cache. Properties the player does not have
+// yet are left out.
 func GetPlaybackStats(socketPath string) (map[string]interface{}, error) {
-	stats := make(map[string]interface{})
-
-	// Get various playback properties
-	properties := []string{
-		"time-pos",
-		"duration",
-		"speed",
-		"volume",
-		"pause",
-		"filename",
-	}
-
-	for _, prop := range properties {
-		value, err := mpvSendCommand(socketPath, []interface{}{"get_property", prop})
-		if err != nil {
-			return nil, fmt.Errorf("failed to get %s: %w", prop, err)
-		}
-		stats[prop] = value
+	p, err := playerFor(socketPath)
+	if err != nil {
+		return nil, err
 	}
-
-	return stats, nil
+	return playbackStats(p), nil
 }
 
 // SetPlaybackSpeed sets the video playback speed
 func SetPlaybackSpeed(socketPath string, speed float64) error {
-	_, err := mpvSendCommand(socketPath, []interface{}{
-		"set_property",
-		"speed",
-		speed,
-	})
-	return err
+	p, err := playerFor(socketPath)
+	if err != nil {
+		return err
+	}
+	return setSpeed(p, speed, "")
 }
@@ -0,0 +1,115 @@
+package player
+
+import (
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMPVHeaderArgs(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{
+		"--referrer=https://allmanga.to/",
+		"--user-agent=Mozilla/5.0",
+		"--http-header-fields-append=Origin: https://allmanga.to",
+	}, mpvHeaderArgs(map[string]string{
+		"Referer":    "https://allmanga.to/",
+		"User-Agent": "Mozilla/5.0",
+		"Origin":     "https://allmanga.to",
+		"Cookie":     "session=1",
+	}))
+	assert.Empty(t, mpvHeaderArgs(nil))
+}
+
+func TestMPVProxyArgs(t *testing.T) {
+	t.Parallel()
+
+	args, err := mpvProxyArgs("")
+	assert.NoError(t, err)
+	assert.Empty(t, args)
+
+	args, err = mpvProxyArgs("http://127.0.0.1:8080")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--http-proxy=http://127.0.0.1:8080"}, args)
+
+	_, err = mpvProxyArgs("socks5://127.0.0.1:9050")
+	assert.ErrorContains(t, err, "socks5", "mpv must not silently bypass a SOCKS proxy")
+}
+
+func TestFilterMPVArgsValidatesHeaderFlags(t *testing.T) {
+	t.Parallel()
+
+	kept, rejected := filterMPVArgs([]string{
+		"--no-config",
+		"--referrer=https://allmanga.to/",
+		"--referrer=file:///etc/passwd",
+		"--http-header-fields-append=Origin: https://allmanga.to",
+		"--http-header-fields-append=Cookie: session=1",
+		"--http-header-fields=Referer: https://a.example/,Cookie: x",
+		"--script=/tmp/evil.lua",
+		"--start=+30",
+	})
+	assert.Equal(t, []string{
+		"--no-config",
+		"--referrer=https://allmanga.to/",
+		"--http-header-fields-append=Origin: https://allmanga.to",
+		"--start=+30",
+	}, kept)
+	assert.Equal(t, []string{
+		"--referrer=file:///etc/passwd",
+		"--http-header-fields-append=Cookie: session=1",
+		"--http-header-fields=Referer: https://a.example/,Cookie: x",
+		"--script=/tmp/evil.lua",
+	}, rejected)
+}
+
+func TestFilterMPVArgsAllowlist(t *testing.T) {
+	t.Parallel()
+
+	kept, rejected := filterMPVArgs([]string{
+		"--alang=jpn,ja",
+		"--vo=gpu-next,gpu",
+		"--no-osc",
+		"--fs",
+		"--glsl-shaders=/tmp/shader.glsl",
+		"--vo=image",
+		"--vo=gpu,image",
+		"--no-sub-auto=fuzzy",
+		"--include=/tmp/other.conf",
+		"--input-ipc-server=/tmp/other",
+		"--vf=lavfi=[movie=/etc/passwd]",
+		"--scripts-append=/tmp/x.lua",
+		"--slang=en\n--script=/tmp/evil.lua",
+		"https://example.org/ep1.mp4",
+	})
+	assert.Equal(t, []string{"--alang=jpn,ja", "--vo=gpu-next,gpu", "--no-osc", "--fs"}, kept)
+	assert.Equal(t, []string{
+		"--glsl-shaders=/tmp/shader.glsl",
+		"--vo=image",
+		"--vo=gpu,image",
+		"--no-sub-auto=fuzzy",
+		"--include=/tmp/other.conf",
+		"--input-ipc-server=/tmp/other",
+		"--vf=lavfi=[movie=/etc/passwd]",
+		"--scripts-append=/tmp/x.lua",
+		"--slang=en\n--script=/tmp/evil.lua",
+	}, rejected, "positional arguments are not options")
+}
+
+func TestMPVPlaybackArgs(t *testing.T) {
+	t.Parallel()
+
+	defaults := mpvPlaybackArgs(config.MPVConfig{Args: []string{"--alang=jpn"}})
+	assert.Contains(t, defaults, "--no-config")
+	assert.Equal(t, []string{"--alang=jpn", "--keep-open=no"}, defaults[len(defaults)-2:],
+		"user options come after the defaults to override them")
+
+	assert.Equal(t, []string{"--profile=anime", "--sub-scale=1.2", "--keep-open=no"},
+		mpvPlaybackArgs(config.MPVConfig{Profile: "anime", Args: []string{"--sub-scale=1.2"}}))
+	assert.Equal(t, []string{"--keep-open=no"}, mpvPlaybackArgs(config.MPVConfig{UserConfig: true}))
+
+	forced := mpvPlaybackArgs(config.MPVConfig{UserConfig: true, Args: []string{"--keep-open=yes"}})
+	assert.Equal(t, "--keep-open=no", forced[len(forced)-1], "autoplay needs the end of each file")
+}
@@ -3,15 +3,14 @@ package player
 import (
 	"errors"
 	"fmt"
-	"os"
-	"os/user"
+	"math"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alvarorichard/Goanime/internal/api"
+	"github.com/alvarorichard/Goanime/internal/config"
 	"github.com/alvarorichard/Goanime/internal/discord"
 	"github.com/alvarorichard/Goanime/internal/models"
 	"github.com/alvarorichard/Goanime/internal/scraper"
@@ -26,28 +25,12 @@ var ErrUserQuit = errors.New("user requested to quit application")
 // ErrChangeAnime is returned when the user chooses to change anime
 var ErrChangeAnime = errors.New("user requested to change anime")
 
-// applySkipTimes applies skip times to an mpv instance
-func applySkipTimes(socketPath string, episode *models.Episode) {
-	var opts []string
-	if episode.SkipTimes.Op.Start > 0 || episode.SkipTimes.Op.End > 0 {
-		opts = append(opts, fmt.Sprintf("skip_op=%d-%d", episode.SkipTimes.Op.Start, episode.SkipTimes.Op.End))
-	}
-	if episode.SkipTimes.Ed.Start > 0 || episode.SkipTimes.Ed.End > 0 {
-		opts = append(opts, fmt.Sprintf("skip_ed=%d-%d", episode.SkipTimes.Ed.Start, episode.SkipTimes.Ed.End))
-	}
-
-	if len(opts) > 0 {
-		combinedOpts := strings.Join(opts, ",")
-		_, cmdErr := mpvSendCommand(socketPath, []interface{}{"set_property", "script-opts", combinedOpts})
-		if cmdErr != nil {
-			util.Debugf("Failed to apply skip times: %v. Command: set_property script-opts %s", cmdErr, combinedOpts)
-		} else {
-			util.Debugf("Skip times applied successfully: %s", combinedOpts)
-		}
-	} else {
-		util.Debugf("No skip times available for episode %s", episode.Number)
-	}
-}
+// The dialogs and the stream lookup are swapped out in tests
+var (
+	openPlayerMenu    = startDashboard
+	resumePrompt      = showResumeDialog
+	resolveEpisodeURL = GetVideoURLForEpisodeEnhanced
+)
 
 // showResumeDialog displays a compact dialog asking if user wants to resume playback
 func showResumeDialog(episodeNum int, timeSeconds int) (bool, error) {
@@ -154,6 +137,21 @@ func playVideo(
 	currentEpisodeNum int,
 	anilistID int,
 	updater *discord.RichPresenceUpdater,
+) error {
+	return playVideoIn("", videoURL, episodes, currentEpisodeNum, anilistID, updater, -1)
+}
+
+// playVideoIn is playVideo in the mpv already running at socketPath, or in a
+// new one when socketPath is empty. Playback starts at resumeAt seconds; when
+// it is negative the user is offered to resume from the saved position.
+func playVideoIn(
+	socketPath string,
+	videoURL string,
+	episodes []models.Episode,
+	currentEpisodeNum int,
+	anilistID int,
+	updater *discord.RichPresenceUpdater,
+	resumeAt int,
 ) error {
 	// Log the episode number and URL for debugging
 	util.Debugf("Playing video for episode %d, URL: %s", currentEpisodeNum, videoURL)
@@ -167,36 +165,53 @@ func playVideo(
 		return fmt.Errorf("error getting current episode: %w", err)
 	}
 
-	// Set up mpv arguments for optimal playback
-	mpvArgs := []string{
-		"--hwdec=auto-safe",
-		"--vo=gpu",
-		"--profile=fast",
-		"--cache=yes",
-		"--demuxer-max-bytes=300M",
-		"--demuxer-readahead-secs=20",
-		"--no-config",
-		"--video-latency-hacks=yes",
-		"--audio-display=no",
-	}
+	// Set up mpv arguments for optimal playback, or the user's own
+	mpvArgs := mpvPlaybackArgs(config.Get().MPV)
+
+	// Audio, subtitles and speed as last chosen for the show
+	prefs := prefsFor(showKey(anilistID, episodeAnime(updater)))
+	mpvArgs = append(mpvArgs, prefs.mpvArgs()...)
 
 	// Initialize tracking and check for resume time
-	tracker, resumeTime := initTracking(anilistID, currentEpisode, currentEpisodeNum)
+	tracker, resumeTime := initTracking(anilistID, currentEpisode, currentEpisodeNum, resumeAt)
 	if resumeTime > 0 {
 		mpvArgs = append(mpvArgs, fmt.Sprintf("--start=+%d", resumeTime))
 	}
 
+	// Keep mpv alive after a stream error when other mirrors were validated,
+	// and at the end of the episode when autoplay may continue in it
+	autoplay := autoplayEnabled()
+	fallbacks := scraper.StreamFallbacks(videoURL)
+	if len(fallbacks) > 0 || autoplay {
+		mpvArgs = append(mpvArgs, "--idle=yes")
+	}
+	playURL, fallbacks := proxyStreams(videoURL, fallbacks)
+
 	// Fetch AniSkip data asynchronously
 	skipDataChan := fetchAniSkipAsync(anilistID, currentEpisodeNum, currentEpisode)
 
-	// Start the video with mpv
-	socketPath, err := StartVideo(videoURL, mpvArgs)
+	// Start the player, or load the video into the window autoplay kept open
+	if socketPath == "" {
+		socketPath, err = launchPlayer(playURL, mpvArgs, resumeTime)
+		if err == nil {
+			bindPlayerKeys(socketPath)
+		}
+	} else {
+		if p, perr := playerFor(socketPath); perr == nil {
+			prefs.apply(p)
+		}
+		err = loadVideo(socketPath, playURL, resumeTime)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to start video: %w", err)
 	}
+	if len(fallbacks) > 0 {
+		go watchStreamErrors(socketPath, fallbacks, !autoplay)
+	}
 
-	// Apply AniSkip results to skip intros/outros
-	applyAniSkipResults(skipDataChan, socketPath, currentEpisode, currentEpisodeNum)
+	// Skip intros/outros with the AniSkip results, as soon as they come
+	skips := startAutoSkip(socketPath, currentEpisode)
+	applyAniSkipResults(skipDataChan, skips, tracker, anilistID, socketPath, currentEpisodeNum)
 
 	// Initialize Discord Rich Presence if updater is provided
 	if updater != nil {
@@ -210,8 +225,14 @@ func playVideo(
 		return fmt.Errorf("episode %d not found in list", currentEpisodeNum)
 	}
 
-	// Preload the next episode for seamless playback
-	preloadNextEpisode(episodes, currentEpisodeIndex)
+	// Resolve the next episode ahead of time: autoplay keeps it to continue
+	// with, otherwise preloading only warms the caches
+	var autoplayNext <-chan autoplayNext
+	if autoplay {
+		autoplayNext = startAutoplay(socketPath, episodes, currentEpisodeIndex, episodeAnime(updater))
+	} else {
+		preloadNextEpisode(episodes, currentEpisodeIndex)
+	}
 
 	// Start tracking routine if tracker is available
 	stopTracking := startTrackingRoutine(tracker, socketPath, anilistID, currentEpisode, currentEpisodeNum, updater)
@@ -226,6 +247,9 @@ func playVideo(
 		updater,
 		stopTracking,
 		currentEpisode,
+		autoplayNext,
+		tracker,
+		skips,
 	)
 
 	// Close the tracking channel if it's still open
@@ -298,40 +322,38 @@ func getCurrentEpisode(episodes []models.Episode, num int) (*models.Episode, err
 // 	return tracker, 0
 // }
 
-// initTracking inicializa o sistema de rastreamento
-func initTracking(anilistID int, episode *models.Episode, episodeNum int) (*tracking.LocalTracker, int) {
+// initTracking inicializa o sistema de rastreamento; com resumeAt >= 0 a
+// reprodução começa ali sem perguntar
+func initTracking(anilistID int, episode *models.Episode, episodeNum int, resumeAt int) (*tracking.LocalTracker, int) {
+	watchedEpisodes.Delete(episode.URL)
+	start := resumeAt
+	if start < 0 {
+		start = 0
+	}
 	if !tracking.IsCgoEnabled {
 		if util.IsDebug {
 			util.Debug("Tracking desabilitado: CGO não disponível")
 		}
-		return nil, 0
-	}
-
-	currentUser, err := user.Current()
-	if err != nil {
-		util.Errorf("Falha ao obter usuário atual: %v", err)
-		return nil, 0
-	}
-
-	var dbPath string
-	if runtime.GOOS == "windows" {
-		dbPath = filepath.Join(os.Getenv("LOCALAPPDATA"), "GoAnime", "tracking", "progress.db")
-	} else {
-		dbPath = filepath.Join(currentUser.HomeDir, ".local", "goanime", "tracking", "progress.db")
+		return nil, start
 	}
 
+	dbPath := filepath.Join(config.Dir(), "tracking", "progress.db")
 	tracker := tracking.NewLocalTracker(dbPath)
 	if tracker == nil {
-		return nil, 0
+		return nil, start
+	}
+	if resumeAt >= 0 {
+		return tracker, resumeAt
 	}
 
 	progress, err := tracker.GetAnime(anilistID, episode.URL)
-	if err != nil || progress == nil || progress.PlaybackTime <= 0 {
+	// Um episódio assistido até o fim começa do início
+	if err != nil || progress == nil || progress.PlaybackTime <= 0 || progress.Watched() {
 		return tracker, 0
 	}
 
 	// Usa o episodeNum selecionado para o diálogo, mas mantém o PlaybackTime do rastreamento
-	if ok, _ := showResumeDialog(episodeNum, progress.PlaybackTime); ok {
+	if ok, _ := resumePrompt(episodeNum, progress.PlaybackTime); ok {
 		util.Debugf("Retomando do tempo salvo: %d segundos para o episódio %d", progress.PlaybackTime, episodeNum)
 		return tracker, progress.PlaybackTime
 	}
@@ -339,42 +361,119 @@ func initTracking(anilistID int, episode *models.Episode, episodeNum int) (*trac
 	return tracker, 0
 }
 
+// skipLookup is the answer of an AniSkip lookup
+type skipLookup struct {
+	times models.SkipTimes
+	err   error
+}
+
+// lookUpSkipTimes looks up the AniSkip times of an episode of length
+// seconds, 0 when it is unknown
+func lookUpSkipTimes(anilistID, episodeNum, length int) skipLookup {
+	found := models.Episode{Duration: length}
+	err := api.GetAndParseAniSkipData(anilistID, episodeNum, &found)
+	return skipLookup{times: found.SkipTimes, err: err}
+}
+
 // fetchAniSkipAsync fetches AniSkip data in parallel
-func fetchAniSkipAsync(anilistID, episodeNum int, episode *models.Episode) chan error {
-	ch := make(chan error, 1)
-	go func() {
-		err := api.GetAndParseAniSkipData(anilistID, episodeNum, episode)
-		ch <- err
-	}()
+func fetchAniSkipAsync(anilistID, episodeNum int, episode *models.Episode) <-chan skipLookup {
+	length := episode.Duration
+	ch := make(chan skipLookup, 1)
+	go func() { ch <- lookUpSkipTimes(anilistID, episodeNum, length) }()
 	return ch
 }
 
-// applyAniSkipResults applies AniSkip results
-func applyAniSkipResults(ch chan error, socketPath string, episode *models.Episode, episodeNum int) {
-	select {
-	case err := <-ch:
-		if err == nil {
-			applySkipTimes(socketPath, episode)
-
-			// For AllAnime episodes, also try to set chapter markers (like Curd does)
-			if strings.Contains(episode.URL, "kibfyvtiFpKC") || len(episode.URL) < 30 {
-				// This looks like an AllAnime episode ID, try to apply chapter markers
-				allAnimeClient := scraper.NewAllAnimeClient()
-				if chapterErr := allAnimeClient.SendSkipTimesToMPV(episode, socketPath, MpvSendCommand); chapterErr != nil {
-					util.Debugf("Failed to set chapter markers: %v", chapterErr)
-				}
+// playedLength waits until the player at socketPath knows the length of the
+// episode, in seconds, and returns 0 if stop is closed first
+func playedLength(socketPath string, stop <-chan struct{}) int {
+	durations, cancel, err := observePlayer(socketPath, "duration")
+	if err != nil {
+		util.Debugf("Failed to observe episode duration: %v", err)
+		return 0
+	}
+	defer cancel()
+
+	for {
+		select {
+		case value, ok := <-durations:
+			if !ok {
+				return 0
+			}
+			if duration, ok := value.(float64); ok && duration >= 1 {
+				return int(math.Round(duration))
 			}
-		} else {
-			util.Debugf("AniSkip data unavailable for episode %d: %v", episodeNum, err)
+		case <-stop:
+			return 0
 		}
-	case <-time.After(3 * time.Second):
-		util.Debugf("Timeout fetching AniSkip data for episode %d", episodeNum)
+	}
+}
+
+// aniSkipWait is how long starting the episode waits for the AniSkip results,
+// so that the dashboard opens with them; later results are applied once they
+// arrive
+var aniSkipWait = 3 * time.Second
+
+// applyAniSkipResults hands the AniSkip results to the episode's skipper and
+// marks them as chapters. Once the player knows the length of the episode,
+// AniSkip's submissions are matched to it again, as the length from metadata
+// is rough or missing. Openings and endings marked by hand in the local
+// tracker take precedence over AniSkip's.
+func applyAniSkipResults(ch <-chan skipLookup, skips *autoSkip, tracker *tracking.LocalTracker, anilistID int, socketPath string, episodeNum int) {
+	apply := func(answer skipLookup) {
+		times := answer.times
+		marked, ok := loadMarkedSkipTimes(tracker, anilistID, episodeNum)
+		if answer.err != nil {
+			util.Debugf("AniSkip data unavailable for episode %d: %v", episodeNum, answer.err)
+			if !ok {
+				return
+			}
+		}
+		applyMarkedSkipTimes(&times, marked)
+		if !skips.playing() {
+			return
+		}
+		skips.set(func(current *models.SkipTimes) { *current = times })
+
+		// Mark the opening and ending as chapters, whatever the source
+		allAnimeClient := scraper.NewAllAnimeClient()
+		if chapterErr := allAnimeClient.SendSkipTimesToMPV(&models.Episode{SkipTimes: times}, socketPath, MpvSendCommand); chapterErr != nil {
+			util.Debugf("Failed to set chapter markers: %v", chapterErr)
+		}
+	}
+
+	rematch := func() {
+		// The submissions are cached, matching them again costs no request
+		if length := playedLength(socketPath, skips.done); length > 0 {
+			apply(lookUpSkipTimes(anilistID, episodeNum, length))
+		}
+	}
+
+	select {
+	case answer := <-ch:
+		apply(answer)
+		if answer.err == nil {
+			go rematch()
+		}
+	case <-time.After(aniSkipWait):
+		util.Debugf("AniSkip data for episode %d is late, it is applied once it arrives", episodeNum)
+		go func() {
+			answer := <-ch
+			apply(answer)
+			if answer.err == nil {
+				rematch()
+			}
+		}()
 	}
 }
 
 // initDiscordPresence initializes Discord presence
 func initDiscordPresence(updater *discord.RichPresenceUpdater, socketPath string, tracker *tracking.LocalTracker, anilistID int, episode *models.Episode, episodeNum int) {
 	updater.SetSocketPath(socketPath)
+	if p, err := playerFor(socketPath); err != nil {
+		util.Debugf("Discord presence falls back to polling mpv: %v", err)
+	} else if err := updater.Watch(p); err != nil {
+		util.Debugf("Discord presence falls back to polling mpv: %v", err)
+	}
 	updater.Start()
 
 	go func() {
@@ -383,34 +482,36 @@ func initDiscordPresence(updater *discord.RichPresenceUpdater, socketPath string
 	}()
 }
 
-// waitForPlaybackStart waits for playback to start
+// waitForPlaybackStart waits until the player reports a playback position, or exits
 func waitForPlaybackStart(socketPath string, updater *discord.RichPresenceUpdater) {
-	for {
-		timePos, err := mpvSendCommand(socketPath, []interface{}{"get_property", "time-pos"})
-		if err == nil && timePos != nil && !updater.IsEpisodeStarted() {
+	positions, cancel, err := observePlayer(socketPath, "time-pos")
+	if err != nil {
+		util.Debugf("Failed to observe playback start: %v", err)
+		return
+	}
+	defer cancel()
+
+	for pos := range positions {
+		if pos != nil {
 			updater.SetEpisodeStarted(true)
 			return
 		}
-		time.Sleep(1 * time.Second)
 	}
 }
 
-// updateEpisodeDuration updates the episode duration
+// updateEpisodeDuration records the episode duration once the player knows it
 func updateEpisodeDuration(socketPath string, updater *discord.RichPresenceUpdater, tracker *tracking.LocalTracker, anilistID int, episode *models.Episode, episodeNum int) {
-	for {
-		if !updater.IsEpisodeStarted() || updater.GetEpisodeDuration() == 0 {
-			time.Sleep(1 * time.Second)
-			continue
-		}
-
-		durationPos, err := mpvSendCommand(socketPath, []interface{}{"get_property", "duration"})
-		if err != nil || durationPos == nil {
-			break
-		}
+	durations, cancel, err := observePlayer(socketPath, "duration")
+	if err != nil {
+		util.Debugf("Failed to observe episode duration: %v", err)
+		return
+	}
+	defer cancel()
 
-		duration, ok := durationPos.(float64)
-		if !ok {
-			break
+	for value := range durations {
+		duration, ok := value.(float64)
+		if !ok || duration <= 0 {
+			continue
 		}
 
 		dur := time.Duration(duration * float64(time.Second))
@@ -420,7 +521,7 @@ func updateEpisodeDuration(socketPath string, updater *discord.RichPresenceUpdat
 
 		updater.SetEpisodeDuration(dur)
 
-		if tracker != nil && dur > 0 {
+		if tracker != nil {
 			anime := tracking.Anime{
 				AnilistID:     anilistID,
 				AllanimeID:    episode.URL,
@@ -433,7 +534,7 @@ func updateEpisodeDuration(socketPath string, updater *discord.RichPresenceUpdat
 				util.Errorf("Failed to update tracking: %v", err)
 			}
 		}
-		break
+		return
 	}
 }
 
@@ -492,53 +593,74 @@ func preloadNextEpisode(episodes []models.Episode, currentIndex int) {
 	}()
 }
 
-// startTrackingRoutine starts the tracking routine
+// trackingInterval is the minimum time between two progress writes
+const trackingInterval = 2 * time.Second
+
+// startTrackingRoutine saves the playback position as the player reports it,
+// at most once per trackingInterval, and once more when tracking stops
 func startTrackingRoutine(tracker *tracking.LocalTracker, socketPath string, anilistID int, episode *models.Episode, episodeNum int, updater *discord.RichPresenceUpdater) chan struct{} {
 	stopChan := make(chan struct{})
 	if tracker == nil {
 		return stopChan
 	}
 
+	positions, cancel, err := observePlayer(socketPath, "time-pos")
+	if err != nil {
+		util.Debugf("Failed to observe playback position: %v", err)
+		return stopChan
+	}
+
 	go func() {
-		ticker := time.NewTicker(2 * time.Second)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				updateTracking(tracker, socketPath, anilistID, episode, episodeNum, updater)
-			case <-stopChan:
-				return
-			}
-		}
+		defer cancel()
+		trackPositions(positions, stopChan, trackingInterval, func(position float64) {
+			updateTracking(tracker, position, anilistID, episode, episodeNum, updater)
+		})
 	}()
 
 	return stopChan
 }
 
-// updateTracking updates tracking
-func updateTracking(tracker *tracking.LocalTracker, socketPath string, anilistID int, episode *models.Episode, episodeNum int, updater *discord.RichPresenceUpdater) {
-	timePos, err := mpvSendCommand(socketPath, []interface{}{"get_property", "time-pos"})
-	if err != nil || timePos == nil {
-		return
-	}
-
-	position, ok := timePos.(float64)
-	if !ok {
-		return
+// trackPositions calls save with the latest position at most once per interval,
+// and with the last unsaved one when positions end or stop is closed
+func trackPositions(positions <-chan interface{}, stop <-chan struct{}, interval time.Duration, save func(float64)) {
+	var (
+		latest    float64
+		pending   bool
+		lastSaved time.Time
+	)
+	flush := func() {
+		if pending {
+			save(latest)
+			pending = false
+			lastSaved = time.Now()
+		}
 	}
-
-	duration := 1440 // Default duration in seconds (24 minutes)
-	if updater != nil {
-		episodeDur := updater.GetEpisodeDuration()
-		if episodeDur > 0 {
-			duration = int(episodeDur.Seconds())
+	for {
+		select {
+		case value, ok := <-positions:
+			if !ok {
+				flush()
+				return
+			}
+			position, isNumber := value.(float64)
+			if !isNumber {
+				continue
+			}
+			latest, pending = position, true
+			if time.Since(lastSaved) >= interval {
+				flush()
+			}
+		case <-stop:
+			flush()
+			return
 		}
 	}
+}
 
-	// Ensure duration is valid before updating tracking
-	if duration <= 0 {
-		duration = 1440 // Fallback to default
+// updateTracking saves the playback position
+func updateTracking(tracker *tracking.LocalTracker, position float64, anilistID int, episode *models.Episode, episodeNum int, updater *discord.RichPresenceUpdater) {
+	if _, watched := watchedEpisodes.Load(episode.URL); watched {
+		return
 	}
 
 	anime := tracking.Anime{
@@ -546,7 +668,7 @@ func updateTracking(tracker *tracking.LocalTracker, socketPath string, anilistID
 		AllanimeID:    episode.URL,
 		EpisodeNumber: episodeNum,
 		PlaybackTime:  int(position),
-		Duration:      duration,
+		Duration:      trackedDuration(updater),
 		Title:         getEpisodeTitle(episode.Title),
 		LastUpdated:   time.Now(),
 	}
@@ -556,33 +678,21 @@ func updateTracking(tracker *tracking.LocalTracker, socketPath string, anilistID
 	}
 }
 
-// showPlayerMenu displays an interactive menu using huh.Select
-func showPlayerMenu(animeName string, currentEpisodeNum int) (string, error) {
-	var choice string
-
-	title := "GoAnime Player Controls"
-	if animeName != "" {
-		title = fmt.Sprintf("Now playing: %s - Episode %d", animeName, currentEpisodeNum)
+// trackedDuration is the episode duration saved with its progress, in seconds
+func trackedDuration(updater *discord.RichPresenceUpdater) int {
+	duration := 1440 // Default duration in seconds (24 minutes)
+	if updater != nil {
+		episodeDur := updater.GetEpisodeDuration()
+		if episodeDur > 0 {
+			duration = int(episodeDur.Seconds())
+		}
 	}
 
-	menu := huh.NewSelect[string]().
-		Title(title).
-		Description("Choose an action:").
-		Options(
-			huh.NewOption("Next episode", "next"),
-			huh.NewOption("Previous episode", "previous"),
-			huh.NewOption("Select episode", "select"),
-			huh.NewOption("Change anime", "change"),
-			huh.NewOption("Skip intro", "skip"),
-			huh.NewOption("Exit", "quit"),
-		).
-		Value(&choice)
-
-	if err := menu.Run(); err != nil {
-		return "", fmt.Errorf("error showing menu: %w", err)
+	// Ensure duration is valid before updating tracking
+	if duration <= 0 {
+		duration = 1440 // Fallback to default
 	}
-
-	return choice, nil
+	return duration
 }
 
 // handleUserInput manages user input
@@ -595,34 +705,101 @@ func handleUserInput(
 	updater *discord.RichPresenceUpdater,
 	stopTracking chan struct{},
 	currentEpisode *models.Episode,
+	autoplay <-chan autoplayNext,
+	tracker *tracking.LocalTracker,
+	skips *autoSkip,
 ) error {
 	// Get anime name for display
 	var animeName string
 	if updater != nil && updater.GetAnime() != nil {
 		animeName = updater.GetAnime().Name
 	}
+	np := newNowPlaying(socketPath, animeName, episodes, currentIndex, currentEpisodeNum, currentEpisode)
+	np.CanSwitchMode = isAllAnimeSourcePlayer(episodeAnime(updater))
+	np.Autoplay = autoplay != nil
+	np.Tracking = tracker != nil
+	if updater != nil {
+		np.Presence = updater.Connected
+	}
+	markers := newMarkerRecorder(socketPath, tracker, anilistID, currentEpisodeNum, skips)
 
+	// Keys pressed in the player window choose from the same menu
+	var keys <-chan string
+	if p, err := playerFor(socketPath); err == nil {
+		var stopKeys func()
+		keys, stopKeys = menuKeyActions(p)
+		defer stopKeys()
+	}
+
+	// The dashboard stays up for the whole episode; actions chosen there, with
+	// a key in the player window or by autoplay arrive as they come
+	menu := openPlayerMenu(np)
+	defer menu.Close()
+	skips.follow(func(times models.SkipTimes) {
+		menu.ShowSkips(skipSegments(times, config.Get().Skip))
+	})
 	for {
-		choice, err := showPlayerMenu(animeName, currentEpisodeNum)
-		if err != nil {
-			return fmt.Errorf("error showing menu: %w", err)
+		var choice string
+		select {
+		case next, ok := <-autoplay:
+			if !ok {
+				autoplay = nil
+				continue
+			}
+			menu.Close()
+			bingeStreak++
+			return continueEpisode(next, episodes, anilistID, updater, stopTracking, socketPath)
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+			choice = key
+		case choice = <-menu.Actions():
+		case <-menu.Done():
+			return menu.Err()
 		}
+		bingeStreak = 0
 
 		switch choice {
 		case "next":
+			menu.Close()
 			return playNextEpisode(currentIndex+1, episodes, anilistID, updater, stopTracking, socketPath)
 		case "previous":
+			menu.Close()
 			return playPreviousEpisode(currentIndex-1, episodes, anilistID, updater, stopTracking, socketPath)
 		case "quit":
-			_, _ = mpvSendCommand(socketPath, []interface{}{"quit"})
+			menu.Close()
+			quitPlayer(socketPath)
 			return ErrUserQuit
 		case "change":
-			_, _ = mpvSendCommand(socketPath, []interface{}{"quit"})
+			menu.Close()
+			quitPlayer(socketPath)
 			return ErrChangeAnime
 		case "select":
+			menu.Close()
 			return selectEpisode(episodes, anilistID, updater, stopTracking, socketPath)
 		case "skip":
-			skipIntro(socketPath, currentEpisode)
+			menu.Suspend(func() { skipIntro(socketPath, skips.times().Op) })
+		case "mark":
+			menu.Suspend(markers.markFromMenu)
+		case "mark-opening":
+			menu.Suspend(func() { markers.report(markers.toggle(tracking.SkipOpening)) })
+		case "mark-ending":
+			menu.Suspend(func() { markers.report(markers.toggle(tracking.SkipEnding)) })
+		case "controls":
+			menu.Suspend(func() { playbackControls(socketPath, showKey(anilistID, episodeAnime(updater))) })
+		case "subdub":
+			var play func() error
+			menu.Suspend(func() {
+				play = switchTranslation(socketPath, episodes, currentEpisode, currentEpisodeNum, anilistID, updater, stopTracking)
+			})
+			if play != nil {
+				menu.Close()
+				return play()
+			}
+		case "watched":
+			menu.Suspend(func() { markWatched(tracker, socketPath, anilistID, currentEpisode, currentEpisodeNum, updater) })
 		}
 	}
 }
@@ -664,11 +841,37 @@ func selectEpisode(episodes []models.Episode, anilistID int, updater *discord.Ri
 // switchEpisode switches between episodes
 func switchEpisode(newIndex int, episodes []models.Episode, anilistID int, updater *discord.RichPresenceUpdater, stopTracking chan struct{}, socketPath string) error {
 	target := episodes[newIndex]
-	targetNum, err := strconv.Atoi(ExtractEpisodeNumber(target.Number))
+	targetNum, err := episodeNumber(target)
 	if err != nil {
-		return fmt.Errorf("invalid episode number: %w", err)
+		return err
 	}
 
+	targetURL, err := resolveEpisodeURL(&target, episodeAnime(updater))
+	if err != nil {
+		return fmt.Errorf("failed to get video URL: %w", err)
+	}
+
+	if updater != nil {
+		updater.Stop()
+	}
+
+	close(stopTracking)
+	quitPlayer(socketPath)
+
+	return playVideo(targetURL, episodes, targetNum, anilistID, nextUpdater(updater, target))
+}
+
+// episodeNumber parses the number of an episode from the list
+func episodeNumber(ep models.Episode) (int, error) {
+	num, err := strconv.Atoi(ExtractEpisodeNumber(ep.Number))
+	if err != nil {
+		return 0, fmt.Errorf("invalid episode number: %w", err)
+	}
+	return num, nil
+}
+
+// episodeAnime returns the anime episodes are resolved for
+func episodeAnime(updater *discord.RichPresenceUpdater) *models.Anime {
 	var anime *models.Anime
 	if updater != nil {
 		anime = updater.GetAnime()
@@ -682,43 +885,127 @@ func switchEpisode(newIndex int, episodes []models.Episode, anilistID int, updat
 		}
 		anime = &models.Anime{URL: lastAnimeURL, Source: guessedSource}
 	}
+	return anime
+}
 
-	targetURL, err := GetVideoURLForEpisodeEnhanced(&target, anime)
-	if err != nil {
-		return fmt.Errorf("failed to get video URL: %w", err)
+// nextUpdater creates the Rich Presence updater for the episode that follows,
+// nil when presence is off
+func nextUpdater(updater *discord.RichPresenceUpdater, target models.Episode) *discord.RichPresenceUpdater {
+	if updater == nil {
+		return nil
 	}
+	duration := time.Duration(target.Duration) * time.Second
+	newUpdater := discord.NewRichPresenceUpdater(
+		updater.GetAnime(),
+		updater.GetIsPaused(),
+		updater.GetAnimeMutex(),
+		updater.GetUpdateFreq(),
+		duration,
+		"",
+		MpvSendCommand,
+	)
+	newUpdater.SetEpisodeStarted(false)
+	return newUpdater
+}
 
-	if updater != nil {
-		updater.Stop()
+// skipIntro skips the intro
+func skipIntro(socketPath string, opening models.Skip) {
+	if opening.End > 0 {
+		if p, err := playerFor(socketPath); err == nil {
+			_ = p.Seek(float64(opening.End))
+		}
+		fmt.Printf("Intro skipped to %ds\n", opening.End)
+	} else {
+		fmt.Println("Intro skip data not available, to mark it " + markKeysHint())
 	}
+}
 
-	close(stopTracking)
-	_, _ = mpvSendCommand(socketPath, []interface{}{"quit"})
+// switchModeLabel is the menu entry that switches between sub and dub
+func switchModeLabel() string {
+	if util.Mode() == "dub" {
+		return "Switch to sub"
+	}
+	return "Switch to dub"
+}
 
-	var newUpdater *discord.RichPresenceUpdater
-	if updater != nil {
-		duration := time.Duration(target.Duration) * time.Second
-		newUpdater = discord.NewRichPresenceUpdater(
-			updater.GetAnime(),
-			updater.GetIsPaused(),
-			updater.GetAnimeMutex(),
-			updater.GetUpdateFreq(),
-			duration,
-			"",
-			MpvSendCommand,
-		)
-		newUpdater.SetEpisodeStarted(false)
+// switchTranslation finds the current episode in the other translation (sub
+// or dub) and returns the reload at the current position. It returns nil when
+// playback goes on unchanged, e.g. because the source has no dub for the
+// episode.
+func switchTranslation(socketPath string, episodes []models.Episode, currentEpisode *models.Episode, currentEpisodeNum int, anilistID int, updater *discord.RichPresenceUpdater, stopTracking chan struct{}) func() error {
+	anime := episodeAnime(updater)
+	if !isAllAnimeSourcePlayer(anime) {
+		notify(socketPath, "Sub/dub switching is only available for AllAnime")
+		return nil
 	}
 
-	return playVideo(targetURL, episodes, targetNum, anilistID, newUpdater)
+	previous := util.Mode()
+	switched := "dub"
+	if previous == "dub" {
+		switched = "sub"
+	}
+	util.SetMode(switched)
+	target := *currentEpisode
+	videoURL, err := resolveEpisodeURL(&target, anime)
+	if err != nil {
+		util.SetMode(previous)
+		util.Debugf("Switching to %s failed: %v", switched, err)
+		notify(socketPath, fmt.Sprintf("No %s available for episode %d", strings.ToUpper(switched), currentEpisodeNum))
+		return nil
+	}
+
+	position := 0
+	if p, err := playerFor(socketPath); err == nil {
+		if pos, err := p.Get("time-pos"); err == nil {
+			if seconds, ok := pos.(float64); ok {
+				position = int(seconds)
+			}
+		}
+	}
+	notify(socketPath, "Switching to "+strings.ToUpper(switched))
+
+	return func() error {
+		if updater != nil {
+			updater.Stop()
+		}
+		select {
+		case <-stopTracking:
+		default:
+			close(stopTracking)
+		}
+		return playVideoIn(socketPath, videoURL, episodes, currentEpisodeNum, anilistID, nextUpdater(updater, target), position)
+	}
 }
 
-// skipIntro skips the intro
-func skipIntro(socketPath string, episode *models.Episode) {
-	if episode.SkipTimes.Op.End > 0 {
-		_, _ = mpvSendCommand(socketPath, []interface{}{"seek", episode.SkipTimes.Op.End, "absolute"})
-		fmt.Printf("Intro skipped to %ds\n", episode.SkipTimes.Op.End)
-	} else {
-		fmt.Println("Intro skip data not available")
+// markWatched saves the episode as played to its end, so it is not offered
+// for resuming; its position is no longer saved while it keeps playing
+func markWatched(tracker *tracking.LocalTracker, socketPath string, anilistID int, episode *models.Episode, episodeNum int, updater *discord.RichPresenceUpdater) {
+	if tracker == nil {
+		notify(socketPath, "Progress tracking is not available")
+		return
+	}
+	watchedEpisodes.Store(episode.URL, struct{}{})
+	duration := trackedDuration(updater)
+	anime := tracking.Anime{
+		AnilistID:     anilistID,
+		AllanimeID:    episode.URL,
+		EpisodeNumber: episodeNum,
+		PlaybackTime:  duration,
+		Duration:      duration,
+		Title:         getEpisodeTitle(episode.Title),
+		LastUpdated:   time.Now(),
+	}
+	if err := tracker.UpdateProgress(anime); err != nil {
+		util.Errorf("Failed to mark episode as watched: %v", err)
+		return
+	}
+	notify(socketPath, fmt.Sprintf("Episode %d marked as watched", episodeNum))
+}
+
+// notify tells the user about an action in the terminal and on the player's OSD
+func notify(socketPath, msg string) {
+	fmt.Println(msg)
+	if p, err := playerFor(socketPath); err == nil {
+		showText(p, msg, 3*time.Second)
 	}
 }
@@ -0,0 +1,196 @@
+package player
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/httpreplay"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/tracking"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var aniskipFixtures = filepath.Join("testdata", "fixtures", "aniskip")
+
+// testEpisodes are two episodes with AllAnime-style IDs, which are not preloaded
+var testEpisodes = []models.Episode{
+	{Number: "1", URL: "frieren-ep-1"},
+	{Number: "2", URL: "frieren-ep-2"},
+}
+
+// playInBackground runs playVideo and returns its result channel
+func playInBackground(videoURL string, num, anilistID int) <-chan error {
+	result := make(chan error, 1)
+	go func() { result <- playVideo(videoURL, testEpisodes, num, anilistID, nil) }()
+	return result
+}
+
+func waitResult(t *testing.T, result <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("playVideo did not return")
+		return nil
+	}
+}
+
+func TestPlayVideoQuitsFromTheMenu(t *testing.T) {
+	httpreplay.Use(t, aniskipFixtures)
+	launcher := useFakePlayers(t)
+	menu := useFakeMenu(t)
+
+	result := playInBackground("https://cdn.example/ep1.mp4", 1, 0)
+	menu.choose(t, "Episode 1", "quit")
+	assert.ErrorIs(t, waitResult(t, result), ErrUserQuit)
+
+	launches := launcher.Launches()
+	require.Len(t, launches, 1)
+	assert.Equal(t, "https://cdn.example/ep1.mp4", launches[0].link)
+	assert.Equal(t, []string{"quit"}, launches[0].player.Calls())
+}
+
+func TestSwitchEpisodeStartsTheNextEpisode(t *testing.T) {
+	httpreplay.Use(t, aniskipFixtures)
+	launcher := useFakePlayers(t)
+	menu := useFakeMenu(t)
+	previous := resolveEpisodeURL
+	resolveEpisodeURL = func(ep *models.Episode, _ *models.Anime) (string, error) {
+		return "https://cdn.example/" + ep.URL + ".mp4", nil
+	}
+	t.Cleanup(func() { resolveEpisodeURL = previous })
+
+	result := playInBackground("https://cdn.example/frieren-ep-1.mp4", 1, 0)
+	menu.choose(t, "Episode 1", "next")
+	menu.choose(t, "Episode 2", "quit")
+	assert.ErrorIs(t, waitResult(t, result), ErrUserQuit)
+
+	launches := launcher.Launches()
+	require.Len(t, launches, 2)
+	assert.Equal(t, []string{"quit"}, launches[0].player.Calls(), "the first episode's player is closed")
+	assert.Equal(t, "https://cdn.example/frieren-ep-2.mp4", launches[1].link)
+	assert.Equal(t, 0, launches[1].start)
+}
+
+func TestPlayVideoResumesFromSavedPosition(t *testing.T) {
+	if !tracking.IsCgoEnabled {
+		t.Skip("progress tracking needs CGO")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	tracker := tracking.NewLocalTracker(filepath.Join(config.Dir(), "tracking", "progress.db"))
+	require.NotNil(t, tracker)
+	for _, a := range []tracking.Anime{
+		{AnilistID: 7, AllanimeID: "frieren-ep-1", EpisodeNumber: 1, PlaybackTime: 312, Duration: 1440},
+		{AnilistID: 7, AllanimeID: "frieren-ep-2", EpisodeNumber: 2, PlaybackTime: 1440, Duration: 1440},
+	} {
+		require.NoError(t, tracker.UpdateProgress(a))
+	}
+	require.NoError(t, tracker.Close())
+
+	httpreplay.Use(t, aniskipFixtures)
+	launcher := useFakePlayers(t)
+	menu := useFakeMenu(t)
+	var prompted []int
+	previous := resumePrompt
+	resumePrompt = func(episodeNum, seconds int) (bool, error) {
+		prompted = append(prompted, seconds)
+		return true, nil
+	}
+	t.Cleanup(func() { resumePrompt = previous })
+
+	result := make(chan error, 1)
+	go func() { result <- playVideo("https://cdn.example/ep1.mp4", testEpisodes, 1, 7, nil) }()
+	menu.choose(t, "Episode 1", "quit")
+	require.ErrorIs(t, waitResult(t, result), ErrUserQuit)
+
+	go func() { result <- playVideo("https://cdn.example/ep2.mp4", testEpisodes, 2, 7, nil) }()
+	menu.choose(t, "Episode 2", "quit")
+	require.ErrorIs(t, waitResult(t, result), ErrUserQuit)
+
+	launches := launcher.Launches()
+	require.Len(t, launches, 2)
+	assert.Equal(t, 312, launches[0].start)
+	assert.Equal(t, 0, launches[1].start, "a watched episode starts from the beginning")
+	assert.Equal(t, []int{312}, prompted, "resuming a watched episode is not offered")
+}
+
+func TestPlayVideoSkipsOpeningFromAniSkip(t *testing.T) {
+	useTempConfigDir(t)
+	httpreplay.Use(t, aniskipFixtures)
+	launcher := useFakePlayers(t)
+	menu := useFakeMenu(t)
+
+	result := playInBackground("https://cdn.example/ep1.mp4", 1, 52991)
+	// The menu shows once skipping is set up
+	select {
+	case <-menu.shown:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the menu was not shown")
+	}
+	p := launcher.Launches()[0].player
+	p.play(60)
+	p.play(91)
+	p.waitCall(t, "seek 181")
+	p.waitCall(t, "text Skipped opening — press Ctrl+z to undo")
+
+	menu.choices <- "quit"
+	assert.ErrorIs(t, waitResult(t, result), ErrUserQuit)
+}
+
+func TestPlayVideoMatchesAniSkipToThePlayedLength(t *testing.T) {
+	useTempConfigDir(t)
+	httpreplay.Use(t, aniskipFixtures)
+	launcher := useFakePlayers(t)
+	menu := useFakeMenu(t)
+
+	result := playInBackground("https://cdn.example/ep1.mp4", 1, 52991)
+	select {
+	case <-menu.shown:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the menu was not shown")
+	}
+	// The first opening submitted is for a 1468s release, this one lasts 1440s
+	p := launcher.Launches()[0].player
+	p.change("duration", 1440.0)
+	require.Eventually(t, func() bool {
+		p.play(63)
+		for _, c := range p.Calls() {
+			if c == "seek 152" {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 20*time.Millisecond, "calls: %q", p.Calls())
+
+	menu.choices <- "quit"
+	assert.ErrorIs(t, waitResult(t, result), ErrUserQuit)
+}
+
+func TestApplyAniSkipResultsTakesLateAnswers(t *testing.T) {
+	useTempConfigDir(t)
+	old := aniSkipWait
+	aniSkipWait = 10 * time.Millisecond
+	t.Cleanup(func() { aniSkipWait = old })
+
+	p := newFakePlayer()
+	handle := "fake:" + t.Name()
+	registerPlayer(handle, p)
+	t.Cleanup(func() { _ = p.Quit() })
+
+	episode := &models.Episode{}
+	skips := startAutoSkip(handle, episode)
+	answers := make(chan skipLookup, 1)
+	applyAniSkipResults(answers, skips, nil, 1, handle, 1)
+
+	// The episode plays without skip times until AniSkip answers
+	p.play(91)
+	answers <- skipLookup{times: models.SkipTimes{Op: models.Skip{Start: 90, End: 180}}}
+	require.Eventually(t, func() bool { return skips.times().Op.End == 180 }, 5*time.Second, 10*time.Millisecond)
+	p.play(92)
+	p.waitCall(t, "seek 180")
+}
@@ -0,0 +1,78 @@
+package player
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// ProbeIPC starts a headless, idle mpv instance, asks for its version over the
+// JSON IPC socket and shuts it down again. It exercises the same socket setup as
+// StartVideo so IPC problems show up before the user tries to play something.
+func ProbeIPC(timeout time.Duration) (string, error) {
+	mpvPath, err := exec.LookPath("mpv")
+	if err != nil {
+		return "", fmt.Errorf("mpv not found in PATH")
+	}
+
+	socketPath, err := newSocketPath()
+	if err != nil {
+		return "", err
+	}
+
+	// #nosec G204: only fixed flags and a generated socket path are passed
+	cmd := exec.Command(mpvPath,
+		"--idle=yes",
+		"--no-config",
+		"--no-terminal",
+		"--vo=null",
+		"--ao=null",
+		fmt.Sprintf("--input-ipc-server=%s", socketPath),
+	)
+	setProcessGroup(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start mpv: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	defer func() {
+		select {
+		case <-exited:
+		case <-time.After(time.Second):
+			_ = cmd.Process.Kill()
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		select {
+		case <-exited:
+			return "", fmt.Errorf("mpv exited before creating its IPC socket: %s", stderr.String())
+		default:
+		}
+
+		if conn, err := dialMPVSocket(socketPath); err == nil {
+			_ = conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			_ = cmd.Process.Kill()
+			return "", fmt.Errorf("timed out after %s waiting for mpv IPC socket %s", timeout, socketPath)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	version, err := mpvSendCommand(socketPath, []interface{}{"get_property", "mpv-version"})
+	_, _ = mpvSendCommand(socketPath, []interface{}{"quit"})
+	if err != nil {
+		return "", fmt.Errorf("mpv IPC socket did not answer: %w", err)
+	}
+	return fmt.Sprint(version), nil
+}
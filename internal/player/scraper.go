@@ -1,6 +1,7 @@
 package player
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,11 +10,16 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	//"github.com/Microsoft/go-winio"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/alvarorichard/Goanime/internal/api"
+	"github.com/alvarorichard/Goanime/internal/hls"
+	"github.com/alvarorichard/Goanime/internal/mirrors"
 	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/scraper"
 	"github.com/alvarorichard/Goanime/internal/util"
 	"github.com/charmbracelet/huh"
 	"github.com/ktr0731/go-fuzzyfinder"
@@ -61,6 +67,23 @@ func getContentLength(url string, client *http.Client) (int64, error) {
 		strings.Contains(url, "master.m3u8") ||
 		strings.Contains(url, "allanime.pro")
 
+	// A HEAD on a playlist only gives the playlist size, so HLS is measured from its segments
+	if hls.IsPlaylistURL(url) {
+		var headers map[string]string
+		if isAllAnimeURL {
+			headers = map[string]string{"Referer": mirrors.Get(network.SourceAllAnime).Active().Referer}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		est, err := hls.EstimateSize(ctx, client, url, headers, "best")
+		if err == nil && est.Size > 0 {
+			util.Debugf("HLS size estimate: %d bytes over %s (variant %s)", est.Size, est.Duration, est.Variant.Resolution())
+			return est.Size, nil
+		}
+		util.Debugf("HLS size estimate failed, using fallback: %v", err)
+		return estimateContentLengthForAllAnime(url, client)
+	}
+
 	// Attempts to create an HTTP HEAD request to retrieve headers without downloading the body.
 	req, err := http.NewRequest("HEAD", url, nil)
 	if err != nil {
@@ -172,12 +195,22 @@ func SelectEpisodeWithFuzzyFinder(episodes []models.Episode) (string, string, er
 		return "", "", errors.New("no episodes provided")
 	}
 
+	opts := []fuzzyfinder.Option{fuzzyfinder.WithPromptString("Select the episode")}
+	// Titles, air dates and filler flags come from the Jikan episode list
+	if hasEpisodeDetails(episodes) {
+		opts = append(opts, fuzzyfinder.WithPreviewWindow(func(i, w, h int) string {
+			if i < 0 || i >= len(episodes) {
+				return ""
+			}
+			return episodePreview(episodes[i])
+		}))
+	}
 	idx, err := fuzzyfinder.Find(
 		episodes,
 		func(i int) string {
-			return episodes[i].Number
+			return episodeLabel(episodes[i])
 		},
-		fuzzyfinder.WithPromptString("Select the episode"),
+		opts...,
 	)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to select episode with go-fuzzyfinder: %w", err)
@@ -254,7 +287,11 @@ func GetVideoURLForEpisode(episodeURL string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return extractActualVideoURL(videoURL)
+	streamURL, err := extractActualVideoURL(videoURL)
+	if err == nil && mirrors.MatchesHost(network.SourceAnimeFire, episodeURL) {
+		scraper.RememberStream(network.SourceAnimeFire, streamURL)
+	}
+	return streamURL, err
 }
 
 // GetVideoURLForEpisodeEnhanced gets the video URL using the enhanced API with AllAnime navigation support
@@ -503,10 +540,10 @@ func extractActualVideoURL(videoSrc string) (string, error) {
 		return videoSrc, nil
 	}
 
-	// If the URL is from animefire.plus, fetch the content
-	if strings.Contains(videoSrc, "animefire.plus/video/") {
+	// If the URL is an AnimeFire video page (on any mirror), fetch the content
+	if isAnimefireVideoPage(videoSrc) {
 		if util.IsDebug {
-			util.Debugf("Found animefire.plus video URL, fetching content...")
+			util.Debugf("Found AnimeFire video URL, fetching content...")
 		}
 
 		// Fetch the video page
@@ -704,3 +741,8 @@ type VideoData struct {
 type VideoResponse struct {
 	Data []VideoData `json:"data"`
 }
+
+// isAnimefireVideoPage reports whether u is a /video/ page on any AnimeFire mirror
+func isAnimefireVideoPage(u string) bool {
+	return strings.Contains(u, "/video/") && mirrors.MatchesHost(network.SourceAnimeFire, u)
+}
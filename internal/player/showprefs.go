@@ -0,0 +1,128 @@
+package player
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// showPrefs are the playback choices remembered per show, for its next episodes
+type showPrefs struct {
+	// AudioLang and SubLang are the languages of the chosen tracks
+	AudioLang string `json:"audio_lang,omitempty"`
+	SubLang   string `json:"sub_lang,omitempty"`
+	SubsOff   bool   `json:"subs_off,omitempty"`
+	// Speed is the playback speed; 0 leaves mpv's
+	Speed float64 `json:"speed,omitempty"`
+}
+
+// showKey identifies the show an episode belongs to, "" when it is unknown
+func showKey(anilistID int, anime *models.Anime) string {
+	if anilistID > 0 {
+		return strconv.Itoa(anilistID)
+	}
+	if anime != nil {
+		return anime.URL
+	}
+	return ""
+}
+
+// mpvArgs are the mpv options that apply p to a new player
+func (p showPrefs) mpvArgs() []string {
+	var args []string
+	if p.AudioLang != "" {
+		args = append(args, "--alang="+p.AudioLang)
+	}
+	if p.SubLang != "" {
+		args = append(args, "--slang="+p.SubLang)
+	}
+	if p.SubsOff {
+		args = append(args, "--no-sub-visibility")
+	}
+	if p.Speed > 0 {
+		args = append(args, "--speed="+strconv.FormatFloat(p.Speed, 'f', -1, 64))
+	}
+	return args
+}
+
+// apply sets p on a running player for the file it loads next; players
+// without these properties keep their own
+func (p showPrefs) apply(pl Player) {
+	set := func(property string, value interface{}) {
+		if err := pl.Set(property, value); err != nil {
+			util.Debugf("Failed to apply %s for the show: %v", property, err)
+		}
+	}
+	if p.AudioLang != "" {
+		set("alang", p.AudioLang)
+	}
+	if p.SubLang != "" {
+		set("slang", p.SubLang)
+	}
+	if p.SubsOff {
+		set("sub-visibility", false)
+	}
+	if p.Speed > 0 {
+		set("speed", p.Speed)
+	}
+}
+
+// showPrefsPath is where the choices per show are remembered
+func showPrefsPath() string {
+	return filepath.Join(config.Dir(), "show_prefs.json")
+}
+
+var showPrefsMu sync.Mutex
+
+func loadShowPrefs() map[string]showPrefs {
+	prefs := map[string]showPrefs{}
+	data, err := os.ReadFile(showPrefsPath())
+	if err != nil {
+		return prefs
+	}
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		util.Debug("Ignoring unreadable show preferences", "error", err)
+	}
+	return prefs
+}
+
+// prefsFor returns the choices remembered for show
+func prefsFor(show string) showPrefs {
+	if show == "" {
+		return showPrefs{}
+	}
+	showPrefsMu.Lock()
+	defer showPrefsMu.Unlock()
+	return loadShowPrefs()[show]
+}
+
+// rememberForShow applies change to the choices remembered for show
+func rememberForShow(show string, change func(*showPrefs)) {
+	if show == "" {
+		return
+	}
+	showPrefsMu.Lock()
+	defer showPrefsMu.Unlock()
+
+	all := loadShowPrefs()
+	prefs := all[show]
+	change(&prefs)
+	all[show] = prefs
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(showPrefsPath()), 0700); err != nil {
+		util.Debug("Failed to save show preferences", "error", err)
+		return
+	}
+	if err := os.WriteFile(showPrefsPath(), data, 0600); err != nil {
+		util.Debug("Failed to save show preferences", "error", err)
+	}
+}
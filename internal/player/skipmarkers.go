@@ -0,0 +1,212 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/tracking"
+	"github.com/alvarorichard/Goanime/internal/util"
+	"github.com/charmbracelet/huh"
+)
+
+// opLengthTolerance is how many seconds the openings marked in earlier
+// episodes may differ in length for the opening to be inferred for later ones
+const opLengthTolerance = 3
+
+// markedSkipTimes returns the skip times marked for episode. An opening that
+// was not marked is inferred, at the same offsets, from the latest earlier
+// episode when every opening marked before it has the same length. Endings
+// move with the episode's length and are not inferred.
+func markedSkipTimes(markers []tracking.SkipMarker, episode int) (models.SkipTimes, bool) {
+	var times models.SkipTimes
+	found := false
+	var earlier []tracking.SkipMarker
+	for _, m := range markers {
+		skip := models.Skip{Start: m.Start, End: m.End}
+		switch {
+		case m.EpisodeNumber == episode && m.Kind == tracking.SkipOpening:
+			times.Op, found = skip, true
+		case m.EpisodeNumber == episode && m.Kind == tracking.SkipEnding:
+			times.Ed, found = skip, true
+		case m.EpisodeNumber < episode && m.Kind == tracking.SkipOpening:
+			earlier = append(earlier, m)
+		}
+	}
+	if times.Op.End > 0 || len(earlier) == 0 {
+		return times, found
+	}
+
+	latest := earlier[0]
+	shortest, longest := latest.End-latest.Start, latest.End-latest.Start
+	for _, m := range earlier[1:] {
+		length := m.End - m.Start
+		if length < shortest {
+			shortest = length
+		}
+		if length > longest {
+			longest = length
+		}
+		if m.EpisodeNumber > latest.EpisodeNumber {
+			latest = m
+		}
+	}
+	if longest-shortest > opLengthTolerance {
+		return times, found
+	}
+	times.Op = models.Skip{Start: latest.Start, End: latest.End}
+	return times, true
+}
+
+// loadMarkedSkipTimes returns the skip times marked for the episode, or
+// inferred for it, from the local tracker
+func loadMarkedSkipTimes(tracker *tracking.LocalTracker, anilistID, episodeNum int) (models.SkipTimes, bool) {
+	if tracker == nil || anilistID <= 0 {
+		return models.SkipTimes{}, false
+	}
+	markers, err := tracker.GetSkipMarkers(anilistID)
+	if err != nil {
+		util.Debugf("Failed to read skip markers: %v", err)
+		return models.SkipTimes{}, false
+	}
+	return markedSkipTimes(markers, episodeNum)
+}
+
+// applyMarkedSkipTimes lays the marked times over those from AniSkip; markers
+// win, as the user placed them for this very release
+func applyMarkedSkipTimes(times *models.SkipTimes, marked models.SkipTimes) {
+	if marked.Op.End > 0 {
+		times.Op = marked.Op
+	}
+	if marked.Ed.End > 0 {
+		times.Ed = marked.Ed
+	}
+}
+
+// skipKindNames names the marker kinds in messages
+var skipKindNames = map[string]string{
+	tracking.SkipOpening: "Opening",
+	tracking.SkipEnding:  "Ending",
+}
+
+// markerRecorder records the opening and ending of the episode being played,
+// marked from the dashboard or with keys in mpv, in the local tracker, and
+// hands them to the episode's skipper
+type markerRecorder struct {
+	socketPath string
+	tracker    *tracking.LocalTracker
+	anilistID  int
+	episodeNum int
+	skips      *autoSkip
+	pending    map[string]int // the marked start of each kind
+}
+
+func newMarkerRecorder(socketPath string, tracker *tracking.LocalTracker, anilistID, episodeNum int, skips *autoSkip) *markerRecorder {
+	return &markerRecorder{
+		socketPath: socketPath,
+		tracker:    tracker,
+		anilistID:  anilistID,
+		episodeNum: episodeNum,
+		skips:      skips,
+		pending:    map[string]int{},
+	}
+}
+
+// errNoMarkerStart is returned when an end is marked before its start
+var errNoMarkerStart = errors.New("mark where it starts first")
+
+// mark marks the start (end false) or the end of kind at the current
+// position; marking the end stores the marker
+func (r *markerRecorder) mark(kind string, end bool) (string, error) {
+	if r.tracker == nil {
+		return "", errors.New("skip markers need local tracking, which is unavailable")
+	}
+	if r.anilistID <= 0 {
+		return "", errors.New("skip markers need the show's AniList ID")
+	}
+	p, err := playerFor(r.socketPath)
+	if err != nil {
+		return "", err
+	}
+	value, err := p.Get("time-pos")
+	if err != nil {
+		return "", err
+	}
+	position, _ := value.(float64)
+	at := int(position)
+	name := skipKindNames[kind]
+
+	if !end {
+		r.pending[kind] = at
+		return fmt.Sprintf("%s starts at %s", name, clock(position)), nil
+	}
+	start, ok := r.pending[kind]
+	if !ok {
+		return "", errNoMarkerStart
+	}
+	if err := r.tracker.SaveSkipMarker(tracking.SkipMarker{
+		AnilistID:     r.anilistID,
+		EpisodeNumber: r.episodeNum,
+		Kind:          kind,
+		Start:         start,
+		End:           at,
+		LastUpdated:   time.Now(),
+	}); err != nil {
+		return "", err
+	}
+	delete(r.pending, kind)
+
+	skip := models.Skip{Start: start, End: at}
+	r.skips.set(func(times *models.SkipTimes) {
+		if kind == tracking.SkipOpening {
+			times.Op = skip
+		} else {
+			times.Ed = skip
+		}
+	})
+	return fmt.Sprintf("%s marked %s-%s", name, clock(float64(start)), clock(position)), nil
+}
+
+// toggle marks the start of kind, or its end once the start is marked, for
+// the single key mpv has for each kind
+func (r *markerRecorder) toggle(kind string) (string, error) {
+	_, started := r.pending[kind]
+	return r.mark(kind, started)
+}
+
+// report shows the result of marking in the player and the terminal
+func (r *markerRecorder) report(msg string, err error) {
+	if err != nil {
+		msg = "Cannot mark: " + err.Error()
+	}
+	notify(r.socketPath, msg)
+}
+
+// markFromMenu asks which edge to mark at the current position
+func (r *markerRecorder) markFromMenu() {
+	type edge struct {
+		kind string
+		end  bool
+	}
+	choice, err := chooseFrom("Mark at the current position", []huh.Option[edge]{
+		huh.NewOption("Opening starts here", edge{tracking.SkipOpening, false}),
+		huh.NewOption("Opening ends here", edge{tracking.SkipOpening, true}),
+		huh.NewOption("Ending starts here", edge{tracking.SkipEnding, false}),
+		huh.NewOption("Ending ends here", edge{tracking.SkipEnding, true}),
+	})
+	if err != nil {
+		return
+	}
+	r.report(r.mark(choice.kind, choice.end))
+}
+
+// markKeysHint tells how to mark the opening, for when it is not known
+func markKeysHint() string {
+	hint := "press m in the player dashboard"
+	if key := config.Get().Keys.Key("mark-opening"); key != "" {
+		hint += " or " + key + " in mpv"
+	}
+	return hint
+}
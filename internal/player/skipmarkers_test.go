@@ -0,0 +1,85 @@
+package player
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/tracking"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func opMarker(episode, start, end int) tracking.SkipMarker {
+	return tracking.SkipMarker{AnilistID: 1, EpisodeNumber: episode, Kind: tracking.SkipOpening, Start: start, End: end}
+}
+
+func TestMarkedSkipTimes(t *testing.T) {
+	markers := []tracking.SkipMarker{
+		opMarker(1, 0, 90),
+		{AnilistID: 1, EpisodeNumber: 1, Kind: tracking.SkipEnding, Start: 1320, End: 1410},
+		opMarker(2, 62, 151),
+	}
+
+	times, ok := markedSkipTimes(markers, 1)
+	assert.True(t, ok)
+	assert.Equal(t, models.SkipTimes{Op: models.Skip{Start: 0, End: 90}, Ed: models.Skip{Start: 1320, End: 1410}}, times)
+
+	// The openings marked so far all last about 90s: the latest one is used
+	times, ok = markedSkipTimes(markers, 5)
+	assert.True(t, ok)
+	assert.Equal(t, models.SkipTimes{Op: models.Skip{Start: 62, End: 151}}, times, "endings are not inferred")
+
+	_, ok = markedSkipTimes(markers, 0)
+	assert.False(t, ok, "nothing is inferred for earlier episodes")
+
+	// Openings of different lengths do not say where the next one is
+	_, ok = markedSkipTimes(append(markers, opMarker(3, 30, 60)), 5)
+	assert.False(t, ok)
+}
+
+func TestApplyMarkedSkipTimesOverridesAniSkip(t *testing.T) {
+	episode := &models.Episode{SkipTimes: models.SkipTimes{
+		Op: models.Skip{Start: 10, End: 100},
+		Ed: models.Skip{Start: 1300, End: 1390},
+	}}
+	applyMarkedSkipTimes(&episode.SkipTimes, models.SkipTimes{Op: models.Skip{Start: 20, End: 110}})
+	assert.Equal(t, models.Skip{Start: 20, End: 110}, episode.SkipTimes.Op)
+	assert.Equal(t, models.Skip{Start: 1300, End: 1390}, episode.SkipTimes.Ed, "AniSkip's ending is kept")
+}
+
+func TestMarkerRecorderStoresMarkers(t *testing.T) {
+	tracker := tracking.NewLocalTracker(filepath.Join(t.TempDir(), "progress.db"))
+	if tracker == nil {
+		t.Skip("local tracking unavailable")
+	}
+	t.Cleanup(func() { _ = tracker.Close() })
+
+	p := newFakePlayer()
+	handle := "fake:" + t.Name()
+	registerPlayer(handle, p)
+	t.Cleanup(func() { _ = p.Quit() })
+
+	episode := &models.Episode{}
+	r := newMarkerRecorder(handle, tracker, 1, 3, startAutoSkip(handle, episode))
+
+	p.play(70)
+	_, err := r.mark(tracking.SkipEnding, true)
+	assert.ErrorIs(t, err, errNoMarkerStart)
+
+	msg, err := r.toggle(tracking.SkipOpening)
+	require.NoError(t, err)
+	assert.Equal(t, "Opening starts at 1:10", msg)
+	p.play(159.6)
+	msg, err = r.toggle(tracking.SkipOpening)
+	require.NoError(t, err)
+	assert.Equal(t, "Opening marked 1:10-2:39", msg)
+	assert.Equal(t, models.Skip{Start: 70, End: 159}, r.skips.times().Op)
+
+	times, ok := loadMarkedSkipTimes(tracker, 1, 4)
+	assert.True(t, ok, "the next episode gets the opening inferred")
+	assert.Equal(t, models.Skip{Start: 70, End: 159}, times.Op)
+
+	_, err = newMarkerRecorder(handle, nil, 1, 3, r.skips).mark(tracking.SkipOpening, false)
+	assert.Error(t, err, "markers need the tracker")
+}
@@ -0,0 +1,38 @@
+package player
+
+import (
+	"fmt"
+
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/streamproxy"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// proxyStreams serves videoURL and its fallbacks through the local stream proxy
+// when -stream-proxy is set. The proxy sends the headers each host expects, so
+// the returned URLs play in any player. Without the flag, or when the proxy
+// cannot start, the links are returned unchanged.
+func proxyStreams(videoURL string, fallbacks []scraper.StreamLink) (string, []scraper.StreamLink) {
+	if util.GlobalStreamProxy == "" {
+		return videoURL, fallbacks
+	}
+	proxy, err := streamproxy.Shared(util.GlobalStreamProxy)
+	if err != nil {
+		util.Warnf("Stream proxy unavailable, playing directly: %v", err)
+		return videoURL, fallbacks
+	}
+
+	// The fallbacks were found with videoURL, on the same source
+	source := scraper.StreamSource(videoURL)
+	local := proxy.URL(videoURL, source, scraper.StreamHeaders(videoURL))
+	fmt.Printf("Stream available at %s\n", local)
+
+	proxied := make([]scraper.StreamLink, 0, len(fallbacks))
+	for _, link := range fallbacks {
+		link.URL = proxy.URL(link.URL, source, link.Headers)
+		// The proxy sends them; mpv talks to the proxy only
+		link.Headers = nil
+		proxied = append(proxied, link)
+	}
+	return local, proxied
+}
@@ -0,0 +1,39 @@
+package player
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/streamproxy"
+	"github.com/alvarorichard/Goanime/internal/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyStreams(t *testing.T) {
+	fallbacks := []scraper.StreamLink{{
+		URL:     "https://cdn.example.com/720.mp4",
+		Headers: map[string]string{"Referer": "https://allanime.to"},
+	}}
+
+	url, same := proxyStreams("https://cdn.example.com/1080.mp4", fallbacks)
+	assert.Equal(t, "https://cdn.example.com/1080.mp4", url, "streams play directly without -stream-proxy")
+	assert.Equal(t, fallbacks, same)
+
+	util.GlobalStreamProxy = "127.0.0.1:0"
+	t.Cleanup(func() { util.GlobalStreamProxy = "" })
+
+	url, proxied := proxyStreams("https://cdn.example.com/1080.mp4", fallbacks)
+	assert.True(t, strings.HasPrefix(url, "http://127.0.0.1:"))
+	origin, ok := streamproxy.Upstream(url)
+	require.True(t, ok)
+	assert.Equal(t, "https://cdn.example.com/1080.mp4", origin)
+
+	require.Len(t, proxied, 1)
+	assert.Nil(t, proxied[0].Headers, "the proxy sends the headers")
+	origin, ok = streamproxy.Upstream(proxied[0].URL)
+	require.True(t, ok)
+	assert.Equal(t, "https://cdn.example.com/720.mp4", origin)
+	assert.Equal(t, "https://allanime.to", fallbacks[0].Headers["Referer"], "the remembered links are not modified")
+}
@@ -0,0 +1,82 @@
+package player
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/api"
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/subtitles"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// mpvSubtitleArgs loads the subtitle tracks offered with link. The preferred
+// language is listed first and passed as --slang so mpv selects it.
+func mpvSubtitleArgs(link string) []string {
+	var args []string
+	for _, file := range subtitleFiles(scraper.StreamSubtitles(link)) {
+		args = append(args, "--sub-file="+file)
+	}
+	if lang := config.Get().Subtitles.Language; lang != "" && len(args) > 0 {
+		args = append(args, "--slang="+lang)
+	}
+	return args
+}
+
+// subtitleFiles returns the URLs of the tracks a player may be given
+func subtitleFiles(tracks []scraper.Subtitle) []string {
+	var files []string
+	for _, track := range tracks {
+		safe, err := sanitizeMediaTarget(track.URL)
+		if err != nil {
+			util.Debugf("Skipping subtitle %s: %v", track.URL, err)
+			continue
+		}
+		files = append(files, safe)
+	}
+	return files
+}
+
+// SaveSubtitles downloads the subtitle tracks offered with videoURL next to
+// episodePath (e.g. "3.en.vtt" and "3.en.srt" beside "3.mp4"), where mpv
+// picks them up when the episode is played offline. Failures are only logged,
+// the episode itself is already saved. The tracks are fetched with the
+// stream's headers, as their hosts often check the same Referer.
+func SaveSubtitles(videoURL, episodePath string) {
+	tracks := scraper.StreamSubtitles(videoURL)
+	if len(tracks) == 0 {
+		return
+	}
+	client := &http.Client{Transport: api.SafeTransport(30 * time.Second), Timeout: 30 * time.Second}
+	headers := network.StreamHeaders(scraper.StreamHeaders(videoURL))
+
+	seen := map[string]int{}
+	for i, track := range tracks {
+		lang := track.Lang
+		if lang == "" {
+			lang = "track" + strconv.Itoa(i+1)
+		}
+		// Two tracks in the same language must not overwrite each other
+		if seen[lang]++; seen[lang] > 1 {
+			lang += "-" + strconv.Itoa(seen[lang])
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		data, err := subtitles.Fetch(ctx, client, track.URL, headers)
+		cancel()
+		if err != nil {
+			util.Warn("Failed to download subtitle", "lang", track.Lang, "error", err)
+			continue
+		}
+		files, err := subtitles.Save(data, episodePath, lang, track.Format)
+		if err != nil {
+			util.Warn("Failed to save subtitle", "lang", track.Lang, "error", err)
+			continue
+		}
+		util.Debug("Subtitle saved", "lang", track.Lang, "files", files)
+	}
+}
@@ -0,0 +1,32 @@
+package player
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackPositionsThrottlesAndFlushes(t *testing.T) {
+	positions := make(chan interface{}, 8)
+	stop := make(chan struct{})
+	var saved []float64
+
+	positions <- nil // time-pos is unavailable until playback starts
+	positions <- 1.0
+	positions <- 1.5
+	positions <- 2.0
+	close(positions)
+	trackPositions(positions, stop, time.Hour, func(p float64) { saved = append(saved, p) })
+	assert.Equal(t, []float64{1.0, 2.0}, saved, "the first position is saved at once and the last on exit")
+
+	saved = nil
+	open := make(chan interface{})
+	go func() {
+		open <- 5.0
+		open <- 6.0
+		close(stop)
+	}()
+	trackPositions(open, stop, time.Hour, func(p float64) { saved = append(saved, p) })
+	assert.Equal(t, []float64{5.0, 6.0}, saved, "stopping saves the position not yet written")
+}
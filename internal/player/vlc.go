@@ -0,0 +1,562 @@
+package player
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/scraper"
+	"github.com/alvarorichard/Goanime/internal/streamproxy"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// VLC is driven through its HTTP interface. It has no event stream, so its
+// status is polled and the changes are published as the events mpv would
+// send. VLC has no OSD text or key bindings reachable from there; those
+// features are mpv only.
+
+const (
+	// vlcPollInterval is how often VLC's status is read
+	vlcPollInterval = 500 * time.Millisecond
+	// vlcMaxFailures is how many status reads in a row may fail before VLC is
+	// taken for closed
+	vlcMaxFailures = 3
+	// vlcLoadTimeout is how long a file given to Load may take to start
+	// playing before it is reported as failed
+	vlcLoadTimeout = 10 * time.Second
+)
+
+// vlcProperties maps the mpv properties VLC can report to their status field
+var vlcProperties = map[string]func(vlcStatus) interface{}{
+	"time-pos": func(s vlcStatus) interface{} {
+		if s.State == "stopped" {
+			return nil
+		}
+		return s.Time
+	},
+	"duration": func(s vlcStatus) interface{} {
+		if s.Length <= 0 {
+			return nil
+		}
+		return s.Length
+	},
+	"pause":  func(s vlcStatus) interface{} { return s.State == "paused" },
+	"speed":  func(s vlcStatus) interface{} { return s.Rate },
+	"volume": func(s vlcStatus) interface{} { return s.Volume * 100 / 256 },
+}
+
+// vlcStatus is the part of requests/status.json GoAnime reads
+type vlcStatus struct {
+	// State is "playing", "paused" or "stopped"
+	State  string  `json:"state"`
+	Time   float64 `json:"time"`
+	Length float64 `json:"length"`
+	Rate   float64 `json:"rate"`
+	// Volume is 256 at 100%
+	Volume float64 `json:"volume"`
+	// PlaylistID identifies the file playing; each Load gets a new one
+	PlaylistID int `json:"currentplid"`
+}
+
+// active reports whether a file is open
+func (s vlcStatus) active() bool {
+	return s.State == "playing" || s.State == "paused"
+}
+
+// vlcPlayer drives a VLC through its HTTP interface
+type vlcPlayer struct {
+	base        string
+	password    string
+	client      *http.Client
+	process     *os.Process
+	interval    time.Duration
+	loadTimeout time.Duration
+
+	hub       eventHub
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu   sync.Mutex
+	last vlcStatus
+	// loading is set while a file given to Load has not started playing
+	loading *vlcLoad
+}
+
+// vlcLoad is a Load waiting for its file to play
+type vlcLoad struct {
+	replaced int // PlaylistID of the file it replaces
+	since    time.Time
+}
+
+// started reports whether status shows the loaded file, not the replaced one
+func (l *vlcLoad) started(status vlcStatus) bool {
+	return status.active() && status.PlaylistID != l.replaced
+}
+
+func newVLCPlayer(base, password string) *vlcPlayer {
+	return &vlcPlayer{
+		base:        base,
+		password:    password,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		interval:    vlcPollInterval,
+		loadTimeout: vlcLoadTimeout,
+		done:        make(chan struct{}),
+	}
+}
+
+// StartVLC opens VLC on link with its HTTP interface on a local port and
+// returns the handle the player functions take
+func StartVLC(link string, start int) (string, error) {
+	vlcPath, err := findVLC()
+	if err != nil {
+		return "", err
+	}
+	port, err := freeLocalPort()
+	if err != nil {
+		return "", fmt.Errorf("failed to find a port for VLC: %w", err)
+	}
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	password := hex.EncodeToString(secret)
+	// The password would be visible to every local user in the process list,
+	// so VLC reads it from a config file of its own
+	configDir, err := os.MkdirTemp("", "goanime-vlc-")
+	if err != nil {
+		return "", err
+	}
+	configFile := filepath.Join(configDir, "vlcrc")
+	if err := os.WriteFile(configFile, []byte("[lua]\nhttp-password="+password+"\n"), 0600); err != nil {
+		_ = os.RemoveAll(configDir)
+		return "", err
+	}
+
+	args, err := vlcArgs(link, configFile, port, start)
+	if err != nil {
+		_ = os.RemoveAll(configDir)
+		return "", err
+	}
+	util.Debugf("Starting VLC with arguments: %v", args)
+
+	// #nosec G204: the media target is validated by sanitizeMediaTarget in vlcArgs
+	cmd := exec.Command(vlcPath, args...)
+	setProcessGroup(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		_ = os.RemoveAll(configDir)
+		return "", fmt.Errorf("failed to start vlc: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		_ = os.RemoveAll(configDir)
+		close(exited)
+	}()
+
+	v := newVLCPlayer(fmt.Sprintf("http://127.0.0.1:%d", port), password)
+	v.process = cmd.Process
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := v.request(nil)
+		if err == nil {
+			v.last = status
+			break
+		}
+		if time.Now().After(deadline) {
+			_ = cmd.Process.Kill()
+			return "", fmt.Errorf("timeout waiting for VLC's HTTP interface: %w", err)
+		}
+		select {
+		case <-exited:
+			return "", fmt.Errorf("vlc exited prematurely: %s", stderr.String())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	go v.poll(exited)
+
+	handle := "vlc:" + v.base
+	registerPlayer(handle, v)
+	return handle, nil
+}
+
+// findVLC looks for VLC in PATH, then where its installers put it
+func findVLC() (string, error) {
+	if path, err := exec.LookPath("vlc"); err == nil {
+		return path, nil
+	}
+	var candidates []string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = append(candidates, "/Applications/VLC.app/Contents/MacOS/VLC")
+	case "windows":
+		for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+			if dir := os.Getenv(env); dir != "" {
+				candidates = append(candidates, filepath.Join(dir, "VideoLAN", "VLC", "vlc.exe"))
+			}
+		}
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("vlc not found in PATH. Please install VLC: https://www.videolan.org/vlc/")
+}
+
+// freeLocalPort returns a loopback TCP port nothing listens on
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = l.Close() }()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// vlcArgs returns the arguments StartVLC opens link with, reading its
+// settings from configFile and serving its HTTP interface on port
+func vlcArgs(link, configFile string, port, start int) ([]string, error) {
+	args := []string{
+		"--config=" + configFile,
+		"--extraintf=http",
+		"--http-host=127.0.0.1",
+		fmt.Sprintf("--http-port=%d", port),
+		"--no-video-title-show",
+	}
+	origin, proxied := streamproxy.Upstream(link)
+	// VLC cannot read a cookies file, so a stream whose host has cookies stored
+	// is played through the local stream proxy, which sends them
+	if !proxied && hasStoredCookies(link) {
+		proxy, err := streamproxy.Shared("")
+		if err != nil {
+			return nil, fmt.Errorf("VLC cannot send the stored cookies and the stream proxy is unavailable: %w", err)
+		}
+		link, proxied = proxy.URL(link, scraper.StreamSource(link), scraper.StreamHeaders(link)), true
+	}
+	if !proxied {
+		proxyArgs, err := vlcProxyArgs(scraper.StreamProxy(link))
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, proxyArgs...)
+		args = append(args, vlcHeaderArgs(scraper.StreamHeaders(link))...)
+	}
+	// VLC takes a single subtitle file; the preferred language comes first
+	if files := subtitleFiles(scraper.StreamSubtitles(origin)); len(files) > 0 {
+		args = append(args, "--sub-file="+files[0])
+	}
+	if start > 0 {
+		args = append(args, fmt.Sprintf("--start-time=%d", start))
+	}
+	safeLink, err := sanitizeMediaTarget(link)
+	if err != nil {
+		return nil, fmt.Errorf("invalid media target: %w", err)
+	}
+	return append(args, safeLink), nil
+}
+
+// hasStoredCookies reports whether cookies are stored for the host of link,
+// in the jar of the source it comes from
+func hasStoredCookies(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return len(network.CookieJar(scraper.StreamSource(link)).Cookies(u)) > 0
+}
+
+// vlcProxyArgs makes VLC use proxy, given as a URL. VLC only speaks to HTTP
+// proxies, and credentials on its command line would be visible to every local
+// user in the process list.
+func vlcProxyArgs(proxy string) ([]string, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("VLC cannot use %s:// proxies; use an http:// proxy or play through --stream-proxy 127.0.0.1:0", u.Scheme)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("VLC cannot be given proxy credentials safely; play through --stream-proxy 127.0.0.1:0")
+	}
+	return []string{"--http-proxy=" + u.Host}, nil
+}
+
+// vlcHeaderArgs sends the Referer and User-Agent a stream host expects; VLC
+// cannot send other headers
+func vlcHeaderArgs(headers map[string]string) []string {
+	h := network.StreamHeaders(headers)
+	var args []string
+	if v := h["Referer"]; v != "" {
+		args = append(args, "--http-referrer="+v)
+	}
+	if v := h["User-Agent"]; v != "" {
+		args = append(args, "--http-user-agent="+v)
+	}
+	return args
+}
+
+// request runs a status request, with a command when params has one
+func (v *vlcPlayer) request(params url.Values) (vlcStatus, error) {
+	var status vlcStatus
+	req, err := http.NewRequest(http.MethodGet, v.base+"/requests/status.json?"+params.Encode(), nil)
+	if err != nil {
+		return status, err
+	}
+	req.SetBasicAuth("", v.password)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return status, fmt.Errorf("failed to reach vlc: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return status, fmt.Errorf("vlc answered with status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return status, fmt.Errorf("failed to read vlc status: %w", err)
+	}
+	return status, nil
+}
+
+// command runs a VLC command with its parameters as name/value pairs
+func (v *vlcPlayer) command(name string, pairs ...string) error {
+	select {
+	case <-v.done:
+		return errPlayerClosed
+	default:
+	}
+	params := url.Values{"command": {name}}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		params.Add(pairs[i], pairs[i+1])
+	}
+	_, err := v.request(params)
+	return err
+}
+
+// poll reads the status until VLC exits, publishing what changed
+func (v *vlcPlayer) poll(exited <-chan struct{}) {
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-exited:
+			v.close()
+			return
+		case <-v.done:
+			return
+		case <-ticker.C:
+		}
+		status, err := v.request(nil)
+		if err != nil {
+			if failures++; failures >= vlcMaxFailures {
+				util.Debugf("VLC stopped answering: %v", err)
+				v.close()
+				return
+			}
+			continue
+		}
+		failures = 0
+		v.update(status)
+	}
+}
+
+// update publishes the property changes from the previous status to status,
+// and the end of the file when VLC stopped. While a loaded file has yet to
+// play, VLC stopping in between is not an end; the file not starting is one.
+func (v *vlcPlayer) update(status vlcStatus) {
+	v.mu.Lock()
+	prev := v.last
+	v.last = status
+	load := v.loading
+	failed := false
+	if load != nil {
+		switch {
+		case load.started(status):
+			v.loading = nil
+		case time.Since(load.since) >= v.loadTimeout:
+			v.loading = nil
+			failed = true
+		}
+	}
+	v.mu.Unlock()
+
+	for _, name := range []string{"duration", "pause", "speed", "volume", "time-pos"} {
+		before, now := vlcProperties[name](prev), vlcProperties[name](status)
+		if before != now {
+			v.hub.publish(Event{Name: "property-change", Property: name, Data: now})
+		}
+	}
+	switch {
+	case failed:
+		v.hub.publish(Event{Name: "end-file", Reason: "error"})
+	case load == nil && prev.active() && !status.active():
+		v.hub.publish(Event{Name: "end-file", Reason: vlcEndReason(prev)})
+	}
+}
+
+// vlcEndReason tells, from the last status while the file played, why it
+// stopped: VLC's status only says that it did. Positions are whole seconds
+// read every vlcPollInterval, hence the margin.
+func vlcEndReason(last vlcStatus) string {
+	switch {
+	case last.Length <= 0 && last.Time <= 0:
+		return "error"
+	case last.Length > 0 && last.Time >= last.Length-2:
+		return "eof"
+	default:
+		return "stop"
+	}
+}
+
+// close marks VLC as exited and ends every subscription
+func (v *vlcPlayer) close() {
+	v.closeOnce.Do(func() {
+		v.hub.publish(Event{Name: "end-file", Reason: "quit"})
+		v.hub.close()
+		close(v.done)
+	})
+}
+
+// Load plays target in place of the current file. VLC only takes one option
+// with it, so the Referer is passed and subtitles and the start position are
+// applied once the file plays.
+func (v *vlcPlayer) Load(target string, opts LoadOptions) error {
+	pairs := []string{"input", target}
+	if referer := network.StreamHeaders(opts.Headers)["Referer"]; referer != "" {
+		pairs = append(pairs, "option", ":http-referrer="+referer)
+	}
+	v.mu.Lock()
+	load := &vlcLoad{replaced: v.last.PlaylistID, since: time.Now()}
+	v.loading = load
+	v.mu.Unlock()
+	if err := v.command("in_play", pairs...); err != nil {
+		v.mu.Lock()
+		v.loading = nil
+		v.mu.Unlock()
+		return err
+	}
+	if len(opts.Subtitles) == 0 && opts.Start <= 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(v.loadTimeout)
+	for {
+		status, err := v.request(nil)
+		if err == nil && load.started(status) && status.Length > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("vlc did not start %s", target)
+		}
+		select {
+		case <-v.done:
+			return errPlayerClosed
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	for _, file := range opts.Subtitles {
+		if err := v.command("addsubtitle", "val", file); err != nil {
+			return err
+		}
+	}
+	if opts.Start > 0 {
+		return v.Seek(float64(opts.Start))
+	}
+	return nil
+}
+
+func (v *vlcPlayer) Seek(seconds float64) error {
+	return v.command("seek", "val", strconv.Itoa(int(seconds)))
+}
+
+func (v *vlcPlayer) SetPause(paused bool) error {
+	if paused {
+		return v.command("pl_forcepause")
+	}
+	return v.command("pl_forceresume")
+}
+
+func (v *vlcPlayer) Get(property string) (interface{}, error) {
+	read, ok := vlcProperties[property]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", property, ErrUnsupported)
+	}
+	status, err := v.request(nil)
+	if err != nil {
+		return nil, err
+	}
+	return read(status), nil
+}
+
+func (v *vlcPlayer) Set(property string, value interface{}) error {
+	switch property {
+	case "pause":
+		if paused, ok := value.(bool); ok {
+			return v.SetPause(paused)
+		}
+	case "speed":
+		if rate, ok := value.(float64); ok {
+			return v.command("rate", "val", strconv.FormatFloat(rate, 'f', -1, 64))
+		}
+	case "volume":
+		if volume, ok := value.(float64); ok {
+			return v.command("volume", "val", strconv.Itoa(int(volume*256/100)))
+		}
+	default:
+		return fmt.Errorf("%s: %w", property, ErrUnsupported)
+	}
+	return fmt.Errorf("invalid value %v for %s", value, property)
+}
+
+func (v *vlcPlayer) Observe(property string) (<-chan interface{}, func(), error) {
+	read, ok := vlcProperties[property]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: %w", property, ErrUnsupported)
+	}
+	v.mu.Lock()
+	current := read(v.last)
+	v.mu.Unlock()
+	values, cancel := v.hub.observe(property, current)
+	return values, cancel, nil
+}
+
+func (v *vlcPlayer) Events() (<-chan Event, func()) {
+	return v.hub.subscribe()
+}
+
+// ShowText is not available: VLC's HTTP interface cannot show text
+func (v *vlcPlayer) ShowText(string, time.Duration) error {
+	return ErrUnsupported
+}
+
+// Quit ends the VLC process, which its HTTP interface cannot do
+func (v *vlcPlayer) Quit() error {
+	defer v.close()
+	if v.process == nil {
+		return nil
+	}
+	return v.process.Kill()
+}
+
+func (v *vlcPlayer) Done() <-chan struct{} {
+	return v.done
+}
@@ -2,6 +2,7 @@
 package scraper
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,37 +13,58 @@ import (
 	"strings"
 	"time"
 
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/mirrors"
 	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/network"
 	"github.com/alvarorichard/Goanime/internal/util"
 )
 
-const (
-	AllAnimeReferer = "https://allanime.to"
-	AllAnimeBase    = "allanime.day"
-	AllAnimeAPI     = "https://api.allanime.day/api"
-	UserAgent       = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/121.0"
-)
+// UserAgent is the browser identity sent to AllAnime and AnimeFire
+const UserAgent = network.UserAgent
 
 // AllAnimeClient handles interactions with AllAnime API
 type AllAnimeClient struct {
-	client    *http.Client
+	client  *http.Client
+	mirrors *mirrors.Set
+	// referer and apiBase pin the client to fixed endpoints instead of the
+	// active mirror; failover is disabled when apiBase is set
 	referer   string
 	apiBase   string
 	userAgent string
+	// prober replaces network.ProbeStream when set
+	prober func(ctx context.Context, rawURL string, headers map[string]string) (time.Duration, error)
 }
 
 // NewAllAnimeClient creates a new AllAnime client
 func NewAllAnimeClient() *AllAnimeClient {
 	return &AllAnimeClient{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		referer:   AllAnimeReferer,
-		apiBase:   AllAnimeAPI,
+		client:    network.NewClient(network.SourceAllAnime, 30*time.Second),
+		mirrors:   mirrors.Get(network.SourceAllAnime),
 		userAgent: UserAgent,
 	}
 }
 
+// mirror returns the endpoints to use for the next request
+func (c *AllAnimeClient) mirror() config.Mirror {
+	m := c.mirrors.Active()
+	if c.apiBase != "" {
+		m.API = c.apiBase
+	}
+	if c.referer != "" {
+		m.Referer = c.referer
+	}
+	return m
+}
+
+// do sends a request built against m, failing over to the next mirror when m is unreachable
+func (c *AllAnimeClient) do(req *http.Request, m config.Mirror) (*http.Response, error) {
+	if c.apiBase != "" {
+		return c.client.Do(req)
+	}
+	return c.mirrors.Do(c.client, m, req)
+}
+
 // SearchResponse represents the API response structure for anime search
 type SearchResponse struct {
 	Data struct {
@@ -65,8 +87,10 @@ type EpisodeResponse struct {
 		Episode struct {
 			EpisodeString string `json:"episodeString"`
 			SourceUrls    []struct {
-				SourceName string `json:"sourceName"`
-				SourceUrl  string `json:"sourceUrl"`
+				SourceName string  `json:"sourceName"`
+				SourceUrl  string  `json:"sourceUrl"`
+				Priority   float64 `json:"priority"`
+				Type       string  `json:"type"`
 			} `json:"sourceUrls"`
 		} `json:"episode"`
 	} `json:"data"`
@@ -82,105 +106,10 @@ type EpisodesListResponse struct {
 	} `json:"data"`
 }
 
-// SearchAnime searches for anime using AllAnime API (based on Curd implementation)
+// SearchAnime searches for anime using AllAnime API (based on Curd implementation).
+// A SearchOptions value in options pages and filters the results.
 func (c *AllAnimeClient) SearchAnime(query string, options ...interface{}) ([]*models.Anime, error) {
-	// Use the exact same GraphQL query as Curd
-	searchGql := `query($search: SearchInput, $limit: Int, $page: Int, $translationType: VaildTranslationTypeEnumType, $countryOrigin: VaildCountryOriginEnumType) {
-		shows(search: $search, limit: $limit, page: $page, translationType: $translationType, countryOrigin: $countryOrigin) {
-			edges {
-				_id
-				name
-				englishName
-				availableEpisodes
-				__typename
-			}
-		}
-	}`
-
-	// Prepare the GraphQL variables exactly like Curd
-	variables := map[string]interface{}{
-		"search": map[string]interface{}{
-			"allowAdult":   false,
-			"allowUnknown": false,
-			"query":        query,
-		},
-		"limit":           40,
-		"page":            1,
-		"translationType": "sub",
-		"countryOrigin":   "ALL",
-	}
-
-	// Marshal the variables to JSON
-	variablesJSON, err := json.Marshal(variables)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal variables: %w", err)
-	}
-
-	// Build the request URL exactly like Curd
-	reqURL := fmt.Sprintf("%s?variables=%s&query=%s", c.apiBase, url.QueryEscape(string(variablesJSON)), url.QueryEscape(searchGql))
-
-	req, err := http.NewRequest("GET", reqURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("User-Agent", c.userAgent)
-	req.Header.Set("Referer", c.referer)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Parse using a simple structure like Curd
-	var response struct {
-		Data struct {
-			Shows struct {
-				Edges []struct {
-					ID                string      `json:"_id"`
-					Name              string      `json:"name"`
-					EnglishName       string      `json:"englishName"`
-					AvailableEpisodes interface{} `json:"availableEpisodes"`
-				} `json:"edges"`
-			} `json:"shows"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	var animes []*models.Anime
-	for _, edge := range response.Data.Shows.Edges {
-		var episodesStr string
-		if episodes, ok := edge.AvailableEpisodes.(map[string]interface{}); ok {
-			if subEpisodes, ok := episodes["sub"].(float64); ok {
-				episodesStr = fmt.Sprintf("(%d episodes)", int(subEpisodes))
-			} else {
-				episodesStr = "(Unknown episodes)"
-			}
-		}
-
-		// Use English name if available, otherwise use default name
-		displayName := edge.Name
-		if edge.EnglishName != "" {
-			displayName = edge.EnglishName
-		}
-
-		anime := &models.Anime{
-			Name: fmt.Sprintf("%s %s", displayName, episodesStr),
-			URL:  edge.ID, // For AllAnime, the "URL" is actually the anime ID
-		}
-		animes = append(animes, anime)
-	}
-
-	return animes, nil
+	return c.searchShows(query, searchOptionsFrom(options))
 }
 
 // GetEpisodesList gets the list of available episodes for an anime (based on Curd implementation)
@@ -193,8 +122,9 @@ func (c *AllAnimeClient) GetEpisodesList(animeID string, mode string) ([]string,
 
 	// Correctly URL encode the parameters like Curd does
 	variables := fmt.Sprintf(`{"showId":"%s"}`, animeID)
+	m := c.mirror()
 	reqURL := fmt.Sprintf("%s?variables=%s&query=%s",
-		c.apiBase,
+		m.API,
 		url.QueryEscape(variables),
 		url.QueryEscape(episodesListGql))
 
@@ -203,9 +133,9 @@ func (c *AllAnimeClient) GetEpisodesList(animeID string, mode string) ([]string,
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", c.userAgent)
-	req.Header.Set("Referer", c.referer)
+	req.Header.Set("Referer", m.Referer)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req, m)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -266,7 +196,7 @@ func (c *AllAnimeClient) GetAnimeEpisodes(animeURL string) ([]models.Episode, er
 	animeID := animeURL
 
 	// Get episode list using existing function
-	episodeStrings, err := c.GetEpisodesList(animeID, "sub")
+	episodeStrings, err := c.GetEpisodesList(animeID, util.Mode())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get episodes list: %w", err)
 	}
@@ -426,7 +356,8 @@ func (c *AllAnimeClient) GetEpisodeURL(animeID string, episodeNo string, mode st
 	episodeEmbedGQL := `query ($showId: String!, $translationType: VaildTranslationTypeEnumType!, $episodeString: String!) { episode( showId: $showId translationType: $translationType episodeString: $episodeString ) { episodeString sourceUrls }}`
 	variables := fmt.Sprintf(`{"showId":"%s","translationType":"%s","episodeString":"%s"}`, animeID, mode, episodeNo)
 
-	req, err := http.NewRequest("GET", c.apiBase+"/api", nil)
+	m := c.mirror()
+	req, err := http.NewRequest("GET", m.API+"/api", nil)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -436,10 +367,10 @@ func (c *AllAnimeClient) GetEpisodeURL(animeID string, episodeNo string, mode st
 	q.Add("query", episodeEmbedGQL)
 	req.URL.RawQuery = q.Encode()
 
-	req.Header.Set("Referer", c.referer)
+	req.Header.Set("Referer", m.Referer)
 	req.Header.Set("User-Agent", c.userAgent)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req, m)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -450,123 +381,90 @@ func (c *AllAnimeClient) GetEpisodeURL(animeID string, episodeNo string, mode st
 		return "", nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	// Parse the response to extract source URLs
-	sourceURLs := c.extractSourceURLs(string(body))
-	if len(sourceURLs) == 0 {
+	// Parse the response to extract the provider sources
+	sources, err := c.extractSources(body)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse sources for episode %s: %w", episodeNo, err)
+	}
+	if len(sources) == 0 {
 		return "", nil, fmt.Errorf("no source URLs found for episode %s", episodeNo)
 	}
 
-	// Process URLs concurrently like Curd does
-	return c.processSourceURLsConcurrent(sourceURLs, quality, animeID, episodeNo)
+	// Process sources concurrently like Curd does
+	return c.processSourcesConcurrent(sources, quality, animeID, episodeNo)
 }
 
-// processSourceURLsConcurrent processes source URLs with concurrent requests and priority-based selection
-func (c *AllAnimeClient) processSourceURLsConcurrent(sourceURLs []string, quality string, animeID string, episodeNo string) (string, map[string]string, error) {
+// processSourcesConcurrent extracts the links of every provider concurrently,
+// probes them and returns the best one that answered. The others are kept as
+// its StreamFallbacks.
+func (c *AllAnimeClient) processSourcesConcurrent(sources []ProviderSource, quality string, animeID string, episodeNo string) (string, map[string]string, error) {
 	type result struct {
-		index     int
-		links     map[string]string
-		err       error
-		sourceURL string
+		links []StreamLink
+		err   error
+		src   ProviderSource
 	}
 
-	results := make(chan result, len(sourceURLs))
-	highPriorityLink := make(chan string, 1)
+	results := make(chan result, len(sources))
 
 	// Rate limiter like in Curd
 	rateLimiter := time.NewTicker(50 * time.Millisecond)
 	defer rateLimiter.Stop()
 
-	// Launch goroutines for concurrent processing
-	for i, sourceURL := range sourceURLs {
-		go func(idx int, url string) {
+	for _, src := range sources {
+		go func(src ProviderSource) {
 			<-rateLimiter.C // Rate limit the requests
-
-			links, err := c.getLinks(url)
-			if err != nil {
-				results <- result{index: idx, err: err, sourceURL: url}
-				return
-			}
-
-			// Check for high priority links first
-			for _, link := range links {
-				for _, domain := range LinkPriorities[:3] { // Check top 3 priority domains
-					if strings.Contains(link, domain) {
-						// Found high priority link, send it immediately
-						select {
-						case highPriorityLink <- link:
-						default:
-							// Channel already has a high priority link
-						}
-						break
-					}
-				}
-			}
-
-			results <- result{index: idx, links: links, sourceURL: url}
-		}(i, sourceURL)
-	}
-
-	// First, try to get a high priority link quickly
-	select {
-	case link := <-highPriorityLink:
-		// Found high priority link, return it immediately
-		metadata := map[string]string{
-			"quality":  quality,
-			"anime_id": animeID,
-			"episode":  episodeNo,
-			"priority": "high",
-		}
-		return link, metadata, nil
-	case <-time.After(2 * time.Second): // Wait briefly for high priority link
-		// No high priority link found quickly, proceed with normal collection
+			links, err := c.extractLinks(src)
+			results <- result{links: links, err: err, src: src}
+		}(src)
 	}
 
-	// Collect results with timeout
 	timeout := time.After(10 * time.Second)
-	successCount := 0
-	var bestURL string
-	var bestMetadata map[string]string
-
-	for successCount < len(sourceURLs) {
+	var all []StreamLink
+collect:
+	for received := 0; received < len(sources); received++ {
 		select {
 		case res := <-results:
 			if res.err != nil {
+				util.Debug("Provider failed", "provider", res.src.Name, "error", res.err)
 				continue
 			}
-
-			// Select quality from the links
-			selectedURL, metadata := c.selectQuality(res.links, quality)
-			if selectedURL != "" {
-				// Check if this is a prioritized link
-				priority := c.getPriorityScore(selectedURL)
-				if priority > 0 || bestURL == "" {
-					bestURL = selectedURL
-					bestMetadata = metadata
-					bestMetadata["source_url"] = res.sourceURL
-					bestMetadata["anime_id"] = animeID
-					bestMetadata["episode"] = episodeNo
-
-					if priority > 0 {
-						// Found a priority link, return immediately
-						return bestURL, bestMetadata, nil
-					}
-				}
-			}
-			successCount++
-
+			all = append(all, res.links...)
 		case <-timeout:
-			if bestURL != "" {
-				return bestURL, bestMetadata, nil
-			}
-			return "", nil, fmt.Errorf("timeout waiting for results")
+			break collect
 		}
 	}
 
-	if bestURL != "" {
-		return bestURL, bestMetadata, nil
+	all = c.expandHLSVariants(all)
+
+	var link StreamLink
+	if ranked := c.rankCandidates(all, quality); len(ranked) > 0 {
+		rememberCandidates(network.SourceAllAnime, ranked)
+		link = ranked[0]
+	} else {
+		// Probes can fail for reasons the player copes with, so an unverified
+		// link is still better than none
+		var ok bool
+		if link, ok = c.pickLink(all, quality); !ok {
+			return "", nil, fmt.Errorf("no suitable quality found from any source")
+		}
+		util.Warn("No stream answered the probe, trying the best unverified link", "provider", link.Provider)
+		rememberCandidates(network.SourceAllAnime, []StreamLink{link})
+	}
+	for _, src := range sources {
+		if src.Name == link.Provider {
+			return link.URL, c.episodeMetadata(link, src, animeID, episodeNo), nil
+		}
 	}
+	return link.URL, c.episodeMetadata(link, ProviderSource{}, animeID, episodeNo), nil
+}
 
-	return "", nil, fmt.Errorf("no suitable quality found from any source")
+// episodeMetadata is linkMetadata plus where the link came from
+func (c *AllAnimeClient) episodeMetadata(link StreamLink, src ProviderSource, animeID, episodeNo string) map[string]string {
+	metadata := c.linkMetadata(link)
+	metadata["source_url"] = src.URL
+	metadata["anime_id"] = animeID
+	metadata["episode"] = episodeNo
+	return metadata
 }
 
 // getPriorityScore returns the priority score of a URL based on domain
@@ -579,40 +477,27 @@ func (c *AllAnimeClient) getPriorityScore(url string) int {
 	return 0
 }
 
-// extractSourceURLs extracts source URLs from the API response
-func (c *AllAnimeClient) extractSourceURLs(response string) []string {
-	// Parse the response as JSON to extract sourceUrls properly
+// extractSources parses the episode query response into provider sources,
+// decoding obfuscated URLs, highest AllAnime priority first
+func (c *AllAnimeClient) extractSources(response []byte) ([]ProviderSource, error) {
 	var episodeResp EpisodeResponse
-	if err := json.Unmarshal([]byte(response), &episodeResp); err == nil {
-		var urls []string
-		for _, sourceUrl := range episodeResp.Data.Episode.SourceUrls {
-			if strings.HasPrefix(sourceUrl.SourceUrl, "--") {
-				// This is an encoded URL that needs decoding
-				encoded := strings.TrimPrefix(sourceUrl.SourceUrl, "--")
-				decoded := c.decodeSourceURL(encoded)
-				urls = append(urls, decoded)
-			} else {
-				// Direct URL
-				urls = append(urls, sourceUrl.SourceUrl)
-			}
-		}
-		return urls
+	if err := json.Unmarshal(response, &episodeResp); err != nil {
+		return nil, err
 	}
 
-	// Fallback to regex-based extraction if JSON parsing fails
-	re := regexp.MustCompile(`"sourceUrl":"--([^"]*)".*?"sourceName":"([^"]*)"`)
-	matches := re.FindAllStringSubmatch(response, -1)
-
-	var urls []string
-	for _, match := range matches {
-		if len(match) >= 2 {
-			// Decode the URL using the complex decoding logic from ani-cli
-			decodedURL := c.decodeSourceURL(match[1])
-			urls = append(urls, decodedURL)
+	var sources []ProviderSource
+	for _, s := range episodeResp.Data.Episode.SourceUrls {
+		u := s.SourceUrl
+		if strings.HasPrefix(u, "--") {
+			u = c.decodeSourceURL(strings.TrimPrefix(u, "--"))
+		}
+		if u == "" {
+			continue
 		}
+		sources = append(sources, ProviderSource{Name: s.SourceName, URL: u, Priority: s.Priority, Type: s.Type})
 	}
-
-	return urls
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].Priority > sources[j].Priority })
+	return sources, nil
 }
 
 // decodeSourceURL decodes the encoded source URL using the exact logic from Curd
@@ -651,212 +536,14 @@ func (c *AllAnimeClient) decodeSourceURL(encoded string) string {
 	// Replace "/clock" with "/clock.json" like in Curd
 	result = strings.ReplaceAll(result, "/clock", "/clock.json")
 
-	// If it starts with /, it's a path that needs the AllAnime base
+	// If it starts with /, it's a path that needs the active AllAnime mirror
 	if strings.HasPrefix(result, "/") {
-		result = fmt.Sprintf("https://%s%s", AllAnimeBase, result)
+		result = c.mirror().Base + result
 	}
 
 	return result
 }
 
-// getLinks extracts video links from the source URL with proper headers
-func (c *AllAnimeClient) getLinks(sourceURL string) (map[string]string, error) {
-	req, err := http.NewRequest("GET", sourceURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Use the same headers as Curd for better compatibility
-	req.Header.Set("Referer", "https://allanime.to")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/121.0")
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	links := c.extractVideoLinks(string(body))
-
-	// Apply priority-based link selection
-	return c.prioritizeLinks(links), nil
-}
-
-// prioritizeLinks applies priority-based sorting to video links
-func (c *AllAnimeClient) prioritizeLinks(links map[string]string) map[string]string {
-	prioritizedLinks := make(map[string]string)
-
-	// First, add high priority links
-	for quality, link := range links {
-		for _, domain := range LinkPriorities {
-			if strings.Contains(link, domain) {
-				prioritizedLinks[quality+"_priority"] = link
-			}
-		}
-	}
-
-	// Then add regular links
-	for quality, link := range links {
-		prioritizedLinks[quality] = link
-	}
-
-	return prioritizedLinks
-}
-
-// extractVideoLinks extracts video links from the response with debug logging
-func (c *AllAnimeClient) extractVideoLinks(response string) map[string]string {
-	links := make(map[string]string)
-
-	// Debug: log response structure
-	util.Debugf("Response length: %d", len(response))
-
-	// Parse JSON response
-	var jsonData map[string]interface{}
-	if err := json.Unmarshal([]byte(response), &jsonData); err == nil {
-		// Extract links from JSON structure
-		if linksInterface, ok := jsonData["links"].([]interface{}); ok {
-			for _, linkInterface := range linksInterface {
-				if linkMap, ok := linkInterface.(map[string]interface{}); ok {
-					if link, ok := linkMap["link"].(string); ok {
-						quality := "unknown"
-						if resStr, ok := linkMap["resolutionStr"].(string); ok {
-							quality = resStr
-						} else if hls, ok := linkMap["hls"].(bool); ok && hls {
-							quality = "hls"
-						}
-
-						link = strings.ReplaceAll(link, "\\", "")
-						links[quality] = link
-						util.Debugf("Found link - Quality: %s, URL: %s", quality, link)
-					}
-				}
-			}
-		}
-	}
-
-	// Fallback: Extract mp4 links with quality information using regex
-	re := regexp.MustCompile(`"link":"([^"]*)".*?"resolutionStr":"([^"]*)"`)
-	matches := re.FindAllStringSubmatch(response, -1)
-
-	for _, match := range matches {
-		if len(match) >= 3 {
-			quality := match[2]
-			link := match[1]
-			// Clean up the link
-			link = strings.ReplaceAll(link, "\\", "")
-			links[quality] = link
-			util.Debugf("Regex found link - Quality: %s, URL: %s", quality, link)
-		}
-	}
-
-	// Extract m3u8 links
-	m3u8Re := regexp.MustCompile(`"hls":true.*?"link":"([^"]*)"`)
-	m3u8Matches := m3u8Re.FindAllStringSubmatch(response, -1)
-
-	for _, match := range m3u8Matches {
-		if len(match) >= 2 {
-			link := match[1]
-			link = strings.ReplaceAll(link, "\\", "")
-			links["hls"] = link
-			util.Debugf("Found HLS link: %s", link)
-		}
-	}
-
-	util.Debugf("Total links found: %d", len(links))
-	return links
-}
-
-// selectQuality selects the appropriate quality from available links with priority consideration
-func (c *AllAnimeClient) selectQuality(links map[string]string, requestedQuality string) (string, map[string]string) {
-	metadata := make(map[string]string)
-
-	// First, try to find priority links matching requested quality
-	switch requestedQuality {
-	case "best":
-		for _, qualityLevel := range []string{"1080p", "720p", "480p", "360p"} {
-			// Check for priority version first
-			if url, exists := links[qualityLevel+"_priority"]; exists {
-				metadata["quality"] = qualityLevel
-				metadata["priority"] = "high"
-				return url, metadata
-			}
-		}
-		// Then check regular links
-		for _, qualityLevel := range []string{"1080p", "720p", "480p", "360p"} {
-			if url, exists := links[qualityLevel]; exists {
-				metadata["quality"] = qualityLevel
-				return url, metadata
-			}
-		}
-	case "worst":
-		for _, qualityLevel := range []string{"360p", "480p", "720p", "1080p"} {
-			// Check for priority version first
-			if url, exists := links[qualityLevel+"_priority"]; exists {
-				metadata["quality"] = qualityLevel
-				metadata["priority"] = "high"
-				return url, metadata
-			}
-		}
-		// Then check regular links
-		for _, qualityLevel := range []string{"360p", "480p", "720p", "1080p"} {
-			if url, exists := links[qualityLevel]; exists {
-				metadata["quality"] = qualityLevel
-				return url, metadata
-			}
-		}
-	default:
-		// Try exact match with priority first
-		if url, exists := links[requestedQuality+"_priority"]; exists {
-			metadata["quality"] = requestedQuality
-			metadata["priority"] = "high"
-			return url, metadata
-		}
-		// Then try exact match regular
-		if url, exists := links[requestedQuality]; exists {
-			metadata["quality"] = requestedQuality
-			return url, metadata
-		}
-	}
-
-	// Fallback to HLS if available (with priority check)
-	if url, exists := links["hls_priority"]; exists {
-		metadata["quality"] = "hls"
-		metadata["type"] = "m3u8"
-		metadata["priority"] = "high"
-		return url, metadata
-	}
-	if url, exists := links["hls"]; exists {
-		metadata["quality"] = "hls"
-		metadata["type"] = "m3u8"
-		return url, metadata
-	}
-
-	// Return first priority link available
-	for quality, url := range links {
-		if strings.HasSuffix(quality, "_priority") {
-			actualQuality := strings.TrimSuffix(quality, "_priority")
-			metadata["quality"] = actualQuality
-			metadata["priority"] = "high"
-			return url, metadata
-		}
-	}
-
-	// Return first available if nothing else works
-	for quality, url := range links {
-		if !strings.HasSuffix(quality, "_priority") {
-			metadata["quality"] = quality
-			return url, metadata
-		}
-	}
-
-	return "", metadata
-}
-
 // GetStreamURL implements the UnifiedScraper interface
 func (c *AllAnimeClient) GetStreamURL(episodeURL string, options ...interface{}) (string, map[string]string, error) {
 	// For AllAnime, episodeURL contains episode ID, we need anime ID and episode number
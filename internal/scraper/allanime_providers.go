@@ -0,0 +1,342 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alvarorichard/Goanime/internal/hls"
+	"github.com/alvarorichard/Goanime/internal/subtitles"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// ProviderSource is one entry of an episode's sourceUrls: a provider name as
+// AllAnime reports it ("Default", "S-mp4", ...) and its decoded URL
+type ProviderSource struct {
+	Name     string
+	URL      string
+	Priority float64
+	Type     string
+}
+
+// StreamLink is a playable URL offered by an AllAnime provider
+type StreamLink struct {
+	// Provider is the sourceName the link came from
+	Provider string
+	URL      string
+	// Resolution is "1080p", "720p", ... or "" when unknown (e.g. HLS masters)
+	Resolution string
+	HLS        bool
+	// Headers are the request headers the host expects, usually Referer
+	Headers   map[string]string
+	Subtitles []Subtitle
+}
+
+// Subtitle is an external subtitle track offered with a link
+type Subtitle struct {
+	Lang  string
+	Label string
+	URL   string
+	// Format is "vtt", "srt", "ass" or "ssa"
+	Format string
+}
+
+// height returns the vertical resolution of the link, 0 when unknown
+func (l StreamLink) height() int {
+	h, _ := strconv.Atoi(strings.TrimSuffix(l.Resolution, "p"))
+	return h
+}
+
+// quality is the label used in metadata and for exact quality requests
+func (l StreamLink) quality() string {
+	if l.Resolution != "" {
+		return l.Resolution
+	}
+	if l.HLS {
+		return "hls"
+	}
+	return "unknown"
+}
+
+// linkExtractor turns a provider source into stream links
+type linkExtractor func(c *AllAnimeClient, src ProviderSource) ([]StreamLink, error)
+
+// providerExtractors are keyed by lowercase provider name. Providers not listed
+// fall back to the clock API when their URL points at it.
+var providerExtractors = map[string]linkExtractor{
+	"default": extractClockLinks,
+	"s-mp4":   extractClockLinks,
+	"luf-mp4": extractClockLinks,
+	"sak":     extractClockLinks,
+	"kir":     extractClockLinks,
+	"yt-mp4":  extractDirectLink,
+}
+
+// ProviderPriorities orders providers when links are otherwise equal; the
+// first is preferred. Unlisted providers come last.
+var ProviderPriorities = []string{"Default", "S-mp4", "Yt-mp4", "Luf-mp4", "Sak", "Kir"}
+
+// providerRank returns the position of name in ProviderPriorities
+func providerRank(name string) int {
+	for i, p := range ProviderPriorities {
+		if strings.EqualFold(p, name) {
+			return i
+		}
+	}
+	return len(ProviderPriorities)
+}
+
+// isClockURL reports whether u is AllAnime's own link API
+func isClockURL(u string) bool {
+	return strings.Contains(u, "/clock.json")
+}
+
+// extractLinks returns the links of src using its provider's extractor
+func (c *AllAnimeClient) extractLinks(src ProviderSource) ([]StreamLink, error) {
+	extract, ok := providerExtractors[strings.ToLower(src.Name)]
+	if !ok {
+		if !isClockURL(src.URL) {
+			return nil, fmt.Errorf("provider %s is not supported (%s)", src.Name, src.Type)
+		}
+		extract = extractClockLinks
+	}
+	links, err := extract(c, src)
+	if err != nil {
+		return nil, fmt.Errorf("provider %s: %w", src.Name, err)
+	}
+	util.Debug("Provider links", "provider", src.Name, "count", len(links))
+	return links, nil
+}
+
+// clockResponse is the body of AllAnime's clock.json link API
+type clockResponse struct {
+	Links []struct {
+		Link          string            `json:"link"`
+		HLS           bool              `json:"hls"`
+		MP4           bool              `json:"mp4"`
+		ResolutionStr string            `json:"resolutionStr"`
+		Headers       map[string]string `json:"headers"`
+		Subtitles     []struct {
+			Lang  string `json:"lang"`
+			Label string `json:"label"`
+			Src   string `json:"src"`
+		} `json:"subtitles"`
+	} `json:"links"`
+}
+
+// extractClockLinks reads the links of a provider served by the clock API
+func extractClockLinks(c *AllAnimeClient, src ProviderSource) ([]StreamLink, error) {
+	if !isClockURL(src.URL) {
+		return nil, fmt.Errorf("unexpected source URL %s", src.URL)
+	}
+	req, err := http.NewRequest("GET", src.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	m := c.mirror()
+	req.Header.Set("Referer", m.Referer)
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.do(req, m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("clock API returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return parseClockLinks(src.Name, body)
+}
+
+// parseClockLinks converts a clock.json body into links tagged with provider
+func parseClockLinks(provider string, body []byte) ([]StreamLink, error) {
+	var clock clockResponse
+	if err := json.Unmarshal(body, &clock); err != nil {
+		return nil, fmt.Errorf("invalid clock response: %w", err)
+	}
+
+	links := make([]StreamLink, 0, len(clock.Links))
+	for _, l := range clock.Links {
+		if l.Link == "" {
+			continue
+		}
+		link := StreamLink{
+			Provider:   provider,
+			URL:        l.Link,
+			Resolution: normalizeResolution(l.ResolutionStr),
+			HLS:        l.HLS || strings.Contains(l.Link, ".m3u8"),
+		}
+		if len(l.Headers) > 0 {
+			link.Headers = l.Headers
+		}
+		for _, s := range l.Subtitles {
+			if s.Src != "" {
+				link.Subtitles = append(link.Subtitles, Subtitle{Lang: s.Lang, Label: s.Label, URL: s.Src, Format: subtitles.FormatOf(s.Src)})
+			}
+		}
+		links = append(links, link)
+	}
+	if len(links) == 0 {
+		return nil, fmt.Errorf("no links in clock response")
+	}
+	return links, nil
+}
+
+// extractDirectLink handles providers whose source URL is the video itself.
+// Their hosts check the referer, so the mirror's is attached.
+func extractDirectLink(c *AllAnimeClient, src ProviderSource) ([]StreamLink, error) {
+	if !strings.HasPrefix(src.URL, "http") {
+		return nil, fmt.Errorf("unexpected source URL %s", src.URL)
+	}
+	return []StreamLink{{
+		Provider: src.Name,
+		URL:      src.URL,
+		HLS:      strings.Contains(src.URL, ".m3u8"),
+		Headers:  map[string]string{"Referer": c.mirror().Referer},
+	}}, nil
+}
+
+// expandHLSVariants adds a link per variant of the HLS master playlists in links,
+// so HLS streams take part in quality selection like MP4 links do. The masters
+// are kept for "hls" requests and as fallbacks, and stand alone for variants
+// whose audio is a separate rendition, which their playlist would play silent.
+func (c *AllAnimeClient) expandHLSVariants(links []StreamLink) []StreamLink {
+	variants := make([][]StreamLink, len(links))
+	var wg sync.WaitGroup
+	for i, link := range links {
+		if !link.HLS || link.Resolution != "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, link StreamLink) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+			defer cancel()
+			playlist, err := hls.Fetch(ctx, c.client, link.URL, link.Headers)
+			if err != nil {
+				util.Debug("HLS playlist unavailable", "provider", link.Provider, "error", err)
+				return
+			}
+			for _, v := range playlist.Variants {
+				if v.Resolution() == "" || playlist.SeparateAudio(v) {
+					continue
+				}
+				variant := link
+				variant.URL = v.URI
+				variant.Resolution = v.Resolution()
+				variants[i] = append(variants[i], variant)
+			}
+		}(i, link)
+	}
+	wg.Wait()
+
+	expanded := append([]StreamLink(nil), links...)
+	for _, v := range variants {
+		expanded = append(expanded, v...)
+	}
+	return expanded
+}
+
+var resolutionPattern = regexp.MustCompile(`(\d{3,4})p?`)
+
+// normalizeResolution maps "1080", "1080p" or "1080P" to "1080p"; labels
+// without a number ("Hls", "Mp4") give ""
+func normalizeResolution(s string) string {
+	m := resolutionPattern.FindStringSubmatch(strings.ToLower(s))
+	if m == nil {
+		return ""
+	}
+	return m[1] + "p"
+}
+
+// selectQuality picks the link for requestedQuality ("best", "worst", "hls" or a
+// resolution such as "720p"). Links on a LinkPriorities host win over others,
+// then resolution decides, then ProviderPriorities. Without a match, HLS and
+// then any link is used.
+func (c *AllAnimeClient) selectQuality(links []StreamLink, requestedQuality string) (string, map[string]string) {
+	link, ok := c.pickLink(links, requestedQuality)
+	if !ok {
+		return "", map[string]string{}
+	}
+	return link.URL, c.linkMetadata(link)
+}
+
+func (c *AllAnimeClient) pickLink(links []StreamLink, requested string) (StreamLink, bool) {
+	if len(links) == 0 {
+		return StreamLink{}, false
+	}
+	ordered := append([]StreamLink(nil), links...)
+	worst := requested == "worst"
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if pa, pb := c.getPriorityScore(a.URL), c.getPriorityScore(b.URL); pa != pb {
+			return pa > pb
+		}
+		if ha, hb := a.height(), b.height(); ha != hb {
+			// Unknown resolutions sort after known ones either way
+			if ha == 0 || hb == 0 {
+				return hb == 0
+			}
+			if worst {
+				return ha < hb
+			}
+			return ha > hb
+		}
+		return providerRank(a.Provider) < providerRank(b.Provider)
+	})
+
+	switch requested {
+	case "best", "worst", "":
+		for _, l := range ordered {
+			if l.Resolution != "" {
+				return l, true
+			}
+		}
+	default:
+		want := normalizeResolution(requested)
+		if requested == "hls" {
+			want = ""
+		}
+		for _, l := range ordered {
+			if (requested == "hls" && l.HLS) || (want != "" && l.Resolution == want) {
+				return l, true
+			}
+		}
+	}
+
+	for _, l := range ordered {
+		if l.HLS {
+			return l, true
+		}
+	}
+	return ordered[0], true
+}
+
+// linkMetadata describes link for callers of GetEpisodeURL
+func (c *AllAnimeClient) linkMetadata(link StreamLink) map[string]string {
+	metadata := map[string]string{
+		"quality":  link.quality(),
+		"provider": link.Provider,
+	}
+	if link.HLS {
+		metadata["type"] = "m3u8"
+	}
+	if c.getPriorityScore(link.URL) > 0 {
+		metadata["priority"] = "high"
+	}
+	if ref := link.Headers["Referer"]; ref != "" {
+		metadata["referer"] = ref
+	}
+	return metadata
+}
@@ -0,0 +1,124 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClockLinksKeepsEveryLink(t *testing.T) {
+	t.Parallel()
+
+	links, err := parseClockLinks("Sak", []byte(`{"links":[
+		{"link":"https://a.example/1080.mp4","mp4":true,"resolutionStr":"1080p"},
+		{"link":"https://b.example/1080.mp4","mp4":true,"resolutionStr":"1080"},
+		{"link":"https://c.example/master.m3u8","hls":true,"resolutionStr":"Hls",
+		 "headers":{"Referer":"https://c.example/"},
+		 "subtitles":[{"lang":"en","label":"English","src":"https://c.example/en.vtt"},{"lang":"pt"}]},
+		{"link":""}
+	]}`))
+	require.NoError(t, err)
+	require.Len(t, links, 3, "same-resolution links must not overwrite each other")
+
+	assert.Equal(t, "1080p", links[0].Resolution)
+	assert.Equal(t, "1080p", links[1].Resolution)
+	assert.Equal(t, "Sak", links[1].Provider)
+	assert.True(t, links[2].HLS)
+	assert.Empty(t, links[2].Resolution)
+	assert.Equal(t, map[string]string{"Referer": "https://c.example/"}, links[2].Headers)
+	assert.Equal(t, []Subtitle{{Lang: "en", Label: "English", URL: "https://c.example/en.vtt", Format: "vtt"}}, links[2].Subtitles)
+
+	_, err = parseClockLinks("Sak", []byte(`{"links":[]}`))
+	assert.Error(t, err)
+}
+
+func TestPickLinkOrdering(t *testing.T) {
+	t.Parallel()
+
+	c := NewAllAnimeClient()
+	links := []StreamLink{
+		{Provider: "Kir", URL: "https://x.example/kir-1080.mp4", Resolution: "1080p"},
+		{Provider: "S-mp4", URL: "https://x.example/s-1080.mp4", Resolution: "1080p"},
+		{Provider: "Luf-mp4", URL: "https://x.example/luf.m3u8", HLS: true, Headers: map[string]string{"Referer": "https://ref.example/"}},
+		{Provider: "Sak", URL: "https://x.example/sak-360.mp4", Resolution: "360p"},
+	}
+
+	url, meta := c.selectQuality(links, "best")
+	assert.Equal(t, "https://x.example/s-1080.mp4", url, "provider priority breaks resolution ties")
+	assert.Equal(t, "S-mp4", meta["provider"])
+
+	url, _ = c.selectQuality(links, "worst")
+	assert.Equal(t, "https://x.example/sak-360.mp4", url)
+
+	url, meta = c.selectQuality(links, "hls")
+	assert.Equal(t, "https://x.example/luf.m3u8", url)
+	assert.Equal(t, "m3u8", meta["type"])
+	assert.Equal(t, "https://ref.example/", meta["referer"])
+
+	url, meta = c.selectQuality(links, "720p")
+	assert.Equal(t, "https://x.example/luf.m3u8", url, "a missing resolution falls back to HLS")
+	assert.Equal(t, "hls", meta["quality"])
+
+	links = append(links, StreamLink{Provider: "Default", URL: "https://video.wixmp.com/v/720p/file.mp4", Resolution: "720p"})
+	_, meta = c.selectQuality(links, "best")
+	assert.Equal(t, "Default", meta["provider"], "priority hosts win over resolution")
+	assert.Equal(t, "high", meta["priority"])
+}
+
+func TestExtractLinksByProvider(t *testing.T) {
+	t.Parallel()
+
+	c := NewAllAnimeClient()
+	links, err := c.extractLinks(ProviderSource{Name: "Yt-mp4", URL: "https://tools.example/video.mp4"})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "Yt-mp4", links[0].Provider)
+	assert.NotEmpty(t, links[0].Headers["Referer"])
+
+	_, err = c.extractLinks(ProviderSource{Name: "Ok", URL: "https://ok.example/embed/1", Type: "iframe"})
+	assert.ErrorContains(t, err, "not supported")
+}
+
+func TestExtractSourcesDecodesAndOrders(t *testing.T) {
+	t.Parallel()
+
+	c := NewAllAnimeClient()
+	sources, err := c.extractSources([]byte(`{"data":{"episode":{"sourceUrls":[
+		{"sourceName":"Luf-mp4","sourceUrl":"--175948514e4c4f57175b54575b5307515c0559015d08","priority":7.7},
+		{"sourceName":"Yt-mp4","sourceUrl":"https://tools.example/v.mp4","priority":8.1}
+	]}}}`))
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+	assert.Equal(t, "Yt-mp4", sources[0].Name)
+	assert.Equal(t, "Luf-mp4", sources[1].Name)
+	assert.Contains(t, sources[1].URL, "/apivtwo/clock.json?id=")
+
+	_, err = c.extractSources([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestExpandHLSVariantsKeepsMastersWithSeparateAudio(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "#EXTM3U\n"+
+			"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"ja\",NAME=\"Japanese\",URI=\"audio/ja.m3u8\"\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,AUDIO=\"ja\"\n1080.m3u8\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=2800000,RESOLUTION=1280x720\n720.m3u8\n")
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewAllAnimeClient()
+	c.client = srv.Client()
+	master := StreamLink{Provider: "Default", URL: srv.URL + "/master.m3u8", HLS: true}
+	links := c.expandHLSVariants([]StreamLink{master})
+
+	require.Len(t, links, 2)
+	assert.Equal(t, master, links[0])
+	assert.Equal(t, srv.URL+"/720.m3u8", links[1].URL)
+	assert.Equal(t, "720p", links[1].Resolution, "1080p is only playable through the master, with its audio")
+}
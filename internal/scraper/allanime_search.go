@@ -0,0 +1,398 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/models"
+)
+
+// SortOrder controls how search and browse results are ranked
+type SortOrder string
+
+const (
+	SortRelevance SortOrder = ""
+	SortPopular   SortOrder = "popular"
+	SortRecent    SortOrder = "recent"
+	SortScore     SortOrder = "score"
+)
+
+// allAnimeSortBy maps sort orders to the sortBy values of AllAnime's SearchInput
+var allAnimeSortBy = map[SortOrder]string{
+	SortPopular: "Popular",
+	SortRecent:  "Recent",
+	SortScore:   "Top",
+}
+
+// BrowseMode lists titles without a search query
+type BrowseMode string
+
+const (
+	BrowseTrending BrowseMode = "trending"
+	BrowseRecent   BrowseMode = "recent"
+	BrowseSeason   BrowseMode = "season"
+	BrowseGenre    BrowseMode = "genre"
+)
+
+// BrowseModes is the list offered by the CLI and the browse screen
+var BrowseModes = []BrowseMode{BrowseTrending, BrowseRecent, BrowseSeason, BrowseGenre}
+
+// Seasons, formats and countries accepted by AllAnime filters
+var (
+	Seasons   = []string{"Winter", "Spring", "Summer", "Fall"}
+	Formats   = []string{"TV", "Movie", "OVA", "ONA", "Special"}
+	Countries = []string{"ALL", "JP", "CN", "KR"}
+)
+
+const (
+	defaultSearchLimit = 40
+	maxSearchLimit     = 100
+)
+
+// SearchOptions narrows and pages a search. The zero value matches a plain
+// search: first page of 40 subbed results from any country, no adult titles.
+type SearchOptions struct {
+	Page    int
+	Limit   int
+	Mode    string // sub or dub
+	Season  string
+	Year    int
+	Genres  []string
+	Format  string
+	Country string
+	Sort    SortOrder
+}
+
+// Filtered reports whether o narrows or reorders results beyond a plain search; paging is not a filter
+func (o SearchOptions) Filtered() bool {
+	return o.Season != "" || o.Year != 0 || len(o.Genres) > 0 || o.Format != "" ||
+		(o.Country != "" && !strings.EqualFold(o.Country, "ALL")) || o.Sort != SortRelevance
+}
+
+// ParseSortOrder accepts popular, recent, score or an empty string for relevance
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch o := SortOrder(strings.ToLower(strings.TrimSpace(s))); o {
+	case SortRelevance, SortPopular, SortRecent, SortScore:
+		return o, nil
+	default:
+		return "", fmt.Errorf("unknown sort order %q (use popular, recent or score)", s)
+	}
+}
+
+// ParseBrowseMode accepts trending, recent or season
+func ParseBrowseMode(s string) (BrowseMode, error) {
+	m := BrowseMode(strings.ToLower(strings.TrimSpace(s)))
+	for _, known := range BrowseModes {
+		if m == known {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown browse mode %q (use trending, recent, season or genre)", s)
+}
+
+// Normalize fills defaults and canonicalises the case of every filter, rejecting values AllAnime does not know
+func (o SearchOptions) Normalize() (SearchOptions, error) {
+	if o.Page < 1 {
+		o.Page = 1
+	}
+	if o.Limit < 1 {
+		o.Limit = defaultSearchLimit
+	}
+	if o.Limit > maxSearchLimit {
+		return o, fmt.Errorf("limit must be at most %d", maxSearchLimit)
+	}
+
+	switch o.Mode = strings.ToLower(o.Mode); o.Mode {
+	case "":
+		o.Mode = "sub"
+	case "sub", "dub":
+	default:
+		return o, fmt.Errorf("unknown mode %q (use sub or dub)", o.Mode)
+	}
+
+	var err error
+	if o.Season, err = matchOption("season", o.Season, Seasons); err != nil {
+		return o, err
+	}
+	if o.Format, err = matchOption("format", o.Format, Formats); err != nil {
+		return o, err
+	}
+	if o.Country == "" {
+		o.Country = "ALL"
+	}
+	if o.Country, err = matchOption("country", o.Country, Countries); err != nil {
+		return o, err
+	}
+	if o.Sort, err = ParseSortOrder(string(o.Sort)); err != nil {
+		return o, err
+	}
+	if o.Year != 0 && (o.Year < 1900 || o.Year > time.Now().Year()+1) {
+		return o, fmt.Errorf("invalid year %d", o.Year)
+	}
+
+	genres := o.Genres[:0:0]
+	for _, g := range o.Genres {
+		if g = strings.TrimSpace(g); g != "" {
+			genres = append(genres, g)
+		}
+	}
+	o.Genres = genres
+	return o, nil
+}
+
+// matchOption returns the canonical spelling of value from allowed, case-insensitively
+func matchOption(name, value string, allowed []string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, value) {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown %s %q (use one of %s)", name, value, strings.Join(allowed, ", "))
+}
+
+// CurrentSeason returns the anime season and year for t
+func CurrentSeason(t time.Time) (string, int) {
+	return Seasons[(int(t.Month())-1)/3], t.Year()
+}
+
+// searchOptionsFrom picks the SearchOptions out of the variadic options of UnifiedScraper.SearchAnime
+func searchOptionsFrom(options []interface{}) SearchOptions {
+	for _, o := range options {
+		switch v := o.(type) {
+		case SearchOptions:
+			return v
+		case *SearchOptions:
+			if v != nil {
+				return *v
+			}
+		}
+	}
+	return SearchOptions{}
+}
+
+// searchVariables builds the GraphQL variables of the shows query
+func searchVariables(query string, o SearchOptions) map[string]interface{} {
+	search := map[string]interface{}{
+		"allowAdult":   false,
+		"allowUnknown": false,
+	}
+	if query != "" {
+		search["query"] = query
+	}
+	if o.Season != "" {
+		search["season"] = o.Season
+	}
+	if o.Year != 0 {
+		search["year"] = o.Year
+	}
+	if len(o.Genres) > 0 {
+		search["genres"] = o.Genres
+	}
+	if o.Format != "" {
+		search["types"] = []string{o.Format}
+	}
+	if sortBy, ok := allAnimeSortBy[o.Sort]; ok {
+		search["sortBy"] = sortBy
+	}
+
+	return map[string]interface{}{
+		"search":          search,
+		"limit":           o.Limit,
+		"page":            o.Page,
+		"translationType": o.Mode,
+		"countryOrigin":   o.Country,
+	}
+}
+
+const searchGql = `query($search: SearchInput, $limit: Int, $page: Int, $translationType: VaildTranslationTypeEnumType, $countryOrigin: VaildCountryOriginEnumType) {
+		shows(search: $search, limit: $limit, page: $page, translationType: $translationType, countryOrigin: $countryOrigin) {
+			edges {
+				_id
+				name
+				englishName
+				availableEpisodes
+				__typename
+			}
+		}
+	}`
+
+const popularGql = `query($type: VaildPopularTypeEnumType!, $size: Int!, $page: Int, $dateRange: Int) {
+		queryPopular(type: $type, size: $size, page: $page, dateRange: $dateRange) {
+			recommendations {
+				anyCard {
+					_id
+					name
+					englishName
+					availableEpisodes
+				}
+			}
+		}
+	}`
+
+// allAnimeShow is a show card as returned by both the search and popular queries
+type allAnimeShow struct {
+	ID                string      `json:"_id"`
+	Name              string      `json:"name"`
+	EnglishName       string      `json:"englishName"`
+	AvailableEpisodes interface{} `json:"availableEpisodes"`
+}
+
+// toAnime converts a show card to a search result, labelled with the episode count for mode
+func (s allAnimeShow) toAnime(mode string) *models.Anime {
+	var episodesStr string
+	if episodes, ok := s.AvailableEpisodes.(map[string]interface{}); ok {
+		if count, ok := episodes[mode].(float64); ok {
+			episodesStr = fmt.Sprintf("(%d episodes)", int(count))
+		} else {
+			episodesStr = "(Unknown episodes)"
+		}
+	}
+
+	// Use English name if available, otherwise use default name
+	displayName := s.Name
+	if s.EnglishName != "" {
+		displayName = s.EnglishName
+	}
+
+	return &models.Anime{
+		Name: strings.TrimSpace(fmt.Sprintf("%s %s", displayName, episodesStr)),
+		URL:  s.ID, // For AllAnime, the "URL" is actually the anime ID
+	}
+}
+
+// searchShows runs the shows query with the given filters
+func (c *AllAnimeClient) searchShows(query string, opts SearchOptions) ([]*models.Anime, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data struct {
+			Shows struct {
+				Edges []allAnimeShow `json:"edges"`
+			} `json:"shows"`
+		} `json:"data"`
+	}
+	if err := c.graphQL(searchGql, searchVariables(query, opts), &response); err != nil {
+		return nil, err
+	}
+
+	animes := make([]*models.Anime, 0, len(response.Data.Shows.Edges))
+	for _, edge := range response.Data.Shows.Edges {
+		animes = append(animes, edge.toAnime(opts.Mode))
+	}
+	return animes, nil
+}
+
+// Browse lists titles without a query. Trending uses AllAnime's daily popularity
+// ranking and ignores filters; the other modes honour every filter in opts.
+// AllAnime does not report a page count, so more is set when the page was full.
+func (c *AllAnimeClient) Browse(mode BrowseMode, opts SearchOptions) (animes []*models.Anime, more bool, err error) {
+	if opts, err = opts.Normalize(); err != nil {
+		return nil, false, err
+	}
+	switch mode {
+	case BrowseTrending:
+		return c.queryPopular(opts)
+	case BrowseRecent:
+		if opts.Sort == SortRelevance {
+			opts.Sort = SortRecent
+		}
+	case BrowseSeason:
+		season, year := CurrentSeason(time.Now())
+		if opts.Season == "" {
+			opts.Season = season
+		}
+		if opts.Year == 0 {
+			opts.Year = year
+		}
+		if opts.Sort == SortRelevance {
+			opts.Sort = SortPopular
+		}
+	case BrowseGenre:
+		if len(opts.Genres) == 0 {
+			return nil, false, fmt.Errorf("browsing by genre needs a genre")
+		}
+		if opts.Sort == SortRelevance {
+			opts.Sort = SortPopular
+		}
+	default:
+		return nil, false, fmt.Errorf("unknown browse mode %q", mode)
+	}
+	animes, err = c.searchShows("", opts)
+	return animes, len(animes) >= opts.Limit, err
+}
+
+// queryPopular fetches the titles trending over the last day; more is set when
+// the page was full
+func (c *AllAnimeClient) queryPopular(opts SearchOptions) ([]*models.Anime, bool, error) {
+	variables := map[string]interface{}{
+		"type":      "anime",
+		"size":      opts.Limit,
+		"page":      opts.Page,
+		"dateRange": 1,
+	}
+	var response struct {
+		Data struct {
+			QueryPopular struct {
+				Recommendations []struct {
+					AnyCard *allAnimeShow `json:"anyCard"`
+				} `json:"recommendations"`
+			} `json:"queryPopular"`
+		} `json:"data"`
+	}
+	if err := c.graphQL(popularGql, variables, &response); err != nil {
+		return nil, false, err
+	}
+
+	recommendations := response.Data.QueryPopular.Recommendations
+	var animes []*models.Anime
+	for _, rec := range recommendations {
+		if rec.AnyCard != nil && rec.AnyCard.ID != "" {
+			animes = append(animes, rec.AnyCard.toAnime(opts.Mode))
+		}
+	}
+	return animes, len(recommendations) >= opts.Limit, nil
+}
+
+// graphQL sends a GET GraphQL request to the active mirror and decodes the response into out
+func (c *AllAnimeClient) graphQL(query string, variables map[string]interface{}, out interface{}) error {
+	variablesJSON, err := json.Marshal(variables)
+	if err != nil {
+		return fmt.Errorf("failed to marshal variables: %w", err)
+	}
+
+	m := c.mirror()
+	reqURL := fmt.Sprintf("%s?variables=%s&query=%s", m.API, url.QueryEscape(string(variablesJSON)), url.QueryEscape(query))
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Referer", m.Referer)
+
+	resp, err := c.do(req, m)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
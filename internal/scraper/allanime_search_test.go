@@ -0,0 +1,91 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchOptionsNormalize(t *testing.T) {
+	t.Parallel()
+
+	opts, err := SearchOptions{Season: "fall", Format: "tv", Country: "jp", Sort: "SCORE", Genres: []string{" Action", ""}}.Normalize()
+	require.NoError(t, err)
+	assert.Equal(t, SearchOptions{
+		Page: 1, Limit: 40, Mode: "sub", Season: "Fall", Format: "TV", Country: "JP", Sort: SortScore,
+		Genres: []string{"Action"},
+	}, opts)
+
+	for _, bad := range []SearchOptions{
+		{Season: "monsoon"},
+		{Format: "manga"},
+		{Country: "BR"},
+		{Sort: "random"},
+		{Limit: 500},
+		{Year: 1500},
+		{Mode: "raw"},
+	} {
+		_, err := bad.Normalize()
+		assert.Error(t, err, "%+v", bad)
+	}
+}
+
+func TestSearchVariablesOmitsUnsetFilters(t *testing.T) {
+	t.Parallel()
+
+	opts, err := SearchOptions{}.Normalize()
+	require.NoError(t, err)
+	vars := searchVariables("frieren", opts)
+	assert.Equal(t, map[string]interface{}{
+		"allowAdult":   false,
+		"allowUnknown": false,
+		"query":        "frieren",
+	}, vars["search"])
+	assert.Equal(t, "ALL", vars["countryOrigin"])
+
+	opts, err = SearchOptions{Sort: SortRecent, Format: "Movie"}.Normalize()
+	require.NoError(t, err)
+	search := searchVariables("", opts)["search"].(map[string]interface{})
+	assert.Equal(t, "Recent", search["sortBy"])
+	assert.Equal(t, []string{"Movie"}, search["types"])
+	assert.NotContains(t, search, "query")
+}
+
+func TestCurrentSeason(t *testing.T) {
+	t.Parallel()
+
+	season, year := CurrentSeason(time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, "Fall", season)
+	assert.Equal(t, 2026, year)
+
+	season, _ = CurrentSeason(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, "Winter", season)
+}
+
+func TestAllAnimeFilteredSearchReplay(t *testing.T) {
+	useFixtures(t, "allanime")
+
+	results, err := NewAllAnimeClient().SearchAnime("", SearchOptions{
+		Page: 2, Limit: 2, Mode: "dub", Season: "fall", Year: 2023,
+		Genres: []string{"Fantasy"}, Format: "tv", Country: "jp", Sort: SortScore,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "Frieren: Beyond Journey's End (26 episodes)", results[0].Name)
+	assert.Equal(t, "The Apothecary Diaries (24 episodes)", results[1].Name)
+}
+
+func TestAllAnimeBrowseTrendingReplay(t *testing.T) {
+	useFixtures(t, "allanime")
+
+	source := AllAnimeType
+	results, more, err := NewScraperManager().Browse(BrowseTrending, SearchOptions{}, &source)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.False(t, more, "two results do not fill a page")
+	assert.Equal(t, "[AllAnime] Frieren: Beyond Journey's End (28 episodes)", results[0].Name)
+	assert.Equal(t, "[AllAnime] One Piece (1122 episodes)", results[1].Name)
+	assert.Equal(t, "AllAnime", results[1].Source)
+}
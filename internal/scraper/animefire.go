@@ -9,17 +9,19 @@ import (
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/mirrors"
 	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/network"
 	"github.com/alvarorichard/Goanime/internal/util"
 )
 
-const (
-	AnimefireBase = "https://animefire.plus"
-)
-
 // AnimefireClient handles interactions with Animefire.plus
 type AnimefireClient struct {
-	client     *http.Client
+	client  *http.Client
+	mirrors *mirrors.Set
+	// baseURL pins the client to a fixed site root instead of the active
+	// mirror; failover is disabled when it is set
 	baseURL    string
 	userAgent  string
 	maxRetries int
@@ -29,43 +31,104 @@ type AnimefireClient struct {
 // NewAnimefireClient creates a new Animefire client
 func NewAnimefireClient() *AnimefireClient {
 	return &AnimefireClient{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		baseURL:    AnimefireBase,
+		client:     network.NewClient(network.SourceAnimeFire, 30*time.Second),
+		mirrors:    mirrors.Get(network.SourceAnimeFire),
 		userAgent:  UserAgent,
 		maxRetries: 2,
 		retryDelay: 350 * time.Millisecond,
 	}
 }
 
-// SearchAnime searches for anime on Animefire.plus using the original logic
+// mirror returns the site root to use for the next request
+func (c *AnimefireClient) mirror() config.Mirror {
+	if c.baseURL != "" {
+		return config.Mirror{Base: c.baseURL, API: c.baseURL, Referer: c.baseURL}
+	}
+	return c.mirrors.Active()
+}
+
+// do sends a request built against m, failing over to the next mirror when m is unreachable
+func (c *AnimefireClient) do(req *http.Request, m config.Mirror) (*http.Response, error) {
+	if c.baseURL != "" {
+		return c.client.Do(req)
+	}
+	return c.mirrors.Do(c.client, m, req)
+}
+
+// SearchAnime searches for anime on Animefire.plus, following the result pages up
+// to maxSearchPages. Dubbed and subtitled pages of the same show are grouped.
 func (c *AnimefireClient) SearchAnime(query string) ([]*models.Anime, error) {
+	var all []*models.Anime
+	for page := 1; page <= maxSearchPages; page++ {
+		animes, hasNext, err := c.SearchAnimePage(query, page)
+		if err != nil {
+			if page > 1 {
+				// Keep what the earlier pages returned
+				util.Debug("AnimeFire search page failed", "page", page, "error", err)
+				break
+			}
+			return nil, err
+		}
+		all = append(all, animes...)
+		if !hasNext {
+			break
+		}
+	}
+	return GroupVariants(dedupeByURL(all)), nil
+}
+
+// SearchAnimePage returns one page of ungrouped search results and whether another page follows
+func (c *AnimefireClient) SearchAnimePage(query string, page int) ([]*models.Anime, bool, error) {
 	// AnimeFire expects spaces as hyphens in the URL
 	normalizedQuery := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(query)), " ", "-")
-	searchURL := fmt.Sprintf("%s/pesquisar/%s", c.baseURL, normalizedQuery)
 
-	util.Debug("AnimeFire search", "query", query, "normalized", normalizedQuery, "url", searchURL)
+	util.Debug("AnimeFire search", "query", query, "normalized", normalizedQuery, "page", page)
+
+	return c.listPage("/pesquisar/"+normalizedQuery, page)
+}
+
+// listPage scrapes a listing (search, genre, season...) at path and reports whether it has a next page
+func (c *AnimefireClient) listPage(path string, page int) ([]*models.Anime, bool, error) {
+	if page > 1 {
+		path = fmt.Sprintf("%s/%d", path, page)
+	}
+	doc, base, err := c.fetchDocument(path)
+	if err != nil {
+		return nil, false, err
+	}
 
+	animes := c.extractSearchResults(doc, base)
+	if len(animes) == 0 {
+		// Legitimate empty result set – return without error
+		return []*models.Anime{}, false, nil
+	}
+	return animes, hasNextPage(doc, page), nil
+}
+
+// fetchDocument GETs path on the active mirror with retries and returns the parsed page
+// along with the site root that served it
+func (c *AnimefireClient) fetchDocument(path string) (*goquery.Document, string, error) {
 	var lastErr error
 	attempts := c.maxRetries + 1
 
 	for attempt := 0; attempt < attempts; attempt++ {
-		req, err := http.NewRequest("GET", searchURL, nil)
+		// Rebuilt every attempt so a mirror switch is picked up
+		m := c.mirror()
+		req, err := http.NewRequest("GET", m.Base+path, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
+			return nil, "", fmt.Errorf("failed to create request: %w", err)
 		}
 
-		c.decorateRequest(req)
+		c.decorateRequest(req, m)
 
-		resp, err := c.client.Do(req)
+		resp, err := c.do(req, m)
 		if err != nil {
 			lastErr = fmt.Errorf("failed to make request: %w", err)
 			if c.shouldRetry(attempt) {
 				c.sleep()
 				continue
 			}
-			return nil, lastErr
+			return nil, "", lastErr
 		}
 
 		if resp.StatusCode != http.StatusOK {
@@ -75,7 +138,7 @@ func (c *AnimefireClient) SearchAnime(query string) ([]*models.Anime, error) {
 				c.sleep()
 				continue
 			}
-			return nil, lastErr
+			return nil, "", lastErr
 		}
 
 		doc, err := goquery.NewDocumentFromReader(resp.Body)
@@ -86,40 +149,34 @@ func (c *AnimefireClient) SearchAnime(query string) ([]*models.Anime, error) {
 				c.sleep()
 				continue
 			}
-			return nil, lastErr
+			return nil, "", lastErr
 		}
 
 		if c.isChallengePage(doc) {
-			lastErr = errors.New("animefire returned a challenge page (try VPN or wait)")
+			lastErr = errors.New("animefire returned a challenge page (try VPN, wait, or import browser cookies with `goanime cookies import <cookies.txt>`)")
 			if c.shouldRetry(attempt) {
 				c.sleep()
 				continue
 			}
-			return nil, lastErr
+			return nil, "", lastErr
 		}
 
-		animes := c.extractSearchResults(doc)
-		if len(animes) == 0 {
-			// Legitimate empty result set – return without error
-			return []*models.Anime{}, nil
-		}
-
-		return animes, nil
+		return doc, resp.Request.URL.Scheme + "://" + resp.Request.URL.Host, nil
 	}
 
 	if lastErr != nil {
-		return nil, lastErr
+		return nil, "", lastErr
 	}
-	return nil, errors.New("failed to retrieve results from AnimeFire")
+	return nil, "", errors.New("failed to retrieve results from AnimeFire")
 }
 
-func (c *AnimefireClient) decorateRequest(req *http.Request) {
+func (c *AnimefireClient) decorateRequest(req *http.Request, m config.Mirror) {
 	req.Header.Set("User-Agent", c.userAgent)
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "pt-BR,pt;q=0.9,en-US;q=0.8,en;q=0.7")
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Pragma", "no-cache")
-	req.Header.Set("Referer", c.baseURL+"/")
+	req.Header.Set("Referer", m.Referer+"/")
 }
 
 func (c *AnimefireClient) handleStatusError(resp *http.Response) error {
@@ -154,16 +211,19 @@ func (c *AnimefireClient) isChallengePage(doc *goquery.Document) bool {
 	return strings.Contains(body, "cf-error") || strings.Contains(body, "cloudflare")
 }
 
-func (c *AnimefireClient) extractSearchResults(doc *goquery.Document) []*models.Anime {
+func (c *AnimefireClient) extractSearchResults(doc *goquery.Document, base string) []*models.Anime {
 	var animes []*models.Anime
 
 	doc.Find(".row.ml-1.mr-1 a").Each(func(i int, s *goquery.Selection) {
 		if urlPath, exists := s.Attr("href"); exists {
-			name := strings.TrimSpace(s.Text())
+			name := strings.TrimSpace(s.Find(".animeTitle").First().Text())
+			if name == "" {
+				name = strings.TrimSpace(s.Text())
+			}
 			if name != "" {
 				animes = append(animes, &models.Anime{
 					Name: name,
-					URL:  c.resolveURL(c.baseURL, urlPath),
+					URL:  c.resolveURL(base, urlPath),
 				})
 			}
 		}
@@ -182,12 +242,12 @@ func (c *AnimefireClient) extractSearchResults(doc *goquery.Document) []*models.
 			imgElem := s.Find(".div_img img")
 			imgURL, _ := imgElem.Attr("src")
 			if imgURL != "" {
-				imgURL = c.resolveURL(c.baseURL, imgURL)
+				imgURL = c.resolveURL(base, imgURL)
 			}
 
 			animes = append(animes, &models.Anime{
 				Name:     title,
-				URL:      c.resolveURL(c.baseURL, link),
+				URL:      c.resolveURL(base, link),
 				ImageURL: imgURL,
 			})
 		}
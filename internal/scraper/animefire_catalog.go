@@ -0,0 +1,205 @@
+package scraper
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/alvarorichard/Goanime/internal/models"
+)
+
+// maxSearchPages bounds how many AnimeFire result pages a plain search follows
+const maxSearchPages = 3
+
+// animefireSeasons maps AllAnime season names to AnimeFire's /temporada paths
+var animefireSeasons = map[string]string{
+	"Winter": "inverno",
+	"Spring": "primavera",
+	"Summer": "verao",
+	"Fall":   "outono",
+}
+
+// animefireGenres maps the English genre names used by the CLI to AnimeFire's /genero slugs.
+// Anything else is slugified as typed, so Portuguese names work too.
+var animefireGenres = map[string]string{
+	"action":        "acao",
+	"adventure":     "aventura",
+	"comedy":        "comedia",
+	"drama":         "drama",
+	"ecchi":         "ecchi",
+	"fantasy":       "fantasia",
+	"horror":        "terror",
+	"isekai":        "isekai",
+	"mecha":         "mecha",
+	"music":         "musica",
+	"mystery":       "misterio",
+	"psychological": "psicologico",
+	"romance":       "romance",
+	"school":        "escolar",
+	"sci-fi":        "ficcao-cientifica",
+	"seinen":        "seinen",
+	"shoujo":        "shoujo",
+	"shounen":       "shounen",
+	"slice of life": "slice-of-life",
+	"sports":        "esportes",
+	"supernatural":  "sobrenatural",
+	"suspense":      "suspense",
+}
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a",
+	"é", "e", "ê", "e",
+	"í", "i",
+	"ó", "o", "ô", "o", "õ", "o",
+	"ú", "u", "ç", "c",
+)
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// genreSlug returns AnimeFire's /genero slug for genre
+func genreSlug(genre string) string {
+	g := strings.ToLower(strings.TrimSpace(genre))
+	if slug, ok := animefireGenres[g]; ok {
+		return slug
+	}
+	return strings.Trim(nonSlugChars.ReplaceAllString(accentReplacer.Replace(g), "-"), "-")
+}
+
+// Browse lists AnimeFire's catalog: recent is the "lançamentos" page, season the
+// seasonal chart and genre the first genre in opts. Trending is not available.
+// more reports whether the listing links to a next page.
+func (c *AnimefireClient) Browse(mode BrowseMode, opts SearchOptions) ([]*models.Anime, bool, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var path string
+	switch mode {
+	case BrowseRecent:
+		path = "/em-lancamento"
+	case BrowseSeason:
+		season, year := CurrentSeason(time.Now())
+		if opts.Season != "" {
+			season = opts.Season
+		}
+		if opts.Year != 0 {
+			year = opts.Year
+		}
+		path = fmt.Sprintf("/temporada/%s/%d", animefireSeasons[season], year)
+	case BrowseGenre:
+		if len(opts.Genres) == 0 {
+			return nil, false, fmt.Errorf("browsing by genre needs a genre")
+		}
+		path = "/genero/" + genreSlug(opts.Genres[0])
+	default:
+		return nil, false, fmt.Errorf("AnimeFire does not support browse mode %q", mode)
+	}
+
+	animes, more, err := c.listPage(path, opts.Page)
+	if err != nil {
+		return nil, false, err
+	}
+	return GroupVariants(animes), more, nil
+}
+
+// hasNextPage reports whether an AnimeFire listing links to the page after page
+func hasNextPage(doc *goquery.Document, page int) bool {
+	if doc.Find(".pagination .next a").Length() > 0 {
+		return true
+	}
+	next := strconv.Itoa(page + 1)
+	found := false
+	doc.Find(".pagination a").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		href, _ := s.Attr("href")
+		if strings.TrimSpace(s.Text()) == next || strings.HasSuffix(strings.TrimRight(href, "/"), "/"+next) {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+func dedupeByURL(animes []*models.Anime) []*models.Anime {
+	seen := make(map[string]bool, len(animes))
+	out := animes[:0]
+	for _, a := range animes {
+		if seen[a.URL] {
+			continue
+		}
+		seen[a.URL] = true
+		out = append(out, a)
+	}
+	return out
+}
+
+var dubMarker = regexp.MustCompile(`(?i)\s*[(\[]?\bdublado\b[)\]]?`)
+
+// parseDubMarker strips AnimeFire's "(Dublado)" marker from name and reports
+// whether name or the page URL marks the entry as dubbed
+func parseDubMarker(name, rawURL string) (string, bool) {
+	dubbed := dubMarker.MatchString(name) || strings.Contains(strings.ToLower(rawURL), "-dublado")
+	title := strings.TrimSpace(dubMarker.ReplaceAllString(name, ""))
+	if title == "" {
+		title = strings.TrimSpace(name)
+	}
+	return title, dubbed
+}
+
+// GroupVariants merges the dubbed and subtitled pages of the same show into one
+// entry whose Variants hold both, so the language is picked explicitly after
+// the show. Results keep the order in which each show first appeared.
+func GroupVariants(animes []*models.Anime) []*models.Anime {
+	type group struct {
+		title    string
+		variants []*models.Anime
+	}
+	var order []string
+	groups := make(map[string]*group)
+
+	for _, a := range animes {
+		title, dubbed := parseDubMarker(a.Name, a.URL)
+		a.Dubbed = dubbed
+		key := strings.ToLower(title)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{title: title}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.variants = append(g.variants, a)
+	}
+
+	out := make([]*models.Anime, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		if len(g.variants) == 1 || !hasBothLanguages(g.variants) {
+			out = append(out, g.variants...)
+			continue
+		}
+		first := g.variants[0]
+		out = append(out, &models.Anime{
+			Name:     g.title + " (Dublado/Legendado)",
+			URL:      first.URL,
+			ImageURL: first.ImageURL,
+			Variants: g.variants,
+		})
+	}
+	return out
+}
+
+func hasBothLanguages(variants []*models.Anime) bool {
+	dub, sub := false, false
+	for _, v := range variants {
+		if v.Dubbed {
+			dub = true
+		} else {
+			sub = true
+		}
+	}
+	return dub && sub
+}
@@ -0,0 +1,124 @@
+package scraper
+
+import (
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDubMarker(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, url string
+		title     string
+		dubbed    bool
+	}{
+		{"Naruto (Dublado)", "/animes/naruto-dublado-todos-os-episodios", "Naruto", true},
+		{"Naruto [dublado]", "", "Naruto", true},
+		{"One Piece", "/animes/one-piece-dublado-todos-os-episodios", "One Piece", true},
+		{"Dublado", "", "Dublado", true},
+		{"Naruto Shippuden", "/animes/naruto-shippuden-todos-os-episodios", "Naruto Shippuden", false},
+	}
+	for _, tt := range tests {
+		title, dubbed := parseDubMarker(tt.name, tt.url)
+		assert.Equal(t, tt.title, title, tt.name)
+		assert.Equal(t, tt.dubbed, dubbed, tt.name)
+	}
+}
+
+func TestGroupVariantsKeepsSingleLanguageEntries(t *testing.T) {
+	t.Parallel()
+
+	grouped := GroupVariants([]*models.Anime{
+		{Name: "Bleach (Dublado)", URL: "/bleach-dublado"},
+		{Name: "Naruto", URL: "/naruto"},
+		{Name: "Bleach", URL: "/bleach"},
+		{Name: "Gintama (Dublado)", URL: "/gintama-dublado"},
+	})
+	require.Len(t, grouped, 3)
+	assert.Equal(t, "Bleach (Dublado/Legendado)", grouped[0].Name)
+	assert.Len(t, grouped[0].Variants, 2)
+	assert.Equal(t, "Naruto", grouped[1].Name)
+	assert.Empty(t, grouped[1].Variants)
+	assert.Equal(t, "Gintama (Dublado)", grouped[2].Name)
+	assert.True(t, grouped[2].Dubbed)
+}
+
+func TestGenreSlug(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "acao", genreSlug("Action"))
+	assert.Equal(t, "ficcao-cientifica", genreSlug("sci-fi"))
+	assert.Equal(t, "artes-marciais", genreSlug("Artes Marciais"))
+	assert.Equal(t, "comedia", genreSlug("Comédia"))
+}
+
+func TestAnimefireSearchFollowsPages(t *testing.T) {
+	useFixtures(t, "animefire")
+
+	results, err := NewAnimefireClient().SearchAnime("naruto")
+	require.NoError(t, err)
+
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	assert.Equal(t, []string{
+		"Naruto (Dublado/Legendado)",
+		"Naruto Shippuden (Dublado/Legendado)",
+		"Boruto: Naruto Next Generations",
+	}, names)
+}
+
+func TestAnimefireSearchSinglePage(t *testing.T) {
+	useFixtures(t, "animefire")
+
+	results, hasNext, err := NewAnimefireClient().SearchAnimePage("naruto", 2)
+	require.NoError(t, err)
+	assert.False(t, hasNext)
+	require.Len(t, results, 2)
+	assert.Equal(t, "https://animefire.plus/animes/naruto-shippuden-dublado-todos-os-episodios", results[0].URL)
+}
+
+func TestAnimefireBrowseReplay(t *testing.T) {
+	useFixtures(t, "animefire")
+	client := NewAnimefireClient()
+
+	season, more, err := client.Browse(BrowseSeason, SearchOptions{Season: "fall", Year: 2023})
+	require.NoError(t, err)
+	require.Len(t, season, 2)
+	assert.False(t, more, "the seasonal chart has no pagination")
+	assert.Equal(t, "Sousou no Frieren", season[0].Name)
+
+	genre, more, err := client.Browse(BrowseGenre, SearchOptions{Genres: []string{"Fantasy"}})
+	require.NoError(t, err)
+	assert.True(t, more, "the genre listing links to page 2")
+	require.Len(t, genre, 2)
+	assert.Equal(t, "Sousou no Frieren (Dublado/Legendado)", genre[0].Name)
+
+	recent, _, err := client.Browse(BrowseRecent, SearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, recent, 1)
+	assert.Equal(t, "One Piece", recent[0].Name)
+
+	_, _, err = client.Browse(BrowseTrending, SearchOptions{})
+	assert.Error(t, err)
+}
+
+func TestScraperManagerBrowseTagsVariants(t *testing.T) {
+	useFixtures(t, "animefire")
+
+	source := AnimefireType
+	results, more, err := NewScraperManager().Browse(BrowseGenre, SearchOptions{Genres: []string{"fantasy"}}, &source)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.True(t, more)
+	assert.Equal(t, "[AnimeFire] Sousou no Frieren (Dublado/Legendado)", results[0].Name)
+	for _, v := range results[0].Variants {
+		assert.Equal(t, "AnimeFire.plus", v.Source)
+		assert.Contains(t, v.Name, "[AnimeFire]")
+	}
+}
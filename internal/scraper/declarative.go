@@ -0,0 +1,435 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// SourceDefinition describes a site declaratively so it can be scraped without
+// Go code. Definitions are YAML or JSON files in SourcesDir(); see
+// docs/CUSTOM_SOURCES.md for the format.
+type SourceDefinition struct {
+	// ID selects the source with -source and names its cookie jar and proxy settings
+	ID string `yaml:"id" json:"id"`
+	// Name is shown in result tags; defaults to ID
+	Name    string `yaml:"name" json:"name"`
+	BaseURL string `yaml:"base_url" json:"base_url"`
+	// Headers are sent with every request; values may use {{base}}
+	Headers  map[string]string `yaml:"headers" json:"headers"`
+	Search   SearchSpec        `yaml:"search" json:"search"`
+	Episodes EpisodesSpec      `yaml:"episodes" json:"episodes"`
+	Stream   StreamSpec        `yaml:"stream" json:"stream"`
+
+	// File is the path the definition was loaded from
+	File string `yaml:"-" json:"-"`
+}
+
+// RequestSpec is an HTTP request template. URL and Body may use {{base}},
+// {{query}}, {{page}} and {{url}} depending on where the request is made.
+type RequestSpec struct {
+	URL    string `yaml:"url" json:"url"`
+	Method string `yaml:"method" json:"method"`
+	Body   string `yaml:"body" json:"body"`
+	// Format is how the response is read: "html" (default) or "json"
+	Format string `yaml:"format" json:"format"`
+}
+
+// SearchSpec finds shows. Results is a CSS selector (html) or JSONPath (json)
+// matching one element per show; Fields are read relative to it.
+type SearchSpec struct {
+	RequestSpec `yaml:",inline"`
+	Results     string `yaml:"results" json:"results"`
+	Fields      struct {
+		Name  FieldSpec `yaml:"name" json:"name"`
+		URL   FieldSpec `yaml:"url" json:"url"`
+		Image FieldSpec `yaml:"image" json:"image"`
+	} `yaml:"fields" json:"fields"`
+}
+
+// EpisodesSpec lists the episodes of a show. The URL defaults to {{url}}, the
+// show URL returned by the search.
+type EpisodesSpec struct {
+	RequestSpec `yaml:",inline"`
+	List        string `yaml:"list" json:"list"`
+	Fields      struct {
+		Number FieldSpec `yaml:"number" json:"number"`
+		URL    FieldSpec `yaml:"url" json:"url"`
+		Title  FieldSpec `yaml:"title" json:"title"`
+	} `yaml:"fields" json:"fields"`
+	// Reverse is for sites that list the newest episode first
+	Reverse bool `yaml:"reverse" json:"reverse"`
+}
+
+// StreamSpec turns an episode URL into a playable URL by running Steps in order
+// on a single value that starts as the episode URL
+type StreamSpec struct {
+	Steps []StepSpec `yaml:"steps" json:"steps"`
+}
+
+// StepSpec is one stream extraction step; exactly one field must be set.
+//
+//	fetch:  GET the URL template ({{url}} is the episode URL, {{value}} the current value); the value becomes the body
+//	iframe: find the iframe matching the selector in the current HTML and fetch its src
+//	select: read a field ("selector@attr") from the current HTML
+//	regex:  keep the first capture group (or the whole match) of the pattern
+//	json:   decode the current value and keep the string at the JSONPath
+type StepSpec struct {
+	Fetch  string `yaml:"fetch" json:"fetch"`
+	Iframe string `yaml:"iframe" json:"iframe"`
+	Select string `yaml:"select" json:"select"`
+	Regex  string `yaml:"regex" json:"regex"`
+	JSON   string `yaml:"json" json:"json"`
+
+	re     *regexp.Regexp
+	path   jsonPath
+	field  FieldSpec
+	action string
+}
+
+// FieldSpec extracts a string from a result element. It is written either as a
+// string ("selector@attr" for html, a JSONPath for json) or as an object.
+// An empty selector means the element itself; without attr the text is used.
+type FieldSpec struct {
+	Selector string `yaml:"selector" json:"selector"`
+	Attr     string `yaml:"attr" json:"attr"`
+	Path     string `yaml:"path" json:"path"`
+	// Regex optionally narrows the value to its first capture group
+	Regex string `yaml:"regex" json:"regex"`
+
+	short string
+	re    *regexp.Regexp
+	path  jsonPath
+}
+
+// UnmarshalYAML accepts the string shorthand as well as the object form
+func (f *FieldSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		f.short = node.Value
+		return nil
+	}
+	type plain FieldSpec
+	return node.Decode((*plain)(f))
+}
+
+// UnmarshalJSON accepts the string shorthand as well as the object form
+func (f *FieldSpec) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &f.short)
+	}
+	type plain FieldSpec
+	return json.Unmarshal(data, (*plain)(f))
+}
+
+// isSet reports whether the field was given in the definition
+func (f *FieldSpec) isSet() bool {
+	return f.short != "" || f.Selector != "" || f.Attr != "" || f.Path != ""
+}
+
+// compile resolves the shorthand for format and compiles the regex and JSONPath
+func (f *FieldSpec) compile(format string) error {
+	if f.short != "" {
+		if format == "json" {
+			f.Path = f.short
+		} else if i := strings.LastIndex(f.short, "@"); i >= 0 {
+			f.Selector, f.Attr = strings.TrimSpace(f.short[:i]), strings.TrimSpace(f.short[i+1:])
+		} else {
+			f.Selector = strings.TrimSpace(f.short)
+		}
+	}
+	if format == "json" {
+		p, err := compileJSONPath(f.Path)
+		if err != nil {
+			return err
+		}
+		f.path = p
+	}
+	if f.Regex != "" {
+		re, err := regexp.Compile(f.Regex)
+		if err != nil {
+			return fmt.Errorf("regex: %w", err)
+		}
+		f.re = re
+	}
+	return nil
+}
+
+var sourceIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// builtinSources cannot be redefined by a definition file
+var builtinSources = []string{network.SourceAllAnime, network.SourceAnimeFire, "shared"}
+
+// Validate checks the definition and compiles its selectors, JSONPaths and regexes
+func (d *SourceDefinition) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	d.ID = strings.ToLower(strings.TrimSpace(d.ID))
+	switch {
+	case !sourceIDPattern.MatchString(d.ID):
+		add("id: %q must be lowercase letters, digits, - or _", d.ID)
+	default:
+		for _, b := range builtinSources {
+			if d.ID == b {
+				add("id: %q is a built-in source", d.ID)
+			}
+		}
+	}
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		d.Name = d.ID
+	}
+	for _, b := range []string{"AllAnime", "AnimeFire", "AnimeFire.plus"} {
+		if strings.EqualFold(d.Name, b) {
+			add("name: %q is used by a built-in source", d.Name)
+		}
+	}
+	if u, err := url.Parse(d.BaseURL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		add("base_url: %q must be an absolute http(s) URL", d.BaseURL)
+	}
+	d.BaseURL = strings.TrimRight(d.BaseURL, "/")
+
+	checkRequest := func(section string, r *RequestSpec) {
+		r.Method = strings.ToUpper(r.Method)
+		if r.Method == "" {
+			r.Method = "GET"
+		}
+		if r.Method != "GET" && r.Method != "POST" {
+			add("%s.method: %q must be GET or POST", section, r.Method)
+		}
+		r.Format = strings.ToLower(r.Format)
+		if r.Format == "" {
+			r.Format = "html"
+		}
+		if r.Format != "html" && r.Format != "json" {
+			add("%s.format: %q must be html or json", section, r.Format)
+		}
+	}
+	checkField := func(name string, f *FieldSpec, format string, required bool) {
+		if !f.isSet() {
+			if required {
+				add("%s is required", name)
+			}
+			return
+		}
+		if err := f.compile(format); err != nil {
+			add("%s: %v", name, err)
+		}
+	}
+	checkList := func(name, expr, format string) {
+		if strings.TrimSpace(expr) == "" {
+			add("%s is required", name)
+			return
+		}
+		if format == "json" {
+			if _, err := compileJSONPath(expr); err != nil {
+				add("%s: %v", name, err)
+			}
+		}
+	}
+
+	s := &d.Search
+	checkRequest("search", &s.RequestSpec)
+	if s.URL == "" {
+		add("search.url is required")
+	}
+	checkList("search.results", s.Results, s.Format)
+	checkField("search.fields.name", &s.Fields.Name, s.Format, true)
+	checkField("search.fields.url", &s.Fields.URL, s.Format, true)
+	checkField("search.fields.image", &s.Fields.Image, s.Format, false)
+
+	e := &d.Episodes
+	checkRequest("episodes", &e.RequestSpec)
+	if e.URL == "" {
+		e.URL = "{{url}}"
+	}
+	checkList("episodes.list", e.List, e.Format)
+	checkField("episodes.fields.url", &e.Fields.URL, e.Format, true)
+	checkField("episodes.fields.number", &e.Fields.Number, e.Format, false)
+	checkField("episodes.fields.title", &e.Fields.Title, e.Format, false)
+
+	if len(d.Stream.Steps) == 0 {
+		add("stream.steps needs at least one step")
+	}
+	for i := range d.Stream.Steps {
+		if err := d.Stream.Steps[i].compile(); err != nil {
+			add("stream.steps[%d]: %v", i, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (st *StepSpec) compile() error {
+	set := 0
+	for action, v := range map[string]string{"fetch": st.Fetch, "iframe": st.Iframe, "select": st.Select, "regex": st.Regex, "json": st.JSON} {
+		if v != "" {
+			set++
+			st.action = action
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of fetch, iframe, select, regex or json must be set")
+	}
+	switch st.action {
+	case "regex":
+		re, err := regexp.Compile(st.Regex)
+		if err != nil {
+			return fmt.Errorf("regex: %w", err)
+		}
+		st.re = re
+	case "json":
+		p, err := compileJSONPath(st.JSON)
+		if err != nil {
+			return err
+		}
+		st.path = p
+	case "select":
+		st.field = FieldSpec{short: st.Select}
+		return st.field.compile("html")
+	}
+	return nil
+}
+
+// ParseSourceDefinition decodes and validates a definition; name picks the
+// decoder by extension (.json, otherwise YAML)
+func ParseSourceDefinition(name string, data []byte) (*SourceDefinition, error) {
+	def := &SourceDefinition{File: name}
+	var err error
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(def)
+	} else {
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		err = dec.Decode(def)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(name), err)
+	}
+	if err := def.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(name), err)
+	}
+	return def, nil
+}
+
+// SourcesDir is where declarative source definitions are loaded from
+func SourcesDir() string {
+	return filepath.Join(config.Dir(), "sources")
+}
+
+// LoadSourceDefinitions reads every .yaml, .yml and .json file in dir, sorted by
+// file name. Files that fail to parse or validate are returned in errs and skipped.
+func LoadSourceDefinitions(dir string) (defs []*SourceDefinition, errs []error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+		return nil, errs
+	}
+
+	var files []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !e.IsDir() {
+				files = append(files, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+
+	seen := map[string]string{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		def, err := ParseSourceDefinition(f, data)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if prev, dup := seen[def.ID]; dup {
+			errs = append(errs, fmt.Errorf("%s: source id %q is already defined in %s", filepath.Base(f), def.ID, filepath.Base(prev)))
+			continue
+		}
+		seen[def.ID] = f
+		defs = append(defs, def)
+	}
+	return defs, errs
+}
+
+// customTypeBase is the ScraperType of the first declarative source
+const customTypeBase ScraperType = 100
+
+type customSet struct {
+	scrapers []*DeclarativeScraper
+	errs     []error
+}
+
+var (
+	customMu    sync.Mutex
+	customCache = map[string]*customSet{}
+)
+
+// CustomSources returns the declarative sources of SourcesDir() and the files
+// that failed to load. Definitions are read once per directory.
+func CustomSources() ([]*DeclarativeScraper, []error) {
+	dir := SourcesDir()
+	customMu.Lock()
+	defer customMu.Unlock()
+	if set, ok := customCache[dir]; ok {
+		return set.scrapers, set.errs
+	}
+
+	defs, errs := LoadSourceDefinitions(dir)
+	set := &customSet{errs: errs}
+	for i, def := range defs {
+		set.scrapers = append(set.scrapers, NewDeclarativeScraper(def, customTypeBase+ScraperType(i)))
+	}
+	for _, err := range errs {
+		util.Warnf("Skipping custom source: %v", err)
+	}
+	customCache[dir] = set
+	return set.scrapers, set.errs
+}
+
+// CustomSource returns the declarative source with the given ID or display name
+func CustomSource(name string) (*DeclarativeScraper, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, false
+	}
+	scrapers, _ := CustomSources()
+	for _, s := range scrapers {
+		if strings.EqualFold(s.def.ID, name) || strings.EqualFold(s.def.Name, name) {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// LookupSource returns the scraper type of a custom source by ID or display name
+func LookupSource(name string) (ScraperType, bool) {
+	s, ok := CustomSource(name)
+	if !ok {
+		return 0, false
+	}
+	return s.typ, true
+}
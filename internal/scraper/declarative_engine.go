@@ -0,0 +1,415 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/alvarorichard/Goanime/internal/models"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// maxDeclarativeBody bounds the size of a page read by a declarative source
+const maxDeclarativeBody = 10 << 20
+
+// DeclarativeScraper implements UnifiedScraper from a SourceDefinition
+type DeclarativeScraper struct {
+	def    *SourceDefinition
+	typ    ScraperType
+	client *http.Client
+}
+
+// NewDeclarativeScraper creates a scraper for def, which must have passed Validate.
+// Requests go through the network settings and cookie jar of def.ID.
+func NewDeclarativeScraper(def *SourceDefinition, typ ScraperType) *DeclarativeScraper {
+	return &DeclarativeScraper{
+		def:    def,
+		typ:    typ,
+		client: network.NewClient(def.ID, 30*time.Second),
+	}
+}
+
+// Definition returns the definition the scraper was built from
+func (s *DeclarativeScraper) Definition() *SourceDefinition {
+	return s.def
+}
+
+func (s *DeclarativeScraper) GetType() ScraperType {
+	return s.typ
+}
+
+// SearchAnime runs the search request. Only the page of SearchOptions is honoured;
+// a search URL without {{page}} has a single page.
+func (s *DeclarativeScraper) SearchAnime(query string, options ...interface{}) ([]*models.Anime, error) {
+	opts := searchOptionsFrom(options)
+	if opts.Filtered() {
+		return nil, fmt.Errorf("%s does not support search filters", s.def.Name)
+	}
+	page := opts.Page
+	if page < 1 {
+		page = 1
+	}
+	spec := &s.def.Search
+	if page > 1 && !strings.Contains(spec.URL+spec.Body, "{{page}}") {
+		return nil, nil
+	}
+
+	vars := map[string]string{
+		"query":     url.QueryEscape(query),
+		"raw_query": query,
+		"page":      strconv.Itoa(page),
+	}
+	body, pageURL, err := s.request(spec.RequestSpec, vars, "")
+	if err != nil {
+		return nil, fmt.Errorf("%s search failed: %w", s.def.Name, err)
+	}
+
+	var animes []*models.Anime
+	add := func(name, link, image string) {
+		name = strings.TrimSpace(name)
+		if name == "" || link == "" {
+			return
+		}
+		animes = append(animes, &models.Anime{Name: name, URL: link, ImageURL: image})
+	}
+
+	if spec.Format == "json" {
+		items, err := jsonItems(body, spec.Results)
+		if err != nil {
+			return nil, fmt.Errorf("%s search: %w", s.def.Name, err)
+		}
+		for _, item := range items {
+			add(spec.Fields.Name.fromJSON(item),
+				resolveJSONLink(pageURL, spec.Fields.URL.fromJSON(item)),
+				resolveJSONLink(pageURL, spec.Fields.Image.fromJSON(item)))
+		}
+	} else {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+		if err != nil {
+			return nil, fmt.Errorf("%s search: %w", s.def.Name, err)
+		}
+		doc.Find(spec.Results).Each(func(_ int, sel *goquery.Selection) {
+			add(spec.Fields.Name.fromHTML(sel),
+				resolveLink(pageURL, spec.Fields.URL.fromHTML(sel)),
+				resolveLink(pageURL, spec.Fields.Image.fromHTML(sel)))
+		})
+	}
+
+	util.Debug("Custom source search", "source", s.def.ID, "query", query, "page", page, "results", len(animes))
+	return dedupeByURL(animes), nil
+}
+
+// GetAnimeEpisodes lists the episodes of the show at animeURL
+func (s *DeclarativeScraper) GetAnimeEpisodes(animeURL string) ([]models.Episode, error) {
+	spec := &s.def.Episodes
+	body, pageURL, err := s.request(spec.RequestSpec, map[string]string{"url": animeURL}, "")
+	if err != nil {
+		return nil, fmt.Errorf("%s episodes failed: %w", s.def.Name, err)
+	}
+
+	type raw struct{ number, link, title string }
+	var found []raw
+	if spec.Format == "json" {
+		items, err := jsonItems(body, spec.List)
+		if err != nil {
+			return nil, fmt.Errorf("%s episodes: %w", s.def.Name, err)
+		}
+		for _, item := range items {
+			found = append(found, raw{
+				number: spec.Fields.Number.fromJSON(item),
+				link:   resolveJSONLink(pageURL, spec.Fields.URL.fromJSON(item)),
+				title:  spec.Fields.Title.fromJSON(item),
+			})
+		}
+	} else {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+		if err != nil {
+			return nil, fmt.Errorf("%s episodes: %w", s.def.Name, err)
+		}
+		doc.Find(spec.List).Each(func(_ int, sel *goquery.Selection) {
+			found = append(found, raw{
+				number: spec.Fields.Number.fromHTML(sel),
+				link:   resolveLink(pageURL, spec.Fields.URL.fromHTML(sel)),
+				title:  spec.Fields.Title.fromHTML(sel),
+			})
+		})
+	}
+
+	if spec.Reverse {
+		for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+			found[i], found[j] = found[j], found[i]
+		}
+	}
+
+	episodes := make([]models.Episode, 0, len(found))
+	for _, r := range found {
+		if r.link == "" {
+			continue
+		}
+		pos := len(episodes) + 1
+		number := episodeNumberPattern.FindString(r.number)
+		if number == "" {
+			number = strconv.Itoa(pos)
+		}
+		num := pos
+		if f, err := strconv.ParseFloat(number, 64); err == nil {
+			num = int(f)
+		}
+		title := strings.TrimSpace(r.title)
+		if title == "" {
+			title = "Episode " + number
+		}
+		episodes = append(episodes, models.Episode{
+			Number: number,
+			Num:    num,
+			URL:    r.link,
+			Title:  models.TitleDetails{Romaji: title},
+		})
+	}
+
+	if len(episodes) == 0 {
+		return nil, fmt.Errorf("%s: no episodes found at %s", s.def.Name, animeURL)
+	}
+	return episodes, nil
+}
+
+var episodeNumberPattern = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// GetStreamURL runs the stream steps on episodeURL. The metadata carries the page
+// the stream was found on as "referer", since hosts often check it.
+func (s *DeclarativeScraper) GetStreamURL(episodeURL string, _ ...interface{}) (string, map[string]string, error) {
+	value := episodeURL
+	base, err := url.Parse(episodeURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: bad episode URL %q: %w", s.def.Name, episodeURL, err)
+	}
+	referer := s.def.BaseURL + "/"
+
+	for i, step := range s.def.Stream.Steps {
+		switch step.action {
+		case "fetch":
+			vars := map[string]string{"url": episodeURL, "value": value}
+			body, final, err := s.request(RequestSpec{URL: step.Fetch, Method: "GET"}, vars, referer)
+			if err != nil {
+				return "", nil, fmt.Errorf("%s: stream step %d (fetch): %w", s.def.Name, i+1, err)
+			}
+			value, referer = string(body), final.String()
+			base = final
+		case "iframe":
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(value))
+			if err != nil {
+				return "", nil, fmt.Errorf("%s: stream step %d (iframe): %w", s.def.Name, i+1, err)
+			}
+			frame := doc.Find(step.Iframe).First()
+			src := frame.AttrOr("src", frame.AttrOr("data-src", ""))
+			if src == "" {
+				return "", nil, fmt.Errorf("%s: stream step %d: no iframe matches %q", s.def.Name, i+1, step.Iframe)
+			}
+			body, final, err := s.request(RequestSpec{URL: resolveLink(base, src), Method: "GET"}, nil, base.String())
+			if err != nil {
+				return "", nil, fmt.Errorf("%s: stream step %d (iframe): %w", s.def.Name, i+1, err)
+			}
+			value, referer = string(body), final.String()
+			base = final
+		case "select":
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(value))
+			if err != nil {
+				return "", nil, fmt.Errorf("%s: stream step %d (select): %w", s.def.Name, i+1, err)
+			}
+			value = step.field.fromHTML(doc.Selection)
+		case "regex":
+			m := step.re.FindStringSubmatch(value)
+			if m == nil {
+				return "", nil, fmt.Errorf("%s: stream step %d: regex %q did not match", s.def.Name, i+1, step.Regex)
+			}
+			value = m[0]
+			if len(m) > 1 {
+				value = m[1]
+			}
+		case "json":
+			var doc interface{}
+			if err := json.Unmarshal([]byte(value), &doc); err != nil {
+				return "", nil, fmt.Errorf("%s: stream step %d (json): %w", s.def.Name, i+1, err)
+			}
+			value = step.path.first(doc)
+		}
+		if value == "" {
+			return "", nil, fmt.Errorf("%s: stream step %d (%s) produced no value", s.def.Name, i+1, step.action)
+		}
+	}
+
+	stream := resolveLink(base, strings.TrimSpace(value))
+	if u, err := url.Parse(stream); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", nil, fmt.Errorf("%s: stream steps ended with %q, which is not a URL", s.def.Name, truncate(value, 80))
+	}
+	// The player gets the same identity the definition scraped with
+	headers := map[string]string{"Referer": referer}
+	for k, v := range s.def.Headers {
+		if strings.EqualFold(k, "User-Agent") || strings.EqualFold(k, "Origin") {
+			headers[k] = s.expand(v, nil)
+		}
+	}
+	rememberCandidates(s.def.ID, []StreamLink{{Provider: s.def.ID, URL: stream, Headers: headers}})
+	return stream, map[string]string{"source": s.def.ID, "referer": referer}, nil
+}
+
+// request expands the templates of r with vars plus {{base}}, sends it and returns
+// the body and the final URL after redirects
+func (s *DeclarativeScraper) request(r RequestSpec, vars map[string]string, referer string) ([]byte, *url.URL, error) {
+	target := s.expand(r.URL, vars)
+	base, _ := url.Parse(s.def.BaseURL + "/")
+	target = resolveLink(base, target)
+
+	var body io.Reader
+	if r.Body != "" {
+		body = strings.NewReader(s.expand(r.Body, vars))
+	}
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", network.UserAgent)
+	if r.Body != "" && r.Format == "json" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range s.def.Headers {
+		req.Header.Set(k, s.expand(v, vars))
+	}
+	// Pages reached from another page (stream steps) send that page as referer
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s returned %s", target, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDeclarativeBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, resp.Request.URL, nil
+}
+
+var templateVar = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// expand replaces {{name}} in tpl with vars[name]; {{base}} is the base URL.
+// Unknown names are left as they are so mistakes show up in the request URL.
+func (s *DeclarativeScraper) expand(tpl string, vars map[string]string) string {
+	return templateVar.ReplaceAllStringFunc(tpl, func(m string) string {
+		name := templateVar.FindStringSubmatch(m)[1]
+		if name == "base" {
+			return s.def.BaseURL
+		}
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// fromHTML reads the field from sel
+func (f *FieldSpec) fromHTML(sel *goquery.Selection) string {
+	if !f.isSet() {
+		return ""
+	}
+	target := sel
+	if f.Selector != "" {
+		target = sel.Find(f.Selector).First()
+	}
+	var v string
+	if f.Attr != "" {
+		v = target.AttrOr(f.Attr, "")
+	} else {
+		v = target.Text()
+	}
+	return f.narrow(strings.Join(strings.Fields(v), " "))
+}
+
+// fromJSON reads the field from a decoded JSON item
+func (f *FieldSpec) fromJSON(item interface{}) string {
+	if !f.isSet() {
+		return ""
+	}
+	return f.narrow(strings.TrimSpace(f.path.first(item)))
+}
+
+func (f *FieldSpec) narrow(v string) string {
+	if f.re == nil || v == "" {
+		return v
+	}
+	m := f.re.FindStringSubmatch(v)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return m[1]
+	default:
+		return m[0]
+	}
+}
+
+// jsonItems decodes body and returns the elements matched by expr
+func jsonItems(body []byte, expr string) ([]interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	path, err := compileJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	items := path.eval(doc)
+	if len(items) == 1 {
+		if list, ok := items[0].([]interface{}); ok {
+			return list, nil
+		}
+	}
+	return items, nil
+}
+
+// resolveLink makes link absolute against base
+func resolveLink(base *url.URL, link string) string {
+	if link == "" || base == nil {
+		return link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(u).String()
+}
+
+// resolveJSONLink resolves only links that look like paths; JSON APIs often
+// return bare IDs that are fed into the next URL template instead
+func resolveJSONLink(base *url.URL, link string) string {
+	if strings.HasPrefix(link, "/") || strings.HasPrefix(link, "./") {
+		return resolveLink(base, link)
+	}
+	return link
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+var _ UnifiedScraper = (*DeclarativeScraper)(nil)
@@ -0,0 +1,224 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exampleSite serves the pages described by testdata/sources/example.yaml
+func exampleSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "frieren", r.URL.Query().Get("q"))
+		assert.Equal(t, r.Host+"/", strings.TrimPrefix(r.Header.Get("Referer"), "http://"))
+		_, _ = fmt.Fprint(w, `<div class="result"><a href="/anime/frieren"><h3> Frieren </h3><img src="/img/f.jpg"></a></div>
+			<div class="result"><a href="/anime/frieren"><h3>Frieren</h3></a></div>
+			<div class="result"><h3>No link</h3></div>`)
+	})
+	mux.HandleFunc("/anime/frieren", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<ul class="episodes">
+			<li><a href="/watch/frieren-2">Episódio 2</a></li>
+			<li><a href="/watch/frieren-1">Episódio 1</a><span class="title">Journey's End</span></li>
+		</ul>`)
+	})
+	mux.HandleFunc("/watch/frieren-1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<iframe id="player" src="/embed/abc"></iframe>`)
+	})
+	mux.HandleFunc("/embed/abc", func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Referer"), "/watch/frieren-1")
+		_, _ = fmt.Fprint(w, `<script>var config = {"sources":[{"file":"\/hls\/abc.m3u8"}]};</script>`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// installExample writes the example definition pointed at srv into a fresh config dir
+func installExample(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	config.Set(config.Default())
+
+	data, err := os.ReadFile(filepath.Join("testdata", "sources", "example.yaml"))
+	require.NoError(t, err)
+	data = []byte(strings.ReplaceAll(string(data), "http://127.0.0.1", srv.URL))
+	require.NoError(t, os.MkdirAll(SourcesDir(), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(SourcesDir(), "example.yaml"), data, 0o644))
+}
+
+func TestDeclarativeSourceEndToEnd(t *testing.T) {
+	srv := exampleSite(t)
+	installExample(t, srv)
+
+	typ, ok := LookupSource("Example")
+	require.True(t, ok)
+	manager := NewScraperManager()
+
+	results, err := manager.SearchAnime("frieren", &typ)
+	require.NoError(t, err)
+	require.Len(t, results, 1, "duplicates and entries without a link are dropped")
+	assert.Equal(t, "[Example] Frieren", results[0].Name)
+	assert.Equal(t, "Example", results[0].Source)
+	assert.Equal(t, srv.URL+"/anime/frieren", results[0].URL)
+	assert.Equal(t, srv.URL+"/img/f.jpg", results[0].ImageURL)
+
+	s, err := manager.GetScraper(typ)
+	require.NoError(t, err)
+	episodes, err := s.GetAnimeEpisodes(results[0].URL)
+	require.NoError(t, err)
+	require.Len(t, episodes, 2)
+	assert.Equal(t, "1", episodes[0].Number, "reverse puts the oldest episode first")
+	assert.Equal(t, "Journey's End", episodes[0].Title.Romaji)
+	assert.Equal(t, "Episode 2", episodes[1].Title.Romaji)
+
+	stream, metadata, err := s.GetStreamURL(episodes[0].URL)
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/hls/abc.m3u8", stream)
+	assert.Equal(t, srv.URL+"/embed/abc", metadata["referer"])
+	assert.Equal(t, "example", metadata["source"])
+	assert.Equal(t, srv.URL+"/embed/abc", StreamHeaders(stream)["Referer"], "the player is sent the embed page as referer")
+}
+
+func TestDeclarativeSourceJSON(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/search", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"data":{"items":[{"title":"Frieren","id":"f1"},{"title":"Dungeon Meshi","id":"d2"}]}}`)
+	})
+	mux.HandleFunc("/api/show/f1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"episodes":[{"n":1,"path":"/play/1"},{"n":2.5,"path":"/play/2"}]}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	def, err := ParseSourceDefinition("api.json", []byte(`{
+		"id": "api", "base_url": "`+srv.URL+`",
+		"search": {"url": "{{base}}/api/search?q={{query}}", "format": "json",
+			"results": "$.data.items[*]", "fields": {"name": "title", "url": "$.id"}},
+		"episodes": {"url": "{{base}}/api/show/{{url}}", "format": "json",
+			"list": "episodes", "fields": {"number": "n", "url": "path"}},
+		"stream": {"steps": [{"regex": "/play/(\\d+)"}]}
+	}`))
+	require.NoError(t, err)
+	s := NewDeclarativeScraper(def, customTypeBase)
+
+	results, err := s.SearchAnime("frieren")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "f1", results[0].URL, "bare IDs are kept for the next template")
+
+	episodes, err := s.GetAnimeEpisodes("f1")
+	require.NoError(t, err)
+	require.Len(t, episodes, 2)
+	assert.Equal(t, "2.5", episodes[1].Number)
+	assert.Equal(t, 2, episodes[1].Num)
+	assert.Equal(t, srv.URL+"/play/2", episodes[1].URL)
+
+	more, err := s.SearchAnime("frieren", SearchOptions{Page: 2})
+	require.NoError(t, err)
+	assert.Empty(t, more, "a search URL without {{page}} has one page")
+	_, err = s.SearchAnime("frieren", SearchOptions{Genres: []string{"Fantasy"}})
+	assert.Error(t, err)
+}
+
+func TestSourceDefinitionValidation(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseSourceDefinition("bad.yaml", []byte(`
+id: AllAnime
+name: AnimeFire
+base_url: ftp://example.com
+search:
+  format: xml
+  fields:
+    name: "h3"
+episodes:
+  list: li
+  fields:
+    url:
+      selector: a
+      regex: "("
+stream:
+  steps:
+    - fetch: "{{url}}"
+      regex: x
+`))
+	require.Error(t, err)
+	for _, want := range []string{
+		`id: "allanime" is a built-in source`,
+		`name: "AnimeFire" is used by a built-in source`,
+		"base_url",
+		"search.format",
+		"search.url is required",
+		"search.results is required",
+		"search.fields.url is required",
+		"episodes.fields.url: regex",
+		"stream.steps[0]: exactly one",
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+
+	_, err = ParseSourceDefinition("typo.yaml", []byte("id: x\nbase_url: https://x.org\nserach: {}\n"))
+	assert.ErrorContains(t, err, "serach", "unknown keys are rejected")
+}
+
+func TestLoadSourceDefinitionsSkipsBrokenFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	good, err := os.ReadFile(filepath.Join("testdata", "sources", "example.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.yaml"), good, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.yml"), good, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.json"), []byte("{"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o644))
+
+	defs, errs := LoadSourceDefinitions(dir)
+	require.Len(t, defs, 1)
+	assert.Equal(t, "example", defs[0].ID)
+	require.Len(t, errs, 2)
+	assert.Contains(t, errs[0].Error(), "already defined in a.yaml")
+	assert.Contains(t, errs[1].Error(), "c.json")
+}
+
+func TestJSONPath(t *testing.T) {
+	t.Parallel()
+
+	doc := map[string]interface{}{
+		"data": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"id": "a", "n": float64(3)},
+				map[string]interface{}{"id": "b", "weird key": true},
+			},
+		},
+	}
+	for expr, want := range map[string]string{
+		"$.data.items[0].id":              "a",
+		"data.items[-1].id":               "b",
+		"$.data.items[0].n":               "3",
+		"$['data'].items[1]['weird key']": "true",
+		"$.data.missing":                  "",
+	} {
+		p, err := compileJSONPath(expr)
+		require.NoError(t, err, expr)
+		assert.Equal(t, want, p.first(doc), expr)
+	}
+
+	p, err := compileJSONPath("$.data.items[*].id")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, p.eval(doc))
+
+	for _, bad := range []string{"$..id", "$.items[x]", "$.items[0"} {
+		_, err := compileJSONPath(bad)
+		assert.Error(t, err, bad)
+	}
+}
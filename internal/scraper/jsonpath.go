@@ -0,0 +1,131 @@
+package scraper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath expression. Only the subset needed by source
+// definitions is supported: $, .key, ['key'], [n], [*] and .*
+type jsonPath []pathSegment
+
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// compileJSONPath parses expr. The leading "$" is optional, so "data.items[*]"
+// and "$.data.items[*]" are the same path.
+func compileJSONPath(expr string) (jsonPath, error) {
+	s := strings.TrimSpace(expr)
+	s = strings.TrimPrefix(s, "$")
+	var path jsonPath
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			return nil, fmt.Errorf("jsonpath %q: recursive descent is not supported", expr)
+		case s[0] == '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			key := s[:end]
+			if key == "" {
+				return nil, fmt.Errorf("jsonpath %q: empty key", expr)
+			}
+			path = append(path, pathSegment{key: key, wildcard: key == "*"})
+			s = s[end:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q: missing ]", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inner == "*":
+				path = append(path, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path = append(path, pathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpath %q: bad index %q", expr, inner)
+				}
+				path = append(path, pathSegment{index: n, isIndex: true})
+			}
+		default:
+			// A bare first key ("data.items")
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			path = append(path, pathSegment{key: s[:end], wildcard: s[:end] == "*"})
+			s = s[end:]
+		}
+	}
+	return path, nil
+}
+
+// eval returns every value matched by p in v, a document decoded by encoding/json.
+// Negative indexes count from the end.
+func (p jsonPath) eval(v interface{}) []interface{} {
+	current := []interface{}{v}
+	for _, seg := range p {
+		var next []interface{}
+		for _, c := range current {
+			switch node := c.(type) {
+			case map[string]interface{}:
+				if seg.wildcard {
+					for _, child := range node {
+						next = append(next, child)
+					}
+				} else if child, ok := node[seg.key]; ok && !seg.isIndex {
+					next = append(next, child)
+				}
+			case []interface{}:
+				switch {
+				case seg.wildcard:
+					next = append(next, node...)
+				case seg.isIndex:
+					i := seg.index
+					if i < 0 {
+						i += len(node)
+					}
+					if i >= 0 && i < len(node) {
+						next = append(next, node[i])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// first returns the first match of p in v as a string, or "" when nothing matches
+func (p jsonPath) first(v interface{}) string {
+	matches := p.eval(v)
+	if len(matches) == 0 {
+		return ""
+	}
+	return jsonString(matches[0])
+}
+
+// jsonString renders a decoded JSON scalar the way it appears in the document
+func jsonString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return fmt.Sprint(x)
+	}
+}
@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/httpreplay"
+	"github.com/alvarorichard/Goanime/internal/mirrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useFixtures replays testdata/fixtures/<name> with the default mirrors and an empty config
+func useFixtures(t *testing.T, name string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	config.Set(config.Default())
+	mirrors.Reset()
+	t.Cleanup(mirrors.Reset)
+	httpreplay.Use(t, filepath.Join("testdata", "fixtures", name))
+}
+
+func TestAllAnimeSearchReplay(t *testing.T) {
+	useFixtures(t, "allanime")
+
+	results, err := NewAllAnimeClient().SearchAnime("frieren")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "Frieren: Beyond Journey's End (28 episodes)", results[0].Name)
+	assert.Equal(t, "ReooPAxPMsHM4KPMY", results[0].URL)
+	assert.Equal(t, "Sousou no Frieren: Marumaru no Mahou (10 episodes)", results[1].Name)
+}
+
+func TestAllAnimeEpisodesListReplay(t *testing.T) {
+	useFixtures(t, "allanime")
+
+	client := NewAllAnimeClient()
+	sub, err := client.GetEpisodesList("ReooPAxPMsHM4KPMY", "sub")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "2.5", "3", "10"}, sub)
+
+	dub, err := client.GetEpisodesList("ReooPAxPMsHM4KPMY", "dub")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, dub)
+}
+
+func TestAllAnimeEpisodeURLPrefersPriorityHost(t *testing.T) {
+	useFixtures(t, "allanime")
+
+	link, metadata, err := NewAllAnimeClient().GetEpisodeURL("ReooPAxPMsHM4KPMY", "1", "sub", "best")
+	require.NoError(t, err)
+	assert.Contains(t, link, "wixmp.com")
+	assert.Contains(t, link, "index-f1-v1-a1.m3u8", "best pins the 1080p variant of the master playlist")
+	assert.Equal(t, "1080p", metadata["quality"])
+	assert.Equal(t, "high", metadata["priority"])
+	assert.Equal(t, "Luf-mp4", metadata["provider"], "the wixmp link comes from Luf-mp4 in the fixture")
+	assert.Equal(t, "m3u8", metadata["type"])
+	assert.Equal(t, "1", metadata["episode"])
+}
+
+func TestAllAnimeQualitySelectionReplay(t *testing.T) {
+	useFixtures(t, "allanime")
+
+	client := NewAllAnimeClient()
+	links, err := client.extractLinks(ProviderSource{Name: "S-mp4", URL: "https://allanime.day/apivtwo/clock.json?id=b4c2"})
+	require.NoError(t, err)
+	require.Len(t, links, 3)
+	assert.Equal(t, "S-mp4", links[0].Provider)
+
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"best", "1080p"},
+		{"worst", "480p"},
+		{"720p", "720p"},
+	}
+	for _, tt := range tests {
+		link, metadata := client.selectQuality(links, tt.requested)
+		assert.Equal(t, tt.want, metadata["quality"], tt.requested)
+		assert.Equal(t, "S-mp4", metadata["provider"], tt.requested)
+		assert.Contains(t, link, tt.want[:len(tt.want)-1]+".mp4", tt.requested)
+	}
+}
+
+func TestAnimefireSearchReplayGroupsVariants(t *testing.T) {
+	useFixtures(t, "animefire")
+
+	results, err := NewAnimefireClient().SearchAnime("Sousou no Frieren")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	group := results[0]
+	assert.Equal(t, "Sousou no Frieren (Dublado/Legendado)", group.Name)
+	require.Len(t, group.Variants, 2)
+	assert.False(t, group.Variants[0].Dubbed)
+	assert.Equal(t, "https://animefire.plus/animes/sousou-no-frieren-todos-os-episodios", group.Variants[0].URL)
+	assert.True(t, group.Variants[1].Dubbed)
+	assert.Equal(t, "https://animefire.plus/animes/sousou-no-frieren-dublado-todos-os-episodios", group.Variants[1].URL)
+}
+
+func TestAllAnimeEpisodeURLKeepsProbedFallbacks(t *testing.T) {
+	useFixtures(t, "allanime")
+
+	link, _, err := NewAllAnimeClient().GetEpisodeURL("ReooPAxPMsHM4KPMY", "1", "sub", "best")
+	require.NoError(t, err)
+
+	var urls []string
+	for _, l := range StreamFallbacks(link) {
+		urls = append(urls, l.URL)
+	}
+	// The 480p fixture answers 404, so it is not offered as a fallback
+	assert.ElementsMatch(t, []string{
+		"https://repackager.wixmp.com/video.wixstatic.com/video/frieren-ep1/,1080p,720p,/mp4/file.mp4.urlset/master.m3u8",
+		"https://repackager.wixmp.com/video.wixstatic.com/video/frieren-ep1/,1080p,720p,/mp4/file.mp4.urlset/index-f2-v1-a1.m3u8",
+		"https://cdn.example-video.net/frieren/ep1/1080.mp4",
+		"https://cdn.example-video.net/frieren/ep1/720.mp4",
+	}, urls)
+}
@@ -0,0 +1,182 @@
+package scraper
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/subtitles"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+// probeTimeout bounds the validation of a single candidate stream
+const probeTimeout = 5 * time.Second
+
+// probedLink is a stream link that answered a probe
+type probedLink struct {
+	StreamLink
+	ttfb time.Duration
+}
+
+// rankCandidates probes every link concurrently, drops the ones that fail and
+// orders the rest: links on a LinkPriorities host first, then links of the
+// quality pickLink would choose for requested, then HLS, then by time to first byte
+func (c *AllAnimeClient) rankCandidates(links []StreamLink, requested string) []StreamLink {
+	probed := make([]*probedLink, len(links))
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func(i int, link StreamLink) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+			defer cancel()
+			ttfb, err := c.probe(ctx, link.URL, link.Headers)
+			if err != nil {
+				util.Debug("Stream probe failed", "provider", link.Provider, "url", link.URL, "error", err)
+				return
+			}
+			probed[i] = &probedLink{StreamLink: link, ttfb: ttfb}
+		}(i, link)
+	}
+	wg.Wait()
+
+	var alive []probedLink
+	var aliveLinks []StreamLink
+	for _, p := range probed {
+		if p != nil {
+			alive = append(alive, *p)
+			aliveLinks = append(aliveLinks, p.StreamLink)
+		}
+	}
+	target, ok := c.pickLink(aliveLinks, requested)
+	if !ok {
+		return nil
+	}
+
+	qualityRank := func(l StreamLink) int {
+		switch {
+		case l.quality() == target.quality():
+			return 0
+		case l.HLS:
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(alive, func(i, j int) bool {
+		a, b := alive[i], alive[j]
+		if pa, pb := c.getPriorityScore(a.URL), c.getPriorityScore(b.URL); pa != pb {
+			return pa > pb
+		}
+		if qa, qb := qualityRank(a.StreamLink), qualityRank(b.StreamLink); qa != qb {
+			return qa < qb
+		}
+		return a.ttfb < b.ttfb
+	})
+
+	ranked := make([]StreamLink, len(alive))
+	for i, p := range alive {
+		ranked[i] = p.StreamLink
+		util.Debug("Stream candidate", "rank", i+1, "provider", p.Provider, "quality", p.quality(), "ttfb", p.ttfb)
+	}
+	return ranked
+}
+
+// probe validates a stream through the AllAnime client so proxy and cookie settings apply
+func (c *AllAnimeClient) probe(ctx context.Context, rawURL string, headers map[string]string) (time.Duration, error) {
+	if c.prober != nil {
+		return c.prober(ctx, rawURL, headers)
+	}
+	return network.ProbeStream(ctx, c.client, rawURL, headers)
+}
+
+// maxRememberedStreams bounds how many chosen streams are remembered
+const maxRememberedStreams = 32
+
+// rememberedStream is a chosen link, the validated alternatives to it and the
+// source (a network.source_proxies key) it was scraped from
+type rememberedStream struct {
+	link      StreamLink
+	fallbacks []StreamLink
+	source    string
+}
+
+var (
+	streamsMu sync.Mutex
+	streams   = map[string]rememberedStream{}
+)
+
+// rememberCandidates records ranked[0], scraped from source, as the chosen link
+// and ranked[1:] as its fallbacks
+func rememberCandidates(source string, ranked []StreamLink) {
+	if len(ranked) == 0 {
+		return
+	}
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	if len(streams) >= maxRememberedStreams {
+		streams = map[string]rememberedStream{}
+	}
+	streams[ranked[0].URL] = rememberedStream{
+		link:      ranked[0],
+		fallbacks: append([]StreamLink(nil), ranked[1:]...),
+		source:    source,
+	}
+}
+
+// RememberStream records streamURL as a stream of source whose link was
+// resolved outside this package, so StreamProxy knows where it came from
+func RememberStream(source, streamURL string) {
+	rememberCandidates(source, []StreamLink{{URL: streamURL}})
+}
+
+// StreamFallbacks returns the validated alternatives to streamURL, best first, as
+// ranked when streamURL was chosen. The player moves on to them when streamURL fails.
+func StreamFallbacks(streamURL string) []StreamLink {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	return append([]StreamLink(nil), streams[streamURL].fallbacks...)
+}
+
+// StreamHeaders returns the request headers the host of streamURL expects, as
+// reported by its source and limited to what network.StreamHeaders forwards
+func StreamHeaders(streamURL string) map[string]string {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	return network.StreamHeaders(streams[streamURL].link.Headers)
+}
+
+// StreamSource returns the source streamURL was found on, "" when unknown
+func StreamSource(streamURL string) string {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	return streams[streamURL].source
+}
+
+// StreamProxy returns the proxy that tools fetching streamURL themselves, such
+// as mpv and yt-dlp, should use: the one configured for the stream's source
+func StreamProxy(streamURL string) string {
+	return network.ProxyURL(StreamSource(streamURL))
+}
+
+// StreamSubtitles returns the subtitle tracks offered with streamURL. The track in
+// the configured subtitles.language comes first; nothing is returned when
+// subtitles are "off".
+func StreamSubtitles(streamURL string) []Subtitle {
+	prefs := config.Get().Subtitles
+	if prefs.Off() {
+		return nil
+	}
+	streamsMu.Lock()
+	tracks := append([]Subtitle(nil), streams[streamURL].link.Subtitles...)
+	streamsMu.Unlock()
+
+	sort.SliceStable(tracks, func(i, j int) bool {
+		return subtitles.MatchesLanguage(tracks[i].Lang, tracks[i].Label, prefs.Language) &&
+			!subtitles.MatchesLanguage(tracks[j].Lang, tracks[j].Label, prefs.Language)
+	})
+	return tracks
+}
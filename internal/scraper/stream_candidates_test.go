@@ -0,0 +1,111 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankCandidatesDropsFailedProbesAndOrdersByTTFB(t *testing.T) {
+	t.Parallel()
+
+	ttfb := map[string]time.Duration{
+		"https://slow.example/1080.mp4":   900 * time.Millisecond,
+		"https://fast.example/1080.mp4":   50 * time.Millisecond,
+		"https://fast.example/720.mp4":    10 * time.Millisecond,
+		"https://hls.example/master.m3u8": 20 * time.Millisecond,
+	}
+	c := NewAllAnimeClient()
+	c.prober = func(_ context.Context, rawURL string, _ map[string]string) (time.Duration, error) {
+		d, ok := ttfb[rawURL]
+		if !ok {
+			return 0, errors.New("403 Forbidden")
+		}
+		return d, nil
+	}
+
+	ranked := c.rankCandidates([]StreamLink{
+		{Provider: "Sak", URL: "https://dead.example/1080.mp4", Resolution: "1080p"},
+		{Provider: "Sak", URL: "https://slow.example/1080.mp4", Resolution: "1080p"},
+		{Provider: "Kir", URL: "https://fast.example/720.mp4", Resolution: "720p"},
+		{Provider: "Default", URL: "https://hls.example/master.m3u8", HLS: true},
+		{Provider: "Kir", URL: "https://fast.example/1080.mp4", Resolution: "1080p"},
+	}, "best")
+
+	var urls []string
+	for _, l := range ranked {
+		urls = append(urls, l.URL)
+	}
+	assert.Equal(t, []string{
+		"https://fast.example/1080.mp4",
+		"https://slow.example/1080.mp4",
+		"https://hls.example/master.m3u8",
+		"https://fast.example/720.mp4",
+	}, urls, "requested quality first, then HLS, each by time to first byte")
+}
+
+func TestStreamFallbacks(t *testing.T) {
+	rememberCandidates("", []StreamLink{
+		{URL: "https://a.example/stream.m3u8"},
+		{URL: "https://b.example/stream.m3u8"},
+		{URL: "https://c.example/stream.m3u8"},
+	})
+
+	fallbacks := StreamFallbacks("https://a.example/stream.m3u8")
+	assert.Equal(t, []StreamLink{{URL: "https://b.example/stream.m3u8"}, {URL: "https://c.example/stream.m3u8"}}, fallbacks)
+	assert.Empty(t, StreamFallbacks("https://unknown.example/stream.m3u8"))
+}
+
+func TestStreamSubtitlesPrefersConfiguredLanguage(t *testing.T) {
+	t.Cleanup(func() { config.Set(config.Default()) })
+	rememberCandidates("", []StreamLink{{
+		URL: "https://a.example/subbed.m3u8",
+		Subtitles: []Subtitle{
+			{Lang: "en", URL: "https://a.example/en.vtt", Format: "vtt"},
+			{Lang: "es", URL: "https://a.example/es.vtt", Format: "vtt"},
+			{Lang: "pt-BR", URL: "https://a.example/pt.vtt", Format: "vtt"},
+		},
+	}})
+
+	cfg := config.Default()
+	cfg.Subtitles.Language = "pt"
+	config.Set(cfg)
+	var langs []string
+	for _, s := range StreamSubtitles("https://a.example/subbed.m3u8") {
+		langs = append(langs, s.Lang)
+	}
+	assert.Equal(t, []string{"pt-BR", "en", "es"}, langs)
+
+	cfg.Subtitles.Language = "off"
+	config.Set(cfg)
+	assert.Empty(t, StreamSubtitles("https://a.example/subbed.m3u8"))
+}
+
+func TestStreamHeadersAreFiltered(t *testing.T) {
+	rememberCandidates("", []StreamLink{{
+		URL:     "https://a.example/headers.mp4",
+		Headers: map[string]string{"Referer": "https://a.example/", "Cookie": "session=1"},
+	}})
+
+	assert.Equal(t, map[string]string{"Referer": "https://a.example/"}, StreamHeaders("https://a.example/headers.mp4"))
+	assert.Nil(t, StreamHeaders("https://unknown.example/stream.mp4"))
+}
+
+func TestStreamProxyFollowsSource(t *testing.T) {
+	t.Cleanup(func() { config.Set(config.Default()) })
+	cfg := config.Default()
+	cfg.Network.Proxy = "http://global.example:8080"
+	cfg.Network.SourceProxies = map[string]string{"animefire": "socks5://127.0.0.1:9050"}
+	config.Set(cfg)
+
+	RememberStream("animefire", "https://a.example/animefire.mp4")
+	rememberCandidates("allanime", []StreamLink{{URL: "https://a.example/allanime.mp4"}})
+
+	assert.Equal(t, "socks5://127.0.0.1:9050", StreamProxy("https://a.example/animefire.mp4"))
+	assert.Equal(t, "http://global.example:8080", StreamProxy("https://a.example/allanime.mp4"))
+	assert.Equal(t, "http://global.example:8080", StreamProxy("https://unknown.example/stream.mp4"))
+}
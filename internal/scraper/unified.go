@@ -2,6 +2,7 @@
 package scraper
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -40,11 +41,18 @@ func NewScraperManager() *ScraperManager {
 	manager.scrapers[AllAnimeType] = &AllAnimeAdapter{client: NewAllAnimeClient()}
 	manager.scrapers[AnimefireType] = &AnimefireAdapter{client: NewAnimefireClient()}
 
+	// Declarative sources from the config dir
+	customs, _ := CustomSources()
+	for _, s := range customs {
+		manager.scrapers[s.GetType()] = s
+	}
+
 	return manager
 }
 
-// SearchAnime searches across all available scrapers with enhanced Portuguese messaging
-func (sm *ScraperManager) SearchAnime(query string, scraperType *ScraperType) ([]*models.Anime, error) {
+// SearchAnime searches across all available scrapers with enhanced Portuguese messaging.
+// options are passed to every scraper, e.g. a SearchOptions value for AllAnime.
+func (sm *ScraperManager) SearchAnime(query string, scraperType *ScraperType, options ...interface{}) ([]*models.Anime, error) {
 	var allResults []*models.Anime
 
 	if scraperType != nil {
@@ -52,7 +60,7 @@ func (sm *ScraperManager) SearchAnime(query string, scraperType *ScraperType) ([
 		if scraper, exists := sm.scrapers[*scraperType]; exists {
 			util.Debug("Searching specific scraper", "scraper", sm.getScraperDisplayName(*scraperType))
 
-			results, err := scraper.SearchAnime(query)
+			results, err := scraper.SearchAnime(query, options...)
 			if err != nil {
 				return nil, fmt.Errorf("busca falhou em %s: %w", sm.getScraperDisplayName(*scraperType), err)
 			}
@@ -67,6 +75,7 @@ func (sm *ScraperManager) SearchAnime(query string, scraperType *ScraperType) ([
 				}
 				// Add metadata to identify the source
 				anime.Source = sourceName
+				tagVariants(anime, sourceTag)
 			}
 
 			if len(results) > 0 {
@@ -84,7 +93,7 @@ func (sm *ScraperManager) SearchAnime(query string, scraperType *ScraperType) ([
 	for scraperType, scraper := range sm.scrapers {
 		util.Debug("Searching in source", "source", sm.getScraperDisplayName(scraperType))
 
-		results, err := scraper.SearchAnime(query)
+		results, err := scraper.SearchAnime(query, options...)
 		if err != nil {
 			// Log error but continue with other scrapers
 			util.Debug("Search error", "source", sm.getScraperDisplayName(scraperType), "error", err)
@@ -104,6 +113,7 @@ func (sm *ScraperManager) SearchAnime(query string, scraperType *ScraperType) ([
 
 			// Add metadata to identify the source
 			anime.Source = sourceName
+			tagVariants(anime, sourceTag)
 		}
 
 		allResults = append(allResults, results...)
@@ -135,6 +145,57 @@ func (sm *ScraperManager) SearchAnime(query string, scraperType *ScraperType) ([
 	return allResults, nil
 }
 
+// Browse lists titles for mode from every source that supports it, or only from
+// scraperType when set. A source failing is logged unless it was asked for explicitly.
+// more reports whether any source has another page.
+func (sm *ScraperManager) Browse(mode BrowseMode, opts SearchOptions, scraperType *ScraperType) ([]*models.Anime, bool, error) {
+	var allResults []*models.Anime
+	anyMore := false
+	for _, t := range []ScraperType{AllAnimeType, AnimefireType} {
+		if scraperType != nil && *scraperType != t {
+			continue
+		}
+
+		var results []*models.Anime
+		var more bool
+		var err error
+		switch adapter := sm.scrapers[t].(type) {
+		case *AllAnimeAdapter:
+			results, more, err = adapter.client.Browse(mode, opts)
+		case *AnimefireAdapter:
+			results, more, err = adapter.client.Browse(mode, opts)
+		default:
+			continue
+		}
+		if err != nil {
+			if scraperType != nil {
+				return nil, false, fmt.Errorf("browse %s failed on %s: %w", mode, sm.getScraperDisplayName(t), err)
+			}
+			util.Debug("Browse error", "source", sm.getScraperDisplayName(t), "error", err)
+			continue
+		}
+
+		for _, anime := range results {
+			anime.Name = fmt.Sprintf("%s %s", sm.getSourceTag(t), anime.Name)
+			anime.Source = sm.getScraperDisplayName(t)
+			tagVariants(anime, sm.getSourceTag(t))
+		}
+		allResults = append(allResults, results...)
+		anyMore = anyMore || more
+	}
+	return allResults, anyMore, nil
+}
+
+// tagVariants gives the language variants of anime the same source and tag as the group
+func tagVariants(anime *models.Anime, sourceTag string) {
+	for _, v := range anime.Variants {
+		v.Source = anime.Source
+		if !strings.Contains(v.Name, sourceTag) {
+			v.Name = fmt.Sprintf("%s %s", sourceTag, v.Name)
+		}
+	}
+}
+
 // GetScraper returns a specific scraper by type
 func (sm *ScraperManager) GetScraper(scraperType ScraperType) (UnifiedScraper, error) {
 	if scraper, exists := sm.scrapers[scraperType]; exists {
@@ -151,6 +212,9 @@ func (sm *ScraperManager) getScraperDisplayName(scraperType ScraperType) string
 	case AnimefireType:
 		return "AnimeFire.plus"
 	default:
+		if s, ok := sm.scrapers[scraperType].(*DeclarativeScraper); ok {
+			return s.def.Name
+		}
 		return "Desconhecido"
 	}
 }
@@ -163,6 +227,9 @@ func (sm *ScraperManager) getSourceTag(scraperType ScraperType) string {
 	case AnimefireType:
 		return "[AnimeFire]"
 	default:
+		if s, ok := sm.scrapers[scraperType].(*DeclarativeScraper); ok {
+			return "[" + s.def.Name + "]"
+		}
 		return "[Unknown]"
 	}
 }
@@ -173,13 +240,12 @@ type AllAnimeAdapter struct {
 }
 
 func (a *AllAnimeAdapter) SearchAnime(query string, options ...interface{}) ([]*models.Anime, error) {
-	// mode is now hardcoded in the new implementation
-	return a.client.SearchAnime(query)
+	return a.client.SearchAnime(query, options...)
 }
 
 func (a *AllAnimeAdapter) GetAnimeEpisodes(animeURL string) ([]models.Episode, error) {
 	// For AllAnime, animeURL is actually the anime ID
-	episodes, err := a.client.GetEpisodesList(animeURL, "sub")
+	episodes, err := a.client.GetEpisodesList(animeURL, util.Mode())
 	if err != nil {
 		return nil, err
 	}
@@ -238,6 +304,17 @@ type AnimefireAdapter struct {
 }
 
 func (a *AnimefireAdapter) SearchAnime(query string, options ...interface{}) ([]*models.Anime, error) {
+	opts := searchOptionsFrom(options)
+	if opts.Filtered() {
+		return nil, errors.New("AnimeFire does not support search filters")
+	}
+	if opts.Page > 1 {
+		results, _, err := a.client.SearchAnimePage(query, opts.Page)
+		if err != nil {
+			return nil, err
+		}
+		return GroupVariants(results), nil
+	}
 	return a.client.SearchAnime(query)
 }
 
@@ -0,0 +1,384 @@
+// Package streamproxy serves remote streams on a local address and adds the
+// headers their hosts expect, for players that cannot send headers themselves
+package streamproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alvarorichard/Goanime/internal/api"
+	"github.com/alvarorichard/Goanime/internal/network"
+	"github.com/alvarorichard/Goanime/internal/util"
+)
+
+const (
+	// maxPlaylistSize bounds how much of a playlist is read for rewriting
+	maxPlaylistSize = 8 << 20
+	// streamTTL is how long a registered stream is kept without being
+	// requested; every segment of a playlist is registered, so streams of
+	// episodes played long ago must not pile up. It outlasts a paused episode.
+	streamTTL = 6 * time.Hour
+)
+
+// forwardedRequestHeaders are passed from the player to the host
+var forwardedRequestHeaders = []string{"Range", "If-Range"}
+
+// forwardedResponseHeaders are passed from the host to the player
+var forwardedResponseHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag",
+}
+
+// stream is a remote URL and the headers it is requested with
+type stream struct {
+	url     string
+	source  string // the source it comes from, whose proxy and cookies it is fetched with
+	headers map[string]string
+	key     string    // its entry in Server.tokens
+	used    time.Time // when it was last registered or requested
+}
+
+// Server proxies registered streams. Only URLs handed out by URL are served,
+// so it cannot be used as an open proxy.
+type Server struct {
+	srv *http.Server
+	// client returns the client the streams of a source are fetched with
+	client func(source string) *http.Client
+	base   string
+
+	mu      sync.Mutex
+	streams map[string]*stream
+	tokens  map[string]string
+	ttl     time.Duration
+	swept   time.Time
+}
+
+// Start listens on addr ("host:port"; "" means 127.0.0.1 on a free port) and serves in the background
+func Start(addr string) (*Server, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("stream proxy: %w", err)
+	}
+	s := &Server{
+		client:  upstreamClients(),
+		base:    "http://" + advertisedAddr(ln.Addr().(*net.TCPAddr)),
+		streams: map[string]*stream{},
+		tokens:  map[string]string{},
+		ttl:     streamTTL,
+		swept:   time.Now(),
+	}
+	s.srv = &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			util.Warn("Stream proxy stopped", "error", err)
+		}
+	}()
+	util.Debug("Stream proxy listening", "url", s.base)
+	return s, nil
+}
+
+// upstreamClients returns the clients upstreams are fetched with, one per
+// source, through the source's proxy and with its cookies. Redirects are
+// followed here so players only ever see local URLs. Upstreams are checked like
+// any other request, so a stream cannot point the proxy at the LAN.
+func upstreamClients() func(source string) *http.Client {
+	var (
+		mu      sync.Mutex
+		clients = map[string]*http.Client{}
+	)
+	return func(source string) *http.Client {
+		mu.Lock()
+		defer mu.Unlock()
+		c, ok := clients[source]
+		if !ok {
+			transport := api.SafeTransport(30 * time.Second)
+			transport.Proxy = network.ProxyFunc(source)
+			c = &http.Client{Transport: network.Overridable(transport), Jar: network.CookieJar(source)}
+			clients[source] = c
+		}
+		return c
+	}
+}
+
+// advertisedAddr is the address handed to players: a wildcard listener is
+// reachable on the LAN through the machine's first non-loopback IPv4 address
+func advertisedAddr(a *net.TCPAddr) string {
+	port := strconv.Itoa(a.Port)
+	if !a.IP.IsUnspecified() {
+		return net.JoinHostPort(a.IP.String(), port)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+				return net.JoinHostPort(ipNet.IP.String(), port)
+			}
+		}
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// Addr returns the base URL of the proxy
+func (s *Server) Addr() string {
+	return s.base
+}
+
+// Close stops the proxy
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
+
+// URL registers upstream, a stream of source ("" when unknown), and returns
+// the local URL that serves it with headers. Only the headers
+// network.StreamHeaders allows are sent. Registering the same stream twice
+// returns the same URL.
+func (s *Server) URL(upstream, source string, headers map[string]string) string {
+	headers = network.StreamHeaders(headers)
+	key := streamKey(upstream, source, headers)
+
+	s.mu.Lock()
+	s.evictLocked()
+	token, ok := s.tokens[key]
+	if ok {
+		s.streams[token].used = time.Now()
+	} else {
+		token = newToken()
+		s.tokens[key] = token
+		s.streams[token] = &stream{url: upstream, source: source, headers: headers, key: key, used: time.Now()}
+	}
+	s.mu.Unlock()
+
+	// The file name is kept so players can tell playlists from video files
+	name := "stream"
+	if u, err := url.Parse(upstream); err == nil {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			name = url.PathEscape(base)
+		}
+	}
+	return s.base + "/" + token + "/" + name
+}
+
+// evictLocked forgets the streams not used for the TTL, looking at most every
+// tenth of it
+func (s *Server) evictLocked() {
+	now := time.Now()
+	if now.Sub(s.swept) < s.ttl/10 {
+		return
+	}
+	s.swept = now
+	for token, st := range s.streams {
+		if now.Sub(st.used) >= s.ttl {
+			delete(s.streams, token)
+			delete(s.tokens, st.key)
+		}
+	}
+}
+
+// Upstream returns the remote URL behind a local URL of this proxy
+func (s *Server) Upstream(local string) (string, bool) {
+	rest, ok := strings.CutPrefix(local, s.base+"/")
+	if !ok {
+		return "", false
+	}
+	token, _, _ := strings.Cut(rest, "/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.streams[token]
+	if !ok {
+		return "", false
+	}
+	return st.url, true
+}
+
+// streamKey identifies a stream by URL, source and headers
+func streamKey(upstream, source string, headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(upstream)
+	b.WriteString("\x00" + source)
+	for _, k := range names {
+		b.WriteString("\x00" + k + ":" + headers[k])
+	}
+	return b.String()
+}
+
+// newToken returns an unguessable path segment
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ServeHTTP serves a registered stream
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	s.mu.Lock()
+	st, ok := s.streams[token]
+	if ok {
+		st.used = time.Now()
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, st.url, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	req.Header.Set("User-Agent", network.UserAgent)
+	for k, v := range st.headers {
+		req.Header.Set(k, v)
+	}
+	for _, h := range forwardedRequestHeaders {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+
+	resp, err := s.client(st.source).Do(req)
+	if err != nil {
+		util.Debug("Stream proxy upstream failed", "url", st.url, "error", err)
+		http.Error(w, "upstream request failed", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusOK && isPlaylist(resp) {
+		s.servePlaylist(w, r, resp, st)
+		return
+	}
+	for _, h := range forwardedResponseHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodGet {
+		_, _ = io.Copy(w, resp.Body)
+	}
+}
+
+// isPlaylist reports whether resp is an HLS playlist
+func isPlaylist(resp *http.Response) bool {
+	ct := strings.ToLower(resp.Header.Get("Content-Type"))
+	return strings.Contains(ct, "mpegurl") || strings.HasSuffix(strings.ToLower(resp.Request.URL.Path), ".m3u8")
+}
+
+// servePlaylist rewrites the playlist in resp so its segments, keys and
+// variants are requested through the proxy too
+func (s *Server) servePlaylist(w http.ResponseWriter, r *http.Request, resp *http.Response, st *stream) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPlaylistSize))
+	if err != nil {
+		http.Error(w, "upstream read failed", http.StatusBadGateway)
+		return
+	}
+	rewritten := rewritePlaylist(body, resp.Request.URL, func(u string) string {
+		return s.URL(u, st.source, st.headers)
+	})
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Content-Length", strconv.Itoa(len(rewritten)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(rewritten)
+	}
+}
+
+// uriAttribute matches the URI="..." attribute of tags such as EXT-X-KEY and EXT-X-MEDIA
+var uriAttribute = regexp.MustCompile(`URI="([^"]*)"`)
+
+// rewritePlaylist resolves every URI of an HLS playlist against base and passes it through proxify
+func rewritePlaylist(body []byte, base *url.URL, proxify func(string) string) []byte {
+	resolve := func(ref string) string {
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil {
+			return ref
+		}
+		return proxify(u.String())
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxPlaylistSize)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case !strings.HasPrefix(trimmed, "#"):
+			line = resolve(trimmed)
+		case strings.Contains(line, `URI="`):
+			line = uriAttribute.ReplaceAllStringFunc(line, func(m string) string {
+				return `URI="` + resolve(uriAttribute.FindStringSubmatch(m)[1]) + `"`
+			})
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   *Server
+)
+
+// Shared returns the process-wide proxy, starting it on addr the first time it is needed
+func Shared(addr string) (*Server, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if shared != nil {
+		return shared, nil
+	}
+	s, err := Start(addr)
+	if err != nil {
+		return nil, err
+	}
+	shared = s
+	return s, nil
+}
+
+// Upstream returns the remote URL behind a URL of the shared proxy, or local
+// unchanged when it is not one
+func Upstream(local string) (string, bool) {
+	sharedMu.Lock()
+	s := shared
+	sharedMu.Unlock()
+	if s == nil {
+		return local, false
+	}
+	if u, ok := s.Upstream(local); ok {
+		return u, true
+	}
+	return local, false
+}
@@ -0,0 +1,242 @@
+package streamproxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const referer = "https://allanime.to"
+
+// upstream serves a master playlist, a media playlist with an encryption key
+// and a segment, all of which require the Referer
+func upstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	segment := strings.Repeat("0123456789", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != referer {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/hls/master.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			_, _ = fmt.Fprint(w, "#EXTM3U\n#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"a\",URI=\"audio.m3u8\"\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\n360.m3u8\n")
+		case "/hls/360.m3u8":
+			_, _ = fmt.Fprint(w, "#EXTM3U\r\n#EXT-X-KEY:METHOD=AES-128,URI=\"/keys/k1\"\r\n#EXTINF:10,\r\nseg/s1.ts\r\n#EXT-X-ENDLIST\r\n")
+		case "/hls/seg/s1.ts":
+			w.Header().Set("Content-Type", "video/mp2t")
+			http.ServeContent(w, r, "s1.ts", time.Time{}, strings.NewReader(segment))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func startProxy(t *testing.T) *Server {
+	t.Helper()
+	s, err := Start("127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	// The upstreams of the tests are on loopback, which the proxy refuses
+	s.client = func(string) *http.Client { return &http.Client{} }
+	return s
+}
+
+func get(t *testing.T, rawURL string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestProxyRewritesPlaylists(t *testing.T) {
+	t.Parallel()
+
+	up := upstream(t)
+	s := startProxy(t)
+	headers := map[string]string{"Referer": referer, "Cookie": "dropped=1"}
+
+	master := s.URL(up.URL+"/hls/master.m3u8", "", headers)
+	assert.True(t, strings.HasPrefix(master, s.Addr()+"/"))
+	assert.True(t, strings.HasSuffix(master, "/master.m3u8"), "the file name is kept for players")
+	assert.Equal(t, master, s.URL(up.URL+"/hls/master.m3u8", "", headers), "registering twice reuses the URL")
+
+	resp, body := get(t, master, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/vnd.apple.mpegurl", resp.Header.Get("Content-Type"))
+	assert.NotContains(t, body, up.URL, "no upstream URL reaches the player")
+
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[1], `URI="`+s.Addr())
+	variant := lines[3]
+	origin, ok := s.Upstream(variant)
+	require.True(t, ok)
+	assert.Equal(t, up.URL+"/hls/360.m3u8", origin)
+
+	resp, body = get(t, variant, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	lines = strings.Split(strings.TrimSpace(body), "\n")
+	require.Len(t, lines, 5)
+	assert.NotContains(t, body, "\r")
+	key := strings.TrimSuffix(strings.TrimPrefix(lines[1], `#EXT-X-KEY:METHOD=AES-128,URI="`), `"`)
+	origin, ok = s.Upstream(key)
+	require.True(t, ok)
+	assert.Equal(t, up.URL+"/keys/k1", origin, "absolute paths resolve against the host")
+
+	segment := lines[3]
+	origin, _ = s.Upstream(segment)
+	assert.Equal(t, up.URL+"/hls/seg/s1.ts", origin)
+
+	resp, body = get(t, segment, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "video/mp2t", resp.Header.Get("Content-Type"))
+	assert.Len(t, body, 1000)
+}
+
+func TestProxyForwardsRange(t *testing.T) {
+	t.Parallel()
+
+	up := upstream(t)
+	s := startProxy(t)
+	local := s.URL(up.URL+"/hls/seg/s1.ts", "", map[string]string{"Referer": referer})
+
+	resp, body := get(t, local, http.Header{"Range": {"bytes=10-19"}})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 10-19/1000", resp.Header.Get("Content-Range"))
+	assert.Equal(t, "10", resp.Header.Get("Content-Length"))
+	assert.Equal(t, "0123456789", body)
+}
+
+func TestProxyPassesUpstreamErrors(t *testing.T) {
+	t.Parallel()
+
+	up := upstream(t)
+	s := startProxy(t)
+
+	resp, _ := get(t, s.URL(up.URL+"/hls/master.m3u8", "", nil), nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "headers are only those registered")
+
+	resp, _ = get(t, s.URL(up.URL+"/missing.mp4", "", map[string]string{"Referer": referer}), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestProxyFetchesWithTheClientOfTheSource(t *testing.T) {
+	t.Parallel()
+
+	up := upstream(t)
+	s := startProxy(t)
+	var (
+		mu      sync.Mutex
+		sources []string
+	)
+	s.client = func(source string) *http.Client {
+		mu.Lock()
+		sources = append(sources, source)
+		mu.Unlock()
+		return &http.Client{}
+	}
+
+	resp, body := get(t, s.URL(up.URL+"/hls/360.m3u8", "animefire", map[string]string{"Referer": referer}), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	segment := strings.Split(strings.TrimSpace(body), "\n")[3]
+	resp, _ = get(t, segment, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"animefire", "animefire"}, sources, "playlist entries keep the source")
+	assert.NotEqual(t, s.URL(up.URL+"/hls/360.m3u8", "", nil), s.URL(up.URL+"/hls/360.m3u8", "animefire", nil))
+}
+
+func TestProxyOnlyServesRegisteredStreams(t *testing.T) {
+	t.Parallel()
+
+	s := startProxy(t)
+
+	resp, _ := get(t, s.Addr()+"/0123456789abcdef/video.mp4", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = get(t, s.Addr()+"/?url="+url.QueryEscape("https://example.com/video.mp4"), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	local := s.URL("https://example.com/video.mp4", "", nil)
+	resp, err := http.Post(local, "text/plain", strings.NewReader("x"))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	_, ok := s.Upstream("https://example.com/video.mp4")
+	assert.False(t, ok)
+}
+
+func TestProxyRefusesPrivateUpstreams(t *testing.T) {
+	t.Parallel()
+
+	up := upstream(t)
+	s, err := Start("127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	resp, _ := get(t, s.URL(up.URL+"/hls/master.m3u8", "", map[string]string{"Referer": referer}), nil)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+}
+
+func TestProxyForgetsUnusedStreams(t *testing.T) {
+	t.Parallel()
+
+	up := upstream(t)
+	s := startProxy(t)
+	s.ttl = 200 * time.Millisecond
+	headers := map[string]string{"Referer": referer}
+
+	old := s.URL(up.URL+"/hls/seg/s1.ts", "", headers)
+	kept := s.URL(up.URL+"/hls/master.m3u8", "", headers)
+	time.Sleep(120 * time.Millisecond)
+	resp, _ := get(t, kept, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	time.Sleep(120 * time.Millisecond)
+
+	s.URL(up.URL+"/hls/360.m3u8", "", headers)
+	_, ok := s.Upstream(old)
+	assert.False(t, ok, "a stream nobody requested is forgotten")
+	resp, _ = get(t, old, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	_, ok = s.Upstream(kept)
+	assert.True(t, ok, "streams still being played are kept")
+}
+
+func TestRewritePlaylist(t *testing.T) {
+	t.Parallel()
+
+	base, err := url.Parse("https://cdn.example.com/a/b/index.m3u8?token=1")
+	require.NoError(t, err)
+	in := "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\",BYTERANGE=\"100@0\"\n\n#EXTINF:4.0,\nhttps://other.example.com/s1.ts\n#EXTINF:4.0,\n../s2.ts?x=1\n"
+	out := rewritePlaylist([]byte(in), base, func(u string) string { return "<" + u + ">" })
+
+	assert.Equal(t, "#EXTM3U\n"+
+		"#EXT-X-MAP:URI=\"<https://cdn.example.com/a/b/init.mp4>\",BYTERANGE=\"100@0\"\n"+
+		"\n"+
+		"#EXTINF:4.0,\n<https://other.example.com/s1.ts>\n"+
+		"#EXTINF:4.0,\n<https://cdn.example.com/a/s2.ts?x=1>\n", string(out))
+}
@@ -0,0 +1,191 @@
+// Package subtitles downloads subtitle tracks and converts WebVTT to SubRip
+package subtitles
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alvarorichard/Goanime/internal/network"
+)
+
+// maxSubtitleSize bounds how much of a subtitle file is downloaded
+const maxSubtitleSize = 16 << 20
+
+// FormatOf returns the subtitle format of rawURL from its extension: "vtt",
+// "srt", "ass" or "ssa". Sources without an extension serve WebVTT, so that is
+// the default.
+func FormatOf(rawURL string) string {
+	p := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		p = u.Path
+	}
+	switch ext := strings.ToLower(strings.TrimPrefix(path.Ext(p), ".")); ext {
+	case "srt", "ass", "ssa", "vtt":
+		return ext
+	default:
+		return "vtt"
+	}
+}
+
+// MatchesLanguage reports whether a track in lang (a code such as "pt-BR") or
+// labelled label ("Portuguese") satisfies the preferred language. Codes match on
+// their primary subtag, so "pt" accepts "pt-BR" and "pt-BR" accepts "pt".
+func MatchesLanguage(lang, label, preferred string) bool {
+	preferred = strings.TrimSpace(preferred)
+	if preferred == "" {
+		return false
+	}
+	if strings.EqualFold(label, preferred) {
+		return true
+	}
+	primary := func(code string) string {
+		code, _, _ = strings.Cut(strings.ReplaceAll(code, "_", "-"), "-")
+		return strings.ToLower(code)
+	}
+	return lang != "" && primary(lang) == primary(preferred)
+}
+
+// Fetch downloads a subtitle file
+func Fetch(ctx context.Context, client *http.Client, rawURL string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", network.UserAgent)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("subtitle request returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSubtitleSize))
+}
+
+// SidecarPath returns where the subtitle for videoPath in lang is saved, e.g.
+// "Episode 1.en.srt" next to "Episode 1.mp4", the layout mpv loads automatically
+func SidecarPath(videoPath, lang, format string) string {
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	if lang = strings.TrimSpace(lang); lang != "" {
+		base += "." + sanitizeLang(lang)
+	}
+	return base + "." + format
+}
+
+// sanitizeLang keeps a language code safe for use in a file name
+func sanitizeLang(lang string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return -1
+		}
+	}, lang)
+}
+
+// Save writes data next to videoPath in its format. WebVTT is also written as
+// SubRip, which more players understand. It returns the files written.
+func Save(data []byte, videoPath, lang, format string) ([]string, error) {
+	target := SidecarPath(videoPath, lang, format)
+	if err := os.WriteFile(target, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save subtitle: %w", err)
+	}
+	written := []string{target}
+	if format != "vtt" {
+		return written, nil
+	}
+
+	srt, err := VTTToSRT(data)
+	if err != nil {
+		return written, fmt.Errorf("failed to convert %s: %w", target, err)
+	}
+	srtPath := SidecarPath(videoPath, lang, "srt")
+	if err := os.WriteFile(srtPath, srt, 0600); err != nil {
+		return written, fmt.Errorf("failed to save subtitle: %w", err)
+	}
+	return append(written, srtPath), nil
+}
+
+var (
+	// vttTiming matches a cue timing line; the hours are optional in WebVTT
+	vttTiming = regexp.MustCompile(`^((?:\d+:)?\d{2}:\d{2}\.\d{3})\s+-->\s+((?:\d+:)?\d{2}:\d{2}\.\d{3})`)
+	// vttTag matches markup SubRip has no equivalent for (voices, classes, ruby, timestamps)
+	vttTag = regexp.MustCompile(`</?(?:c|v|lang|ruby|rt)(?:[.\s][^>]*)?>|<\d[\d:.]*>`)
+)
+
+// VTTToSRT converts a WebVTT document to SubRip. Cue settings, styles, regions
+// and notes are dropped; <b>, <i> and <u> are kept.
+func VTTToSRT(vtt []byte) ([]byte, error) {
+	vtt = bytes.TrimPrefix(vtt, []byte("\xef\xbb\xbf"))
+	scanner := bufio.NewScanner(bytes.NewReader(vtt))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSubtitleSize)
+
+	if !scanner.Scan() || !strings.HasPrefix(strings.TrimSpace(scanner.Text()), "WEBVTT") {
+		return nil, fmt.Errorf("not a WebVTT file")
+	}
+
+	var (
+		out   bytes.Buffer
+		cue   int
+		block []string
+	)
+	flush := func() {
+		defer func() { block = block[:0] }()
+		for i, line := range block {
+			m := vttTiming.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			var text []string
+			for _, t := range block[i+1:] {
+				text = append(text, html.UnescapeString(vttTag.ReplaceAllString(t, "")))
+			}
+			cue++
+			fmt.Fprintf(&out, "%d\n%s --> %s\n%s\n\n", cue, srtTime(m[1]), srtTime(m[2]), strings.Join(text, "\n"))
+			return
+		}
+		// Header metadata, NOTE, STYLE and REGION blocks have no timing line
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		block = append(block, line)
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// srtTime turns "01:02.500" or "1:01:02.500" into "00:01:02,500" / "01:01:02,500"
+func srtTime(t string) string {
+	parts := strings.Split(t, ":")
+	if len(parts) == 2 {
+		parts = append([]string{"00"}, parts...)
+	}
+	if len(parts[0]) < 2 {
+		parts[0] = "0" + parts[0]
+	}
+	return strings.Replace(strings.Join(parts, ":"), ".", ",", 1)
+}
@@ -0,0 +1,67 @@
+package subtitles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVTTToSRT(t *testing.T) {
+	t.Parallel()
+
+	vtt, err := os.ReadFile("testdata/episode.vtt")
+	require.NoError(t, err)
+	want, err := os.ReadFile("testdata/episode.srt")
+	require.NoError(t, err)
+
+	srt, err := VTTToSRT(vtt)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(srt))
+
+	_, err = VTTToSRT([]byte("1\n00:00:01,000 --> 00:00:02,000\nalready srt\n"))
+	assert.Error(t, err)
+}
+
+func TestFormatOf(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "vtt", FormatOf("https://subs.example/ep1/en.vtt?token=1"))
+	assert.Equal(t, "srt", FormatOf("https://subs.example/ep1/en.SRT"))
+	assert.Equal(t, "ass", FormatOf("https://subs.example/ep1/en.ass"))
+	assert.Equal(t, "vtt", FormatOf("https://subs.example/sub?id=42"), "sources serve WebVTT by default")
+}
+
+func TestMatchesLanguage(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, MatchesLanguage("pt-BR", "", "pt"))
+	assert.True(t, MatchesLanguage("pt", "", "pt_BR"))
+	assert.True(t, MatchesLanguage("EN", "", "en"))
+	assert.True(t, MatchesLanguage("", "English", "english"))
+	assert.False(t, MatchesLanguage("es", "Spanish", "en"))
+	assert.False(t, MatchesLanguage("en", "English", ""))
+}
+
+func TestSaveWritesVTTAndSRT(t *testing.T) {
+	t.Parallel()
+
+	vtt, err := os.ReadFile("testdata/episode.vtt")
+	require.NoError(t, err)
+	video := filepath.Join(t.TempDir(), "3.mp4")
+
+	files, err := Save(vtt, video, "en/../x", "vtt")
+	require.NoError(t, err)
+	dir := filepath.Dir(video)
+	assert.Equal(t, []string{filepath.Join(dir, "3.enx.vtt"), filepath.Join(dir, "3.enx.srt")}, files, "the language cannot escape the folder")
+
+	srt, err := os.ReadFile(files[1])
+	require.NoError(t, err)
+	assert.Contains(t, string(srt), "00:00:01,000 --> 00:00:04,250")
+
+	files, err = Save([]byte("[Script Info]\n"), video, "pt", "ass")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "3.pt.ass")}, files)
+}
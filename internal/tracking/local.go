@@ -54,6 +54,11 @@ type Anime struct {
 	LastUpdated   time.Time `json:"last_updated"`
 }
 
+// Watched reports whether the episode was played, or marked, to its end
+func (a Anime) Watched() bool {
+	return a.Duration > 0 && a.PlaybackTime >= a.Duration
+}
+
 type LocalTracker struct {
 	db       *sql.DB
 	upsertPS *sql.Stmt
@@ -162,6 +167,10 @@ func initializeDatabase(db *sql.DB) error {
 		return fmt.Errorf("schema creation failed: %w", err)
 	}
 
+	if _, err := db.Exec(skipTimesSchema); err != nil {
+		return fmt.Errorf("skip times schema creation failed: %w", err)
+	}
+
 	indexes := []string{
 		`CREATE INDEX IF NOT EXISTS idx_anime_cover 
 		ON anime_progress(
@@ -246,3 +246,15 @@ func TestLocalTracker_DeleteAnime(t *testing.T) {
 		t.Error("Anime was not deleted")
 	}
 }
+
+func TestAnimeWatched(t *testing.T) {
+	if !(Anime{PlaybackTime: 1440, Duration: 1440}).Watched() {
+		t.Error("an episode played to its end should be watched")
+	}
+	if (Anime{PlaybackTime: 700, Duration: 1440}).Watched() {
+		t.Error("an episode played halfway should not be watched")
+	}
+	if (Anime{}).Watched() {
+		t.Error("an episode without duration should not be watched")
+	}
+}
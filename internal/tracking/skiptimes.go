@@ -0,0 +1,103 @@
+package tracking
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Skip marker kinds
+const (
+	SkipOpening = "op"
+	SkipEnding  = "ed"
+)
+
+// skipTimesSchema holds the openings and endings marked by hand during
+// playback, for episodes AniSkip has no data for
+const skipTimesSchema = `CREATE TABLE IF NOT EXISTS skip_times (
+	anilist_id     INTEGER NOT NULL,
+	episode_number INTEGER NOT NULL,
+	kind           TEXT    NOT NULL CHECK(kind IN ('op', 'ed')),
+	start_time     INTEGER NOT NULL CHECK(start_time >= 0),
+	end_time       INTEGER NOT NULL CHECK(end_time > start_time),
+	last_updated   INTEGER NOT NULL,
+	PRIMARY KEY (anilist_id, episode_number, kind)
+);`
+
+// SkipMarker is an opening or ending marked in an episode, in seconds
+type SkipMarker struct {
+	AnilistID     int       `json:"anilist_id"`
+	EpisodeNumber int       `json:"episode_number"`
+	Kind          string    `json:"kind"` // SkipOpening or SkipEnding
+	Start         int       `json:"start"`
+	End           int       `json:"end"`
+	LastUpdated   time.Time `json:"last_updated"`
+}
+
+// SaveSkipMarker stores m, replacing the marker of the same kind for its episode
+func (t *LocalTracker) SaveSkipMarker(m SkipMarker) error {
+	if t == nil || t.db == nil {
+		return ErrTrackerNotInited
+	}
+	if m.Kind != SkipOpening && m.Kind != SkipEnding {
+		return fmt.Errorf("invalid skip marker kind %q", m.Kind)
+	}
+	if m.Start < 0 || m.End <= m.Start {
+		return fmt.Errorf("invalid skip marker %d-%d: the end must come after the start", m.Start, m.End)
+	}
+
+	_, err := t.db.Exec(`INSERT INTO skip_times (
+		anilist_id,
+		episode_number,
+		kind,
+		start_time,
+		end_time,
+		last_updated
+	) VALUES (?,?,?,?,?,?)
+	ON CONFLICT(anilist_id, episode_number, kind) DO UPDATE SET
+		start_time = excluded.start_time,
+		end_time = excluded.end_time,
+		last_updated = excluded.last_updated`,
+		m.AnilistID, m.EpisodeNumber, m.Kind, m.Start, m.End, m.LastUpdated.Unix())
+	return err
+}
+
+// GetSkipMarkers returns the markers of every episode of anilistID, by episode
+func (t *LocalTracker) GetSkipMarkers(anilistID int) ([]SkipMarker, error) {
+	if t == nil || t.db == nil {
+		return nil, ErrTrackerNotInited
+	}
+
+	rows, err := t.db.Query(`SELECT
+		episode_number,
+		kind,
+		start_time,
+		end_time,
+		last_updated
+	FROM skip_times
+	WHERE anilist_id = ?
+	ORDER BY episode_number, kind`, anilistID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	var markers []SkipMarker
+	for rows.Next() {
+		m := SkipMarker{AnilistID: anilistID}
+		var ts int64
+		if err := rows.Scan(&m.EpisodeNumber, &m.Kind, &m.Start, &m.End, &ts); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		m.LastUpdated = time.Unix(ts, 0)
+		markers = append(markers, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+	return markers, nil
+}
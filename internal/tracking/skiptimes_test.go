@@ -0,0 +1,68 @@
+package tracking
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalTracker_SkipMarkers(t *testing.T) {
+	tracker := NewLocalTracker(filepath.Join(t.TempDir(), "test.db"))
+	if tracker == nil {
+		t.Fatal("NewLocalTracker returned nil")
+	}
+	defer func() {
+		if err := tracker.Close(); err != nil {
+			t.Logf("Error closing tracker: %v", err)
+		}
+	}()
+
+	now := time.Now()
+	for _, m := range []SkipMarker{
+		{AnilistID: 1, EpisodeNumber: 2, Kind: SkipOpening, Start: 60, End: 150, LastUpdated: now},
+		{AnilistID: 1, EpisodeNumber: 1, Kind: SkipEnding, Start: 1300, End: 1390, LastUpdated: now},
+		{AnilistID: 2, EpisodeNumber: 1, Kind: SkipOpening, Start: 0, End: 90, LastUpdated: now},
+		// Marking again replaces the earlier marker
+		{AnilistID: 1, EpisodeNumber: 2, Kind: SkipOpening, Start: 62, End: 151, LastUpdated: now},
+	} {
+		if err := tracker.SaveSkipMarker(m); err != nil {
+			t.Fatalf("SaveSkipMarker(%+v) failed: %v", m, err)
+		}
+	}
+
+	markers, err := tracker.GetSkipMarkers(1)
+	if err != nil {
+		t.Fatalf("GetSkipMarkers failed: %v", err)
+	}
+	if len(markers) != 2 {
+		t.Fatalf("expected 2 markers, got %+v", markers)
+	}
+	if m := markers[0]; m.EpisodeNumber != 1 || m.Kind != SkipEnding || m.Start != 1300 || m.End != 1390 {
+		t.Errorf("unexpected first marker %+v", m)
+	}
+	if m := markers[1]; m.EpisodeNumber != 2 || m.Kind != SkipOpening || m.Start != 62 || m.End != 151 {
+		t.Errorf("unexpected second marker %+v", m)
+	}
+}
+
+func TestLocalTracker_SaveSkipMarkerRejectsInvalid(t *testing.T) {
+	tracker := NewLocalTracker(filepath.Join(t.TempDir(), "test.db"))
+	if tracker == nil {
+		t.Fatal("NewLocalTracker returned nil")
+	}
+	defer func() {
+		if err := tracker.Close(); err != nil {
+			t.Logf("Error closing tracker: %v", err)
+		}
+	}()
+
+	for _, m := range []SkipMarker{
+		{AnilistID: 1, EpisodeNumber: 1, Kind: "recap", Start: 0, End: 60},
+		{AnilistID: 1, EpisodeNumber: 1, Kind: SkipOpening, Start: 90, End: 90},
+		{AnilistID: 1, EpisodeNumber: 1, Kind: SkipOpening, Start: -1, End: 90},
+	} {
+		if err := tracker.SaveSkipMarker(m); err == nil {
+			t.Errorf("SaveSkipMarker(%+v) should fail", m)
+		}
+	}
+}
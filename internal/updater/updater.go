@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/alvarorichard/Goanime/internal/network"
 	"github.com/alvarorichard/Goanime/internal/util"
 	"github.com/alvarorichard/Goanime/internal/version"
 	"github.com/charmbracelet/huh"
@@ -24,6 +25,9 @@ const (
 	GitHubAPI   = "https://api.github.com/repos/" + GitHubOwner + "/" + GitHubRepo
 )
 
+// httpClient honors the user's proxy and DNS-over-HTTPS settings
+var httpClient = network.NewClient("", 0)
+
 // GitHubRelease represents a GitHub release
 type GitHubRelease struct {
 	TagName string `json:"tag_name"`
@@ -38,7 +42,7 @@ type GitHubRelease struct {
 // CheckForUpdates checks if a new version is available on GitHub
 func CheckForUpdates() (*GitHubRelease, bool, error) {
 	// Get latest release from GitHub API
-	resp, err := http.Get(GitHubAPI + "/releases/latest")
+	resp, err := httpClient.Get(GitHubAPI + "/releases/latest")
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to fetch latest release: %w", err)
 	}
@@ -397,7 +401,7 @@ func downloadAssetWithTestFlag(url, filename string, allowTestMode bool) (string
 	}
 
 	// #nosec G107 - URL is validated above to ensure it's from trusted GitHub domains
-	resp, err := http.Get(url)
+	resp, err := httpClient.Get(url)
 	if err != nil {
 		return "", err
 	}
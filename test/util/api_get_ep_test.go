@@ -1,9 +1,7 @@
 package test_util
 
 import (
-	"io"
-	"net/http"
-	"net/http/httptest"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
@@ -12,14 +10,13 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/pkg/errors"
 
+	"github.com/alvarorichard/Goanime/internal/api"
+	"github.com/alvarorichard/Goanime/internal/config"
+	"github.com/alvarorichard/Goanime/internal/httpreplay"
+	"github.com/alvarorichard/Goanime/internal/mirrors"
 	"github.com/alvarorichard/Goanime/internal/util"
 )
 
-// Mock SafeGet function for testing
-func SafeGet(url string) (*http.Response, error) {
-	return http.Get(url)
-}
-
 // Define the Episode struct
 type Episode struct {
 	Number string
@@ -27,71 +24,46 @@ type Episode struct {
 	URL    string
 }
 
+// useAnimefireFixtures answers AnimeFire requests from testdata/fixtures/animefire
+// with the default mirrors and an empty config
+func useAnimefireFixtures(t *testing.T) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	config.Set(config.Default())
+	mirrors.Reset()
+	t.Cleanup(mirrors.Reset)
+	httpreplay.Use(t, filepath.Join("testdata", "fixtures", "animefire"))
+}
+
 // Test function for GetAnimeEpisodes
 func TestGetAnimeEpisodes(t *testing.T) {
-	// Create a mock server
-	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Simulate a HTML response
-		html := `
-		<html>
-			<body>
-				<a class="lEp epT divNumEp smallbox px-2 mx-1 text-left d-flex" href="/episode1">Episode 1</a>
-				<a class="lEp epT divNumEp smallbox px-2 mx-1 text-left d-flex" href="/episode2">Episode 2</a>
-			</body>
-		</html>`
-		_, err := w.Write([]byte(html))
-		if err != nil {
-			t.Fatalf("Failed to write response: %v", err)
-			return
-		}
-	}))
-	defer mockServer.Close()
+	useAnimefireFixtures(t)
 
-	// Call the GetAnimeEpisodes function with the mock server URL
-	episodes, err := GetAnimeEpisodes(mockServer.URL)
+	episodes, err := api.GetAnimeEpisodes("https://animefire.plus/animes/sousou-no-frieren-todos-os-episodios")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 
-	// Validate the results
-	expectedEpisodes := []Episode{
-		{Number: "Episode 1", Num: 1, URL: "/episode1"},
-		{Number: "Episode 2", Num: 2, URL: "/episode2"},
+	// Validate the results, sorted by episode number
+	expected := []struct {
+		Number string
+		Num    int
+		URL    string
+	}{
+		{"Episódio 1", 1, "https://animefire.plus/animes/sousou-no-frieren/1"},
+		{"Episódio 2", 2, "https://animefire.plus/animes/sousou-no-frieren/2"},
+		{"Episódio 10", 10, "https://animefire.plus/animes/sousou-no-frieren/10"},
 	}
 
-	if len(episodes) != len(expectedEpisodes) {
-		t.Fatalf("Expected %d episodes, got %d", len(expectedEpisodes), len(episodes))
+	if len(episodes) != len(expected) {
+		t.Fatalf("Expected %d episodes, got %d", len(expected), len(episodes))
 	}
 
 	for i, episode := range episodes {
-		if episode.Number != expectedEpisodes[i].Number || episode.Num != expectedEpisodes[i].Num || episode.URL != expectedEpisodes[i].URL {
-			t.Errorf("Expected episode %v, got %v", expectedEpisodes[i], episode)
-		}
-	}
-}
-
-// Helper functions for GetAnimeEpisodes
-func GetAnimeEpisodes(animeURL string) ([]Episode, error) {
-	resp, err := SafeGet(animeURL)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get anime details")
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			util.Debugf("Failed to close response body: %v", err)
+		if episode.Number != expected[i].Number || episode.Num != expected[i].Num || episode.URL != expected[i].URL {
+			t.Errorf("Expected episode %v, got %+v", expected[i], episode)
 		}
-	}(resp.Body)
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse anime details")
 	}
-
-	episodes := parseEpisodes(doc)
-	sortEpisodesByNum(episodes)
-
-	return episodes, nil
 }
 
 func parseEpisodes(doc *goquery.Document) []Episode {
@@ -1,108 +1,24 @@
-package test_util_test
+package test_util
 
 import (
-	"fmt"
-	"io"
-	"net/http"
-	"net/http/httptest"
-	"strings"
 	"testing"
 
-	"github.com/PuerkitoBio/goquery"
-	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
-	"github.com/alvarorichard/Goanime/internal/util"
+	"github.com/alvarorichard/Goanime/internal/scraper"
 )
 
-// Define a variable for the base site URL for testing purposes
-var testBaseSiteURL string
-
 func TestSearchAnime(t *testing.T) {
-	// Mock server setup
-	handler := http.NewServeMux()
-	handler.HandleFunc("/pesquisar/", func(w http.ResponseWriter, r *http.Request) {
-		page := `
-		<html>
-		<head><title>Search Results</title></head>
-		<body>
-			<a href="/anime/1">Anime 1</a>
-			<a href="/anime/2">Anime 2</a>
-			<a href="/anime/3">Anime 3</a>
-		</body>
-		</html>
-		`
-		_, err := w.Write([]byte(page))
-		if err != nil {
-			t.Fatalf("Failed to write response: %v", err)
-			return
-		}
-	})
-
-	server := httptest.NewServer(handler)
-	defer server.Close()
-
-	// Substitute the base URL with our mock server URL
-	testBaseSiteURL = server.URL
-
-	animeName := "anime"
-	expectedURL := server.URL + "/anime/1"
-
-	url, err := searchAnimeWithBaseURL(animeName, testBaseSiteURL)
-	assert.NoError(t, err)
-	assert.Equal(t, expectedURL, url)
-}
-
-func searchAnimeWithBaseURL(animeName, baseURL string) (string, error) {
-	currentPageURL := fmt.Sprintf("%s/pesquisar/%s", baseURL, animeName)
-
-	for {
-		animeURL, nextPageURL, err := searchAnimeOnPage(currentPageURL, baseURL)
-		if err != nil {
-			return "", err
-		}
-		if animeURL != "" {
-			return animeURL, nil
-		}
-		if nextPageURL == "" {
-			return "", errors.New("no anime found with the given name")
-		}
-		currentPageURL = baseURL + nextPageURL
-	}
-}
-
-func searchAnimeOnPage(currentPageURL, baseURL string) (string, string, error) {
-	resp, err := http.Get(currentPageURL)
-	if err != nil {
-		return "", "", err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			// Log the error but don't return it
-			util.Debugf("Failed to close response body: %v", err)
-		}
-	}(resp.Body)
-
-	if resp.StatusCode != 200 {
-		return "", "", errors.New("failed to fetch page")
-	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return "", "", err
-	}
+	useAnimefireFixtures(t)
 
-	var animeURL string
-	doc.Find("a").EachWithBreak(func(i int, s *goquery.Selection) bool {
-		href, exists := s.Attr("href")
-		if exists {
-			animeURL = strings.TrimRight(baseURL, "/") + href
-			return false
-		}
-		return true
-	})
+	results, err := scraper.NewAnimefireClient().SearchAnime("Sousou no Frieren")
+	require.NoError(t, err)
+	require.Len(t, results, 1, "the dubbed and subtitled pages are one result")
 
-	// In this mock, we won't handle pagination
-	return animeURL, "", nil
+	variants := results[0].Variants
+	require.Len(t, variants, 2)
+	assert.Equal(t, "https://animefire.plus/animes/sousou-no-frieren-todos-os-episodios", variants[0].URL)
+	assert.Equal(t, "https://animefire.plus/animes/sousou-no-frieren-dublado-todos-os-episodios", variants[1].URL,
+		"relative links are resolved against the mirror")
 }